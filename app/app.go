@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"io"
 	"strings"
 	"time"
 )
@@ -31,6 +32,11 @@ const (
 	ComponentService   = "SERVICE"
 	ComponentRest      = "REST"
 	ComponentAPP       = "APP"
+	ComponentAuth      = "AUTH"
+	ComponentUpload    = "UPLOAD"
+	ComponentRollout   = "ROLLOUT"
+	ComponentRetention = "RETENTION"
+	ComponentFsck      = "FSCK"
 )
 
 type ServableID struct {
@@ -165,14 +171,36 @@ type LabelChanged struct {
 	NewVersion      int64
 }
 
+// LabelHistoryEntry records single move of label, version 0 means that label
+// hasn't been assigned before move or has been removed by it
+type LabelHistoryEntry struct {
+	ServableID
+	ID          int64  `json:"id"`
+	Label       string `json:"label"`
+	FromVersion int64  `json:"from_version"`
+	ToVersion   int64  `json:"to_version"`
+	Actor       string `json:"actor,omitempty"`
+	RequestID   string `json:"request_id,omitempty"`
+	Created     string `json:"created"`
+}
+
+// ArchiveContent is a seekable stream of archive, it has to be closed after use
+type ArchiveContent interface {
+	io.ReadSeeker
+	io.Closer
+}
+
 type Archive struct {
-	Data []byte
-	Name string
+	Content ArchiveContent
+	Name    string
+	// ETag identifies content of archive, it changes only when servable is uploaded again
+	ETag string
 }
 
 type ErrorDetails struct {
-	ErrorCode    string `json:"error_code"`
-	ErrorMessage string `json:"error_message"`
+	ErrorCode    string      `json:"error_code"`
+	ErrorMessage string      `json:"error_message"`
+	Details      interface{} `json:"details,omitempty"`
 }
 
 type ErrorBody struct {
@@ -0,0 +1,49 @@
+package app
+
+// Outcomes of audited operations
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records single mutating call of REST API. ErrorCode holds component
+// and code of error returned by failed call, e.g. SERVICE-1008
+type AuditEntry struct {
+	ID        int64  `json:"id"`
+	Operation string `json:"operation"`
+	Actor     string `json:"actor,omitempty"`
+	Team      string `json:"team"`
+	Project   string `json:"project"`
+	Name      string `json:"name,omitempty"`
+	Version   int64  `json:"version,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Outcome   string `json:"outcome"`
+	Status    int    `json:"status"`
+	ErrorCode string `json:"error_code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Created   int64  `json:"created"`
+}
+
+// AuditQuery filters entries of audit log, empty fields match every entry.
+// Since and Until bound creation time of entries (Unix seconds, inclusive),
+// Before returns entries older than entry with given ID and is used to fetch next pages
+type AuditQuery struct {
+	Operation string
+	Actor     string
+	Team      string
+	Project   string
+	Name      string
+	Outcome   string
+	Since     int64
+	Until     int64
+	Before    int64
+	Limit     int
+}
+
+// AuditPage holds entries of audit log, the latest entry is the first one.
+// NextBefore is set when more entries may exist and has to be passed as
+// before parameter to fetch them
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextBefore int64        `json:"next_before,omitempty"`
+}
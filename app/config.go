@@ -23,16 +23,23 @@ const (
 	models   = "models"
 	modules  = "modules"
 	incoming = "incoming"
+	uploads  = "uploads"
 )
 
 var (
 	logUnsupportedDiscoverySourceErrorCode = 1001
 	logUnsupportedStorageBackendErrorCode  = 1002
 	logUnsupportedMetadataBackendErrorCode = 1003
+	logUnsupportedAuthTokenStoreErrorCode  = 1004
+	logMissingJWKSErrorCode                = 1005
+	logInvalidCompositeSourcesErrorCode    = 1006
 
 	errUnsupportedDiscoverySource = exterr.NewErrorWithMessage("unsupported discovery source").WithComponent(ComponentAPP).WithCode(logUnsupportedDiscoverySourceErrorCode)
 	errUnsupportedStorageBackend  = exterr.NewErrorWithMessage("unsupported storage backend").WithComponent(ComponentAPP).WithCode(logUnsupportedStorageBackendErrorCode)
 	errUnsupportedMetadataBackend = exterr.NewErrorWithMessage("unsupported metadata backend").WithComponent(ComponentAPP).WithCode(logUnsupportedMetadataBackendErrorCode)
+	errUnsupportedAuthTokenStore  = exterr.NewErrorWithMessage("unsupported auth token store").WithComponent(ComponentAPP).WithCode(logUnsupportedAuthTokenStoreErrorCode)
+	errMissingJWKS                = exterr.NewErrorWithMessage("either JWKS path or JWKS URL has to be set").WithComponent(ComponentAPP).WithCode(logMissingJWKSErrorCode)
+	errInvalidCompositeSources    = exterr.NewErrorWithMessage("sources of composite discovery have to be distinct and can't be composite").WithComponent(ComponentAPP).WithCode(logInvalidCompositeSourcesErrorCode)
 
 	ErrCLIUsage error = errors.New("cli usage")
 )
@@ -44,6 +51,8 @@ type (
 		Discovery ConfigDiscovery `yaml:"discovery" group:"Discovery Options"`
 		Storage   ConfigStorage   `yaml:"storage" group:"Storage Options"`
 		Metadata  ConfigMetadata  `yaml:"metadata" group:"Metadata Options"`
+		Auth      ConfigAuth      `yaml:"auth" group:"Auth Options"`
+		Retention ConfigRetention `yaml:"retention" group:"Retention Options"`
 	}
 
 	// ConfigApp holds configuration parameters common to the application
@@ -54,17 +63,32 @@ type (
 		ReloadIntervalInSec             *int    `validate:"min=1" defaults:"300" yaml:"reloadIntervalInSec" envconfig:"TFD_RELOAD_INTERVAL_IN_SEC" long:"reload_interval_in_sec" description:"The interval of time after which the model configurations will be reloaded on TFS instances" default-mask:"300"`
 		MaxAutoReloadDurationInSec      *int    `validate:"min=900" defaults:"900" yaml:"maxAutoReloadDurationInSec" envconfig:"TFD_MAX_AUTO_RELOAD_DURATION_IN_SEC" long:"max_auto_reload_duration_in_sec" description:"Max auto-reload duration" default-mask:"3600"`
 		UploadTimeoutInSec              *int    `validate:"min=1" defaults:"300" yaml:"uploadTimeoutInSec" envconfig:"TFD_UPLOAD_TIMEOUT_IN_SEC" long:"upload_timeout_in_sec" description:"Timeout after which upload will be interrupted" default-mask:"300"`
+		ShutdownTimeoutInSec            *int    `validate:"min=1" defaults:"30" yaml:"shutdownTimeoutInSec" envconfig:"TFD_SHUTDOWN_TIMEOUT_IN_SEC" long:"shutdown_timeout_in_sec" description:"Time given to in-flight requests, e.g. uploads, to finish after SIGTERM or SIGINT before they are interrupted" default-mask:"30"`
+		ResumableUploads                *bool   `defaults:"false" yaml:"resumableUploads" envconfig:"TFD_RESUMABLE_UPLOADS" long:"resumable_uploads" description:"If true, models may be uploaded in chunks with resumable uploads" default-mask:"false"`
+		UploadSessionsPath              *string `defaults:"/tfdeploy/incoming/uploads" yaml:"uploadSessionsPath" envconfig:"TFD_UPLOAD_SESSIONS_PATH" long:"upload_sessions_path" description:"Local path where content and state of resumable uploads are kept, derived from storage filesystem base path if not set" default-mask:"/tfdeploy/incoming/uploads"`
+		UploadSessionMaxLengthInMB      *int64  `validate:"min=1" defaults:"51200" yaml:"uploadSessionMaxLengthInMB" envconfig:"TFD_UPLOAD_SESSION_MAX_LENGTH_IN_MB" long:"upload_session_max_length_in_mb" description:"Max length of archive declared by resumable upload" default-mask:"51200"`
+		UploadSessionExpirationInSec    *int    `validate:"min=60" defaults:"86400" yaml:"uploadSessionExpirationInSec" envconfig:"TFD_UPLOAD_SESSION_EXPIRATION_IN_SEC" long:"upload_session_expiration_in_sec" description:"Time after which resumable upload which hasn't received any chunk is removed" default-mask:"86400"`
 		DefaultModelLabel               *string `defaults:"canary" yaml:"defaultModelLabel" envconfig:"TFD_DEFAULT_MODEL_LABEL" long:"default_model_label" description:"Default model label" default-mask:"canary"`
 		AllowLabelsForUnavailableModels *bool   `defaults:"false" yaml:"tfsAllowsLabelsForUnavailableModels" envconfig:"TFD_TFS_ALLOWS_LABELS_FOR_UNAVAILABLE_MODELS" long:"tfs_allows_labels_for_unavailable_models" description:"If true, assume TFS instances allow assigning labels to models that are not available yet" default-mask:"false"`
-		Discovery                       *string `validate:"oneof=plaintext dns" defaults:"dns" yaml:"discovery" envconfig:"TFD_DISCOVERY" long:"discovery" description:"Discovery source, see section of selected Discovery Options" choice:"plaintext" choice:"dns" default-mask:"dns"`
-		Storage                         *string `validate:"oneof=filesystem" defaults:"filesystem" yaml:"storage" envconfig:"TFD_STORAGE" long:"storage" description:"Storage backend, see section of selected Storage Options" choice:"filesystem" default-mask:"filesystem"`
+		SmokeTestTimeoutInSec           *int    `validate:"min=1" defaults:"10" yaml:"smokeTestTimeoutInSec" envconfig:"TFD_SMOKE_TEST_TIMEOUT_IN_SEC" long:"smoke_test_timeout_in_sec" description:"Timeout of smoke test request sent to single TFS instance" default-mask:"10"`
+		RequireSmokeTestForStable       *bool   `defaults:"false" yaml:"requireSmokeTestForStable" envconfig:"TFD_REQUIRE_SMOKE_TEST_FOR_STABLE" long:"require_smoke_test_for_stable" description:"If true, versions of models with attached smoke test have to pass it before they become stable" default-mask:"false"`
+		RolloutIntervalInSec            *int    `validate:"min=1" defaults:"10" yaml:"rolloutIntervalInSec" envconfig:"TFD_ROLLOUT_INTERVAL_IN_SEC" long:"rollout_interval_in_sec" description:"The interval of time after which running rollouts are advanced" default-mask:"10"`
+		FsckGracePeriodInSec            *int    `validate:"min=0" defaults:"3600" yaml:"fsckGracePeriodInSec" envconfig:"TFD_FSCK_GRACE_PERIOD_IN_SEC" long:"fsck_grace_period_in_sec" description:"Versions uploaded or modified more recently are skipped by consistency check, so uploads in progress aren't reported as inconsistent" default-mask:"3600"`
+		AuditFilePath                   *string `defaults:"" yaml:"auditFilePath" envconfig:"TFD_AUDIT_FILE_PATH" long:"audit_file_path" description:"Path to the file which entries of audit log are appended to as JSON lines; optional" default-mask:"not set"` // allowed empty string
+		Discovery                       *string `validate:"oneof=plaintext dns kubernetes consul composite" defaults:"dns" yaml:"discovery" envconfig:"TFD_DISCOVERY" long:"discovery" description:"Discovery source, see section of selected Discovery Options" choice:"plaintext" choice:"dns" choice:"kubernetes" choice:"consul" choice:"composite" default-mask:"dns"`
+		Storage                         *string `validate:"oneof=filesystem s3" defaults:"filesystem" yaml:"storage" envconfig:"TFD_STORAGE" long:"storage" description:"Storage backend, see section of selected Storage Options" choice:"filesystem" choice:"s3" default-mask:"filesystem"`
 		Metadata                        *string `validate:"oneof=sqldb" defaults:"sqldb" yaml:"metadata" envconfig:"TFD_METADATA" long:"metadata" description:"Metadata backend, see section of selected Metadata Options" choice:"sqldb" default-mask:"sqldb"`
+		Auth                            *string `validate:"oneof=none static sqldb jwt" defaults:"none" yaml:"auth" envconfig:"TFD_AUTH" long:"auth" description:"Bearer token store used to authenticate REST API requests, see section of selected Auth Options; none disables authentication" choice:"none" choice:"static" choice:"sqldb" choice:"jwt" default-mask:"none"`
 	}
 
 	// ConfigDiscovery holds discovery package configuration parameters
 	ConfigDiscovery struct {
-		Plaintext ConfigDiscoveryPlaintext `yaml:"plaintext" group:"Plaintext Discovery Options"`
-		DNS       ConfigDiscoveryDNS       `yaml:"dns" group:"DNS Discovery Options"`
+		Plaintext  ConfigDiscoveryPlaintext  `yaml:"plaintext" group:"Plaintext Discovery Options"`
+		DNS        ConfigDiscoveryDNS        `yaml:"dns" group:"DNS Discovery Options"`
+		Kubernetes ConfigDiscoveryKubernetes `yaml:"kubernetes" group:"Kubernetes Discovery Options"`
+		Consul     ConfigDiscoveryConsul     `yaml:"consul" group:"Consul Discovery Options"`
+		Composite  ConfigDiscoveryComposite  `yaml:"composite" group:"Composite Discovery Options"`
+		Cache      ConfigDiscoveryCache      `yaml:"cache" group:"Discovery Cache Options"`
 	}
 	// ConfigDiscoveryPlaintext holds Plaintext package configuration parameters
 	ConfigDiscoveryPlaintext struct {
@@ -75,10 +99,41 @@ type (
 		ServiceSuffix       *string `defaults:"" yaml:"serviceSuffix" envconfig:"TFD_DISCOVERY_DNS_SERVICE_SUFFIX" long:"discovery_dns_service_suffix" description:"Service suffix with or without dot prefix; optional" default-mask:"not set"` // allowed empty string
 		DefaultInstancePort *uint16 `validate:"min=1000,max=65535" defaults:"8500" yaml:"defaultInstancePort" envconfig:"TFD_DISCOVERY_DNS_DEFAULT_INSTANCE_PORT" long:"discovery_dns_default_instance_port" description:"Default TFS instance port in case SRV records are unavailable" default-mask:"8500"`
 	}
+	// ConfigDiscoveryKubernetes holds Kubernetes package configuration parameters
+	ConfigDiscoveryKubernetes struct {
+		KubeconfigPath    *string `defaults:"" yaml:"kubeconfigPath" envconfig:"TFD_DISCOVERY_KUBERNETES_KUBECONFIG_PATH" long:"discovery_kubernetes_kubeconfig_path" description:"Path to the kubeconfig file used to access Kubernetes API; in-cluster credentials of service account are used when not set" default-mask:"not set"` // allowed empty string
+		NamespaceTemplate *string `validate:"required" defaults:"default" yaml:"namespaceTemplate" envconfig:"TFD_DISCOVERY_KUBERNETES_NAMESPACE_TEMPLATE" long:"discovery_kubernetes_namespace_template" description:"Template of namespace of TFS services, {{.Team}} and {{.Project}} are replaced with team and project of servable" default-mask:"default"`
+		PortName          *string `validate:"required" defaults:"grpc" yaml:"portName" envconfig:"TFD_DISCOVERY_KUBERNETES_PORT_NAME" long:"discovery_kubernetes_port_name" description:"Name of gRPC port of TFS services" default-mask:"grpc"`
+		Watch             *bool   `defaults:"false" yaml:"watch" envconfig:"TFD_DISCOVERY_KUBERNETES_WATCH" long:"discovery_kubernetes_watch" description:"If true, endpoints of TFS services are watched and instances are reloaded as soon as they change instead of waiting for reload interval" default-mask:"false"`
+	}
+	// ConfigDiscoveryConsul holds Consul package configuration parameters
+	ConfigDiscoveryConsul struct {
+		Address       *string `validate:"url" defaults:"http://127.0.0.1:8500" yaml:"address" envconfig:"TFD_DISCOVERY_CONSUL_ADDRESS" long:"discovery_consul_address" description:"Address of Consul HTTP API" default-mask:"http://127.0.0.1:8500"`
+		Datacenter    *string `defaults:"" yaml:"datacenter" envconfig:"TFD_DISCOVERY_CONSUL_DATACENTER" long:"discovery_consul_datacenter" description:"Datacenter of TFS services; datacenter of queried Consul agent is used when not set" default-mask:"not set"`
+		Token         *string `defaults:"" yaml:"token" envconfig:"TFD_DISCOVERY_CONSUL_TOKEN" long:"discovery_consul_token" description:"ACL token sent with requests to Consul; optional" default-mask:"not set"`
+		Tags          *string `defaults:"" yaml:"tags" envconfig:"TFD_DISCOVERY_CONSUL_TAGS" long:"discovery_consul_tags" description:"Comma separated tags which TFS services have to be registered with; optional" default-mask:"not set"`
+		Watch         *bool   `defaults:"false" yaml:"watch" envconfig:"TFD_DISCOVERY_CONSUL_WATCH" long:"discovery_consul_watch" description:"If true, blocking queries are used to notice changes of TFS services and instances are reloaded as soon as they change instead of waiting for reload interval" default-mask:"false"`
+		WaitTimeInSec *int    `validate:"min=1,max=600" defaults:"300" yaml:"waitTimeInSec" envconfig:"TFD_DISCOVERY_CONSUL_WAIT_TIME_IN_SEC" long:"discovery_consul_wait_time_in_sec" description:"Maximum time which blocking query waits for changes of TFS service" default-mask:"300"`
+	}
+	// ConfigDiscoveryComposite holds Composite package configuration parameters, every source is configured in its own section
+	ConfigDiscoveryComposite struct {
+		Sources *string `validate:"required" defaults:"plaintext,dns" yaml:"sources" envconfig:"TFD_DISCOVERY_COMPOSITE_SOURCES" long:"discovery_composite_sources" description:"Comma separated discovery sources in order of their priority" default-mask:"plaintext,dns"`
+		Mode    *string `validate:"oneof=union first_non_empty priority" defaults:"union" yaml:"mode" envconfig:"TFD_DISCOVERY_COMPOSITE_MODE" long:"discovery_composite_mode" description:"Way instances of sources are combined; union merges instances of all sources, first_non_empty uses the first source which discovers any instance, priority uses the first source which doesn't fail" choice:"union" choice:"first_non_empty" choice:"priority" default-mask:"union"`
+	}
+	// ConfigDiscoveryCache holds configuration parameters of cache of discovered instances, it is used with every discovery source
+	ConfigDiscoveryCache struct {
+		TTLInSec                *int    `validate:"min=0" defaults:"0" yaml:"ttlInSec" envconfig:"TFD_DISCOVERY_CACHE_TTL_IN_SEC" long:"discovery_cache_ttl_in_sec" description:"Time for which discovered instances are cached; 0 disables caching" default-mask:"0"`
+		NegativeTTLInSec        *int    `validate:"min=0" defaults:"0" yaml:"negativeTTLInSec" envconfig:"TFD_DISCOVERY_CACHE_NEGATIVE_TTL_IN_SEC" long:"discovery_cache_negative_ttl_in_sec" description:"Time for which failed discovery is cached; 0 disables caching of failures" default-mask:"0"`
+		HealthCheck             *string `validate:"oneof=none grpc model_status" defaults:"none" yaml:"healthCheck" envconfig:"TFD_DISCOVERY_CACHE_HEALTH_CHECK" long:"discovery_cache_health_check" description:"Health check of discovered instances, unhealthy instances are excluded from reloads until they pass it again; grpc uses grpc.health.v1 protocol, model_status sends GetModelStatus request" choice:"none" choice:"grpc" choice:"model_status" default-mask:"none"`
+		HealthCheckTimeoutInSec *int    `validate:"min=1" defaults:"2" yaml:"healthCheckTimeoutInSec" envconfig:"TFD_DISCOVERY_CACHE_HEALTH_CHECK_TIMEOUT_IN_SEC" long:"discovery_cache_health_check_timeout_in_sec" description:"Timeout of health check of single instance" default-mask:"2"`
+	}
 
 	// ConfigStorage holds storage package configuration parameters
 	ConfigStorage struct {
 		Filesystem ConfigStorageFilesystem `yaml:"filesystem" group:"Filesystem Storage Options"`
+		S3         ConfigStorageS3         `yaml:"s3" group:"S3 Storage Options"`
+		Limits     ConfigStorageLimits     `yaml:"limits" group:"Storage Limits Options"`
+		Validation ConfigStorageValidation `yaml:"validation" group:"Storage Validation Options"`
 	}
 	// ConfigStorageFilesystem holds filesystem configuration parameters
 	ConfigStorageFilesystem struct {
@@ -106,16 +161,73 @@ type (
 	}
 
 	ConfigStorageFilesystemBase struct {
-		BasePath *string `defaults:"/tfdeploy" yaml:"basePath" envconfig:"TFD_STORAGE_FILESYSTEM_BASE_PATH" long:"storage_filesystem_base_path" description:"Base path sets: incoming model/module archive path, model/module base path if these paths aren't set" default-mask:"/tfdeploy"`
+		BasePath *string `defaults:"/tfdeploy" yaml:"basePath" envconfig:"TFD_STORAGE_FILESYSTEM_BASE_PATH" long:"storage_filesystem_base_path" description:"Base path sets: incoming model/module archive path, model/module base path, upload sessions path if these paths aren't set" default-mask:"/tfdeploy"`
+	}
+
+	// ConfigStorageS3 holds S3-compatible object storage configuration parameters
+	ConfigStorageS3 struct {
+		Endpoint            *string `validate:"url" defaults:"https://s3.amazonaws.com" yaml:"endpoint" envconfig:"TFD_STORAGE_S3_ENDPOINT" long:"storage_s3_endpoint" description:"S3 endpoint URL" default-mask:"https://s3.amazonaws.com"`
+		Region              *string `validate:"min=1" defaults:"us-east-1" yaml:"region" envconfig:"TFD_STORAGE_S3_REGION" long:"storage_s3_region" description:"S3 region used to sign requests" default-mask:"us-east-1"`
+		Bucket              *string `validate:"required,min=3,max=63" yaml:"bucket" envconfig:"TFD_STORAGE_S3_BUCKET" long:"storage_s3_bucket" description:"S3 bucket name" default-mask:"not set"`
+		Prefix              *string `defaults:"" yaml:"prefix" envconfig:"TFD_STORAGE_S3_PREFIX" long:"storage_s3_prefix" description:"Key prefix prepended to models and modules keys; optional" default-mask:"not set"`                   // allowed empty string
+		AccessKeyID         *string `defaults:"" yaml:"accessKeyID" envconfig:"TFD_STORAGE_S3_ACCESS_KEY_ID" long:"storage_s3_access_key_id" description:"S3 access key ID; anonymous requests are sent when empty" default-mask:"not set"` // allowed empty string
+		SecretAccessKey     *string `defaults:"" yaml:"secretAccessKey" envconfig:"TFD_STORAGE_S3_SECRET_ACCESS_KEY" long:"storage_s3_secret_access_key" description:"S3 secret access key" default-mask:"not set"`                         // allowed empty string
+		PathStyle           *bool   `defaults:"false" yaml:"pathStyle" envconfig:"TFD_STORAGE_S3_PATH_STYLE" long:"storage_s3_path_style" description:"If true, use path-style requests (endpoint/bucket/key) instead of virtual-hosted style" default-mask:"false"`
+		IncomingArchivePath *string `defaults:"/tfdeploy/incoming" yaml:"incomingArchivePath" envconfig:"TFD_STORAGE_S3_INCOMING_ARCHIVE_PATH" long:"storage_s3_incoming_archive_path" description:"Local path where incoming archives are extracted before upload" default-mask:"/tfdeploy/incoming"`
+		ModelArchiveName    *string `defaults:"model_archive.tar" yaml:"modelArchiveName" envconfig:"TFD_STORAGE_S3_MODEL_ARCHIVE_NAME" long:"storage_s3_model_archive_name" description:"Model archive name" default-mask:"model_archive.tar"`
+		ModuleArchiveName   *string `defaults:"module_archive.tar" yaml:"moduleArchiveName" envconfig:"TFD_STORAGE_S3_MODULE_ARCHIVE_NAME" long:"storage_s3_module_archive_name" description:"Module archive name" default-mask:"module_archive.tar"`
+		ConfigName          *string `defaults:"models.config" yaml:"configName" envconfig:"TFD_STORAGE_S3_CONFIG_NAME" long:"storage_s3_config_name" description:"Models config filename" default-mask:"models.config"`
+	}
+
+	// ConfigStorageLimits holds limits of archives extracted while saving models and modules
+	ConfigStorageLimits struct {
+		MaxArchiveSizeInMB *int64  `validate:"min=0" defaults:"51200" yaml:"maxArchiveSizeInMB" envconfig:"TFD_STORAGE_LIMITS_MAX_ARCHIVE_SIZE_IN_MB" long:"storage_limits_max_archive_size_in_mb" description:"Max total size of files extracted from uploaded archive; 0 disables the limit" default-mask:"51200"`
+		MaxFileCount       *int    `validate:"min=0" defaults:"10000" yaml:"maxFileCount" envconfig:"TFD_STORAGE_LIMITS_MAX_FILE_COUNT" long:"storage_limits_max_file_count" description:"Max number of files extracted from uploaded archive; 0 disables the limit" default-mask:"10000"`
+		MaxFileSizeInMB    *int64  `validate:"min=0" defaults:"0" yaml:"maxFileSizeInMB" envconfig:"TFD_STORAGE_LIMITS_MAX_FILE_SIZE_IN_MB" long:"storage_limits_max_file_size_in_mb" description:"Max size of single file extracted from uploaded archive; 0 disables the limit" default-mask:"0"`
+		TeamLimitsPath     *string `validate:"len=0|file" defaults:"" yaml:"teamLimitsPath" envconfig:"TFD_STORAGE_LIMITS_TEAM_LIMITS_PATH" long:"storage_limits_team_limits_path" description:"Path to the YAML file containing limits of teams which override limits above; optional" default-mask:"not set"` // allowed empty string
+	}
+
+	// ConfigStorageValidation holds validation parameters of models saved by storage
+	ConfigStorageValidation struct {
+		SkipSavedModel *bool `defaults:"false" yaml:"skipSavedModel" envconfig:"TFD_STORAGE_VALIDATION_SKIP_SAVED_MODEL" long:"storage_validation_skip_saved_model" description:"If true, skip validation of saved_model.pb and variables of uploaded models" default-mask:"false"`
 	}
 
 	// ConfigMetadata holds metadata package configuration parameters
 	ConfigMetadata struct {
 		SQLDB ConfigMetadataSQLDB `yaml:"sqldb" group:"SQLDB Metadata Options"`
 	}
+	// ConfigAuth holds auth package configuration parameters
+	ConfigAuth struct {
+		Static ConfigAuthStatic `yaml:"static" group:"Static Auth Options"`
+		JWT    ConfigAuthJWT    `yaml:"jwt" group:"JWT Auth Options"`
+	}
+	// ConfigAuthStatic holds static token store configuration parameters
+	ConfigAuthStatic struct {
+		TokensPath *string `validate:"file" defaults:"tokens.yaml" yaml:"tokensPath" envconfig:"TFD_AUTH_STATIC_TOKENS_PATH" long:"auth_static_tokens_path" description:"Path to the YAML file containing tokens and their grants" default-mask:"tokens.yaml"`
+	}
+
+	// ConfigAuthJWT holds JWT validation configuration parameters
+	ConfigAuthJWT struct {
+		JWKSPath         *string `validate:"len=0|file" defaults:"" yaml:"jwksPath" envconfig:"TFD_AUTH_JWT_JWKS_PATH" long:"auth_jwt_jwks_path" description:"Path to the JWKS file with keys used to verify tokens; required when JWKS URL is not set" default-mask:"not set"`                                  // allowed empty string
+		JWKSURL          *string `validate:"len=0|url" defaults:"" yaml:"jwksURL" envconfig:"TFD_AUTH_JWT_JWKS_URL" long:"auth_jwt_jwks_url" description:"URL of JWKS with keys used to verify tokens, refreshed when token is signed with unknown key; takes precedence over JWKS path" default-mask:"not set"` // allowed empty string
+		Issuer           *string `validate:"min=1" yaml:"issuer" envconfig:"TFD_AUTH_JWT_ISSUER" long:"auth_jwt_issuer" description:"Expected value of iss claim" default-mask:"not set"`
+		Audience         *string `validate:"min=1" yaml:"audience" envconfig:"TFD_AUTH_JWT_AUDIENCE" long:"auth_jwt_audience" description:"Expected value of aud claim" default-mask:"not set"`
+		TeamsClaim       *string `validate:"min=1" defaults:"groups" yaml:"teamsClaim" envconfig:"TFD_AUTH_JWT_TEAMS_CLAIM" long:"auth_jwt_teams_claim" description:"Claim holding teams which caller may act on, values have format <prefix><team>[/<project>][:<role>]" default-mask:"groups"`
+		TeamsClaimPrefix *string `defaults:"" yaml:"teamsClaimPrefix" envconfig:"TFD_AUTH_JWT_TEAMS_CLAIM_PREFIX" long:"auth_jwt_teams_claim_prefix" description:"Prefix of teams claim values, values without prefix are ignored; optional" default-mask:"not set"` // allowed empty string
+		DefaultRole      *string `validate:"oneof=reader deployer admin" defaults:"deployer" yaml:"defaultRole" envconfig:"TFD_AUTH_JWT_DEFAULT_ROLE" long:"auth_jwt_default_role" description:"Role granted by teams claim values without role" choice:"reader" choice:"deployer" choice:"admin" default-mask:"deployer"`
+	}
+
+	// ConfigRetention holds retention policies applied to unlabeled versions of models by garbage collector
+	ConfigRetention struct {
+		KeepLastVersions *int    `validate:"min=0" defaults:"0" yaml:"keepLastVersions" envconfig:"TFD_RETENTION_KEEP_LAST_VERSIONS" long:"retention_keep_last_versions" description:"Number of the latest unlabeled versions of each model which are never removed; 0 disables the rule" default-mask:"0"`
+		MaxAgeInDays     *int    `validate:"min=0" defaults:"0" yaml:"maxAgeInDays" envconfig:"TFD_RETENTION_MAX_AGE_IN_DAYS" long:"retention_max_age_in_days" description:"Unlabeled versions older than given number of days are removed unless they are kept by the rule above; 0 disables the rule" default-mask:"0"`
+		RulesPath        *string `validate:"len=0|file" defaults:"" yaml:"rulesPath" envconfig:"TFD_RETENTION_RULES_PATH" long:"retention_rules_path" description:"Path to the YAML file containing retention rules of teams, projects and models which override rules above; optional" default-mask:"not set"` // allowed empty string
+		IntervalInSec    *int    `validate:"min=60" defaults:"3600" yaml:"intervalInSec" envconfig:"TFD_RETENTION_INTERVAL_IN_SEC" long:"retention_interval_in_sec" description:"The interval of time after which versions selected by retention rules are removed" default-mask:"3600"`
+	}
+
 	// ConfigMetadataSQLDB holds sqldb package configuration parameters
 	ConfigMetadataSQLDB struct {
-		Driver *string `validate:"oneof=sqlite3" defaults:"sqlite3" yaml:"driver" envconfig:"TFD_METADATA_SQLDB_DRIVER" long:"metadata_sqldb_driver" description:"SQL driver" choice:"sqlite3" default-mask:"sqlite3"`
+		Driver *string `validate:"oneof=sqlite3 postgres mysql" defaults:"sqlite3" yaml:"driver" envconfig:"TFD_METADATA_SQLDB_DRIVER" long:"metadata_sqldb_driver" description:"SQL driver" choice:"sqlite3" choice:"postgres" choice:"mysql" default-mask:"sqlite3"`
 		DSN    *string `validate:"min=1" defaults:"metadata.db" yaml:"dsn" envconfig:"TFD_METADATA_SQLDB_DSN" long:"metadata_sqldb_dsn" description:"The Data Source Name in common format like e.g. PEAR DB, but without type-prefix (optional parts marked by squared brackets)" default-mask:"metadata.db"`
 	}
 )
@@ -135,15 +247,22 @@ func (ConfigStorageFilesystem) ConvertPerms(perms string) (os.FileMode, error) {
 	return os.FileMode(p), nil
 }
 
-// Validate validates configuration parameters
-func (c *Config) Validate(ctx context.Context) error {
-	validate := validator.New()
-
-	if err := validate.StructCtx(ctx, c.App); err != nil {
-		return exterr.WrapWithFrame(err)
+// SourceList returns non-empty sources of comma separated list
+func (c ConfigDiscoveryComposite) SourceList() []string {
+	var sources []string
+	for _, source := range strings.Split(*c.Sources, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
 	}
 
-	switch *c.App.Discovery {
+	return sources
+}
+
+// validateDiscovery validates configuration of discovery source, sources of
+// composite discovery are validated as they were chosen alone
+func (c *Config) validateDiscovery(ctx context.Context, validate *validator.Validate, discovery string) error {
+	switch discovery {
 	case "plaintext":
 		if err := validate.StructCtx(ctx, c.Discovery.Plaintext); err != nil {
 			return exterr.WrapWithFrame(err)
@@ -152,19 +271,77 @@ func (c *Config) Validate(ctx context.Context) error {
 		if err := validate.StructCtx(ctx, c.Discovery.DNS); err != nil {
 			return exterr.WrapWithFrame(err)
 		}
+	case "kubernetes":
+		if err := validate.StructCtx(ctx, c.Discovery.Kubernetes); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+	case "consul":
+		if err := validate.StructCtx(ctx, c.Discovery.Consul); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+	case "composite":
+		if err := validate.StructCtx(ctx, c.Discovery.Composite); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+		sources := c.Discovery.Composite.SourceList()
+		if len(sources) == 0 {
+			return errInvalidCompositeSources
+		}
+		seen := make(map[string]bool)
+		for _, source := range sources {
+			if source == "composite" || seen[source] {
+				return errInvalidCompositeSources
+			}
+			seen[source] = true
+			if err := c.validateDiscovery(ctx, validate, source); err != nil {
+				return err
+			}
+		}
 	default:
 		return errUnsupportedDiscoverySource
 	}
 
+	return nil
+}
+
+// Validate validates configuration parameters
+func (c *Config) Validate(ctx context.Context) error {
+	validate := validator.New()
+
+	if err := validate.StructCtx(ctx, c.App); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	if err := c.validateDiscovery(ctx, validate, *c.App.Discovery); err != nil {
+		return err
+	}
+
+	// cache may decorate every discovery source
+	if err := validate.StructCtx(ctx, c.Discovery.Cache); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
 	switch *c.App.Storage {
 	case "filesystem":
 		if err := validate.StructCtx(ctx, c.Storage.Filesystem); err != nil {
 			return exterr.WrapWithFrame(err)
 		}
+	case "s3":
+		if err := validate.StructCtx(ctx, c.Storage.S3); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
 	default:
 		return errUnsupportedStorageBackend
 	}
 
+	if err := validate.StructCtx(ctx, c.Storage.Limits); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	if err := validate.StructCtx(ctx, c.Retention); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
 	switch *c.App.Metadata {
 	case "sqldb":
 		if err := validate.StructCtx(ctx, c.Metadata.SQLDB); err != nil {
@@ -174,6 +351,23 @@ func (c *Config) Validate(ctx context.Context) error {
 		return errUnsupportedMetadataBackend
 	}
 
+	switch *c.App.Auth {
+	case "none", "sqldb":
+	case "static":
+		if err := validate.StructCtx(ctx, c.Auth.Static); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+	case "jwt":
+		if err := validate.StructCtx(ctx, c.Auth.JWT); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+		if *c.Auth.JWT.JWKSPath == "" && *c.Auth.JWT.JWKSURL == "" {
+			return errMissingJWKS
+		}
+	default:
+		return errUnsupportedAuthTokenStore
+	}
+
 	return nil
 }
 
@@ -196,10 +390,75 @@ func NewConfigDefaults(ctx context.Context) (*Config, error) {
 		params.App.ConfigFile = &empty
 	}
 
+	// allowed empty value for audit file path, it disables file sink of audit log
+	if params.App.AuditFilePath == nil {
+		params.App.AuditFilePath = &empty
+	}
+
 	// allowed empty value for dns discovery service suffix
 	if params.Discovery.DNS.ServiceSuffix == nil {
 		params.Discovery.DNS.ServiceSuffix = &empty
 	}
+
+	// allowed empty value for kubernetes kubeconfig path, in-cluster credentials are used then
+	if params.Discovery.Kubernetes.KubeconfigPath == nil {
+		params.Discovery.Kubernetes.KubeconfigPath = &empty
+	}
+
+	// allowed empty values for consul datacenter, token and tags
+	if params.Discovery.Consul.Datacenter == nil {
+		params.Discovery.Consul.Datacenter = &empty
+	}
+	if params.Discovery.Consul.Token == nil {
+		params.Discovery.Consul.Token = &empty
+	}
+	if params.Discovery.Consul.Tags == nil {
+		params.Discovery.Consul.Tags = &empty
+	}
+
+	// s3 bucket has no default, it is validated only when s3 storage is chosen
+	if params.Storage.S3.Bucket == nil {
+		params.Storage.S3.Bucket = &empty
+	}
+
+	// jwt issuer and audience have no defaults, they are validated only when jwt auth is chosen
+	if params.Auth.JWT.Issuer == nil {
+		params.Auth.JWT.Issuer = &empty
+	}
+	if params.Auth.JWT.Audience == nil {
+		params.Auth.JWT.Audience = &empty
+	}
+
+	// allowed empty values for jwt keys source and teams claim prefix
+	if params.Auth.JWT.JWKSPath == nil {
+		params.Auth.JWT.JWKSPath = &empty
+	}
+	if params.Auth.JWT.JWKSURL == nil {
+		params.Auth.JWT.JWKSURL = &empty
+	}
+	if params.Auth.JWT.TeamsClaimPrefix == nil {
+		params.Auth.JWT.TeamsClaimPrefix = &empty
+	}
+
+	// allowed empty values for s3 storage prefix and credentials
+	if params.Storage.S3.Prefix == nil {
+		params.Storage.S3.Prefix = &empty
+	}
+	if params.Storage.S3.AccessKeyID == nil {
+		params.Storage.S3.AccessKeyID = &empty
+	}
+	if params.Storage.S3.SecretAccessKey == nil {
+		params.Storage.S3.SecretAccessKey = &empty
+	}
+
+	// allowed empty value for team limits path
+	if params.Storage.Limits.TeamLimitsPath == nil {
+		params.Storage.Limits.TeamLimitsPath = &empty
+	}
+	// allowed empty value for retention rules path
+	if params.Retention.RulesPath == nil {
+		params.Retention.RulesPath = &empty
+	}
 	setNeededPaths(params)
 
 	return params, nil
@@ -217,18 +476,19 @@ func NewConfigENV(ctx context.Context, prefix string) (*Config, error) {
 }
 
 // NewConfigCLI loads configuration parameters from command-line
-func NewConfigCLI(ctx context.Context) (*Config, error) {
+func NewConfigCLI(ctx context.Context) (*Config, []string, error) {
 	params := &Config{}
 	parser := flags.NewParser(params, flags.Default)
-	if _, err := parser.Parse(); err != nil {
+	args, err := parser.Parse()
+	if err != nil {
 		if strings.HasPrefix(err.Error(), "Usage") {
-			return nil, ErrCLIUsage
+			return nil, nil, ErrCLIUsage
 		}
-		return nil, exterr.WrapWithFrame(err)
+		return nil, nil, exterr.WrapWithFrame(err)
 	}
 	setNeededPaths(params)
 
-	return params, nil
+	return params, args, nil
 }
 
 // NewConfigYAML loads configuration parameters from YAML file
@@ -255,7 +515,8 @@ func NewConfigYAML(ctx context.Context, file string) (*Config, error) {
 	return params, nil
 }
 
-// setNeededPaths sets incoming model/module archive path, model/module base path if these paths aren't set
+// setNeededPaths sets incoming model/module archive path, model/module base path
+// and path of resumable uploads if these paths aren't set
 func setNeededPaths(config *Config) {
 	if config.Storage.Filesystem.Base.BasePath != nil {
 		// models
@@ -277,5 +538,11 @@ func setNeededPaths(config *Config) {
 			path := path.Join(*config.Storage.Filesystem.Base.BasePath, modules)
 			config.Storage.Filesystem.Module.BasePath = &path
 		}
+
+		// resumable uploads
+		if config.App.UploadSessionsPath == nil {
+			path := path.Join(*config.Storage.Filesystem.Base.BasePath, incoming, uploads)
+			config.App.UploadSessionsPath = &path
+		}
 	}
 }
@@ -0,0 +1,36 @@
+package app
+
+// Types of inconsistencies between files of models kept in storage,
+// metadata of models and configs of TFS instances
+const (
+	// InconsistencyOrphanFiles means that files of version are kept in storage without metadata
+	InconsistencyOrphanFiles = "orphan_files"
+	// InconsistencyStalePending means that upload of version was interrupted and it has been left pending
+	InconsistencyStalePending = "stale_pending"
+	// InconsistencyMissingFiles means that files of ready version aren't kept in storage
+	InconsistencyMissingFiles = "missing_files"
+	// InconsistencyMissingConfigVersion means that ready version isn't served by TFS instances
+	InconsistencyMissingConfigVersion = "missing_config_version"
+	// InconsistencyOrphanConfigVersion means that config of TFS instances contains version without metadata
+	InconsistencyOrphanConfigVersion = "orphan_config_version"
+	// InconsistencyLabelMismatch means that label points to different versions in metadata and config
+	InconsistencyLabelMismatch = "label_mismatch"
+)
+
+// Inconsistency describes single inconsistency of version or label of model.
+// RepairError explains why inconsistency hasn't been repaired when repair was requested
+type Inconsistency struct {
+	ServableID
+	Type        string `json:"type"`
+	Version     int64  `json:"version"`
+	Label       string `json:"label,omitempty"`
+	Details     string `json:"details"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repair_error,omitempty"`
+}
+
+// ConsistencyReport lists inconsistencies found by consistency check
+type ConsistencyReport struct {
+	Inconsistencies []Inconsistency `json:"inconsistencies"`
+	Repaired        int             `json:"repaired"`
+}
@@ -0,0 +1,23 @@
+package app
+
+// RetentionPolicy selects unlabeled versions of model removed by garbage collector.
+// KeepLastVersions latest unlabeled versions are always kept, the older ones are
+// removed once they are older than MaxAgeInDays. Zero disables a rule, policy with
+// both rules disabled keeps every version
+type RetentionPolicy struct {
+	KeepLastVersions int `json:"keep_last_versions"`
+	MaxAgeInDays     int `json:"max_age_in_days"`
+}
+
+// Enabled checks if policy removes any version
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLastVersions > 0 || p.MaxAgeInDays > 0
+}
+
+// RetentionCandidate is unlabeled version of model selected for removal by retention policy
+type RetentionCandidate struct {
+	ServableID
+	Version int64           `json:"version"`
+	Created int64           `json:"created"`
+	Policy  RetentionPolicy `json:"policy"`
+}
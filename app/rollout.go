@@ -0,0 +1,58 @@
+package app
+
+// States of rollout
+const (
+	RolloutRunning   = "running"
+	RolloutPaused    = "paused"
+	RolloutSucceeded = "succeeded"
+	RolloutFailed    = "failed"
+	RolloutAborted   = "aborted"
+)
+
+// Health gates which can be checked by step of rollout
+const (
+	// GateSmokeTest passes when smoke test attached to model has passed on every tested instance
+	GateSmokeTest = "smoke_test"
+	// GateAvailable passes when model version is AVAILABLE on every discovered instance of TFS
+	GateAvailable = "available"
+)
+
+// RolloutStep is single step of rollout plan, exactly one of its fields has to be set.
+// Label moves label to rolled out version, Wait holds duration of pause (e.g. 30m)
+// and Gates are health gates which have to pass before next step is started
+type RolloutStep struct {
+	Label string   `json:"label,omitempty"`
+	Wait  string   `json:"wait,omitempty"`
+	Gates []string `json:"gates,omitempty"`
+}
+
+// RolloutPlan holds ordered steps of rollout
+type RolloutPlan struct {
+	Steps []RolloutStep `json:"steps"`
+}
+
+// RolloutLabelMove records label moved by rollout, so it may be restored when rollout fails
+type RolloutLabelMove struct {
+	Label           string `json:"label"`
+	PreviousVersion int64  `json:"previous_version"`
+}
+
+// Rollout is staged promotion of model version. Step is index of current step of plan,
+// StepStarted is unix time when it has been started
+type Rollout struct {
+	ModelID
+	RolloutPlan
+	ID          int64              `json:"id"`
+	State       string             `json:"state"`
+	Step        int                `json:"step"`
+	StepStarted int64              `json:"step_started"`
+	Moves       []RolloutLabelMove `json:"moves,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	Created     string             `json:"created,omitempty"`
+	Updated     string             `json:"updated,omitempty"`
+}
+
+// Active checks if rollout hasn't finished yet
+func (r *Rollout) Active() bool {
+	return r.State == RolloutRunning || r.State == RolloutPaused
+}
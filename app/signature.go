@@ -0,0 +1,54 @@
+package app
+
+// Signatures maps keys of SignatureDefs to signatures of model
+type Signatures map[string]Signature
+
+// Signature describes inputs and outputs of single SignatureDef
+type Signature struct {
+	MethodName string                `json:"method_name"`
+	Inputs     map[string]TensorInfo `json:"inputs"`
+	Outputs    map[string]TensorInfo `json:"outputs"`
+}
+
+// TensorInfo describes tensor of signature. Unknown dimensions of shape
+// are set to -1, shape is nil when rank of tensor is unknown
+type TensorInfo struct {
+	Name  string  `json:"name,omitempty"`
+	DType string  `json:"dtype"`
+	Shape []int64 `json:"shape"`
+}
+
+// ModelSignatures holds signatures of model version
+type ModelSignatures struct {
+	ModelID
+	Signatures Signatures `json:"signatures"`
+}
+
+// Kinds of incompatible changes of signatures
+const (
+	SignatureRemoved   = "signature_removed"
+	MethodNameChanged  = "method_name_changed"
+	InputRemoved       = "input_removed"
+	InputAdded         = "input_added"
+	OutputRemoved      = "output_removed"
+	TensorDTypeChanged = "dtype_changed"
+	TensorShapeChanged = "shape_changed"
+)
+
+// SignatureChange describes single incompatible change of signature. Tensor is
+// prefixed with inputs/ or outputs/, Current and New hold changed values
+type SignatureChange struct {
+	Kind      string      `json:"kind"`
+	Signature string      `json:"signature"`
+	Tensor    string      `json:"tensor,omitempty"`
+	Current   interface{} `json:"current,omitempty"`
+	New       interface{} `json:"new,omitempty"`
+}
+
+// SignaturesDiff lists changes which make signatures of new version
+// incompatible with signatures of current version
+type SignaturesDiff struct {
+	CurrentVersion int64             `json:"current_version"`
+	NewVersion     int64             `json:"new_version"`
+	Changes        []SignatureChange `json:"changes"`
+}
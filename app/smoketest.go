@@ -0,0 +1,54 @@
+package app
+
+import "encoding/json"
+
+// Methods of PredictionService which can be called by smoke test
+const (
+	SmokeTestPredict  = "predict"
+	SmokeTestClassify = "classify"
+	SmokeTestRegress  = "regress"
+)
+
+// SmokeTest is sample request sent to every instance of TFS after version of model is loaded.
+// Request holds PredictRequest, Examples hold rows of ClassificationRequest or RegressionRequest
+// as tf.Example messages, both in JSON mapping of protocol buffers. Model spec is set by tfd
+type SmokeTest struct {
+	Method        string            `json:"method"`
+	SignatureName string            `json:"signature_name,omitempty"`
+	Request       json.RawMessage   `json:"request,omitempty"`
+	Examples      []json.RawMessage `json:"examples,omitempty"`
+}
+
+// ModelSmokeTest holds smoke test attached to model
+type ModelSmokeTest struct {
+	ServableID
+	SmokeTest
+}
+
+// SmokeTestResult is result of smoke test of model version on single instance of TFS
+type SmokeTestResult struct {
+	Instance  string `json:"instance"`
+	Passed    bool   `json:"passed"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Created   string `json:"created,omitempty"`
+}
+
+// ModelSmokeTestResults holds results of smoke test of model version, Passed
+// is set when smoke test has passed on every tested instance
+type ModelSmokeTestResults struct {
+	ModelID
+	Passed  bool              `json:"passed"`
+	Results []SmokeTestResult `json:"results"`
+}
+
+// SmokeTestPassed returns true when smoke test has been run and passed on every instance
+func SmokeTestPassed(results []SmokeTestResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+
+	return len(results) > 0
+}
@@ -0,0 +1,162 @@
+// Package audit records mutating calls of REST API, e.g. uploads, deletions and
+// moves of labels. Entries are appended to metadata store and optionally written
+// as JSON lines to a file, so they may be shipped to external log collectors
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	filePerm = 0640
+
+	// DefaultLimit is number of entries listed when query doesn't limit them
+	DefaultLimit = 100
+	// MaxLimit is the highest number of entries listed at once
+	MaxLimit = 1000
+)
+
+// Store keeps entries of audit log, it mustn't allow to change or remove them
+type Store interface {
+	Add(ctx context.Context, entry app.AuditEntry) (int64, error)
+	List(ctx context.Context, query app.AuditQuery) ([]app.AuditEntry, error)
+}
+
+// Log is an audit log
+type Log struct {
+	store Store
+
+	sinkLock sync.Mutex
+	sink     *os.File
+
+	now func() time.Time
+}
+
+// NewLog returns audit log kept in store
+func NewLog(store Store) *Log {
+	return &Log{store: store, now: time.Now}
+}
+
+// WithFileSink appends every recorded entry to file as single JSON line,
+// file is created when it doesn't exist
+func (l *Log) WithFileSink(path string) (*Log, error) {
+	sink, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, filePerm)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	l.sink = sink
+
+	return l, nil
+}
+
+// Close closes file sink
+func (l *Log) Close() error {
+	if l.sink == nil {
+		return nil
+	}
+
+	l.sinkLock.Lock()
+	defer l.sinkLock.Unlock()
+
+	if err := l.sink.Close(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// Record appends entry to audit log. Failures are only logged, so they don't
+// change outcome of audited call. Entry is recorded even if ctx has been
+// canceled in the meantime, e.g. when client has disconnected
+func (l *Log) Record(ctx context.Context, entry app.AuditEntry) {
+	ctx = detach(ctx)
+	entry.Created = l.now().Unix()
+
+	id, err := l.store.Add(ctx, entry)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+	}
+	entry.ID = id
+
+	if l.sink != nil {
+		if err := l.writeSink(entry); err != nil {
+			logging.ErrorWithStack(ctx, err)
+		}
+	}
+}
+
+// List lists entries of audit log matching query, the latest entry is the first one
+func (l *Log) List(ctx context.Context, query app.AuditQuery) (*app.AuditPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit > MaxLimit {
+		query.Limit = MaxLimit
+	}
+	limit := query.Limit
+
+	// one more entry tells whether next page exists
+	query.Limit++
+	entries, err := l.store.List(ctx, query)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	page := &app.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextBefore = entries[limit-1].ID
+	}
+	if page.Entries == nil {
+		page.Entries = []app.AuditEntry{}
+	}
+
+	return page, nil
+}
+
+func (l *Log) writeSink(entry app.AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	line = append(line, '\n')
+
+	l.sinkLock.Lock()
+	defer l.sinkLock.Unlock()
+
+	if _, err := l.sink.Write(line); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// detachedContext keeps values of its parent, but is never canceled
+type detachedContext struct {
+	context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{Context: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
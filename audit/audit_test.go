@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+)
+
+// memoryStore keeps entries in memory and ignores filters other than cursor
+type memoryStore struct {
+	entries []app.AuditEntry
+}
+
+func (s *memoryStore) Add(ctx context.Context, entry app.AuditEntry) (int64, error) {
+	entry.ID = int64(len(s.entries) + 1)
+	s.entries = append(s.entries, entry)
+
+	return entry.ID, nil
+}
+
+func (s *memoryStore) List(ctx context.Context, query app.AuditQuery) ([]app.AuditEntry, error) {
+	var entries []app.AuditEntry
+	for i := len(s.entries) - 1; i >= 0 && len(entries) < query.Limit; i-- {
+		if query.Before == 0 || s.entries[i].ID < query.Before {
+			entries = append(entries, s.entries[i])
+		}
+	}
+
+	return entries, nil
+}
+
+func TestLog_Record(t *testing.T) {
+	dir := testutil.TempDir(t)
+
+	path := filepath.Join(dir, "audit.log")
+	store := &memoryStore{}
+	log, err := NewLog(store).WithFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.now = func() time.Time { return time.Unix(100, 0) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	log.Record(ctx, app.AuditEntry{Operation: "model_upload", Team: "team", Project: "project", Name: "name", Version: 1, Outcome: app.AuditSuccess, Status: 200})
+	log.Record(ctx, app.AuditEntry{Operation: "model_delete_version", Team: "team", Project: "project", Name: "name", Version: 1, Outcome: app.AuditFailure, Status: 307, ErrorCode: "SERVICE-1003"})
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []app.AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry app.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, entry)
+	}
+
+	if len(lines) != 2 || len(store.entries) != 2 {
+		t.Fatalf("sink = %+v, store = %+v, want 2 entries in both", lines, store.entries)
+	}
+	for i := range lines {
+		if lines[i] != store.entries[i] || lines[i].ID != int64(i+1) || lines[i].Created != 100 {
+			t.Errorf("sink line %d = %+v, store entry = %+v", i, lines[i], store.entries[i])
+		}
+	}
+}
+
+func TestLog_List(t *testing.T) {
+	store := &memoryStore{}
+	log := NewLog(store)
+	for i := 0; i < 5; i++ {
+		log.Record(context.Background(), app.AuditEntry{Operation: "model_upload"})
+	}
+
+	tests := []struct {
+		name           string
+		query          app.AuditQuery
+		wantIDs        []int64
+		wantNextBefore int64
+	}{
+		{name: "First page should point to next one", query: app.AuditQuery{Limit: 2}, wantIDs: []int64{5, 4}, wantNextBefore: 4},
+		{name: "Next page should start before cursor", query: app.AuditQuery{Limit: 2, Before: 4}, wantIDs: []int64{3, 2}, wantNextBefore: 2},
+		{name: "Last page shouldn't point to next one", query: app.AuditQuery{Limit: 2, Before: 2}, wantIDs: []int64{1}},
+		{name: "Default limit should be applied", query: app.AuditQuery{}, wantIDs: []int64{5, 4, 3, 2, 1}},
+		{name: "Empty page should have no entries", query: app.AuditQuery{Before: 1}, wantIDs: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := log.List(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Entries == nil || len(page.Entries) != len(tt.wantIDs) || page.NextBefore != tt.wantNextBefore {
+				t.Fatalf("List() = %+v, want IDs %v and next before %d", page, tt.wantIDs, tt.wantNextBefore)
+			}
+			for i, entry := range page.Entries {
+				if entry.ID != tt.wantIDs[i] {
+					t.Errorf("List() entry %d ID = %d, want %d", i, entry.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+const (
+	// Wildcard matches any team or project in grants
+	Wildcard = "*"
+)
+
+// Role defines what caller is allowed to do, every role
+// includes permissions of lower roles
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleReader
+	RoleDeployer
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleReader:   "reader",
+	RoleDeployer: "deployer",
+	RoleAdmin:    "admin",
+}
+
+var (
+	invalidTokenErrorCode = 1001
+	invalidRoleErrorCode  = 1002
+
+	// ErrInvalidToken is returned when token is unknown, malformed or expired
+	ErrInvalidToken = exterr.NewErrorWithMessage("invalid token").WithComponent(app.ComponentAuth).WithCode(invalidTokenErrorCode)
+	errInvalidRole  = exterr.NewErrorWithMessage("invalid role").WithComponent(app.ComponentAuth).WithCode(invalidRoleErrorCode)
+)
+
+// ParseRole converts role name to Role
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if roleName == strings.ToLower(name) {
+			return role, nil
+		}
+	}
+
+	return RoleNone, errInvalidRole
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+
+	return "none"
+}
+
+// Grant gives role in project of a team, Wildcard can be used as team or project
+type Grant struct {
+	Team    string
+	Project string
+	Role    Role
+}
+
+// matches checks if grant covers given team and project
+func (g Grant) matches(team, project string) bool {
+	return (g.Team == Wildcard || g.Team == team) && (g.Project == Wildcard || g.Project == project)
+}
+
+// Identity describes authenticated caller
+type Identity struct {
+	Subject string
+	Grants  []Grant
+}
+
+// Allowed checks if identity has at least given role in project of a team.
+// Requests which are not bound to any team require grant for all teams
+func (i *Identity) Allowed(team, project string, role Role) bool {
+	if team == "" {
+		team, project = Wildcard, Wildcard
+	}
+
+	for _, grant := range i.Grants {
+		if grant.Role < role {
+			continue
+		}
+		if team == Wildcard && (grant.Team != Wildcard || grant.Project != Wildcard) {
+			continue
+		}
+		if grant.matches(team, project) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator returns identity of token owner or ErrInvalidToken
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+type ctxKey struct{}
+
+// NewContext returns context holding given identity
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, identity)
+}
+
+// FromContext returns identity stored in context, nil when request is not authenticated
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(ctxKey{}).(*Identity)
+
+	return identity
+}
@@ -0,0 +1,48 @@
+package auth
+
+import "testing"
+
+func TestIdentity_Allowed(t *testing.T) {
+	identity := &Identity{Subject: "ci", Grants: []Grant{
+		{Team: "team", Project: "project", Role: RoleDeployer},
+		{Team: "other", Project: Wildcard, Role: RoleReader},
+	}}
+	global := &Identity{Subject: "ops", Grants: []Grant{{Team: Wildcard, Project: Wildcard, Role: RoleAdmin}}}
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		team     string
+		project  string
+		role     Role
+		want     bool
+	}{
+		{name: "Lower role should be allowed", identity: identity, team: "team", project: "project", role: RoleReader, want: true},
+		{name: "Same role should be allowed", identity: identity, team: "team", project: "project", role: RoleDeployer, want: true},
+		{name: "Higher role should be denied", identity: identity, team: "team", project: "project", role: RoleAdmin, want: false},
+		{name: "Other project should be denied", identity: identity, team: "team", project: "another", role: RoleReader, want: false},
+		{name: "Wildcard project should match any project", identity: identity, team: "other", project: "any", role: RoleReader, want: true},
+		{name: "Request without team should require global grant", identity: identity, role: RoleReader, want: false},
+		{name: "Global grant should allow request without team", identity: global, role: RoleReader, want: true},
+		{name: "Global grant should allow any team", identity: global, team: "team", project: "project", role: RoleAdmin, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.identity.Allowed(tt.team, tt.project, tt.role); got != tt.want {
+				t.Errorf("Identity.Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for role, name := range roleNames {
+		if got, err := ParseRole(name); err != nil || got != role {
+			t.Errorf("ParseRole(%s) = %v, %v, want %v", name, got, err, role)
+		}
+	}
+
+	if _, err := ParseRole("root"); err != errInvalidRole {
+		t.Errorf("ParseRole() error = %v, want %v", err, errInvalidRole)
+	}
+}
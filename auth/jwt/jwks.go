@@ -0,0 +1,100 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+// jwks is a JSON Web Key Set as defined in RFC 7517
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		// RSA
+		N string `json:"n"`
+		E string `json:"e"`
+		// EC
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+// parseJWKS returns public keys of signing keys indexed by key ID,
+// keys of unsupported types are skipped
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			n, err := decodeBigInt(key.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := decodeBigInt(key.E)
+			if err != nil {
+				return nil, err
+			}
+			if !e.IsInt64() {
+				return nil, errInvalidJWKS
+			}
+			keys[key.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err := decodeBigInt(key.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := decodeBigInt(key.Y)
+			if err != nil {
+				return nil, err
+			}
+			if !curve.IsOnCurve(x, y) {
+				return nil, errInvalidJWKS
+			}
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errInvalidJWKS
+	}
+
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errInvalidJWKS
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
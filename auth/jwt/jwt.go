@@ -0,0 +1,337 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	// leeway is a tolerated clock skew between tfd and token issuer
+	leeway = time.Minute
+	// jwksRefreshInterval is a minimal interval between fetches of JWKS
+	// triggered by tokens signed with unknown keys
+	jwksRefreshInterval = 5 * time.Minute
+	jwksFetchTimeout    = 10 * time.Second
+
+	algRS256 = "RS256"
+	algES256 = "ES256"
+)
+
+var (
+	invalidJWKSErrorCode = 1006
+	fetchJWKSErrorCode   = 1007
+
+	errInvalidJWKS = exterr.NewErrorWithMessage("invalid or empty JWKS").WithComponent(app.ComponentAuth).WithCode(invalidJWKSErrorCode)
+	errFetchJWKS   = exterr.NewErrorWithMessage("couldn't fetch JWKS").WithComponent(app.ComponentAuth).WithCode(fetchJWKSErrorCode)
+)
+
+// JWT validates RS256 and ES256 signed JSON Web Tokens and maps
+// values of configured claim to teams which caller may act on.
+// Claim values have format <prefix><team>[/<project>][:<role>], values
+// without configured prefix are ignored
+type JWT struct {
+	jwksURL     string
+	issuer      string
+	audience    string
+	teamsClaim  string
+	teamsPrefix string
+	defaultRole auth.Role
+
+	client *http.Client
+	now    func() time.Time
+
+	m         sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewJWT creates instance of JWT validator, keys are loaded from JWKS file
+// once or fetched from JWKS URL and refreshed when token is signed with unknown key
+func NewJWT(ctx context.Context, conf *app.ConfigAuthJWT) (*JWT, error) {
+	defaultRole, err := auth.ParseRole(*conf.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &JWT{
+		jwksURL:     *conf.JWKSURL,
+		issuer:      *conf.Issuer,
+		audience:    *conf.Audience,
+		teamsClaim:  *conf.TeamsClaim,
+		teamsPrefix: *conf.TeamsClaimPrefix,
+		defaultRole: defaultRole,
+		client:      &http.Client{Timeout: jwksFetchTimeout},
+		now:         time.Now,
+	}
+
+	if j.jwksURL != "" {
+		if err := j.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		return j, nil
+	}
+
+	data, err := ioutil.ReadFile(*conf.JWKSPath)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if j.keys, err = parseJWKS(data); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// Authenticate validates token and returns identity of its subject
+func (j *JWT) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, auth.ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	key := j.key(ctx, header.Kid)
+	if key == nil || !verify(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	if !j.validClaims(claims) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	identity := &auth.Identity{Subject: subject}
+	for _, value := range stringValues(claims[j.teamsClaim]) {
+		if grant, ok := j.grant(value); ok {
+			identity.Grants = append(identity.Grants, grant)
+		}
+	}
+
+	return identity, nil
+}
+
+// validClaims checks registered claims: issuer, audience, expiration and not before time
+func (j *JWT) validClaims(claims map[string]interface{}) bool {
+	if issuer, _ := claims["iss"].(string); issuer != j.issuer {
+		return false
+	}
+
+	audienceFound := false
+	for _, audience := range stringValues(claims["aud"]) {
+		if audience == j.audience {
+			audienceFound = true
+		}
+	}
+	if !audienceFound {
+		return false
+	}
+
+	now := j.now()
+	expiration, ok := claims["exp"].(json.Number)
+	if !ok {
+		return false
+	}
+	if exp, err := expiration.Int64(); err != nil || now.After(time.Unix(exp, 0).Add(leeway)) {
+		return false
+	}
+
+	if notBefore, ok := claims["nbf"].(json.Number); ok {
+		if nbf, err := notBefore.Int64(); err != nil || now.Before(time.Unix(nbf, 0).Add(-leeway)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// grant converts claim value to grant
+func (j *JWT) grant(value string) (auth.Grant, bool) {
+	if !strings.HasPrefix(value, j.teamsPrefix) {
+		return auth.Grant{}, false
+	}
+	value = strings.TrimPrefix(value, j.teamsPrefix)
+
+	grant := auth.Grant{Project: auth.Wildcard, Role: j.defaultRole}
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		role, err := auth.ParseRole(value[i+1:])
+		if err != nil {
+			return auth.Grant{}, false
+		}
+		grant.Role = role
+		value = value[:i]
+	}
+	if i := strings.Index(value, "/"); i >= 0 {
+		grant.Project = value[i+1:]
+		value = value[:i]
+	}
+	grant.Team = value
+
+	if grant.Team == "" || grant.Project == "" {
+		return auth.Grant{}, false
+	}
+
+	return grant, true
+}
+
+// key returns public key of given ID, keys fetched from URL are refreshed when key is unknown
+func (j *JWT) key(ctx context.Context, kid string) crypto.PublicKey {
+	j.m.RLock()
+	key := findKey(j.keys, kid)
+	refresh := key == nil && j.jwksURL != "" && j.now().Sub(j.fetchedAt) > jwksRefreshInterval
+	j.m.RUnlock()
+
+	if !refresh {
+		return key
+	}
+
+	if err := j.fetchKeys(ctx); err != nil {
+		logging.ErrorWithStack(ctx, err)
+		return nil
+	}
+
+	j.m.RLock()
+	defer j.m.RUnlock()
+
+	return findKey(j.keys, kid)
+}
+
+// fetchKeys replaces keys with keys fetched from JWKS URL
+func (j *JWT) fetchKeys(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, j.jwksURL, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	resp, err := j.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return exterr.WrapWithErr(err, errFetchJWKS)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return exterr.WrapWithErr(fmt.Errorf("unexpected status code %d", resp.StatusCode), errFetchJWKS)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return exterr.WrapWithErr(err, errFetchJWKS)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	j.m.Lock()
+	j.keys = keys
+	j.fetchedAt = j.now()
+	j.m.Unlock()
+
+	return nil
+}
+
+// findKey returns key of given ID, key ID may be omitted when key set holds single key
+func findKey(keys map[string]crypto.PublicKey, kid string) crypto.PublicKey {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// verify checks signature of signing input, only RS256 and ES256 algorithms are accepted
+func verify(alg string, key crypto.PublicKey, signingInput string, signature []byte) bool {
+	hash := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case algRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, hash[:], signature) == nil
+	case algES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, hash[:], r, s)
+	}
+
+	return false
+}
+
+// decodeSegment decodes base64url encoded JSON segment of token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	return d.Decode(v)
+}
+
+// stringValues returns values of claim which is either a string or an array of strings
+func stringValues(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+)
+
+var testNow = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X.Bytes()), "y": b64(key.Y.Bytes())}
+}
+
+func jwksJSON(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hash[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signingInput + "." + b64(signature)
+}
+
+func claims(overrides map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"iss":    "https://sso.example.com",
+		"aud":    []string{"tfd"},
+		"sub":    "jdoe",
+		"exp":    testNow.Add(time.Hour).Unix(),
+		"groups": []string{"tfd-team", "tfd-other/project:admin", "unrelated"},
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+
+	return c
+}
+
+func newTestJWT(t *testing.T, jwksPath, jwksURL string) *JWT {
+	str := func(s string) *string { return &s }
+
+	j, err := NewJWT(context.Background(), &app.ConfigAuthJWT{
+		JWKSPath:         str(jwksPath),
+		JWKSURL:          str(jwksURL),
+		Issuer:           str("https://sso.example.com"),
+		Audience:         str("tfd"),
+		TeamsClaim:       str("groups"),
+		TeamsClaimPrefix: str("tfd-"),
+		DefaultRole:      str("deployer"),
+	})
+	if err != nil {
+		t.Fatalf("NewJWT() error = %v", err)
+	}
+	j.now = func() time.Time { return testNow }
+
+	return j
+}
+
+func TestJWT_Authenticate(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	f, err := ioutil.TempFile("", "tfd-jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(jwksJSON(t, rsaJWK("rsa", rsaKey), ecJWK("ec", ecKey)))
+	f.Close()
+
+	j := newTestJWT(t, f.Name(), "")
+
+	wantGrants := []auth.Grant{
+		{Team: "team", Project: auth.Wildcard, Role: auth.RoleDeployer},
+		{Team: "other", Project: "project", Role: auth.RoleAdmin},
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "RS256 token should be valid", token: sign(t, algRS256, "rsa", rsaKey, claims(nil))},
+		{name: "ES256 token should be valid", token: sign(t, algES256, "ec", ecKey, claims(nil))},
+		{name: "Single audience should be accepted", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"aud": "tfd"}))},
+		{name: "Token signed with other key should be rejected", token: sign(t, algRS256, "ec", rsaKey, claims(nil)), wantErr: true},
+		{name: "Token with unsupported algorithm should be rejected", token: sign(t, "none", "rsa", rsaKey, claims(nil)), wantErr: true},
+		{name: "Token of other issuer should be rejected", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})), wantErr: true},
+		{name: "Token for other audience should be rejected", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"aud": "other"})), wantErr: true},
+		{name: "Expired token should be rejected", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"exp": testNow.Add(-time.Hour).Unix()})), wantErr: true},
+		{name: "Token without expiration should be rejected", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"exp": nil})), wantErr: true},
+		{name: "Token used before nbf should be rejected", token: sign(t, algRS256, "rsa", rsaKey, claims(map[string]interface{}{"nbf": testNow.Add(time.Hour).Unix()})), wantErr: true},
+		{name: "Malformed token should be rejected", token: "secret", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := j.Authenticate(context.Background(), tt.token)
+			if tt.wantErr {
+				if err != auth.ErrInvalidToken {
+					t.Errorf("Authenticate() error = %v, want %v", err, auth.ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if identity.Subject != "jdoe" || !reflect.DeepEqual(identity.Grants, wantGrants) {
+				t.Errorf("Authenticate() = %+v, want subject jdoe with grants %+v", identity, wantGrants)
+			}
+		})
+	}
+}
+
+func TestJWT_refreshKeys(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var rotated, fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&rotated) == 1 {
+			w.Write(jwksJSON(t, rsaJWK("new", newKey)))
+			return
+		}
+		w.Write(jwksJSON(t, rsaJWK("old", oldKey)))
+	}))
+	defer server.Close()
+
+	j := newTestJWT(t, "", server.URL)
+	atomic.StoreInt32(&rotated, 1)
+	token := sign(t, algRS256, "new", newKey, claims(nil))
+
+	// keys were fetched recently, so they are not refreshed yet
+	if _, err := j.Authenticate(context.Background(), token); err != auth.ErrInvalidToken {
+		t.Errorf("Authenticate() error = %v, want %v", err, auth.ErrInvalidToken)
+	}
+
+	j.now = func() time.Time { return time.Now().Add(2 * jwksRefreshInterval) }
+	token = sign(t, algRS256, "new", newKey, claims(map[string]interface{}{"exp": time.Now().Add(3 * jwksRefreshInterval).Unix()}))
+	if _, err := j.Authenticate(context.Background(), token); err != nil {
+		t.Errorf("Authenticate() with rotated key error = %v", err)
+	}
+
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
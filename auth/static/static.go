@@ -0,0 +1,97 @@
+package static
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+var (
+	emptyTokenErrorCode     = 1003
+	duplicateTokenErrorCode = 1004
+
+	errEmptyToken     = exterr.NewErrorWithMessage("token of static token store is empty").WithComponent(app.ComponentAuth).WithCode(emptyTokenErrorCode)
+	errDuplicateToken = exterr.NewErrorWithMessage("token of static token store is duplicated").WithComponent(app.ComponentAuth).WithCode(duplicateTokenErrorCode)
+)
+
+// tokensFile is a structure of YAML file with tokens, e.g.
+//
+//	tokens:
+//	  - subject: ci-team-a
+//	    token: secret
+//	    grants:
+//	      - team: team-a
+//	        project: "*"
+//	        role: deployer
+type tokensFile struct {
+	Tokens []struct {
+		Subject string `yaml:"subject"`
+		Token   string `yaml:"token"`
+		Grants  []struct {
+			Team    string `yaml:"team"`
+			Project string `yaml:"project"`
+			Role    string `yaml:"role"`
+		} `yaml:"grants"`
+	} `yaml:"tokens"`
+}
+
+// Static is a token store loaded once from YAML file
+type Static struct {
+	// identities are indexed by SHA-256 of token, so lookup
+	// time does not depend on a common prefix with a valid token
+	identities map[[sha256.Size]byte]*auth.Identity
+}
+
+// NewStatic creates instance of Static token store from given YAML file
+func NewStatic(ctx context.Context, tokensPath string) (*Static, error) {
+	f, err := os.Open(tokensPath)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer f.Close()
+
+	var file tokensFile
+	if err := yaml.NewDecoder(f).Decode(&file); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	s := &Static{identities: make(map[[sha256.Size]byte]*auth.Identity)}
+	for _, token := range file.Tokens {
+		if token.Token == "" {
+			return nil, errEmptyToken
+		}
+
+		key := sha256.Sum256([]byte(token.Token))
+		if _, ok := s.identities[key]; ok {
+			return nil, errDuplicateToken
+		}
+
+		identity := &auth.Identity{Subject: token.Subject}
+		for _, grant := range token.Grants {
+			role, err := auth.ParseRole(grant.Role)
+			if err != nil {
+				return nil, err
+			}
+			identity.Grants = append(identity.Grants, auth.Grant{Team: grant.Team, Project: grant.Project, Role: role})
+		}
+		s.identities[key] = identity
+	}
+
+	return s, nil
+}
+
+// Authenticate returns identity of token owner
+func (s *Static) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	identity, ok := s.identities[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return identity, nil
+}
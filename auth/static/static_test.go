@@ -0,0 +1,71 @@
+package static
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/auth"
+)
+
+func writeTokensFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "tfd-tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	return f.Name()
+}
+
+func TestStatic_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewStatic(ctx, writeTokensFile(t, `
+tokens:
+  - subject: ci
+    token: secret
+    grants:
+      - team: team
+        project: "*"
+        role: deployer
+`))
+	if err != nil {
+		t.Fatalf("NewStatic() error = %v", err)
+	}
+
+	identity, err := s.Authenticate(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if identity.Subject != "ci" || !identity.Allowed("team", "project", auth.RoleDeployer) {
+		t.Errorf("Authenticate() = %+v, want deployer of team", identity)
+	}
+
+	if _, err := s.Authenticate(ctx, "unknown"); err != auth.ErrInvalidToken {
+		t.Errorf("Authenticate() of unknown token error = %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestNewStatic(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "Empty token should be rejected", content: "tokens:\n  - subject: ci\n"},
+		{name: "Duplicated token should be rejected", content: "tokens:\n  - token: a\n  - token: a\n"},
+		{name: "Unknown role should be rejected", content: "tokens:\n  - token: a\n    grants:\n      - team: t\n        project: p\n        role: root\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStatic(context.Background(), writeTokensFile(t, tt.content)); err == nil {
+				t.Errorf("NewStatic() error = nil, want error")
+			}
+		})
+	}
+}
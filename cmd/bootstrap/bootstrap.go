@@ -2,24 +2,37 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-
-	_ "github.com/mattn/go-sqlite3"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
 	"github.com/grupawp/tensorflow-deploy/config"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metadata/sqldb"
 )
 
 const (
 	logConfigErrorCode            = "1001"
 	logConfParseErrorCode         = "1002"
 	logMetadataBootstrapErrorCode = "1003"
-	logcreateTablesErrorCode      = "1004"
+	logMigrateErrorCode           = "1004"
+	logUnknownCommandErrorCode    = "1005"
+	logTokenErrorCode             = "1006"
 )
 
+const usage = "usage: bootstrap [options] [migrate up|down|status | token add SUBJECT TEAM/PROJECT:ROLE... | token revoke SUBJECT]"
+
+// tokenLength is number of random bytes of generated token
+const tokenLength = 32
+
 func main() {
 	ctx := context.Background()
 	conf, err := config.NewConfig(ctx)
@@ -35,78 +48,175 @@ func main() {
 		logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logConfParseErrorCode)
 	}
 
-	md, err := newMetadataBootstrap(ctx, *mainConfig.Metadata.SQLDB.Driver, *mainConfig.Metadata.SQLDB.DSN)
+	md, err := sqldb.NewSQLDB(ctx, *mainConfig.Metadata.SQLDB.Driver, *mainConfig.Metadata.SQLDB.DSN)
 	if err != nil {
 		logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logMetadataBootstrapErrorCode)
 	}
+	defer md.Close(ctx)
 
-	if err := md.createTables(ctx, tableSQLiteModelDefinition, tableSQLiteModuleDefinition); err != nil {
-		logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logcreateTablesErrorCode)
+	// bootstrap without arguments is an equivalent of "migrate up"
+	args := conf.Args()
+	if len(args) == 0 {
+		args = []string{"migrate", "up"}
+	}
+
+	switch {
+	case len(args) >= 4 && args[0] == "token" && args[1] == "add":
+		if err := addToken(ctx, md, args[2], args[3:]); err != nil {
+			logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logTokenErrorCode)
+		}
+		return
+	case len(args) == 3 && args[0] == "token" && args[1] == "revoke":
+		if err := revokeTokens(ctx, md, args[2]); err != nil {
+			logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logTokenErrorCode)
+		}
+		return
+	case len(args) != 2 || args[0] != "migrate":
+		logging.Fatal(ctx, fmt.Sprintf("unknown command `%s`, %s", strings.Join(args, " "), usage), logUnknownCommandErrorCode)
+	}
+
+	command := args[1]
+	switch command {
+	case "up":
+		err = migrateUp(ctx, md)
+	case "down":
+		err = migrateDown(ctx, md)
+	case "status":
+		err = migrateStatus(ctx, md)
+	default:
+		logging.Fatal(ctx, fmt.Sprintf("unknown migrate command `%s`, %s", command, usage), logUnknownCommandErrorCode)
+	}
+	if err != nil {
+		logging.FatalErrorWithStack(ctx, exterr.WrapWithFrame(err), logMigrateErrorCode)
 	}
 }
 
-const (
-	tableSQLiteModelDefinition = `CREATE TABLE IF NOT EXISTS model (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		team VARCHAR(250) NOT NULL,
-		project VARCHAR(250) NOT NULL,
-		name VARCHAR(250) NOT NULL,
-		version INTEGER NOT NULL,
-		label VARCHAR(250) NOT NULL,
-		status INTEGER NOT NULL,
-		created INTEGER NOT NULL,
-		updated INTEGER NOT NULL);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_model ON model (team, project, name, version, label);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_model_label ON model (team, project, name, label) WHERE label!="";`
-
-	tableSQLiteModuleDefinition = `CREATE TABLE IF NOT EXISTS module (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		team VARCHAR(250) NOT NULL,
-		project VARCHAR(250) NOT NULL,
-		name VARCHAR(250) NOT NULL,
-		version INTEGER NOT NULL,
-		created INTEGER NOT NULL,
-		updated INTEGER NOT NULL);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_module ON module (team, project, name, version);`
-)
+// migrateUp applies every pending migration
+func migrateUp(ctx context.Context, md *sqldb.SQLDB) error {
+	versions, err := md.MigrateUp(ctx)
+	for _, version := range versions {
+		logging.Info(ctx, fmt.Sprintf("Migration %d applied", version))
+	}
+	if err != nil {
+		return err
+	}
 
-type metadataBootstrap struct {
-	connection *sql.DB
+	if len(versions) == 0 {
+		logging.Info(ctx, "Metadata schema is up to date")
+	}
+
+	return nil
 }
 
-func newMetadataBootstrap(ctx context.Context, driver, dsn string) (*metadataBootstrap, error) {
-	connection, err := sql.Open(driver, dsn)
+// migrateDown reverts the latest applied migration
+func migrateDown(ctx context.Context, md *sqldb.SQLDB) error {
+	version, err := md.MigrateDown(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &metadataBootstrap{
-		connection: connection,
-	}, nil
+	if version == 0 {
+		logging.Info(ctx, "No migration to revert")
+		return nil
+	}
+
+	logging.Info(ctx, fmt.Sprintf("Migration %d reverted", version))
+
+	return nil
 }
 
-// createTables creates tables from the given schemas
-func (b *metadataBootstrap) createTables(ctx context.Context, schemas ...string) error {
-	if len(schemas) == 0 {
-		return errors.New("no schemas provided")
+// migrateStatus prints status of every known migration
+func migrateStatus(ctx context.Context, md *sqldb.SQLDB) error {
+	statuses, err := md.MigrationsStatus(ctx)
+	if err != nil {
+		return err
 	}
 
-	for _, schema := range schemas {
-		if err := b.createTable(ctx, schema); err != nil {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied != 0 {
+			applied = time.Unix(status.Applied, 0).UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, applied, status.Description)
+	}
+
+	return w.Flush()
+}
+
+// addToken generates token with given grants written as TEAM/PROJECT:ROLE, token
+// is printed once, only its hash is stored in auth_token table
+func addToken(ctx context.Context, md *sqldb.SQLDB, subject string, grants []string) error {
+	if err := md.CheckSchema(ctx); err != nil {
+		return err
+	}
+
+	identity := &auth.Identity{Subject: subject}
+	for _, grant := range grants {
+		parsed, err := parseGrant(grant)
+		if err != nil {
 			return err
 		}
+		identity.Grants = append(identity.Grants, parsed)
+	}
+
+	random := make([]byte, tokenLength)
+	if _, err := rand.Read(random); err != nil {
+		return exterr.WrapWithFrame(err)
 	}
+	token := hex.EncodeToString(random)
+
+	if err := md.Token.AddToken(ctx, token, identity); err != nil {
+		return err
+	}
+
+	logging.Info(ctx, fmt.Sprintf("Token of %s added with %d grants", subject, len(identity.Grants)))
+	fmt.Println(token)
 
 	return nil
 }
 
-// createTable creates table from the given schema
-func (b *metadataBootstrap) createTable(ctx context.Context, schema string) error {
-	if _, err := b.connection.ExecContext(ctx, schema); err != nil {
-		logging.Error(ctx, err.Error(), "")
+// revokeTokens deletes every token of subject
+func revokeTokens(ctx context.Context, md *sqldb.SQLDB, subject string) error {
+	if err := md.CheckSchema(ctx); err != nil {
+		return err
+	}
 
-		return errors.New("create table error")
+	deleted, err := md.Token.RevokeTokens(ctx, subject)
+	if err != nil {
+		return err
 	}
 
+	logging.Info(ctx, fmt.Sprintf("%d grants of %s revoked", deleted, subject))
+
 	return nil
 }
+
+// parseGrant parses grant written as TEAM/PROJECT:ROLE, * matches any team or project
+func parseGrant(grant string) (auth.Grant, error) {
+	scope, roleName, ok := cut(grant, ":")
+	if !ok {
+		return auth.Grant{}, fmt.Errorf("grant `%s` isn't written as TEAM/PROJECT:ROLE", grant)
+	}
+	team, project, ok := cut(scope, "/")
+	if !ok || team == "" || project == "" {
+		return auth.Grant{}, fmt.Errorf("grant `%s` isn't written as TEAM/PROJECT:ROLE", grant)
+	}
+
+	role, err := auth.ParseRole(roleName)
+	if err != nil {
+		return auth.Grant{}, err
+	}
+
+	return auth.Grant{Team: team, Project: project, Role: role}, nil
+}
+
+func cut(s, sep string) (string, string, bool) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/auth/jwt"
+	"github.com/grupawp/tensorflow-deploy/auth/static"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metadata/sqldb"
+)
+
+const (
+	noneAuth   = "none"
+	staticAuth = "static"
+	sqldbAuth  = "sqldb"
+	jwtAuth    = "jwt"
+)
+
+var (
+	logUnknownAuthErrorCode = 1005
+	errUnknownAuth          = exterr.NewErrorWithMessage("auth token store is unknown").WithComponent(app.ComponentAuth).WithCode(logUnknownAuthErrorCode)
+)
+
+// NewAuthenticator creates instance of token store depends on authID,
+// nil authenticator means that authentication is disabled
+func NewAuthenticator(authID string, conf app.ConfigAuth, meta *sqldb.SQLDB) (auth.Authenticator, error) {
+	switch authID {
+	case noneAuth:
+		return nil, nil
+	case staticAuth:
+		return static.NewStatic(context.Background(), *conf.Static.TokensPath)
+	case sqldbAuth:
+		return meta.Token, nil
+	case jwtAuth:
+		return jwt.NewJWT(context.Background(), &conf.JWT)
+	}
+
+	return nil, errUnknownAuth
+}
@@ -2,16 +2,32 @@ package main
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/discovery"
+	"github.com/grupawp/tensorflow-deploy/discovery/cache"
+	"github.com/grupawp/tensorflow-deploy/discovery/composite"
+	"github.com/grupawp/tensorflow-deploy/discovery/consul"
 	"github.com/grupawp/tensorflow-deploy/discovery/dns"
+	"github.com/grupawp/tensorflow-deploy/discovery/kubernetes"
 	"github.com/grupawp/tensorflow-deploy/discovery/plaintext"
 	"github.com/grupawp/tensorflow-deploy/serving"
 )
 
-// NewServiceDiscovery creates instance of ServiceDiscovery depends on serviceID
+// NewServiceDiscovery creates instance of ServiceDiscovery depends on serviceID,
+// it is decorated with cache when caching or health check is enabled
 func NewServiceDiscovery(packageID string, conf app.ConfigDiscovery) (serving.Discoverer, error) {
+	discoverer, err := newDiscoverer(packageID, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return withCache(discoverer, conf.Cache), nil
+}
+
+func newDiscoverer(packageID string, conf app.ConfigDiscovery) (serving.Discoverer, error) {
 	pacID, err := discovery.Package(packageID)
 	if err != nil {
 		return nil, err
@@ -23,7 +39,62 @@ func NewServiceDiscovery(packageID string, conf app.ConfigDiscovery) (serving.Di
 		return plaintext.NewPlaintext(ctx, *conf.Plaintext.HostsPath)
 	case discovery.DNSPackage:
 		return dns.NewDNS(ctx, *conf.DNS.ServiceSuffix, *conf.DNS.DefaultInstancePort)
+	case discovery.KubernetesPackage:
+		return kubernetes.NewKubernetes(ctx, *conf.Kubernetes.KubeconfigPath, *conf.Kubernetes.NamespaceTemplate, *conf.Kubernetes.PortName, *conf.Kubernetes.Watch)
+	case discovery.ConsulPackage:
+		return consul.NewConsul(ctx, *conf.Consul.Address, *conf.Consul.Datacenter, *conf.Consul.Token, splitTags(*conf.Consul.Tags), *conf.Consul.Watch, time.Duration(*conf.Consul.WaitTimeInSec)*time.Second)
+	case discovery.CompositePackage:
+		return newComposite(ctx, conf)
 	}
 
 	return nil, discovery.ErrUnknownPackageDiscovery
 }
+
+// newComposite creates sources of composite discovery, they are configured in their own sections
+func newComposite(ctx context.Context, conf app.ConfigDiscovery) (serving.Discoverer, error) {
+	var sources []composite.Source
+	for _, name := range conf.Composite.SourceList() {
+		if name == discovery.CompositePackage {
+			return nil, discovery.ErrUnknownPackageDiscovery
+		}
+		source, err := newDiscoverer(name, conf)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, composite.Source{Name: name, Discoverer: source})
+	}
+
+	return composite.NewComposite(ctx, *conf.Composite.Mode, sources)
+}
+
+func withCache(discoverer serving.Discoverer, conf app.ConfigDiscoveryCache) serving.Discoverer {
+	var healthCheck cache.HealthCheck
+	switch *conf.HealthCheck {
+	case cache.HealthCheckGRPC:
+		healthCheck = cache.GRPCHealthCheck
+	case cache.HealthCheckModelStatus:
+		healthCheck = cache.ModelStatusHealthCheck
+	}
+	if *conf.TTLInSec == 0 && *conf.NegativeTTLInSec == 0 && healthCheck == nil {
+		return discoverer
+	}
+
+	c := cache.NewCache(discoverer, time.Duration(*conf.TTLInSec)*time.Second, time.Duration(*conf.NegativeTTLInSec)*time.Second)
+	if healthCheck != nil {
+		c.WithHealthCheck(healthCheck, time.Duration(*conf.HealthCheckTimeoutInSec)*time.Second)
+	}
+
+	return c
+}
+
+// splitTags returns non-empty tags of comma separated list
+func splitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/grupawp/tensorflow-deploy/fsck"
+)
+
+const usage = "usage: tensorflow_deploy [options] [fsck [repair]]"
+
+// fsckIdentity is identity of fsck command recorded e.g. in logs
+const fsckIdentity = "fsck"
+
+// parseFsckArgs checks if positional arguments request fsck command
+// and returns true when found inconsistencies should be repaired
+func parseFsckArgs(args []string) (bool, error) {
+	switch {
+	case len(args) == 1 && args[0] == "fsck":
+		return false, nil
+	case len(args) == 2 && args[0] == "fsck" && args[1] == "repair":
+		return true, nil
+	}
+
+	return false, fmt.Errorf("unknown command `%s`, %s", strings.Join(args, " "), usage)
+}
+
+// runFsck prints inconsistencies found by checker and returns number
+// of inconsistencies which haven't been repaired
+func runFsck(ctx context.Context, checker *fsck.Checker, repair bool) (int, error) {
+	report, err := checker.Check(ctx, repair)
+	if err != nil {
+		return 0, err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tTEAM\tPROJECT\tNAME\tVERSION\tLABEL\tREPAIRED\tDETAILS")
+	for _, i := range report.Inconsistencies {
+		repaired := "no"
+		if i.Repaired {
+			repaired = "yes"
+		}
+		details := i.Details
+		if i.RepairError != "" {
+			details = fmt.Sprintf("%s; repair failed: %s", details, i.RepairError)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", i.Type, i.Team, i.Project, i.Name, i.Version, i.Label, repaired, details)
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(report.Inconsistencies) - report.Repaired, nil
+}
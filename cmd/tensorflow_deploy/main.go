@@ -4,17 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/audit"
 	"github.com/grupawp/tensorflow-deploy/config"
+	"github.com/grupawp/tensorflow-deploy/fsck"
 	"github.com/grupawp/tensorflow-deploy/lock"
 	"github.com/grupawp/tensorflow-deploy/logging"
 	"github.com/grupawp/tensorflow-deploy/metadata/sqldb"
 	"github.com/grupawp/tensorflow-deploy/rest"
+	"github.com/grupawp/tensorflow-deploy/retention"
+	"github.com/grupawp/tensorflow-deploy/rollout"
 	"github.com/grupawp/tensorflow-deploy/service"
 	"github.com/grupawp/tensorflow-deploy/serving"
 	"github.com/grupawp/tensorflow-deploy/storage"
-	"github.com/grupawp/tensorflow-deploy/storage/filesystem"
+	"github.com/grupawp/tensorflow-deploy/upload"
 )
 
 // VERSION - service version initialized during build process
@@ -27,6 +35,14 @@ var (
 	logConfErrorCode      = "1004"
 	logServingErrorCode   = "1004"
 	logSQLDBErrorCode     = "1005"
+	logSchemaErrorCode    = "1006"
+	logAuthErrorCode      = "1007"
+	logRESTErrorCode      = "1008"
+	logUploadErrorCode    = "1009"
+	logAuditErrorCode     = "1010"
+	logRetentionErrorCode = "1011"
+	logFsckErrorCode      = "1012"
+	logCommandErrorCode   = "1013"
 )
 
 func main() {
@@ -45,13 +61,22 @@ func main() {
 		logging.FatalErrorWithStack(ctx, err, logConfErrorCode)
 	}
 
-	storageImpl, err := filesystem.NewStorager(&mainConfig.Storage.Filesystem)
+	storageImpl, err := NewStorage(*mainConfig.App.Storage, mainConfig.Storage)
 	if err != nil {
 		logging.FatalErrorWithStack(ctx, err, logStoragerErrorCode)
 	}
 
-	modelsStorage := storage.NewModelsStorage(storageImpl)
-	servingConf, err := serving.NewServableConfig(modelsStorage, *mainConfig.App.DefaultModelLabel)
+	storageLimits, err := NewStorageLimits(mainConfig.Storage.Limits)
+	if err != nil {
+		logging.FatalErrorWithStack(ctx, err, logStoragerErrorCode)
+	}
+
+	modelsStorage := storage.NewModelsStorage(storageImpl.models).WithLimits(storageLimits)
+	if !*mainConfig.Storage.Validation.SkipSavedModel {
+		modelsStorage = modelsStorage.WithSavedModelValidation()
+	}
+
+	servingConf, err := serving.NewServableConfig(modelsStorage, *mainConfig.App.DefaultModelLabel, storageImpl.servingBasePath)
 	if err != nil {
 		logging.FatalErrorWithStack(ctx, err, logServingErrorCode)
 	}
@@ -66,20 +91,145 @@ func main() {
 		logging.FatalErrorWithStack(ctx, err, logSQLDBErrorCode)
 	}
 
-	defer meta.Close(ctx)
+	if err := meta.CheckSchema(ctx); err != nil {
+		logging.FatalErrorWithStack(ctx, err, logSchemaErrorCode)
+	}
+
+	smokeTester := serving.NewSmokeTester(discovery, meta.Model, time.Duration(*mainConfig.App.SmokeTestTimeoutInSec)*time.Second)
+	servingReloader := serving.NewModelsReloader(discovery, meta.Model, servingConf, lock.New(), *mainConfig.App.ReloadIntervalInSec, *mainConfig.App.MaxAutoReloadDurationInSec, *mainConfig.App.AllowLabelsForUnavailableModels).
+		WithSmokeTests(smokeTester)
+	if watcher, ok := discovery.(serving.Watcher); ok {
+		servingReloader.WithWatcher(watcher)
+	}
+
+	modelsSvc := service.NewModelsService(meta.Model, servingConf, servingReloader, modelsStorage).
+		WithSmokeTests(smokeTester, *mainConfig.App.RequireSmokeTestForStable)
 
-	servingReloader := serving.NewModelsReloader(discovery, meta.Model, servingConf, lock.New(), *mainConfig.App.ReloadIntervalInSec, *mainConfig.App.MaxAutoReloadDurationInSec, *mainConfig.App.AllowLabelsForUnavailableModels)
+	checker := fsck.NewChecker(meta.Model, modelsStorage, servingConf, servingReloader, time.Duration(*mainConfig.App.FsckGracePeriodInSec)*time.Second)
 
-	modelsSvc := service.NewModelsService(meta.Model, servingConf, servingReloader, modelsStorage)
+	// positional arguments run fsck command instead of REST API
+	if args := conf.Args(); len(args) > 0 {
+		repair, err := parseFsckArgs(args)
+		if err != nil {
+			logging.Fatal(ctx, err.Error(), logCommandErrorCode)
+		}
+
+		unrepaired, err := runFsck(logging.WithIdentity(ctx, fsckIdentity), checker, repair)
+		if closeErr := meta.Close(ctx); closeErr != nil {
+			logging.ErrorWithStack(ctx, closeErr)
+		}
+		if err != nil {
+			logging.FatalErrorWithStack(ctx, err, logFsckErrorCode)
+		}
+		if unrepaired > 0 {
+			logging.Fatal(ctx, fmt.Sprintf("%d inconsistencies haven't been repaired", unrepaired), logFsckErrorCode)
+		}
+		return
+	}
 
-	modulesStorage := storage.NewModuleStorage(storageImpl)
+	modulesStorage := storage.NewModuleStorage(storageImpl.modules).WithLimits(storageLimits)
 	modulesSvc := service.NewModulesService(meta.Module, modulesStorage)
 
-	api := rest.NewREST(modelsSvc, modulesSvc, mainConfig.App.Listen(), VERSION)
+	authenticator, err := NewAuthenticator(*mainConfig.App.Auth, mainConfig.Auth, meta)
+	if err != nil {
+		logging.FatalErrorWithStack(ctx, err, logAuthErrorCode)
+	}
+
+	var uploadSessions *upload.Sessions
+	if *mainConfig.App.ResumableUploads {
+		uploadSessions, err = upload.NewSessions(ctx, *mainConfig.App.UploadSessionsPath, time.Duration(*mainConfig.App.UploadSessionExpirationInSec)*time.Second)
+		if err != nil {
+			logging.FatalErrorWithStack(ctx, err, logUploadErrorCode)
+		}
+		uploadSessions.WithMaxLength(*mainConfig.App.UploadSessionMaxLengthInMB << 20)
+	}
+
+	rollouts := rollout.NewRollouts(modelsSvc, servingReloader, meta.Model, time.Duration(*mainConfig.App.RolloutIntervalInSec)*time.Second)
+
+	retentionPolicies, err := NewRetentionPolicies(mainConfig.Retention)
+	if err != nil {
+		logging.FatalErrorWithStack(ctx, err, logRetentionErrorCode)
+	}
+	collector := retention.NewCollector(modelsSvc, retentionPolicies, time.Duration(*mainConfig.Retention.IntervalInSec)*time.Second)
+
+	auditLog := audit.NewLog(meta.Audit)
+	if path := *mainConfig.App.AuditFilePath; path != "" {
+		if _, err := auditLog.WithFileSink(path); err != nil {
+			logging.FatalErrorWithStack(ctx, err, logAuditErrorCode)
+		}
+	}
+
+	api := rest.NewREST(modelsSvc, modulesSvc, mainConfig.App.Listen(), VERSION).
+		WithRollouts(rollouts).
+		WithAuditor(auditLog).
+		WithRetention(collector).
+		WithFsck(checker).
+		WithShutdownTimeout(time.Duration(*mainConfig.App.ShutdownTimeoutInSec) * time.Second)
+	if authenticator != nil {
+		api.WithAuthenticator(authenticator)
+	}
+	if uploadSessions != nil {
+		api.WithUploadSessions(uploadSessions)
+	}
+	if reporter, ok := discovery.(rest.StatusReporter); ok {
+		api.WithStatusReporter("discovery", reporter)
+	}
 
 	logging.Info(context.Background(), fmt.Sprintf("%s v%s is up" /*service.ServiceName*/, "tensorflow-deploy", VERSION))
 	logging.Info(context.Background(), fmt.Sprintf("REST listening on %s", mainConfig.App.Listen()))
 
-	go servingReloader.ReloadInstancesJob(ctx)
-	api.Mount()
+	ctx, cancel := context.WithCancel(ctx)
+	go cancelOnSignal(ctx, cancel, syscall.SIGTERM, syscall.SIGINT)
+
+	reloadJobDone := make(chan struct{})
+	go func() {
+		servingReloader.ReloadInstancesJob(ctx)
+		close(reloadJobDone)
+	}()
+
+	rolloutsJobDone := make(chan struct{})
+	go func() {
+		rollouts.RolloutsJob(ctx)
+		close(rolloutsJobDone)
+	}()
+
+	retentionJobDone := make(chan struct{})
+	go func() {
+		collector.CollectJob(ctx)
+		close(retentionJobDone)
+	}()
+
+	mountErr := api.Mount(ctx)
+
+	// stop jobs and wait for them before closing metadata connection
+	cancel()
+	<-reloadJobDone
+	<-rolloutsJobDone
+	<-retentionJobDone
+
+	if err := auditLog.Close(); err != nil {
+		logging.ErrorWithStack(ctx, err)
+	}
+	if err := meta.Close(ctx); err != nil {
+		logging.ErrorWithStack(ctx, err)
+	}
+	logging.Info(ctx, fmt.Sprintf("%s v%s is down", "tensorflow-deploy", VERSION))
+
+	if mountErr != nil {
+		logging.FatalErrorWithStack(ctx, mountErr, logRESTErrorCode)
+	}
+}
+
+// cancelOnSignal cancels ctx when one of given signals is received
+func cancelOnSignal(ctx context.Context, cancel context.CancelFunc, signals ...os.Signal) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	select {
+	case sig := <-received:
+		logging.Info(ctx, fmt.Sprintf("received %s, shutting down", sig))
+		cancel()
+	case <-ctx.Done():
+	}
 }
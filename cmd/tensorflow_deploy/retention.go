@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/retention"
+)
+
+// NewRetentionPolicies creates retention policies applied by garbage collector
+func NewRetentionPolicies(conf app.ConfigRetention) (*retention.Policies, error) {
+	policies := retention.NewPolicies(app.RetentionPolicy{
+		KeepLastVersions: *conf.KeepLastVersions,
+		MaxAgeInDays:     *conf.MaxAgeInDays,
+	})
+	if *conf.RulesPath == "" {
+		return policies, nil
+	}
+
+	return policies.WithRules(*conf.RulesPath)
+}
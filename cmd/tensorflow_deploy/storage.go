@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/serving"
+	"github.com/grupawp/tensorflow-deploy/storage"
+	"github.com/grupawp/tensorflow-deploy/storage/filesystem"
+	"github.com/grupawp/tensorflow-deploy/storage/s3"
+)
+
+const (
+	filesystemStorage = "filesystem"
+	s3Storage         = "s3"
+)
+
+var (
+	logUnknownStorageErrorCode = 1010
+	errUnknownStorage          = exterr.NewErrorWithMessage("storage backend is unknown").WithComponent(app.ComponentStorage).WithCode(logUnknownStorageErrorCode)
+)
+
+// servableStorage holds chosen storage implementation for models and modules
+// together with models location seen by TFS instances
+type servableStorage struct {
+	models          storage.ModelStorage
+	modules         storage.ModuleStorage
+	servingBasePath string
+}
+
+// NewStorage creates instance of storage backend depends on storageID
+func NewStorage(storageID string, conf app.ConfigStorage) (*servableStorage, error) {
+	switch storageID {
+	case filesystemStorage:
+		impl, err := filesystem.NewStorager(&conf.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		return &servableStorage{models: impl, modules: impl, servingBasePath: serving.DefaultModelsBasePath}, nil
+	case s3Storage:
+		impl, err := s3.NewStorager(&conf.S3)
+		if err != nil {
+			return nil, err
+		}
+		return &servableStorage{models: impl, modules: impl, servingBasePath: impl.ServingBasePath()}, nil
+	}
+
+	return nil, errUnknownStorage
+}
+
+// NewStorageLimits creates limits of archives extracted by storage
+func NewStorageLimits(conf app.ConfigStorageLimits) (*storage.Limits, error) {
+	limits := storage.NewLimits(storage.ArchiveLimits{
+		MaxArchiveSize: *conf.MaxArchiveSizeInMB << 20,
+		MaxFileCount:   *conf.MaxFileCount,
+		MaxFileSize:    *conf.MaxFileSizeInMB << 20,
+	})
+	if *conf.TeamLimitsPath == "" {
+		return limits, nil
+	}
+
+	return limits.WithTeamLimits(*conf.TeamLimitsPath)
+}
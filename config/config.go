@@ -15,6 +15,9 @@ type Config struct {
 	cli      *app.Config
 	env      *app.Config
 	yamlFile *app.Config
+
+	// args holds positional command line arguments
+	args []string
 }
 
 // NewConfig ...
@@ -28,7 +31,7 @@ func NewConfig(ctx context.Context) (*Config, error) {
 		return nil, exterr.WrapWithFrame(err)
 	}
 
-	c.cli, err = app.NewConfigCLI(ctx)
+	c.cli, c.args, err = app.NewConfigCLI(ctx)
 	if err != nil {
 		if err == app.ErrCLIUsage {
 			return nil, err
@@ -56,6 +59,11 @@ func NewConfig(ctx context.Context) (*Config, error) {
 	return c, nil
 }
 
+// Args returns positional command line arguments left after parsing flags
+func (c *Config) Args() []string {
+	return c.args
+}
+
 // Parse ...
 func (c *Config) Parse(ctx context.Context) (*app.Config, error) {
 	configParams := app.NewConfig()
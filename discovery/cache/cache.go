@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/discovery"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+)
+
+const (
+	infoInstanceRecovered = "instance is healthy again"
+)
+
+var (
+	cacheUnhealthyInstancesErrorCode = 1018
+	logUnhealthyInstanceErrorCode    = "1018"
+
+	messageErrorUnhealthyInstance  = "instance is unhealthy"
+	messageErrorUnhealthyInstances = "unhealthy instances"
+)
+
+// Discoverer is decorated by Cache
+type Discoverer interface {
+	Discover(ctx context.Context, servableID app.ServableID) ([]string, error)
+}
+
+type watcher interface {
+	Watch(ctx context.Context, changed chan<- struct{})
+}
+
+type statusReporter interface {
+	Status() error
+}
+
+// Cache keeps instances discovered by decorated discoverer for TTL and its errors for
+// negative TTL. Optional health check excludes unhealthy instances from discovered ones,
+// so they aren't dialed by every reload until they are checked again
+type Cache struct {
+	discoverer         Discoverer
+	ttl                time.Duration
+	negativeTTL        time.Duration
+	healthCheck        HealthCheck
+	healthCheckTimeout time.Duration
+	now                func() time.Time
+
+	m       sync.Mutex
+	entries map[string]*entry
+	// generation is bumped when entries are invalidated, so results of discoveries
+	// started before are dropped instead of being cached for whole TTL
+	generation uint64
+	// unhealthy instances by instance name of servable, kept when entries are invalidated
+	unhealthy map[string][]string
+}
+
+type entry struct {
+	instances []string
+	err       error
+	expires   time.Time
+}
+
+// NewCache decorates discoverer with cache, zero TTL or negative TTL disables caching
+// of instances or errors respectively
+func NewCache(discoverer Discoverer, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		discoverer:  discoverer,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		entries:     make(map[string]*entry),
+		unhealthy:   make(map[string][]string),
+	}
+}
+
+// WithHealthCheck makes Cache check discovered instances and exclude unhealthy ones,
+// every instance is given timeout to answer
+func (c *Cache) WithHealthCheck(check HealthCheck, timeout time.Duration) *Cache {
+	c.healthCheck = check
+	c.healthCheckTimeout = timeout
+
+	return c
+}
+
+// Discover returns cached instances or cached error of decorated discoverer
+// while they are valid, otherwise it discovers and checks instances again
+func (c *Cache) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	name := servableID.InstanceName()
+
+	c.m.Lock()
+	e, ok := c.entries[name]
+	generation := c.generation
+	c.m.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return copyInstances(e.instances), e.err
+	}
+
+	instances, err := c.discoverer.Discover(ctx, servableID)
+	if err != nil {
+		c.store(name, &entry{err: err, expires: c.now().Add(c.negativeTTL)}, c.negativeTTL, generation)
+		return nil, err
+	}
+
+	if c.healthCheck != nil {
+		instances = c.checkInstances(ctx, servableID, instances)
+	}
+	c.store(name, &entry{instances: instances, expires: c.now().Add(c.ttl)}, c.ttl, generation)
+
+	return copyInstances(instances), nil
+}
+
+// store caches entry discovered in generation, entries of generations
+// invalidated in the meantime are stale and aren't cached
+func (c *Cache) store(name string, e *entry, ttl time.Duration, generation uint64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if ttl <= 0 {
+		delete(c.entries, name)
+		return
+	}
+	c.entries[name] = e
+}
+
+// checkInstances checks all instances concurrently and returns healthy ones,
+// instances which change their health are logged
+func (c *Cache) checkInstances(ctx context.Context, servableID app.ServableID, instances []string) []string {
+	errs := make([]error, len(instances))
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance string) {
+			defer wg.Done()
+			errs[i] = c.checkInstance(ctx, instance)
+		}(i, instance)
+	}
+	wg.Wait()
+
+	var healthy, unhealthy []string
+	for i, instance := range instances {
+		result := metrics.ResultSuccess
+		if errs[i] != nil {
+			result = metrics.ResultFailure
+			unhealthy = append(unhealthy, instance)
+		} else {
+			healthy = append(healthy, instance)
+		}
+		metrics.DiscoveryHealthCheckTotal.Inc(servableID.Team, servableID.Project, result)
+	}
+	sort.Strings(unhealthy)
+
+	name := servableID.InstanceName()
+	c.m.Lock()
+	previous := c.unhealthy[name]
+	if len(unhealthy) > 0 {
+		c.unhealthy[name] = unhealthy
+	} else {
+		delete(c.unhealthy, name)
+	}
+	c.m.Unlock()
+	metrics.DiscoveryUnhealthyInstances.Set(float64(len(unhealthy)), servableID.Team, servableID.Project)
+
+	for i, instance := range instances {
+		wasUnhealthy := contains(previous, instance)
+		if errs[i] != nil && !wasUnhealthy {
+			logging.Error(ctx, fmt.Sprintf("%s %s: %v", messageErrorUnhealthyInstance, instance, errs[i]), logUnhealthyInstanceErrorCode)
+		}
+		if errs[i] == nil && wasUnhealthy {
+			logging.Info(ctx, fmt.Sprintf("%s %s", infoInstanceRecovered, instance))
+		}
+	}
+
+	return healthy
+}
+
+func (c *Cache) checkInstance(ctx context.Context, instance string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.healthCheckTimeout)
+	defer cancel()
+
+	conn, err := grpc.Dial(fmt.Sprintf("dns:///%s", instance), grpc.WithInsecure(), grpc.WithBalancerName(roundrobin.Name))
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	defer conn.Close()
+
+	return c.healthCheck(ctx, conn)
+}
+
+// Watch forwards changes noticed by decorated discoverer until ctx is done, cached
+// instances are dropped first, so reload discovers them again. It returns immediately
+// when decorated discoverer doesn't watch instances
+func (c *Cache) Watch(ctx context.Context, changed chan<- struct{}) {
+	w, ok := c.discoverer.(watcher)
+	if !ok {
+		return
+	}
+
+	innerChanged := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Watch(ctx, innerChanged)
+	}()
+
+	for {
+		select {
+		case <-innerChanged:
+			c.invalidate()
+			discovery.Notify(changed)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *Cache) invalidate() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.entries = make(map[string]*entry)
+	c.generation++
+}
+
+// Status returns error reported by decorated discoverer or error listing
+// instances which have failed their last health check
+func (c *Cache) Status() error {
+	if reporter, ok := c.discoverer.(statusReporter); ok {
+		if err := reporter.Status(); err != nil {
+			return err
+		}
+	}
+
+	c.m.Lock()
+	var unhealthy []string
+	for _, instances := range c.unhealthy {
+		unhealthy = append(unhealthy, instances...)
+	}
+	c.m.Unlock()
+	if len(unhealthy) == 0 {
+		return nil
+	}
+	sort.Strings(unhealthy)
+
+	return exterr.NewErrorWithMessage(fmt.Sprintf("%s: %s", messageErrorUnhealthyInstances, strings.Join(unhealthy, ", "))).
+		WithComponent(app.ComponentDiscovery).WithCode(cacheUnhealthyInstancesErrorCode)
+}
+
+// copyInstances keeps cached instances intact when caller modifies returned ones
+func copyInstances(instances []string) []string {
+	if instances == nil {
+		return nil
+	}
+
+	return append([]string(nil), instances...)
+}
+
+func contains(instances []string, instance string) bool {
+	for _, v := range instances {
+		if v == instance {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+	tfsApis "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/apis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var testServable = app.ServableID{Team: "team", Project: "project"}
+
+// fakeDiscoverer returns its instances or error and counts calls of Discover
+type fakeDiscoverer struct {
+	m         sync.Mutex
+	instances []string
+	err       error
+	calls     int
+	changes   chan struct{}
+	status    error
+	// discovering is called by Discover before it returns
+	discovering func()
+}
+
+func (f *fakeDiscoverer) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.calls++
+	if f.discovering != nil {
+		f.discovering()
+	}
+	return f.instances, f.err
+}
+
+func (f *fakeDiscoverer) Watch(ctx context.Context, changed chan<- struct{}) {
+	for {
+		select {
+		case <-f.changes:
+			changed <- struct{}{}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *fakeDiscoverer) Status() error {
+	return f.status
+}
+
+func TestCache_Discover(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		negativeTTL time.Duration
+		err         error
+		elapsed     time.Duration
+		wantCalls   int
+	}{
+		{
+			name:      "Instances should be cached for TTL",
+			ttl:       time.Minute,
+			elapsed:   30 * time.Second,
+			wantCalls: 1,
+		},
+		{
+			name:      "Instances should be discovered again after TTL",
+			ttl:       time.Minute,
+			elapsed:   time.Minute,
+			wantCalls: 2,
+		},
+		{
+			name:      "Instances shouldn't be cached when caching is disabled",
+			wantCalls: 2,
+		},
+		{
+			name:        "Error should be cached for negative TTL",
+			ttl:         time.Minute,
+			negativeTTL: 10 * time.Second,
+			err:         errors.New("discovery failed"),
+			elapsed:     5 * time.Second,
+			wantCalls:   1,
+		},
+		{
+			name:        "Error should be discovered again after negative TTL",
+			ttl:         time.Minute,
+			negativeTTL: 10 * time.Second,
+			err:         errors.New("discovery failed"),
+			elapsed:     10 * time.Second,
+			wantCalls:   2,
+		},
+		{
+			name:      "Error shouldn't be cached when negative caching is disabled",
+			ttl:       time.Minute,
+			err:       errors.New("discovery failed"),
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &fakeDiscoverer{instances: []string{"10.0.0.1:8500"}, err: tt.err}
+			now := time.Now()
+			c := NewCache(inner, tt.ttl, tt.negativeTTL)
+			c.now = func() time.Time { return now }
+
+			for i := 0; i < 2; i++ {
+				got, err := c.Discover(context.Background(), testServable)
+				if (err != nil) != (tt.err != nil) {
+					t.Fatalf("Discover() error = %v, want %v", err, tt.err)
+				}
+				if err == nil && strings.Join(got, ",") != "10.0.0.1:8500" {
+					t.Errorf("Discover() = %v, want [10.0.0.1:8500]", got)
+				}
+				now = now.Add(tt.elapsed)
+			}
+
+			if inner.calls != tt.wantCalls {
+				t.Errorf("Discover() called decorated discoverer %d times, want %d", inner.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// testHealthServer answers grpc.health.v1 checks of the whole server with its status
+type testHealthServer struct {
+	healthpb.HealthServer
+
+	m      sync.Mutex
+	status healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (s *testHealthServer) Check(ctx context.Context, request *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return &healthpb.HealthCheckResponse{Status: s.status}, nil
+}
+
+func (s *testHealthServer) setStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.status = status
+}
+
+// startHealthServer serves grpc.health.v1 protocol, status of server may be changed later on
+func startHealthServer(t *testing.T, status healthpb.HealthCheckResponse_ServingStatus) (string, *testHealthServer) {
+	healthServer := &testHealthServer{status: status}
+	address := testutil.ServeGRPC(t, func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, healthServer)
+	})
+
+	return address, healthServer
+}
+
+// closedAddress returns address which nothing listens on
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	return address
+}
+
+func TestCache_WithHealthCheck(t *testing.T) {
+	serving, _ := startHealthServer(t, healthpb.HealthCheckResponse_SERVING)
+	notServing, notServingHealth := startHealthServer(t, healthpb.HealthCheckResponse_NOT_SERVING)
+	unreachable := closedAddress(t)
+
+	inner := &fakeDiscoverer{instances: []string{serving, notServing, unreachable}}
+	c := NewCache(inner, 0, 0).WithHealthCheck(GRPCHealthCheck, 2*time.Second)
+
+	got, err := c.Discover(context.Background(), testServable)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if strings.Join(got, ",") != serving {
+		t.Errorf("Discover() = %v, want only serving instance %s", got, serving)
+	}
+	err = c.Status()
+	if err == nil || !strings.Contains(err.Error(), notServing) || !strings.Contains(err.Error(), unreachable) {
+		t.Errorf("Status() = %v, want unhealthy instances %s and %s", err, notServing, unreachable)
+	}
+
+	// instance which passes health check again is discovered again
+	notServingHealth.setStatus(healthpb.HealthCheckResponse_SERVING)
+	inner.instances = []string{serving, notServing}
+	got, err = c.Discover(context.Background(), testServable)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if strings.Join(got, ",") != serving+","+notServing {
+		t.Errorf("Discover() = %v, want %s and %s", got, serving, notServing)
+	}
+	if err := c.Status(); err != nil {
+		t.Errorf("Status() = %v, want no unhealthy instances", err)
+	}
+}
+
+func TestCache_Status(t *testing.T) {
+	inner := &fakeDiscoverer{status: errors.New("hosts file is invalid")}
+	if err := NewCache(inner, time.Minute, 0).Status(); err != inner.status {
+		t.Errorf("Status() = %v, want error of decorated discoverer", err)
+	}
+}
+
+// testModelServer answers GetModelStatus requests with given error
+type testModelServer struct {
+	tfsApis.ModelServiceServer
+	err error
+}
+
+func (s *testModelServer) GetModelStatus(ctx context.Context, request *tfsApis.GetModelStatusRequest) (*tfsApis.GetModelStatusResponse, error) {
+	return nil, s.err
+}
+
+func TestModelStatusHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "Missing model should be answered by healthy instance", err: status.Error(codes.NotFound, "model not found")},
+		{name: "Unavailable instance should be unhealthy", err: status.Error(codes.Unavailable, "shutting down"), wantErr: true},
+		{name: "Instance without model service should be unhealthy", err: status.Error(codes.Unimplemented, "unknown service"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := testutil.ServeGRPC(t, func(server *grpc.Server) {
+				tfsApis.RegisterModelServiceServer(server, &testModelServer{err: tt.err})
+			})
+
+			conn, err := grpc.Dial(address, grpc.WithInsecure())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := ModelStatusHealthCheck(ctx, conn); (err != nil) != tt.wantErr {
+				t.Errorf("ModelStatusHealthCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCache_Watch(t *testing.T) {
+	inner := &fakeDiscoverer{instances: []string{"10.0.0.1:8500"}, changes: make(chan struct{})}
+	c := NewCache(inner, time.Hour, 0)
+	if _, err := c.Discover(context.Background(), testServable); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, changed)
+		close(done)
+	}()
+
+	inner.changes <- struct{}{}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() hasn't forwarded change of instances")
+	}
+
+	// changed instances aren't taken from cache
+	if _, err := c.Discover(context.Background(), testServable); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Discover() called decorated discoverer %d times after change, want 2", inner.calls)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't returned after ctx is done")
+	}
+}
+
+func TestCache_invalidate(t *testing.T) {
+	inner := &fakeDiscoverer{instances: []string{"10.0.0.1:8500"}}
+	c := NewCache(inner, time.Hour, 0)
+
+	// change noticed while discovery is running makes its result stale
+	inner.discovering = c.invalidate
+	if _, err := c.Discover(context.Background(), testServable); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	inner.discovering = nil
+
+	if _, err := c.Discover(context.Background(), testServable); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Discover() called decorated discoverer %d times, want 2 as stale result isn't cached", inner.calls)
+	}
+
+	if _, err := c.Discover(context.Background(), testServable); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("Discover() called decorated discoverer %d times, want 2 as fresh result is cached", inner.calls)
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	tfsApis "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/apis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// HealthCheckNone disables health checks of discovered instances
+	HealthCheckNone = "none"
+	// HealthCheckGRPC checks instances with grpc.health.v1 protocol
+	HealthCheckGRPC = "grpc"
+	// HealthCheckModelStatus checks instances with GetModelStatus request
+	HealthCheckModelStatus = "model_status"
+
+	// healthCheckModelName is asked for by GetModelStatus, it doesn't have to be served
+	healthCheckModelName = "tfd-health-check"
+)
+
+var (
+	healthNotServingErrorCode = 1017
+
+	errNotServing = exterr.NewErrorWithMessage("instance isn't serving").WithComponent(app.ComponentDiscovery).WithCode(healthNotServingErrorCode)
+)
+
+// HealthCheck checks single instance over its gRPC connection, error means that instance is unhealthy
+type HealthCheck func(ctx context.Context, conn *grpc.ClientConn) error
+
+// GRPCHealthCheck checks that instance reports SERVING status of the whole server with grpc.health.v1 protocol
+func GRPCHealthCheck(ctx context.Context, conn *grpc.ClientConn) error {
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return errNotServing
+	}
+
+	return nil
+}
+
+// ModelStatusHealthCheck checks that instance answers GetModelStatus requests, TFS which
+// doesn't serve asked model answers too, so health check doesn't depend on served models
+func ModelStatusHealthCheck(ctx context.Context, conn *grpc.ClientConn) error {
+	request := &tfsApis.GetModelStatusRequest{ModelSpec: &tfsApis.ModelSpec{Name: healthCheckModelName}}
+	_, err := tfsApis.NewModelServiceClient(conn).GetModelStatus(ctx, request)
+	switch status.Code(err) {
+	case codes.OK, codes.NotFound, codes.InvalidArgument, codes.FailedPrecondition:
+		return nil
+	}
+
+	return exterr.WrapWithFrame(err)
+}
@@ -0,0 +1,207 @@
+package composite
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	// ModeUnion returns instances of all sources, failed sources are skipped
+	ModeUnion = "union"
+	// ModeFirstNonEmpty returns instances of the first source which has discovered any
+	ModeFirstNonEmpty = "first_non_empty"
+	// ModePriority returns instances of the first source which hasn't failed, even when it has discovered none
+	ModePriority = "priority"
+)
+
+var (
+	compositeInvalidModeErrorCode    = 1019
+	compositeNoSourcesErrorCode      = 1020
+	compositeSourceStatusErrorCode   = 1021
+	compositeAllSourcesFailErrorCode = 1022
+	logSourceFailedErrorCode         = "1023"
+
+	messageErrorSourceFailed = "discovery source has failed"
+
+	errInvalidMode    = exterr.NewErrorWithMessage("composite discovery mode is invalid").WithComponent(app.ComponentDiscovery).WithCode(compositeInvalidModeErrorCode)
+	errNoSources      = exterr.NewErrorWithMessage("composite discovery has no sources").WithComponent(app.ComponentDiscovery).WithCode(compositeNoSourcesErrorCode)
+	errAllSourcesFail = exterr.NewErrorWithMessage("all sources of composite discovery have failed").WithComponent(app.ComponentDiscovery).WithCode(compositeAllSourcesFailErrorCode)
+)
+
+// Discoverer is a source of Composite
+type Discoverer interface {
+	Discover(ctx context.Context, servableID app.ServableID) ([]string, error)
+}
+
+type watcher interface {
+	Watch(ctx context.Context, changed chan<- struct{})
+}
+
+type statusReporter interface {
+	Status() error
+}
+
+// Source is named discoverer chained by Composite, name is used in logs and status
+type Source struct {
+	Name       string
+	Discoverer Discoverer
+}
+
+// Composite discovers TFS instances with several sources, mode decides which
+// of them are used. Instances are returned without duplicates in order of sources
+type Composite struct {
+	mode    string
+	sources []Source
+}
+
+// NewComposite creates instance of discovery-Composite, sources are given in order of their priority
+func NewComposite(ctx context.Context, mode string, sources []Source) (*Composite, error) {
+	switch mode {
+	case ModeUnion, ModeFirstNonEmpty, ModePriority:
+	default:
+		return nil, exterr.WrapWithErr(fmt.Errorf("%s", mode), errInvalidMode)
+	}
+	if len(sources) == 0 {
+		return nil, errNoSources
+	}
+
+	return &Composite{mode: mode, sources: sources}, nil
+}
+
+// Discover returns instances of sources chosen by mode, it fails only when no source
+// gives result, failures of other sources are logged
+func (c *Composite) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	if c.mode == ModeUnion {
+		return c.discoverUnion(ctx, servableID)
+	}
+
+	var lastErr error
+	answered := false
+	for _, source := range c.sources {
+		instances, err := source.Discoverer.Discover(ctx, servableID)
+		if err != nil {
+			lastErr = c.sourceFailed(ctx, source, err)
+			continue
+		}
+		answered = true
+		if c.mode == ModePriority || len(instances) > 0 {
+			return dedupe(instances), nil
+		}
+	}
+	if !answered {
+		return nil, exterr.WrapWithErr(lastErr, errAllSourcesFail)
+	}
+
+	return nil, nil
+}
+
+// discoverUnion queries all sources concurrently and merges their instances
+func (c *Composite) discoverUnion(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	results := make([][]string, len(c.sources))
+	errs := make([]error, len(c.sources))
+	var wg sync.WaitGroup
+	for i, source := range c.sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			results[i], errs[i] = source.Discoverer.Discover(ctx, servableID)
+		}(i, source)
+	}
+	wg.Wait()
+
+	var instances []string
+	var lastErr error
+	answered := false
+	for i, source := range c.sources {
+		if errs[i] != nil {
+			lastErr = c.sourceFailed(ctx, source, errs[i])
+			continue
+		}
+		answered = true
+		instances = append(instances, results[i]...)
+	}
+	if !answered {
+		return nil, exterr.WrapWithErr(lastErr, errAllSourcesFail)
+	}
+
+	return dedupe(instances), nil
+}
+
+// sourceFailed logs error of source and returns it
+func (c *Composite) sourceFailed(ctx context.Context, source Source, err error) error {
+	logging.Error(ctx, fmt.Sprintf("%s %s: %v", messageErrorSourceFailed, source.Name, err), logSourceFailedErrorCode)
+
+	return err
+}
+
+// Watch runs watches of all sources which are able to notify about changes until ctx is done
+func (c *Composite) Watch(ctx context.Context, changed chan<- struct{}) {
+	var wg sync.WaitGroup
+	for _, source := range c.sources {
+		w, ok := source.Discoverer.(watcher)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Watch(ctx, changed)
+		}()
+	}
+	wg.Wait()
+}
+
+// Status returns errors reported by sources, prefixed with names of sources
+func (c *Composite) Status() error {
+	var messages []string
+	for _, source := range c.sources {
+		reporter, ok := source.Discoverer.(statusReporter)
+		if !ok {
+			continue
+		}
+		if err := reporter.Status(); err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", source.Name, err))
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return exterr.NewErrorWithMessage(strings.Join(messages, "; ")).WithComponent(app.ComponentDiscovery).WithCode(compositeSourceStatusErrorCode)
+}
+
+// dedupe removes duplicates of host:port keeping the first one, hosts are compared
+// case-insensitively and IP addresses in canonical form
+func dedupe(instances []string) []string {
+	seen := make(map[string]struct{}, len(instances))
+	var result []string
+	for _, instance := range instances {
+		key := normalize(instance)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, instance)
+	}
+
+	return result
+}
+
+func normalize(instance string) string {
+	host, port, err := net.SplitHostPort(instance)
+	if err != nil {
+		return strings.ToLower(instance)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+
+	return net.JoinHostPort(strings.ToLower(strings.TrimSuffix(host, ".")), port)
+}
@@ -0,0 +1,176 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+// fakeSource returns its instances or error
+type fakeSource struct {
+	instances []string
+	err       error
+	status    error
+	changes   chan struct{}
+}
+
+func (f *fakeSource) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	return f.instances, f.err
+}
+
+func (f *fakeSource) Status() error {
+	return f.status
+}
+
+// watchingSource notifies about every change sent to it
+type watchingSource struct {
+	fakeSource
+}
+
+func (f *watchingSource) Watch(ctx context.Context, changed chan<- struct{}) {
+	for {
+		select {
+		case <-f.changes:
+			changed <- struct{}{}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func TestComposite_Discover(t *testing.T) {
+	failed := &fakeSource{err: errors.New("source failed")}
+	empty := &fakeSource{}
+	plaintext := &fakeSource{instances: []string{"10.0.0.1:8500", "tfs-1.example.com:8500", "[fd00::1]:8500"}}
+	dns := &fakeSource{instances: []string{"10.0.0.2:8500", "TFS-1.example.com.:8500", "[fd00:0::1]:8500", "10.0.0.1:8500"}}
+
+	tests := []struct {
+		name    string
+		mode    string
+		sources []Discoverer
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Union should merge instances of all sources without duplicates",
+			mode:    ModeUnion,
+			sources: []Discoverer{plaintext, dns},
+			want:    []string{"10.0.0.1:8500", "tfs-1.example.com:8500", "[fd00::1]:8500", "10.0.0.2:8500"},
+		},
+		{
+			name:    "Union should skip failed sources",
+			mode:    ModeUnion,
+			sources: []Discoverer{failed, dns},
+			want:    []string{"10.0.0.2:8500", "TFS-1.example.com.:8500", "[fd00:0::1]:8500", "10.0.0.1:8500"},
+		},
+		{
+			name:    "Union should fail when all sources fail",
+			mode:    ModeUnion,
+			sources: []Discoverer{failed, failed},
+			wantErr: true,
+		},
+		{
+			name:    "First non-empty should skip failed and empty sources",
+			mode:    ModeFirstNonEmpty,
+			sources: []Discoverer{failed, empty, dns, plaintext},
+			want:    []string{"10.0.0.2:8500", "TFS-1.example.com.:8500", "[fd00:0::1]:8500", "10.0.0.1:8500"},
+		},
+		{
+			name:    "First non-empty should return no instances when all sources are empty",
+			mode:    ModeFirstNonEmpty,
+			sources: []Discoverer{failed, empty},
+		},
+		{
+			name:    "Priority should use empty source which hasn't failed",
+			mode:    ModePriority,
+			sources: []Discoverer{failed, empty, plaintext},
+		},
+		{
+			name:    "Priority should fail when all sources fail",
+			mode:    ModePriority,
+			sources: []Discoverer{failed},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []Source
+			for i, source := range tt.sources {
+				sources = append(sources, Source{Name: string(rune('a' + i)), Discoverer: source})
+			}
+			c, err := NewComposite(context.Background(), tt.mode, sources)
+			if err != nil {
+				t.Fatalf("NewComposite() error = %v", err)
+			}
+
+			got, err := c.Discover(context.Background(), app.ServableID{Team: "team", Project: "project"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewComposite(t *testing.T) {
+	sources := []Source{{Name: "dns", Discoverer: &fakeSource{}}}
+	if _, err := NewComposite(context.Background(), "all", sources); err == nil {
+		t.Error("NewComposite() of unknown mode should fail")
+	}
+	if _, err := NewComposite(context.Background(), ModeUnion, nil); err == nil {
+		t.Error("NewComposite() without sources should fail")
+	}
+}
+
+func TestComposite_Status(t *testing.T) {
+	c, err := NewComposite(context.Background(), ModeUnion, []Source{
+		{Name: "plaintext", Discoverer: &fakeSource{status: errors.New("hosts file is invalid")}},
+		{Name: "dns", Discoverer: &fakeSource{}},
+	})
+	if err != nil {
+		t.Fatalf("NewComposite() error = %v", err)
+	}
+
+	if err := c.Status(); err == nil || !strings.Contains(err.Error(), "plaintext: hosts file is invalid") {
+		t.Errorf("Status() = %v, want error of plaintext source", err)
+	}
+}
+
+func TestComposite_Watch(t *testing.T) {
+	watching := &watchingSource{fakeSource{changes: make(chan struct{})}}
+	c, err := NewComposite(context.Background(), ModeUnion, []Source{
+		{Name: "dns", Discoverer: &fakeSource{}},
+		{Name: "kubernetes", Discoverer: watching},
+	})
+	if err != nil {
+		t.Fatalf("NewComposite() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, changed)
+		close(done)
+	}()
+
+	watching.changes <- struct{}{}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't forwarded change of watching source")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't returned after ctx is done")
+	}
+}
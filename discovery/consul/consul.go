@@ -0,0 +1,222 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/discovery"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	healthServicePath  = "/v1/health/service/"
+	tokenHeader        = "X-Consul-Token"
+	indexHeader        = "X-Consul-Index"
+	requestTimeout     = 10 * time.Second
+	watchRetryInterval = 5 * time.Second
+	infoWatchStart     = "blocking query of consul service start"
+)
+
+var (
+	consulInvalidAddressErrorCode  = 1013
+	consulResponseStatusErrorCode  = 1014
+	consulInvalidResponseErrorCode = 1015
+
+	errInvalidAddress  = exterr.NewErrorWithMessage("consul address is invalid").WithComponent(app.ComponentDiscovery).WithCode(consulInvalidAddressErrorCode)
+	errResponseStatus  = exterr.NewErrorWithMessage("consul API response status is invalid").WithComponent(app.ComponentDiscovery).WithCode(consulResponseStatusErrorCode)
+	errInvalidResponse = exterr.NewErrorWithMessage("consul API response is invalid").WithComponent(app.ComponentDiscovery).WithCode(consulInvalidResponseErrorCode)
+)
+
+// Consul discovers TFS instances as healthy instances of Consul services
+// named after instance name of servable
+type Consul struct {
+	address    string
+	datacenter string
+	token      string
+	tags       []string
+	waitTime   time.Duration
+	client     *http.Client
+	// watches of discovered services, nil when watch isn't enabled
+	watches *discovery.Watches
+}
+
+// NewConsul creates instance of discovery-Consul, instances have to be registered with all
+// given tags, watch makes blocking queries notice changes of discovered services
+func NewConsul(ctx context.Context, address, datacenter, token string, tags []string, watch bool, waitTime time.Duration) (*Consul, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, exterr.WrapWithErr(err, errInvalidAddress)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errInvalidAddress
+	}
+
+	c := &Consul{
+		address:    strings.TrimSuffix(address, "/"),
+		datacenter: datacenter,
+		token:      token,
+		tags:       tags,
+		waitTime:   waitTime,
+		client:     &http.Client{},
+	}
+	if watch {
+		c.watches = discovery.NewWatches(func(ctx context.Context, resource interface{}, changed chan<- struct{}) {
+			c.watchService(ctx, resource.(string), changed)
+		})
+	}
+
+	return c, nil
+}
+
+// Discover returns addresses of instances of service which pass all their health checks
+func (c *Consul) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	service := servableID.InstanceName()
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	instances, _, err := c.healthyInstances(ctx, service, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.watches != nil {
+		c.watches.Register(service)
+	}
+
+	return instances, nil
+}
+
+type serviceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// healthyInstances queries health API for instances of service, when index is greater than
+// zero query blocks until index of service changes or wait time elapses. It returns index
+// of service which next blocking query should wait for
+func (c *Consul) healthyInstances(ctx context.Context, service string, index uint64) ([]string, uint64, error) {
+	query := url.Values{"passing": {"true"}}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	for _, tag := range c.tags {
+		query.Add("tag", tag)
+	}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(c.waitTime.Seconds())))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.address+healthServicePath+url.PathEscape(service)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, exterr.WrapWithFrame(err)
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set(tokenHeader, c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, exterr.WrapWithFrame(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, exterr.WrapWithErr(fmt.Errorf("%s %s: %s", service, resp.Status, message), errResponseStatus)
+	}
+
+	var entries []serviceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, exterr.WrapWithErr(err, errInvalidResponse)
+	}
+
+	// index is only used by blocking queries, so missing index doesn't fail discovery
+	newIndex, _ := strconv.ParseUint(resp.Header.Get(indexHeader), 10, 64)
+
+	var instances []string
+	for _, entry := range entries {
+		// address of service defaults to address of its node
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		instances = append(instances, net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)))
+	}
+
+	return discovery.Unique(instances), newIndex, nil
+}
+
+// Watch runs blocking queries of services discovered so far and discovered later on
+// until ctx is done, it returns immediately when watch isn't enabled
+func (c *Consul) Watch(ctx context.Context, changed chan<- struct{}) {
+	if c.watches == nil {
+		return
+	}
+
+	c.watches.Run(ctx, changed)
+}
+
+// watchService notifies about every change of index of service
+func (c *Consul) watchService(ctx context.Context, service string, changed chan<- struct{}) {
+	logging.Info(ctx, fmt.Sprintf("%s %s", infoWatchStart, service))
+
+	var index uint64
+	for {
+		// blocking query may last a bit longer than wait time
+		queryCtx, cancel := context.WithTimeout(ctx, c.waitTime+requestTimeout)
+		_, newIndex, err := c.healthyInstances(queryCtx, service, index)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logging.ErrorWithStackWithoutRequestID(ctx, err)
+			if !wait(ctx, watchRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		if index > 0 && newIndex != index {
+			discovery.Notify(changed)
+		}
+		// index going backwards means that it has been reset, so waiting starts over
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		// query without index doesn't block, so it isn't repeated immediately
+		if index == 0 && !wait(ctx, watchRetryInterval) {
+			return
+		}
+	}
+}
+
+// wait returns false when ctx is done before given duration elapses
+func wait(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
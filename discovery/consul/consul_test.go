@@ -0,0 +1,234 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+const (
+	testToken      = "token"
+	testDatacenter = "dc1"
+)
+
+type fakeInstance struct {
+	node    string
+	address string
+	port    int
+	tags    []string
+	passing bool
+}
+
+// fakeConsul serves health API of single datacenter, blocking queries
+// wait until instances are updated or request is canceled
+type fakeConsul struct {
+	m         sync.Mutex
+	index     uint64
+	instances map[string][]fakeInstance
+	updated   chan struct{}
+}
+
+func newFakeConsul(instances map[string][]fakeInstance) *fakeConsul {
+	return &fakeConsul{index: 10, instances: instances, updated: make(chan struct{})}
+}
+
+func (f *fakeConsul) update(service string, instances []fakeInstance) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.index++
+	f.instances[service] = instances
+	close(f.updated)
+	f.updated = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(tokenHeader) != testToken {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+	if dc := r.URL.Query().Get("dc"); dc != "" && dc != testDatacenter {
+		http.Error(w, "No path to datacenter", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("passing") != "true" {
+		http.Error(w, "passing filter is expected", http.StatusBadRequest)
+		return
+	}
+
+	f.m.Lock()
+	index, updated := f.index, f.updated
+	f.m.Unlock()
+	if r.URL.Query().Get("index") == strconv.FormatUint(index, 10) {
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	entries := []serviceEntry{}
+	for _, instance := range f.instances[strings.TrimPrefix(r.URL.Path, healthServicePath)] {
+		if !instance.passing || !hasTags(instance.tags, r.URL.Query()["tag"]) {
+			continue
+		}
+		var entry serviceEntry
+		entry.Node.Address = instance.node
+		entry.Service.Address = instance.address
+		entry.Service.Port = instance.port
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set(indexHeader, strconv.FormatUint(f.index, 10))
+	json.NewEncoder(w).Encode(entries)
+}
+
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, t := range tags {
+			found = found || t == r
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+var testInstances = map[string][]fakeInstance{
+	"tfs-team-project": {
+		{node: "10.0.0.1", address: "10.0.1.1", port: 8500, tags: []string{"tfs", "prod"}, passing: true},
+		{node: "10.0.0.2", port: 8500, tags: []string{"tfs"}, passing: true},
+		{node: "10.0.0.3", port: 8500, tags: []string{"tfs", "prod"}},
+	},
+}
+
+func TestConsul_Discover(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		datacenter string
+		tags       []string
+		servable   app.ServableID
+		want       []string
+		wantErr    bool
+	}{
+		{
+			name:     "Passing instances should be discovered with node address when service has no address",
+			token:    testToken,
+			servable: app.ServableID{Team: "team", Project: "project"},
+			want:     []string{"10.0.0.2:8500", "10.0.1.1:8500"},
+		},
+		{
+			name:       "Instances should be filtered by all tags",
+			token:      testToken,
+			datacenter: testDatacenter,
+			tags:       []string{"tfs", "prod"},
+			servable:   app.ServableID{Team: "team", Project: "project"},
+			want:       []string{"10.0.1.1:8500"},
+		},
+		{
+			name:     "Unknown service should have no instances",
+			token:    testToken,
+			servable: app.ServableID{Team: "team", Project: "other"},
+		},
+		{
+			name:       "Unknown datacenter should be reported",
+			token:      testToken,
+			datacenter: "dc2",
+			servable:   app.ServableID{Team: "team", Project: "project"},
+			wantErr:    true,
+		},
+		{
+			name:     "Invalid ACL token should be reported",
+			token:    "invalid",
+			servable: app.ServableID{Team: "team", Project: "project"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(newFakeConsul(testInstances))
+			defer server.Close()
+
+			c, err := NewConsul(context.Background(), server.URL, tt.datacenter, tt.token, tt.tags, false, time.Second)
+			if err != nil {
+				t.Fatalf("NewConsul() error = %v", err)
+			}
+
+			got, err := c.Discover(context.Background(), tt.servable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsul_Watch(t *testing.T) {
+	fake := newFakeConsul(map[string][]fakeInstance{
+		"tfs-team-project": {{node: "10.0.0.1", port: 8500, passing: true}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := NewConsul(context.Background(), server.URL, "", testToken, nil, true, time.Minute)
+	if err != nil {
+		t.Fatalf("NewConsul() error = %v", err)
+	}
+	if _, err := c.Discover(context.Background(), app.ServableID{Team: "team", Project: "project"}); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		c.Watch(ctx, changed)
+		close(done)
+	}()
+
+	// blocking query doesn't return until service changes
+	select {
+	case <-changed:
+		t.Error("Watch() has notified before service changed")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	fake.update("tfs-team-project", []fakeInstance{{node: "10.0.0.2", port: 8500, passing: true}})
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't notified about changed service")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't returned after ctx is done")
+	}
+}
+
+func TestNewConsul(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:8500", "ftp://127.0.0.1", "http://"} {
+		if _, err := NewConsul(context.Background(), address, "", "", nil, false, time.Second); err == nil {
+			t.Errorf("NewConsul() of address %s should fail", address)
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package discovery
 
 import (
+	"sort"
+
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 )
 
 const (
-	UnknownPackage   = "unknown"
-	PlaintextPackage = "plaintext"
-	DNSPackage       = "dns"
+	UnknownPackage    = "unknown"
+	PlaintextPackage  = "plaintext"
+	DNSPackage        = "dns"
+	KubernetesPackage = "kubernetes"
+	ConsulPackage     = "consul"
+	CompositePackage  = "composite"
 )
 
 var (
@@ -19,6 +24,9 @@ var (
 var packages = []string{
 	DNSPackage,
 	PlaintextPackage,
+	KubernetesPackage,
+	ConsulPackage,
+	CompositePackage,
 }
 
 // Package returns package for given string
@@ -35,3 +43,18 @@ func Package(packageID string) (string, error) {
 func ListDiscoveries() []string {
 	return packages
 }
+
+// Unique returns sorted instances without duplicates, instance may be
+// listed more than once by sources while they are updated
+func Unique(instances []string) []string {
+	sort.Strings(instances)
+
+	result := instances[:0]
+	for i, instance := range instances {
+		if i == 0 || instance != instances[i-1] {
+			result = append(result, instance)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,264 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	inClusterTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAPath    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	inClusterHostEnv   = "KUBERNETES_SERVICE_HOST"
+	inClusterPortEnv   = "KUBERNETES_SERVICE_PORT"
+)
+
+// client sends requests to Kubernetes API
+type client struct {
+	server    string
+	http      *http.Client
+	token     string
+	tokenFile string
+}
+
+// newInClusterClient creates client authenticated with token of service account of pod
+func newInClusterClient() (*client, error) {
+	host, port := os.Getenv(inClusterHostEnv), os.Getenv(inClusterPortEnv)
+	if host == "" || port == "" {
+		return nil, errNotInCluster
+	}
+
+	tlsConfig, err := newTLSConfig(inClusterCAPath, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// bound service account tokens are rotated, so token is read before every request
+	return &client{
+		server:    "https://" + net.JoinHostPort(host, port),
+		http:      newHTTPClient(tlsConfig),
+		tokenFile: inClusterTokenPath,
+	}, nil
+}
+
+// kubeconfig is the subset of kubeconfig file used to access Kubernetes API
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// newKubeconfigClient creates client using cluster and user of current context of kubeconfig file
+func newKubeconfigClient(path string) (*client, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, exterr.WrapWithErr(err, errInvalidKubeconfig)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, c := range config.Contexts {
+		if c.Name == config.CurrentContext {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, exterr.WrapWithErr(fmt.Errorf("context %q not found", config.CurrentContext), errInvalidKubeconfig)
+	}
+
+	c := &client{}
+	// relative paths of files are resolved against directory of kubeconfig
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(filepath.Dir(path), file)
+	}
+
+	var tlsConfig *tls.Config
+	found = false
+	for _, cl := range config.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		found = true
+		c.server = strings.TrimSuffix(cl.Cluster.Server, "/")
+
+		var ca []byte
+		if cl.Cluster.CertificateAuthorityData != "" {
+			if ca, err = base64.StdEncoding.DecodeString(cl.Cluster.CertificateAuthorityData); err != nil {
+				return nil, exterr.WrapWithErr(err, errInvalidKubeconfig)
+			}
+		}
+		if tlsConfig, err = newTLSConfig(resolve(cl.Cluster.CertificateAuthority), ca, cl.Cluster.InsecureSkipTLSVerify); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if !found || c.server == "" {
+		return nil, exterr.WrapWithErr(fmt.Errorf("server of cluster %q not found", clusterName), errInvalidKubeconfig)
+	}
+
+	for _, u := range config.Users {
+		if u.Name != userName {
+			continue
+		}
+		c.token, c.tokenFile = u.User.Token, resolve(u.User.TokenFile)
+
+		cert, err := readData(resolve(u.User.ClientCertificate), u.User.ClientCertificateData)
+		if err != nil {
+			return nil, err
+		}
+		key, err := readData(resolve(u.User.ClientKey), u.User.ClientKeyData)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil || key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, exterr.WrapWithErr(err, errInvalidKubeconfig)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+		break
+	}
+
+	c.http = newHTTPClient(tlsConfig)
+
+	return c, nil
+}
+
+// readData returns decoded data embedded in kubeconfig or content of file
+func readData(file, data string) ([]byte, error) {
+	if data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, exterr.WrapWithErr(err, errInvalidKubeconfig)
+		}
+		return decoded, nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return content, nil
+}
+
+func newTLSConfig(caFile string, ca []byte, insecure bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+
+	if ca == nil && caFile != "" {
+		content, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		ca = content
+	}
+	if ca != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, exterr.WrapWithErr(fmt.Errorf("certificate authority is invalid"), errInvalidKubeconfig)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// newHTTPClient returns client without timeout, as watch requests are long running,
+// requests are bound by their contexts
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}
+}
+
+// get sends GET request to given path of Kubernetes API, body of response
+// has to be closed by caller when returned error is nil
+func (c *client) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		content, err := ioutil.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errNotFound
+	default:
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, exterr.WrapWithErr(fmt.Errorf("%s %s: %s", path, resp.Status, message), errResponseStatus)
+	}
+}
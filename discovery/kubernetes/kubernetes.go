@@ -0,0 +1,343 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/discovery"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	endpointSlicesPath    = "/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices"
+	endpointsPath         = "/api/v1/namespaces/%s/endpoints"
+	serviceNameLabel      = "kubernetes.io/service-name"
+	requestTimeout        = 10 * time.Second
+	watchTimeoutInSec     = 300
+	watchRetryInterval    = 5 * time.Second
+	watchEventError       = "ERROR"
+	watchEventBookmark    = "BOOKMARK"
+	infoWatchStart        = "watch of kubernetes endpoints start"
+	infoWatchResourceGone = "resource version of watch of kubernetes endpoints is too old, restarting"
+)
+
+var (
+	kubernetesNotInClusterErrorCode      = 1008
+	kubernetesInvalidKubeconfigErrorCode = 1009
+	kubernetesResponseStatusErrorCode    = 1010
+	kubernetesNotFoundErrorCode          = 1011
+	kubernetesInvalidTemplateErrorCode   = 1012
+
+	errNotInCluster      = exterr.NewErrorWithMessage("kubernetes service host and port are not set, kubeconfig is required outside of cluster").WithComponent(app.ComponentDiscovery).WithCode(kubernetesNotInClusterErrorCode)
+	errInvalidKubeconfig = exterr.NewErrorWithMessage("kubeconfig is invalid").WithComponent(app.ComponentDiscovery).WithCode(kubernetesInvalidKubeconfigErrorCode)
+	errResponseStatus    = exterr.NewErrorWithMessage("kubernetes API response status is invalid").WithComponent(app.ComponentDiscovery).WithCode(kubernetesResponseStatusErrorCode)
+	errNotFound          = exterr.NewErrorWithMessage("kubernetes resource not found").WithComponent(app.ComponentDiscovery).WithCode(kubernetesNotFoundErrorCode)
+	errInvalidTemplate   = exterr.NewErrorWithMessage("namespace template is invalid").WithComponent(app.ComponentDiscovery).WithCode(kubernetesInvalidTemplateErrorCode)
+)
+
+// Kubernetes discovers TFS instances as ready endpoints of Kubernetes services named
+// after instance name of servable. EndpointSlices are read when cluster serves them,
+// Endpoints otherwise
+type Kubernetes struct {
+	client    *client
+	namespace *template.Template
+	portName  string
+	// watches of discovered services, nil when watch isn't enabled
+	watches *discovery.Watches
+
+	m sync.Mutex
+	// endpoints is set when cluster doesn't serve discovery.k8s.io/v1 API
+	endpoints bool
+}
+
+type service struct {
+	namespace string
+	name      string
+}
+
+// NewKubernetes creates instance of discovery-Kubernetes, Kubernetes API is accessed with
+// kubeconfig file when its path is given or with in-cluster credentials otherwise
+func NewKubernetes(ctx context.Context, kubeconfigPath, namespaceTemplate, portName string, watch bool) (*Kubernetes, error) {
+	namespace, err := template.New("namespace").Option("missingkey=error").Parse(namespaceTemplate)
+	if err != nil {
+		return nil, exterr.WrapWithErr(err, errInvalidTemplate)
+	}
+
+	var c *client
+	if kubeconfigPath != "" {
+		c, err = newKubeconfigClient(kubeconfigPath)
+	} else {
+		c, err = newInClusterClient()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Kubernetes{
+		client:    c,
+		namespace: namespace,
+		portName:  portName,
+	}
+	if watch {
+		k.watches = discovery.NewWatches(func(ctx context.Context, resource interface{}, changed chan<- struct{}) {
+			k.watchService(ctx, resource.(service), changed)
+		})
+	}
+
+	return k, nil
+}
+
+// Discover returns addresses of ready endpoints of service named after instance name of servable
+func (k *Kubernetes) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
+	var namespace bytes.Buffer
+	if err := k.namespace.Execute(&namespace, servableID); err != nil {
+		return nil, exterr.WrapWithErr(err, errInvalidTemplate)
+	}
+	svc := service{namespace: namespace.String(), name: servableID.InstanceName()}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	instances, err := k.list(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+	if k.watches != nil {
+		k.watches.Register(svc)
+	}
+
+	return instances, nil
+}
+
+func (k *Kubernetes) useEndpoints() bool {
+	k.m.Lock()
+	defer k.m.Unlock()
+
+	return k.endpoints
+}
+
+func (k *Kubernetes) list(ctx context.Context, svc service) ([]string, error) {
+	if !k.useEndpoints() {
+		instances, err := k.listEndpointSlices(ctx, svc)
+		if err != errNotFound {
+			return instances, err
+		}
+
+		k.m.Lock()
+		k.endpoints = true
+		k.m.Unlock()
+	}
+
+	return k.listEndpoints(ctx, svc)
+}
+
+type endpointSliceList struct {
+	Items []endpointSlice `json:"items"`
+}
+
+type endpointSlice struct {
+	AddressType string `json:"addressType"`
+	Endpoints   []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []endpointPort `json:"ports"`
+}
+
+type endpointPort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+func (k *Kubernetes) listEndpointSlices(ctx context.Context, svc service) ([]string, error) {
+	query := url.Values{"labelSelector": {serviceNameLabel + "=" + svc.name}}
+
+	var list endpointSliceList
+	if err := k.getJSON(ctx, fmt.Sprintf(endpointSlicesPath, url.PathEscape(svc.namespace)), query, &list); err != nil {
+		return nil, err
+	}
+
+	var instances []string
+	for _, slice := range list.Items {
+		// FQDN addresses aren't used by TFS services
+		if slice.AddressType != "IPv4" && slice.AddressType != "IPv6" {
+			continue
+		}
+		port, ok := k.port(slice.Ports)
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			// unknown readiness should be interpreted as ready
+			if ready := endpoint.Conditions.Ready; ready != nil && !*ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				instances = append(instances, net.JoinHostPort(address, port))
+			}
+		}
+	}
+
+	return discovery.Unique(instances), nil
+}
+
+type endpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []endpointPort `json:"ports"`
+	} `json:"subsets"`
+}
+
+func (k *Kubernetes) listEndpoints(ctx context.Context, svc service) ([]string, error) {
+	var e endpoints
+	if err := k.getJSON(ctx, fmt.Sprintf(endpointsPath, url.PathEscape(svc.namespace))+"/"+url.PathEscape(svc.name), nil, &e); err != nil {
+		return nil, err
+	}
+
+	// not ready addresses are listed separately, so all addresses are ready
+	var instances []string
+	for _, subset := range e.Subsets {
+		port, ok := k.port(subset.Ports)
+		if !ok {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			instances = append(instances, net.JoinHostPort(address.IP, port))
+		}
+	}
+
+	return discovery.Unique(instances), nil
+}
+
+func (k *Kubernetes) port(ports []endpointPort) (string, bool) {
+	for _, p := range ports {
+		if p.Name == k.portName {
+			return strconv.Itoa(p.Port), true
+		}
+	}
+
+	return "", false
+}
+
+func (k *Kubernetes) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	body, err := k.client.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// Watch watches endpoints of services discovered so far and discovered later on
+// until ctx is done, it returns immediately when watch isn't enabled
+func (k *Kubernetes) Watch(ctx context.Context, changed chan<- struct{}) {
+	if k.watches == nil {
+		return
+	}
+
+	k.watches.Run(ctx, changed)
+}
+
+type watchEvent struct {
+	Type   string `json:"type"`
+	Object struct {
+		Code     int `json:"code"`
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	} `json:"object"`
+}
+
+// watchService notifies about every change of endpoints of service, watch
+// is restarted when it is closed by server or fails
+func (k *Kubernetes) watchService(ctx context.Context, svc service, changed chan<- struct{}) {
+	path := fmt.Sprintf(endpointSlicesPath, url.PathEscape(svc.namespace))
+	query := url.Values{"labelSelector": {serviceNameLabel + "=" + svc.name}}
+	if k.useEndpoints() {
+		path = fmt.Sprintf(endpointsPath, url.PathEscape(svc.namespace))
+		query = url.Values{"fieldSelector": {"metadata.name=" + svc.name}}
+	}
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", strconv.Itoa(watchTimeoutInSec))
+
+	logging.Info(ctx, fmt.Sprintf("%s %s/%s", infoWatchStart, svc.namespace, svc.name))
+
+	resourceVersion := ""
+	for {
+		query.Set("resourceVersion", resourceVersion)
+		var err error
+		resourceVersion, err = k.watchEvents(ctx, path, query, resourceVersion, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		logging.ErrorWithStackWithoutRequestID(ctx, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// watchEvents reads events of single watch request and returns resource version
+// which next watch should be started from
+func (k *Kubernetes) watchEvents(ctx context.Context, path string, query url.Values, resourceVersion string, changed chan<- struct{}) (string, error) {
+	body, err := k.client.get(ctx, path, query)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil || err == io.EOF {
+				return resourceVersion, nil
+			}
+			return resourceVersion, exterr.WrapWithFrame(err)
+		}
+
+		switch event.Type {
+		case watchEventError:
+			if event.Object.Code == http.StatusGone {
+				logging.Info(ctx, infoWatchResourceGone)
+				// events may have been missed, so instances are checked again
+				discovery.Notify(changed)
+				return "", nil
+			}
+			return resourceVersion, exterr.WrapWithErr(fmt.Errorf("watch %s: status %d", path, event.Object.Code), errResponseStatus)
+		case watchEventBookmark:
+		default:
+			discovery.Notify(changed)
+		}
+		resourceVersion = event.Object.Metadata.ResourceVersion
+	}
+}
@@ -0,0 +1,259 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+)
+
+const testToken = "token"
+
+// fakeAPI serves endpoints of single service in namespace team-ns
+type fakeAPI struct {
+	noSlices bool
+}
+
+func (f fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/apis/discovery.k8s.io/v1/namespaces/team-ns/endpointslices"):
+		if f.noSlices {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("labelSelector") != serviceNameLabel+"=tfs-team-project" {
+			fmt.Fprint(w, `{"items":[]}`)
+			return
+		}
+		if r.URL.Query().Get("watch") == "true" {
+			fmt.Fprint(w, `{"type":"BOOKMARK","object":{"metadata":{"resourceVersion":"1"}}}`)
+			fmt.Fprint(w, `{"type":"MODIFIED","object":{"metadata":{"resourceVersion":"2"}}}`)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"items":[
+			{"addressType":"IPv4","ports":[{"name":"http","port":8501},{"name":"grpc","port":8500}],"endpoints":[
+				{"addresses":["10.0.0.2"],"conditions":{"ready":true}},
+				{"addresses":["10.0.0.1"]},
+				{"addresses":["10.0.0.3"],"conditions":{"ready":false}}]},
+			{"addressType":"IPv4","ports":[{"name":"grpc","port":8500}],"endpoints":[
+				{"addresses":["10.0.0.2"],"conditions":{"ready":true}}]},
+			{"addressType":"IPv6","ports":[{"name":"grpc","port":8500}],"endpoints":[
+				{"addresses":["fd00::1"],"conditions":{"ready":true}}]},
+			{"addressType":"IPv4","ports":[{"name":"http","port":8501}],"endpoints":[
+				{"addresses":["10.0.0.4"],"conditions":{"ready":true}}]},
+			{"addressType":"FQDN","ports":[{"name":"grpc","port":8500}],"endpoints":[
+				{"addresses":["tfs.example.com"],"conditions":{"ready":true}}]}]}`)
+	case r.URL.Path == "/api/v1/namespaces/team-ns/endpoints/tfs-team-project":
+		fmt.Fprint(w, `{"subsets":[
+			{"addresses":[{"ip":"10.0.1.1"}],"notReadyAddresses":[{"ip":"10.0.1.2"}],"ports":[{"name":"grpc","port":8500}]},
+			{"addresses":[{"ip":"10.0.1.3"}],"ports":[{"name":"http","port":8501}]}]}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeKubeconfig(t *testing.T, server, token string) string {
+	dir := testutil.TempDir(t)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// token file is given relative to directory of kubeconfig
+	config := fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+current-context: test
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+users:
+- name: test
+  user:
+    tokenFile: token
+`, server)
+	path := filepath.Join(dir, "kubeconfig")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestKubernetes_Discover(t *testing.T) {
+	tests := []struct {
+		name              string
+		api               fakeAPI
+		token             string
+		namespaceTemplate string
+		servable          app.ServableID
+		want              []string
+		wantErr           bool
+	}{
+		{
+			name:              "Ready endpoints with gRPC port should be discovered from endpoint slices",
+			token:             testToken,
+			namespaceTemplate: "{{.Team}}-ns",
+			servable:          app.ServableID{Team: "team", Project: "project"},
+			want:              []string{"10.0.0.1:8500", "10.0.0.2:8500", "[fd00::1]:8500"},
+		},
+		{
+			name:              "Ready endpoints should be discovered from endpoints when endpoint slices aren't served",
+			api:               fakeAPI{noSlices: true},
+			token:             testToken,
+			namespaceTemplate: "{{.Team}}-ns",
+			servable:          app.ServableID{Team: "team", Project: "project"},
+			want:              []string{"10.0.1.1:8500"},
+		},
+		{
+			name:              "Service without endpoint slices should have no instances",
+			token:             testToken,
+			namespaceTemplate: "{{.Team}}-ns",
+			servable:          app.ServableID{Team: "team", Project: "other"},
+		},
+		{
+			name:              "Missing endpoints should be reported",
+			api:               fakeAPI{noSlices: true},
+			token:             testToken,
+			namespaceTemplate: "{{.Team}}-ns",
+			servable:          app.ServableID{Team: "team", Project: "other"},
+			wantErr:           true,
+		},
+		{
+			name:              "Unauthorized request should be reported",
+			token:             "invalid",
+			namespaceTemplate: "{{.Team}}-ns",
+			servable:          app.ServableID{Team: "team", Project: "project"},
+			wantErr:           true,
+		},
+		{
+			name:              "Template with unknown field should be reported",
+			token:             testToken,
+			namespaceTemplate: "{{.Unknown}}",
+			servable:          app.ServableID{Team: "team", Project: "project"},
+			wantErr:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.api)
+			defer server.Close()
+
+			k, err := NewKubernetes(context.Background(), writeKubeconfig(t, server.URL, tt.token), tt.namespaceTemplate, "grpc", false)
+			if err != nil {
+				t.Fatalf("NewKubernetes() error = %v", err)
+			}
+
+			got, err := k.Discover(context.Background(), tt.servable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubernetes_Watch(t *testing.T) {
+	server := httptest.NewServer(fakeAPI{})
+	defer server.Close()
+
+	k, err := NewKubernetes(context.Background(), writeKubeconfig(t, server.URL, testToken), "{{.Team}}-ns", "grpc", true)
+	if err != nil {
+		t.Fatalf("NewKubernetes() error = %v", err)
+	}
+	if _, err := k.Discover(context.Background(), app.ServableID{Team: "team", Project: "project"}); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		k.Watch(ctx, changed)
+		close(done)
+	}()
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't notified about modified endpoint slice")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't returned after ctx is done")
+	}
+}
+
+func Test_newKubeconfigClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		kubeconfig string
+		wantServer string
+		wantErr    bool
+	}{
+		{
+			name:       "Server of current context should be used",
+			wantServer: "https://127.0.0.1:6443",
+		},
+		{
+			name:       "Missing current context should be reported",
+			kubeconfig: "current-context: missing\n",
+			wantErr:    true,
+		},
+		{
+			name:       "Invalid certificate authority data should be reported",
+			kubeconfig: "current-context: test\ncontexts: [{name: test, context: {cluster: test}}]\nclusters: [{name: test, cluster: {server: 'https://127.0.0.1:6443', certificate-authority-data: invalid}}]\n",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeKubeconfig(t, "https://127.0.0.1:6443/", testToken)
+			if tt.kubeconfig != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.kubeconfig), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := newKubeconfigClient(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newKubeconfigClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if c.server != tt.wantServer {
+				t.Errorf("newKubeconfigClient() server = %s, want %s", c.server, tt.wantServer)
+			}
+			if c.tokenFile != filepath.Join(filepath.Dir(path), "token") {
+				t.Errorf("newKubeconfigClient() token file = %s, want it relative to kubeconfig", c.tokenFile)
+			}
+		})
+	}
+}
@@ -2,134 +2,244 @@ package plaintext
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"io/ioutil"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/discovery"
 	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
 )
 
+const (
+	attributeWeight = "weight"
+	attributeZone   = "zone"
+	defaultWeight   = 1
+
+	infoHostsReloaded = "hosts file reloaded"
+)
+
+// Plaintext discovers TFS instances listed in hosts file. File is parsed once and
+// parsed again when it changes, invalid file doesn't replace previously parsed one
 type Plaintext struct {
 	hostsPath string
+	// hosts holds *hosts, it is swapped atomically when file changes
+	hosts atomic.Value
+}
+
+// Instance is TFS instance listed in hosts file with its optional attributes.
+// Weight and Zone are validated and kept for forward compatibility only, reloads
+// address every discovered instance the same way, regardless of its attributes
+type Instance struct {
+	Address string
+	Weight  int
+	Zone    string
+}
+
+// hosts is parsed content of hosts file, err is set when last change
+// of file is invalid and previously parsed instances are kept
+type hosts struct {
+	content   []byte
+	instances map[string][]Instance
+	err       error
 }
 
 var (
-	regexWhitespace = regexp.MustCompile(`[[:space:]]`)
+	regexWhitespace   = regexp.MustCompile(`[[:space:]]`)
+	regexSkipLine     = regexp.MustCompile(`^[[:space:]]*[#;]+`)
+	regexInstanceName = regexp.MustCompile(fmt.Sprintf("^tfs-[[:alnum:]]{1,%d}-[[:alnum:]]{1,%d}", app.MaxTeamLength, app.MaxProjectLength))
+	regexHostLabel    = regexp.MustCompile(`^[[:alnum:]]([[:alnum:]-]{0,61}[[:alnum:]])?$`)
+	regexNumeric      = regexp.MustCompile(`^[0-9]+$`)
+	regexPort         = regexp.MustCompile(`^[1-9][0-9]{0,4}$`)
+	regexZone         = regexp.MustCompile(`^[[:alnum:]._-]+$`)
 
 	plainTextNotFoundInstanceAddressErrorCode = 1004
 	plainTextInvalidInstanceAddressErrorCode  = 1005
 	plainTextInvalidInstanceNameErrorCode     = 1006
 	plainTextInstanceNameIsEmptyErrorCode     = 1007
+	plainTextInvalidAttributeErrorCode        = 1016
 
 	messageErrorInvalidInstanceAddress = "instance's address is invalid"
+	messageErrorInvalidAttribute       = "instance's attribute is invalid"
 
 	errPlainTextNotFoundInstanceAddress = exterr.NewErrorWithMessage("not found instance's address").WithComponent(app.ComponentDiscovery).WithCode(plainTextNotFoundInstanceAddressErrorCode)
 	errPlainTextInvalidInstanceName     = exterr.NewErrorWithMessage("instance name is invalid").WithComponent(app.ComponentDiscovery).WithCode(plainTextInvalidInstanceNameErrorCode)
 	errPlainTextInstanceNameIsEmpty     = exterr.NewErrorWithMessage("instance name is empty").WithComponent(app.ComponentDiscovery).WithCode(plainTextInstanceNameIsEmptyErrorCode)
 )
 
-// NewPlaintext creates instance of discovery-Plaintext
+// NewPlaintext creates instance of discovery-Plaintext, it fails when hosts file is invalid
 func NewPlaintext(ctx context.Context, hostsPath string) (*Plaintext, error) {
-	return &Plaintext{
+	pt := &Plaintext{
 		hostsPath: hostsPath,
-	}, nil
+	}
+	pt.hosts.Store(&hosts{})
+
+	if _, err := pt.reload(); err != nil {
+		return nil, err
+	}
+
+	return pt, nil
 }
 
-// Discover returns serving instances
+// Discover returns addresses of serving instances
 func (pt *Plaintext) Discover(ctx context.Context, servableID app.ServableID) ([]string, error) {
-	lines, err := pt.readLines()
+	var instances []string
+	for _, instance := range pt.Instances(servableID) {
+		instances = append(instances, instance.Address)
+	}
+
+	return instances, nil
+}
+
+// Instances returns serving instances with their attributes, unlike Discover
+// it keeps attributes which aren't used by reloads yet
+func (pt *Plaintext) Instances(servableID app.ServableID) []Instance {
+	return pt.hosts.Load().(*hosts).instances[servableID.InstanceName()]
+}
+
+// Status returns error of last change of hosts file which has been rejected
+func (pt *Plaintext) Status() error {
+	return pt.hosts.Load().(*hosts).err
+}
+
+// reload parses hosts file again when its content has changed and swaps parsed instances,
+// it returns true when instances have been swapped
+func (pt *Plaintext) reload() (bool, error) {
+	current := pt.hosts.Load().(*hosts)
+
+	content, err := ioutil.ReadFile(pt.hostsPath)
 	if err != nil {
-		return nil, exterr.WrapWithFrame(err)
+		// content isn't kept, so file is parsed again when it can be read
+		err = exterr.WrapWithFrame(err)
+		pt.hosts.Store(&hosts{instances: current.instances, err: err})
+		return false, err
 	}
-	var instances []string
+	if content == nil {
+		content = []byte{}
+	}
+	// unchanged content gives the same instances or the same error
+	if current.content != nil && bytes.Equal(content, current.content) {
+		return false, current.err
+	}
+
+	instances, err := pt.parse(content)
+	if err != nil {
+		pt.hosts.Store(&hosts{content: content, instances: current.instances, err: err})
+		return false, err
+	}
+	pt.hosts.Store(&hosts{content: content, instances: instances})
+
+	return true, nil
+}
+
+// parse returns instances of all instance names listed in hosts file
+func (pt *Plaintext) parse(content []byte) (map[string][]Instance, error) {
+	instances := make(map[string][]Instance)
 
-	for lineNo, line := range lines {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
 		if pt.skipLine(line) {
 			continue
 		}
 
-		skip, err := pt.skipInstanceName(line, servableID.InstanceName())
+		name, lineInstances, err := pt.readInstances(lineNo, line)
 		if err != nil {
 			return nil, exterr.WrapWithFrame(err)
 		}
-		if skip {
-			continue
-		}
-		currentInstances, err := pt.readInstances(ctx, lineNo, line)
-		if err != nil {
-			return nil, exterr.WrapWithFrame(err)
-		}
-		if len(currentInstances) == 0 {
-			continue
-		}
-		instances = append(instances, currentInstances...)
+		instances[name] = append(instances[name], lineInstances...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
 	}
+
 	return instances, nil
 }
 
-func (pt *Plaintext) readInstances(ctx context.Context, lineNo int, line string) ([]string, error) {
+// readInstances reads instance name and instances of line, attributes written
+// as key=value after address of instance belong to this instance
+func (pt *Plaintext) readInstances(lineNo int, line string) (string, []Instance, error) {
 	lineNo++
 	line = strings.TrimLeft(line, "\t\n\f\r ")
-	var instances []string
-	_, err := pt.extractInstanceName(line)
+	var instances []Instance
+	name, err := pt.extractInstanceName(line)
 	if err != nil {
-		return nil, exterr.WrapWithFrame(err)
+		return "", nil, exterr.WrapWithFrame(err)
 	}
 
 	entries, err := pt.extractInstances(line)
 	if err != nil {
-		return nil, exterr.WrapWithFrame(err)
+		return "", nil, exterr.WrapWithFrame(err)
 	}
 
 	for _, v := range entries {
+		if key, value, ok := splitAttribute(v); ok {
+			if len(instances) == 0 {
+				return "", nil, invalidAttributeError(lineNo, v)
+			}
+			if !setAttribute(&instances[len(instances)-1], key, value) {
+				return "", nil, invalidAttributeError(lineNo, v)
+			}
+			continue
+		}
+
 		if !pt.isValidInstance(v) {
-			return nil, exterr.NewErrorWithMessage(fmt.Sprintf("%s line %d, address %s", messageErrorInvalidInstanceAddress, lineNo, v)).
+			return "", nil, exterr.NewErrorWithMessage(fmt.Sprintf("%s line %d, address %s", messageErrorInvalidInstanceAddress, lineNo, v)).
 				WithComponent(app.ComponentDiscovery).WithCode(plainTextInvalidInstanceAddressErrorCode)
 		}
-		instances = append(instances, v)
+		instances = append(instances, Instance{Address: normalizeAddress(v), Weight: defaultWeight})
 	}
 
-	return instances, nil
+	return name, instances, nil
 }
 
-func (pt *Plaintext) readLines() ([]string, error) {
-	file, err := os.Open(pt.hostsPath)
-	if err != nil {
-		return nil, err
+func splitAttribute(entry string) (string, string, bool) {
+	i := strings.Index(entry, "=")
+	if i < 0 {
+		return "", "", false
 	}
 
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
-	var lines []string
+	return entry[:i], entry[i+1:], true
+}
 
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// setAttribute returns false when attribute is unknown or its value is invalid
+func setAttribute(instance *Instance, key, value string) bool {
+	switch key {
+	case attributeWeight:
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 1 {
+			return false
+		}
+		instance.Weight = weight
+	case attributeZone:
+		if !regexZone.MatchString(value) {
+			return false
+		}
+		instance.Zone = value
+	default:
+		return false
 	}
-	return lines, nil
+
+	return true
+}
+
+func invalidAttributeError(lineNo int, entry string) error {
+	return exterr.NewErrorWithMessage(fmt.Sprintf("%s line %d, attribute %s", messageErrorInvalidAttribute, lineNo, entry)).
+		WithComponent(app.ComponentDiscovery).WithCode(plainTextInvalidAttributeErrorCode)
 }
 
 func (pt *Plaintext) skipLine(line string) bool {
 	if len(line) == 0 {
 		return true
 	}
-	re := regexp.MustCompile(`^[[:space:]]*[#;]+`)
-	return re.MatchString(line)
-}
-
-func (pt *Plaintext) skipInstanceName(line, instanceName string) (bool, error) {
-	in, err := pt.extractInstanceName(line)
-	if err != nil {
-		return false, exterr.WrapWithFrame(err)
-	}
-	if in != instanceName {
-		return true, nil
-	}
-	return false, nil
+	return regexSkipLine.MatchString(line)
 }
 
 func (pt *Plaintext) extractInstanceName(line string) (string, error) {
@@ -162,36 +272,79 @@ func (pt *Plaintext) extractInstances(line string) ([]string, error) {
 }
 
 func (pt *Plaintext) isValidInstanceName(instanceName string) bool {
-	re := regexp.MustCompile(fmt.Sprintf("^tfs-[[:alnum:]]{1,%d}-[[:alnum:]]{1,%d}", app.MaxTeamLength, app.MaxProjectLength))
-	return re.MatchString(instanceName)
+	return regexInstanceName.MatchString(instanceName)
 }
 
+// isValidInstance accepts host:port where host is IPv4 address, hostname or IPv6 address in brackets
 func (pt *Plaintext) isValidInstance(entry string) bool {
-	re := regexp.MustCompile(`:`)
-	pieces := re.Split(entry, -1)
-	if len(pieces) != 2 {
+	host, port, err := net.SplitHostPort(entry)
+	if err != nil {
 		return false
 	}
 
-	// ip
-	re = regexp.MustCompile(`^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])$`)
-	if !re.MatchString(pieces[0]) {
+	if !regexPort.MatchString(port) {
 		return false
 	}
-
-	// port
-	re = regexp.MustCompile(`^0{1,}`)
-	if re.MatchString(pieces[1]) {
+	if p, _ := strconv.Atoi(port); p > 65535 {
 		return false
 	}
 
-	port, err := strconv.Atoi(pieces[1])
-	if err != nil {
+	if strings.HasPrefix(entry, "[") {
+		ip := net.ParseIP(host)
+		return ip != nil && ip.To4() == nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() != nil
+	}
+
+	return isValidHostname(host)
+}
+
+// isValidHostname checks hostname according to RFC 1123, top-level label can't
+// be numeric, so invalid IPv4 addresses aren't taken for hostnames
+func isValidHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 || len(host) > 253 {
 		return false
 	}
 
-	if 0 < port && port < 65536 {
-		return true
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !regexHostLabel.MatchString(label) {
+			return false
+		}
+	}
+
+	return !regexNumeric.MatchString(labels[len(labels)-1])
+}
+
+// normalizeAddress writes IPv6 addresses in canonical form
+func normalizeAddress(entry string) string {
+	host, port, _ := net.SplitHostPort(entry)
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// Watch reloads hosts file when it changes until ctx is done, changes are noticed
+// with inotify on linux and by polling file on other systems
+func (pt *Plaintext) Watch(ctx context.Context, changed chan<- struct{}) {
+	onChange := func() {
+		swapped, err := pt.reload()
+		if err != nil {
+			logging.ErrorWithStackWithoutRequestID(ctx, err)
+			return
+		}
+		if swapped {
+			logging.Info(ctx, fmt.Sprintf("%s %s", infoHostsReloaded, pt.hostsPath))
+			discovery.Notify(changed)
+		}
+	}
+
+	if err := watchFile(ctx, pt.hostsPath, onChange); err != nil {
+		logging.ErrorWithStackWithoutRequestID(ctx, err)
+		pollFile(ctx, onChange)
 	}
-	return false
 }
@@ -1,8 +1,15 @@
 package plaintext
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
 	"golang.org/x/net/context"
 )
 
@@ -53,7 +60,7 @@ func Test_skipLine(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		pt, _ := NewPlaintext(context.Background(), "")
+		pt := &Plaintext{}
 		t.Run(tt.name, func(t *testing.T) {
 			if result := pt.skipLine(tt.args.line); (result != true) == tt.wantResultTrue {
 				t.Errorf("skipLine() result = %t name test %s", result, tt.name)
@@ -103,7 +110,7 @@ func Test_extractInstanceName(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			pt, _ := NewPlaintext(context.Background(), "")
+			pt := &Plaintext{}
 			if _, err := pt.extractInstanceName(tt.args.line); (err != nil) != tt.wantErr {
 				t.Errorf("extractInstanceName() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -158,7 +165,7 @@ func Test_isValidInstanceName(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		pt, _ := NewPlaintext(context.Background(), "")
+		pt := &Plaintext{}
 		t.Run(tt.name, func(t *testing.T) {
 			if result := pt.isValidInstanceName(tt.args.line); (result != true) == tt.wantResultTrue {
 				t.Errorf("isValidInstanceName() result = %t name test %s", result, tt.name)
@@ -213,9 +220,58 @@ func Test_isInstanceValid(t *testing.T) {
 			},
 			wantResultTrue: false,
 		},
+		{
+			name: "valid hostname",
+			args: args{
+				line: "tfs-1.example.com:8500",
+			},
+			wantResultTrue: true,
+		},
+		{
+			name: "valid IPv6 address in brackets",
+			args: args{
+				line: "[fd00::1]:8500",
+			},
+			wantResultTrue: true,
+		},
+		{
+			name: "invalid IPv6 address without brackets",
+			args: args{
+				line: "fd00::1:8500",
+			},
+			wantResultTrue: false,
+		},
+		{
+			name: "invalid IPv4 address in brackets",
+			args: args{
+				line: "[192.168.1.1]:8500",
+			},
+			wantResultTrue: false,
+		},
+		{
+			name: "invalid IPv4 address taken for hostname",
+			args: args{
+				line: "192.168.1.256:8500",
+			},
+			wantResultTrue: false,
+		},
+		{
+			name: "invalid hostname",
+			args: args{
+				line: "-tfs.example.com:8500",
+			},
+			wantResultTrue: false,
+		},
+		{
+			name: "invalid port out of range",
+			args: args{
+				line: "192.168.1.1:65536",
+			},
+			wantResultTrue: false,
+		},
 	}
 	for _, tt := range tests {
-		pt, _ := NewPlaintext(context.Background(), "")
+		pt := &Plaintext{}
 		t.Run(tt.name, func(t *testing.T) {
 			if result := pt.isValidInstance(tt.args.line); (result != true) == tt.wantResultTrue {
 				t.Errorf("isValidInstance() result = %t name test %s", result, tt.name)
@@ -283,15 +339,142 @@ func Test_parseLine(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid attributes",
+			args: args{
+				line: "tfs-mta-dev 10.10.10.21:8500 weight=3 zone=eu-1 [fd00::1]:8500 zone=eu-2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "attribute without instance",
+			args: args{
+				line: "tfs-mta-dev weight=3 10.10.10.21:8500",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid weight",
+			args: args{
+				line: "tfs-mta-dev 10.10.10.21:8500 weight=0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown attribute",
+			args: args{
+				line: "tfs-mta-dev 10.10.10.21:8500 rack=1",
+			},
+			wantErr: true,
+		},
 	}
 	for k, tt := range tests {
-		ctx := context.Background()
-		pt, _ := NewPlaintext(ctx, "")
+		pt := &Plaintext{}
 		t.Run(tt.name, func(t *testing.T) {
-			if _, err := pt.readInstances(ctx, k, tt.args.line); (err != nil) != tt.wantErr {
+			if _, _, err := pt.readInstances(k, tt.args.line); (err != nil) != tt.wantErr {
 				t.Errorf("parseLine() error = %v, wantErr %v, name %s ", err, tt.wantErr, tt.name)
 			}
 		})
 	}
 
 }
+
+func writeHosts(t *testing.T, path, content string) {
+	// file is replaced by rename, so it is never read partially written
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaintext_reload(t *testing.T) {
+	dir := testutil.TempDir(t)
+	path := filepath.Join(dir, "hosts")
+	servable := app.ServableID{Team: "team", Project: "project"}
+
+	if _, err := NewPlaintext(context.Background(), path); err == nil {
+		t.Error("NewPlaintext() of missing hosts file should fail")
+	}
+
+	writeHosts(t, path, "# comment\ntfs-team-project 10.0.0.1:8500 weight=2 zone=a tfs.example.com:8500\ntfs-team-other [fd00:0::1]:8500\n")
+	pt, err := NewPlaintext(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewPlaintext() error = %v", err)
+	}
+	want := []Instance{{Address: "10.0.0.1:8500", Weight: 2, Zone: "a"}, {Address: "tfs.example.com:8500", Weight: 1}}
+	if got := pt.Instances(servable); !reflect.DeepEqual(got, want) {
+		t.Errorf("Instances() = %+v, want %+v", got, want)
+	}
+	if got, _ := pt.Discover(context.Background(), app.ServableID{Team: "team", Project: "other"}); !reflect.DeepEqual(got, []string{"[fd00::1]:8500"}) {
+		t.Errorf("Discover() = %v, want IPv6 address in canonical form", got)
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		wantSwapped bool
+		want        []string
+		wantErr     bool
+	}{
+		{name: "Unchanged file shouldn't be parsed again", content: "# comment\ntfs-team-project 10.0.0.1:8500 weight=2 zone=a tfs.example.com:8500\ntfs-team-other [fd00:0::1]:8500\n", want: []string{"10.0.0.1:8500", "tfs.example.com:8500"}},
+		{name: "Invalid file should keep previous instances", content: "tfs-team-project 10.0.0.2:8500 10.0.0.256:8500\n", want: []string{"10.0.0.1:8500", "tfs.example.com:8500"}, wantErr: true},
+		{name: "Valid file should replace instances", content: "tfs-team-project 10.0.0.2:8500\n", wantSwapped: true, want: []string{"10.0.0.2:8500"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeHosts(t, path, tt.content)
+
+			swapped, err := pt.reload()
+			if (err != nil) != tt.wantErr || swapped != tt.wantSwapped {
+				t.Errorf("reload() = %t, %v, want %t, wantErr %v", swapped, err, tt.wantSwapped, tt.wantErr)
+			}
+			if (pt.Status() != nil) != tt.wantErr {
+				t.Errorf("Status() = %v, wantErr %v", pt.Status(), tt.wantErr)
+			}
+			if got, _ := pt.Discover(context.Background(), servable); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaintext_Watch(t *testing.T) {
+	dir := testutil.TempDir(t)
+	path := filepath.Join(dir, "hosts")
+
+	writeHosts(t, path, "tfs-team-project 10.0.0.1:8500\n")
+	pt, err := NewPlaintext(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewPlaintext() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		pt.Watch(ctx, changed)
+		close(done)
+	}()
+	// let watch start before file changes
+	time.Sleep(100 * time.Millisecond)
+
+	writeHosts(t, path, "tfs-team-project 10.0.0.2:8500\n")
+	select {
+	case <-changed:
+	case <-time.After(2 * pollInterval):
+		t.Error("Watch() hasn't notified about changed hosts file")
+	}
+	if got, _ := pt.Discover(ctx, app.ServableID{Team: "team", Project: "project"}); !reflect.DeepEqual(got, []string{"10.0.0.2:8500"}) {
+		t.Errorf("Discover() after change = %v", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch() hasn't returned after ctx is done")
+	}
+}
@@ -0,0 +1,28 @@
+package plaintext
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	// changeDelay lets bursts of events caused by single change of file settle down
+	changeDelay = 100 * time.Millisecond
+)
+
+// pollFile calls onChange every poll interval until ctx is done, it is used
+// when changes of file can't be watched
+func pollFile(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build linux
+// +build linux
+
+package plaintext
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/discovery"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+// directory is watched instead of file, so replacing file by rename
+// and swapping symlinks of mounted config maps are noticed too
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// watchFile calls onChange after every change in directory of file until ctx is done,
+// it returns error when inotify can't be set up or reading its events fails
+func watchFile(ctx context.Context, path string, onChange func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return exterr.WrapWithFrame(os.NewSyscallError("inotify_init1", err))
+	}
+	// non-blocking descriptor makes file pollable, so closing it interrupts pending read
+	events := os.NewFile(uintptr(fd), "inotify")
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), inotifyMask); err != nil {
+		events.Close()
+		return exterr.WrapWithFrame(os.NewSyscallError("inotify_add_watch", err))
+	}
+
+	changed := make(chan struct{}, 1)
+	readDone := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(readDone)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			if _, readErr = events.Read(buf); readErr != nil {
+				return
+			}
+			discovery.Notify(changed)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			events.Close()
+			<-readDone
+			return nil
+		case <-readDone:
+			events.Close()
+			return exterr.WrapWithFrame(readErr)
+		case <-changed:
+			select {
+			case <-ctx.Done():
+				continue
+			case <-time.After(changeDelay):
+			}
+			// events which came while waiting belong to the same change
+			select {
+			case <-changed:
+			default:
+			}
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package plaintext
+
+import "context"
+
+// watchFile polls file, as inotify is available only on linux
+func watchFile(ctx context.Context, path string, onChange func()) error {
+	pollFile(ctx, onChange)
+
+	return nil
+}
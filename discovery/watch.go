@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+)
+
+// WatchFunc watches single resource until ctx is done and notifies about its changes
+type WatchFunc func(ctx context.Context, resource interface{}, changed chan<- struct{})
+
+// Watches runs watch of every registered resource while Run is running, it is
+// shared by discoverers which watch resources they have discovered so far
+type Watches struct {
+	watch WatchFunc
+
+	m         sync.Mutex
+	resources map[interface{}]struct{}
+	ctx       context.Context
+	changed   chan<- struct{}
+	watchers  sync.WaitGroup
+}
+
+// NewWatches creates Watches running given watch function for every resource,
+// resources have to be comparable
+func NewWatches(watch WatchFunc) *Watches {
+	return &Watches{
+		watch:     watch,
+		resources: make(map[interface{}]struct{}),
+	}
+}
+
+// Register adds resource to watched ones, its watch is started immediately when Run is running
+func (w *Watches) Register(resource interface{}) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if _, ok := w.resources[resource]; ok {
+		return
+	}
+	w.resources[resource] = struct{}{}
+
+	if w.ctx != nil {
+		w.start(resource)
+	}
+}
+
+// start has to be called with lock held
+func (w *Watches) start(resource interface{}) {
+	ctx, changed := w.ctx, w.changed
+
+	w.watchers.Add(1)
+	go func() {
+		defer w.watchers.Done()
+		w.watch(ctx, resource, changed)
+	}()
+}
+
+// Run watches resources registered so far and registered later on until ctx is done,
+// it returns after all watches are finished
+func (w *Watches) Run(ctx context.Context, changed chan<- struct{}) {
+	w.m.Lock()
+	w.ctx, w.changed = ctx, changed
+	for resource := range w.resources {
+		w.start(resource)
+	}
+	w.m.Unlock()
+
+	<-ctx.Done()
+
+	// resources registered from now on aren't watched
+	w.m.Lock()
+	w.ctx = nil
+	w.m.Unlock()
+
+	w.watchers.Wait()
+}
+
+// Notify doesn't block, pending notification is enough to run reload
+func Notify(changed chan<- struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
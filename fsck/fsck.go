@@ -0,0 +1,538 @@
+// Package fsck cross-checks files of models kept in storage, metadata of models and
+// configs of TFS instances. Uploads, label changes and removals update them one after
+// another, so operations interrupted midway leave them inconsistent. Metadata is the
+// source of truth of statuses and labels of versions, storage of their files
+package fsck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/storage"
+
+	tfsConfig "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/config"
+)
+
+var (
+	labeledVersionErrorCode = 1001
+
+	errLabeledVersion = exterr.NewErrorWithMessage("labeled version can't be removed, upload model again or move its labels").WithComponent(app.ComponentFsck).WithCode(labeledVersionErrorCode)
+)
+
+var (
+	infoInconsistencyFound    = "inconsistency found"
+	infoInconsistencyRepaired = "inconsistency repaired"
+)
+
+// Metadata lists and removes metadata of models
+type Metadata interface {
+	List(ctx context.Context, parameters app.QueryParameters) ([]*app.ModelData, error)
+	Delete(ctx context.Context, id int64) error
+	DeleteSignatures(ctx context.Context, model app.ModelID) error
+	DeleteSmokeTestResults(ctx context.Context, model app.ModelID) error
+}
+
+// Storage lists and removes files of models
+type Storage interface {
+	ListModels(ctx context.Context) ([]storage.StoredModel, error)
+	RemoveModel(ctx context.Context, id app.ServableID, version int64) error
+}
+
+// ModelsConfig reads and saves configs of TFS instances
+type ModelsConfig interface {
+	Config(ctx context.Context, team, project string) (*tfsConfig.ModelServerConfig, error)
+	SaveConfig(ctx context.Context, team, project string, msc *tfsConfig.ModelServerConfig) error
+	NewModelConfig(id app.ServableID) *tfsConfig.ModelConfig
+}
+
+// ModelsReload reloads config of TFS instances
+type ModelsReload interface {
+	ReloadConfig(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error)
+}
+
+// Checker finds and repairs inconsistencies of models
+type Checker struct {
+	metadata Metadata
+	storage  Storage
+	config   ModelsConfig
+	reload   ModelsReload
+	// gracePeriod protects versions which are being uploaded
+	gracePeriod time.Duration
+
+	now func() time.Time
+}
+
+// NewChecker returns checker which skips versions uploaded or modified within grace period
+func NewChecker(metadata Metadata, storage Storage, config ModelsConfig, reload ModelsReload, gracePeriod time.Duration) *Checker {
+	return &Checker{metadata: metadata, storage: storage, config: config, reload: reload, gracePeriod: gracePeriod, now: time.Now}
+}
+
+// projectID identifies config of TFS instances
+type projectID struct {
+	team    string
+	project string
+}
+
+// versionID identifies version of model
+type versionID struct {
+	app.ServableID
+	version int64
+}
+
+// version describes state of single version of model in metadata, storage and config
+type version struct {
+	versionID
+	rows     []*app.ModelData
+	pending  bool
+	created  time.Time
+	stored   bool
+	modified time.Time
+	inConfig bool
+}
+
+// recent checks if version has been uploaded or modified after given time
+func (v *version) recent(since time.Time) bool {
+	return v.created.After(since) || v.modified.After(since)
+}
+
+// labels returns labels of version kept in metadata
+func (v *version) labels() []string {
+	var labels []string
+	for _, row := range v.rows {
+		if row.Label != "" {
+			labels = append(labels, row.Label)
+		}
+	}
+
+	return labels
+}
+
+// fix describes changes which repair inconsistency
+type fix struct {
+	removeConfigVersion bool
+	addConfigVersion    bool
+	// setConfigLabel moves label in config to version, label is removed when version is 0
+	setConfigLabel bool
+	removeFiles    bool
+	deleteRows     []*app.ModelData
+	// err explains why inconsistency can't be repaired
+	err error
+}
+
+func (r fix) changesConfig() bool {
+	return r.removeConfigVersion || r.addConfigVersion || r.setConfigLabel
+}
+
+type finding struct {
+	app.Inconsistency
+	fix fix
+}
+
+// Check finds inconsistencies of every model, they are repaired when repair is set
+func (c *Checker) Check(ctx context.Context, repair bool) (*app.ConsistencyReport, error) {
+	rows, err := c.metadata.List(ctx, app.QueryParameters{})
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	stored, err := c.storage.ListModels(ctx)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	projects := groupVersions(rows, stored)
+	projectIDs := make([]projectID, 0, len(projects))
+	for id := range projects {
+		projectIDs = append(projectIDs, id)
+	}
+	sort.Slice(projectIDs, func(i, j int) bool {
+		if projectIDs[i].team != projectIDs[j].team {
+			return projectIDs[i].team < projectIDs[j].team
+		}
+		return projectIDs[i].project < projectIDs[j].project
+	})
+
+	report := &app.ConsistencyReport{Inconsistencies: []app.Inconsistency{}}
+	for _, id := range projectIDs {
+		findings, err := c.checkProject(ctx, id, projects[id], repair)
+		if err != nil {
+			logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+			return nil, err
+		}
+
+		for _, f := range findings {
+			if f.Repaired {
+				report.Repaired++
+			}
+			report.Inconsistencies = append(report.Inconsistencies, f.Inconsistency)
+		}
+	}
+
+	return report, nil
+}
+
+// checkProject finds inconsistencies of models served by TFS instances of team and project
+func (c *Checker) checkProject(ctx context.Context, id projectID, versions map[versionID]*version, repair bool) ([]*finding, error) {
+	msc, err := c.config.Config(ctx, id.team, id.project)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mc := range msc.GetModelConfigList().GetConfig() {
+		servableID := app.ServableID{Team: id.team, Project: id.project, Name: mc.GetName()}
+		for _, v := range mc.GetModelVersionPolicy().GetSpecific().GetVersions() {
+			getVersion(versions, versionID{ServableID: servableID, version: v}).inConfig = true
+		}
+	}
+
+	sorted := make([]*version, 0, len(versions))
+	for _, v := range versions {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].version < sorted[j].version
+	})
+
+	since := c.now().Add(-c.gracePeriod)
+	var findings []*finding
+	for _, v := range sorted {
+		if v.recent(since) {
+			continue
+		}
+		if f := checkVersion(v); f != nil {
+			findings = append(findings, f)
+		}
+	}
+	// labels are checked after versions, so they are moved after versions are repaired
+	findings = append(findings, checkLabels(id, msc, versions, since)...)
+
+	for _, f := range findings {
+		logging.Info(ctx, fmt.Sprintf("%s: %s model[%s-%s-%s] version [%d] label [%s]: %s", infoInconsistencyFound, f.Type, f.Team, f.Project, f.Name, f.Version, f.Label, f.Details))
+	}
+
+	if repair {
+		c.repair(ctx, id, msc, findings)
+	}
+
+	return findings, nil
+}
+
+// checkVersion finds inconsistency of single version, labels aren't checked
+func checkVersion(v *version) *finding {
+	f := &finding{Inconsistency: app.Inconsistency{ServableID: v.ServableID, Version: v.version}}
+
+	switch {
+	case len(v.rows) == 0 && v.stored:
+		f.Type = app.InconsistencyOrphanFiles
+		f.Details = "files of version are kept in storage without metadata"
+		f.fix = fix{removeConfigVersion: v.inConfig, removeFiles: true}
+	case len(v.rows) == 0:
+		f.Type = app.InconsistencyOrphanConfigVersion
+		f.Details = "config of TFS instances contains version without metadata and files"
+		f.fix = fix{removeConfigVersion: true}
+	case v.pending:
+		f.Type = app.InconsistencyStalePending
+		f.Details = "upload of version was interrupted and version has been left pending"
+		f.fix = fix{removeConfigVersion: v.inConfig, removeFiles: v.stored, deleteRows: v.rows}
+	case !v.stored:
+		f.Type = app.InconsistencyMissingFiles
+		f.Details = "files of ready version aren't kept in storage"
+		f.fix = fix{removeConfigVersion: v.inConfig, deleteRows: v.rows}
+		if labels := v.labels(); len(labels) != 0 {
+			f.Details = fmt.Sprintf("%s, version has labels %v", f.Details, labels)
+			f.fix = fix{err: errLabeledVersion}
+		}
+	case !v.inConfig:
+		f.Type = app.InconsistencyMissingConfigVersion
+		f.Details = "ready version is missing in config of TFS instances"
+		f.fix = fix{addConfigVersion: true}
+	default:
+		return nil
+	}
+
+	return f
+}
+
+// checkLabels finds labels which point to different versions in metadata and config,
+// last_stable label is kept only in metadata
+func checkLabels(id projectID, msc *tfsConfig.ModelServerConfig, versions map[versionID]*version, since time.Time) []*finding {
+	metadataLabels := make(map[string]map[string]int64)
+	for _, v := range versions {
+		if v.pending {
+			continue
+		}
+		for _, label := range v.labels() {
+			if label == app.PrevStableLabel {
+				continue
+			}
+			if metadataLabels[v.Name] == nil {
+				metadataLabels[v.Name] = make(map[string]int64)
+			}
+			metadataLabels[v.Name][label] = v.version
+		}
+	}
+
+	configLabels := make(map[string]map[string]int64)
+	for _, mc := range msc.GetModelConfigList().GetConfig() {
+		configLabels[mc.GetName()] = mc.GetVersionLabels()
+	}
+
+	names := make(map[string]int64)
+	for name := range metadataLabels {
+		names[name] = 0
+	}
+	for name := range configLabels {
+		names[name] = 0
+	}
+
+	var findings []*finding
+	for _, name := range sortedKeys(names) {
+		servableID := app.ServableID{Team: id.team, Project: id.project, Name: name}
+		for _, label := range sortedKeys(metadataLabels[name], configLabels[name]) {
+			metadataVersion, configVersion := metadataLabels[name][label], configLabels[name][label]
+			if metadataVersion == configVersion {
+				continue
+			}
+			// label is moved by upload in progress
+			if isRecent(versions, versionID{ServableID: servableID, version: metadataVersion}, since) ||
+				isRecent(versions, versionID{ServableID: servableID, version: configVersion}, since) {
+				continue
+			}
+
+			findings = append(findings, &finding{
+				Inconsistency: app.Inconsistency{
+					ServableID: servableID,
+					Type:       app.InconsistencyLabelMismatch,
+					Version:    metadataVersion,
+					Label:      label,
+					Details:    fmt.Sprintf("label points to %s in metadata and to %s in config", describeVersion(metadataVersion), describeVersion(configVersion)),
+				},
+				fix: fix{setConfigLabel: true},
+			})
+		}
+	}
+
+	return findings
+}
+
+// repair applies changes of findings, config is saved and TFS instances are reloaded
+// before files and metadata are removed, so removed versions aren't served anymore
+func (c *Checker) repair(ctx context.Context, id projectID, msc *tfsConfig.ModelServerConfig, findings []*finding) {
+	configChanged := false
+	for _, f := range findings {
+		if f.fix.err != nil || !f.fix.changesConfig() {
+			continue
+		}
+		mc := c.modelConfig(msc, f.ServableID)
+		switch {
+		case f.fix.removeConfigVersion:
+			removeConfigVersion(mc, f.Version)
+		case f.fix.addConfigVersion:
+			c.addConfigVersion(mc, f.ServableID, f.Version)
+		case f.fix.setConfigLabel:
+			setConfigLabel(mc, f.Label, f.Version)
+		}
+		configChanged = true
+	}
+
+	var saveErr, reloadErr error
+	if configChanged {
+		saveErr = c.config.SaveConfig(ctx, id.team, id.project, msc)
+		if saveErr == nil && c.reload != nil {
+			_, reloadErr = c.reload.ReloadConfig(ctx, id.team, id.project, true)
+		}
+	}
+
+	for _, f := range findings {
+		err := f.fix.err
+		if err == nil && f.fix.changesConfig() {
+			err = saveErr
+		}
+		if err == nil && (f.fix.removeFiles || len(f.fix.deleteRows) != 0) {
+			err = reloadErr
+		}
+		if err == nil {
+			err = c.remove(ctx, f)
+		}
+
+		if err != nil {
+			logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+			f.RepairError = err.Error()
+			continue
+		}
+
+		f.Repaired = true
+		logging.Info(ctx, fmt.Sprintf("%s: %s model[%s-%s-%s] version [%d] label [%s]", infoInconsistencyRepaired, f.Type, f.Team, f.Project, f.Name, f.Version, f.Label))
+	}
+}
+
+// remove removes files and metadata of version of finding
+func (c *Checker) remove(ctx context.Context, f *finding) error {
+	if f.fix.removeFiles {
+		if err := c.storage.RemoveModel(ctx, f.ServableID, f.Version); err != nil {
+			return err
+		}
+	}
+
+	if len(f.fix.deleteRows) == 0 {
+		return nil
+	}
+	for _, row := range f.fix.deleteRows {
+		if err := c.metadata.Delete(ctx, row.ID); err != nil {
+			return err
+		}
+	}
+
+	modelID := app.ModelID{ServableID: f.ServableID, Version: f.Version}
+	if err := c.metadata.DeleteSignatures(ctx, modelID); err != nil {
+		return err
+	}
+
+	return c.metadata.DeleteSmokeTestResults(ctx, modelID)
+}
+
+// modelConfig returns config of model, it's added to msc when it doesn't exist
+func (c *Checker) modelConfig(msc *tfsConfig.ModelServerConfig, id app.ServableID) *tfsConfig.ModelConfig {
+	list := msc.GetModelConfigList()
+	for _, mc := range list.GetConfig() {
+		if mc.GetName() == id.Name {
+			return mc
+		}
+	}
+
+	mc := c.config.NewModelConfig(id)
+	list.Config = append(list.Config, mc)
+
+	return mc
+}
+
+func (c *Checker) addConfigVersion(mc *tfsConfig.ModelConfig, id app.ServableID, version int64) {
+	if mc.GetModelVersionPolicy().GetSpecific() == nil {
+		mc.ModelVersionPolicy = c.config.NewModelConfig(id).ModelVersionPolicy
+	}
+
+	specific := mc.GetModelVersionPolicy().GetSpecific()
+	specific.Versions = append(specific.Versions, version)
+	sort.Slice(specific.Versions, func(i, j int) bool { return specific.Versions[i] < specific.Versions[j] })
+}
+
+// removeConfigVersion removes version and labels pointing to it from config of model
+func removeConfigVersion(mc *tfsConfig.ModelConfig, version int64) {
+	if specific := mc.GetModelVersionPolicy().GetSpecific(); specific != nil {
+		versions := []int64{}
+		for _, v := range specific.GetVersions() {
+			if v != version {
+				versions = append(versions, v)
+			}
+		}
+		specific.Versions = versions
+	}
+
+	for label, v := range mc.GetVersionLabels() {
+		if v == version {
+			delete(mc.VersionLabels, label)
+		}
+	}
+}
+
+// setConfigLabel moves label to version, label is removed when version is 0
+func setConfigLabel(mc *tfsConfig.ModelConfig, label string, version int64) {
+	if version == 0 {
+		delete(mc.VersionLabels, label)
+		return
+	}
+
+	if mc.VersionLabels == nil {
+		mc.VersionLabels = make(map[string]int64)
+	}
+	mc.VersionLabels[label] = version
+}
+
+// groupVersions merges rows of metadata and files kept in storage into versions
+// of models and groups them by configs of TFS instances serving them
+func groupVersions(rows []*app.ModelData, stored []storage.StoredModel) map[projectID]map[versionID]*version {
+	projects := make(map[projectID]map[versionID]*version)
+	project := func(id app.ServableID) map[versionID]*version {
+		pid := projectID{team: id.Team, project: id.Project}
+		if projects[pid] == nil {
+			projects[pid] = make(map[versionID]*version)
+		}
+		return projects[pid]
+	}
+
+	for _, row := range rows {
+		// last_stable row points to version 0 when there was no stable version before
+		if row.Version == 0 {
+			continue
+		}
+		v := getVersion(project(row.ServableID), versionID{ServableID: row.ServableID, version: row.Version})
+		v.rows = append(v.rows, row)
+		if row.Status == app.StatusPending {
+			v.pending = true
+		}
+		// unlabeled row is added when version is uploaded, labeled rows when labels are moved
+		if created, err := strconv.ParseInt(row.Created, 10, 64); err == nil && (v.created.IsZero() || time.Unix(created, 0).Before(v.created)) {
+			v.created = time.Unix(created, 0)
+		}
+	}
+
+	for _, s := range stored {
+		v := getVersion(project(s.ServableID), versionID{ServableID: s.ServableID, version: s.Version})
+		v.stored = true
+		v.modified = s.Modified
+	}
+
+	return projects
+}
+
+// getVersion returns version with given ID, it's added to versions when it doesn't exist
+func getVersion(versions map[versionID]*version, id versionID) *version {
+	v, ok := versions[id]
+	if !ok {
+		v = &version{versionID: id}
+		versions[id] = v
+	}
+
+	return v
+}
+
+func isRecent(versions map[versionID]*version, id versionID, since time.Time) bool {
+	v, ok := versions[id]
+	return ok && v.recent(since)
+}
+
+func describeVersion(version int64) string {
+	if version == 0 {
+		return "no version"
+	}
+	return fmt.Sprintf("version %d", version)
+}
+
+// sortedKeys returns sorted keys present in any of given maps
+func sortedKeys(maps ...map[string]int64) []string {
+	set := make(map[string]struct{})
+	for _, m := range maps {
+		for k := range m {
+			set[k] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,308 @@
+package fsck
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/serving"
+	"github.com/grupawp/tensorflow-deploy/storage"
+
+	tfsConfig "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/config"
+)
+
+const now = 1600000000
+
+var model = app.ServableID{Team: "team", Project: "project", Name: "name"}
+
+// fakeMetadata keeps rows of model metadata in memory
+type fakeMetadata struct {
+	rows    []*app.ModelData
+	deleted []int64
+}
+
+func (f *fakeMetadata) List(ctx context.Context, parameters app.QueryParameters) ([]*app.ModelData, error) {
+	return f.rows, nil
+}
+
+func (f *fakeMetadata) Delete(ctx context.Context, id int64) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeMetadata) DeleteSignatures(ctx context.Context, model app.ModelID) error {
+	return nil
+}
+
+func (f *fakeMetadata) DeleteSmokeTestResults(ctx context.Context, model app.ModelID) error {
+	return nil
+}
+
+// fakeStorage keeps versions of model stored in storage
+type fakeStorage struct {
+	stored  []storage.StoredModel
+	removed []int64
+}
+
+func (f *fakeStorage) ListModels(ctx context.Context) ([]storage.StoredModel, error) {
+	return f.stored, nil
+}
+
+func (f *fakeStorage) RemoveModel(ctx context.Context, id app.ServableID, version int64) error {
+	f.removed = append(f.removed, version)
+	return nil
+}
+
+// fakeConfigStorage keeps serialized configs of TFS instances in memory
+type fakeConfigStorage map[string][]byte
+
+func (f fakeConfigStorage) ReadConfig(ctx context.Context, team, project string) ([]byte, error) {
+	config, ok := f[team+"/"+project]
+	if !ok {
+		return nil, storage.ErrConfigDoesNotExist
+	}
+	return config, nil
+}
+
+func (f fakeConfigStorage) SaveConfig(ctx context.Context, team, project string, config []byte) error {
+	f[team+"/"+project] = config
+	return nil
+}
+
+type fakeReload struct {
+	err error
+}
+
+func (f *fakeReload) ReloadConfig(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error) {
+	return nil, f.err
+}
+
+func row(id, version int64, label, status string) *app.ModelData {
+	return &app.ModelData{
+		ModelID: app.ModelID{ServableID: model, Version: version, Label: label},
+		ID:      id,
+		Status:  status,
+		Created: strconv.FormatInt(now-24*3600, 10),
+	}
+}
+
+func stored(version int64, modified int64) storage.StoredModel {
+	return storage.StoredModel{ServableID: model, Version: version, Modified: time.Unix(modified, 0)}
+}
+
+// modelState holds versions and labels of model in config of TFS instances
+type modelState struct {
+	versions []int64
+	labels   map[string]int64
+}
+
+func newServableConfig(t *testing.T, state modelState) *serving.ServableConfig {
+	sc, err := serving.NewServableConfig(fakeConfigStorage{}, "canary", serving.DefaultModelsBasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc := sc.NewModelConfig(model)
+	mc.GetModelVersionPolicy().GetSpecific().Versions = state.versions
+	mc.VersionLabels = state.labels
+	msc := &tfsConfig.ModelServerConfig{Config: &tfsConfig.ModelServerConfig_ModelConfigList{
+		ModelConfigList: &tfsConfig.ModelConfigList{Config: []*tfsConfig.ModelConfig{mc}},
+	}}
+	if err := sc.SaveConfig(context.Background(), model.Team, model.Project, proto.Clone(msc).(*tfsConfig.ModelServerConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	return sc
+}
+
+func configState(t *testing.T, sc *serving.ServableConfig) modelState {
+	msc, err := sc.Config(context.Background(), model.Team, model.Project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := modelState{labels: map[string]int64{}}
+	for _, mc := range msc.GetModelConfigList().GetConfig() {
+		if mc.GetName() != model.Name {
+			continue
+		}
+		state.versions = append(state.versions, mc.GetModelVersionPolicy().GetSpecific().GetVersions()...)
+		for label, version := range mc.GetVersionLabels() {
+			state.labels[label] = version
+		}
+	}
+	sort.Slice(state.versions, func(i, j int) bool { return state.versions[i] < state.versions[j] })
+
+	return state
+}
+
+func TestChecker_Check(t *testing.T) {
+	// version 1 is stable and consistent in every test
+	consistent := func() ([]*app.ModelData, []storage.StoredModel) {
+		return []*app.ModelData{row(1, 1, "", app.StatusReady), row(2, 1, app.StableLabel, app.StatusReady), row(3, 0, app.PrevStableLabel, app.StatusReady)},
+			[]storage.StoredModel{stored(1, now-24*3600)}
+	}
+
+	tests := []struct {
+		name      string
+		rows      []*app.ModelData
+		stored    []storage.StoredModel
+		config    modelState
+		reloadErr error
+		// found lists types of found inconsistencies with versions and labels
+		found       []string
+		wantConfig  modelState
+		wantRemoved []int64
+		wantDeleted []int64
+		wantFailed  []string
+	}{
+		{
+			name:       "Consistent model shouldn't be reported",
+			config:     modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantConfig: modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+		},
+		{
+			name:        "Files without metadata should be removed",
+			stored:      []storage.StoredModel{stored(2, now-2*3600)},
+			config:      modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			found:       []string{"orphan_files 2 "},
+			wantConfig:  modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantRemoved: []int64{2},
+		},
+		{
+			name:       "Files of upload in progress should be skipped",
+			stored:     []storage.StoredModel{stored(2, now-60)},
+			config:     modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantConfig: modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+		},
+		{
+			name:        "Interrupted upload should be removed",
+			rows:        []*app.ModelData{row(5, 2, "", app.StatusPending)},
+			stored:      []storage.StoredModel{stored(2, now-24*3600)},
+			config:      modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1, "canary": 2}},
+			found:       []string{"stale_pending 2 ", "label_mismatch 0 canary"},
+			wantConfig:  modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantRemoved: []int64{2},
+			wantDeleted: []int64{5},
+		},
+		{
+			name:       "Interrupted upload shouldn't be removed when TFS instances aren't reloaded",
+			rows:       []*app.ModelData{row(5, 2, "", app.StatusPending)},
+			stored:     []storage.StoredModel{stored(2, now-24*3600)},
+			config:     modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1, "canary": 2}},
+			reloadErr:  errors.New("reload failed"),
+			found:      []string{"stale_pending 2 ", "label_mismatch 0 canary"},
+			wantConfig: modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantFailed: []string{"stale_pending 2 "},
+		},
+		{
+			name:        "Ready version without files should be removed",
+			rows:        []*app.ModelData{row(5, 2, "", app.StatusReady)},
+			config:      modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1}},
+			found:       []string{"missing_files 2 "},
+			wantConfig:  modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			wantDeleted: []int64{5},
+		},
+		{
+			name:       "Labeled version without files shouldn't be removed",
+			rows:       []*app.ModelData{row(5, 2, "", app.StatusReady), row(6, 2, "canary", app.StatusReady)},
+			config:     modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1, "canary": 2}},
+			found:      []string{"missing_files 2 "},
+			wantConfig: modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1, "canary": 2}},
+			wantFailed: []string{"missing_files 2 "},
+		},
+		{
+			name:       "Ready version missing in config should be added",
+			rows:       []*app.ModelData{row(5, 2, "", app.StatusReady), row(6, 2, "canary", app.StatusReady)},
+			stored:     []storage.StoredModel{stored(2, now-24*3600)},
+			config:     modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+			found:      []string{"missing_config_version 2 ", "label_mismatch 2 canary"},
+			wantConfig: modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1, "canary": 2}},
+		},
+		{
+			name:       "Version without metadata and files should be removed from config",
+			config:     modelState{versions: []int64{1, 3}, labels: map[string]int64{app.StableLabel: 1}},
+			found:      []string{"orphan_config_version 3 "},
+			wantConfig: modelState{versions: []int64{1}, labels: map[string]int64{app.StableLabel: 1}},
+		},
+		{
+			name:       "Label moved only in config should be moved back",
+			rows:       []*app.ModelData{row(5, 2, "", app.StatusReady)},
+			stored:     []storage.StoredModel{stored(2, now-24*3600)},
+			config:     modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 2}},
+			found:      []string{"label_mismatch 1 stable"},
+			wantConfig: modelState{versions: []int64{1, 2}, labels: map[string]int64{app.StableLabel: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, storedModels := consistent()
+			metadata := &fakeMetadata{rows: append(rows, tt.rows...)}
+			files := &fakeStorage{stored: append(storedModels, tt.stored...)}
+			sc := newServableConfig(t, tt.config)
+
+			c := NewChecker(metadata, files, sc, &fakeReload{err: tt.reloadErr}, time.Hour)
+			c.now = func() time.Time { return time.Unix(now, 0) }
+
+			report, err := c.Check(context.Background(), false)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got := describe(report.Inconsistencies, false); !reflect.DeepEqual(got, tt.found) {
+				t.Fatalf("Check() found %v, want %v", got, tt.found)
+			}
+			if files.removed != nil || metadata.deleted != nil || !reflect.DeepEqual(configState(t, sc), modelStateOf(tt.config)) {
+				t.Fatalf("Check() without repair changed models")
+			}
+
+			report, err = c.Check(context.Background(), true)
+			if err != nil {
+				t.Fatalf("Check() with repair error = %v", err)
+			}
+			if got := describe(report.Inconsistencies, true); !reflect.DeepEqual(got, tt.wantFailed) {
+				t.Errorf("Check() failed to repair %v, want %v", got, tt.wantFailed)
+			}
+			if report.Repaired != len(tt.found)-len(tt.wantFailed) {
+				t.Errorf("Check() repaired %d, want %d", report.Repaired, len(tt.found)-len(tt.wantFailed))
+			}
+			if got := configState(t, sc); !reflect.DeepEqual(got, tt.wantConfig) {
+				t.Errorf("config after repair = %+v, want %+v", got, tt.wantConfig)
+			}
+			if !reflect.DeepEqual(files.removed, tt.wantRemoved) {
+				t.Errorf("removed files = %v, want %v", files.removed, tt.wantRemoved)
+			}
+			if !reflect.DeepEqual(metadata.deleted, tt.wantDeleted) {
+				t.Errorf("deleted rows = %v, want %v", metadata.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+// describe lists type, version and label of inconsistencies, only these
+// which haven't been repaired are listed when failed is set
+func describe(inconsistencies []app.Inconsistency, failed bool) []string {
+	var described []string
+	for _, i := range inconsistencies {
+		if failed && (i.Repaired || i.RepairError == "") {
+			continue
+		}
+		described = append(described, i.Type+" "+strconv.FormatInt(i.Version, 10)+" "+i.Label)
+	}
+
+	return described
+}
+
+func modelStateOf(state modelState) modelState {
+	if state.labels == nil {
+		state.labels = map[string]int64{}
+	}
+
+	return state
+}
@@ -0,0 +1,41 @@
+// Package testutil holds helpers shared by tests of TensorFlow Deploy packages
+package testutil
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// TempDir creates temporary directory which is removed once test completes
+func TempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "tfd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+// ServeGRPC starts gRPC server with services added by register on random local port
+// and returns its address. Server is stopped once test completes
+func ServeGRPC(t *testing.T, register func(*grpc.Server)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
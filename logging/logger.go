@@ -14,6 +14,13 @@ import (
 // logger as global
 var logger = newLogger()
 
+// Key to use when setting the identity of authenticated caller.
+type ctxKeyIdentity int
+
+// IdentityCtxKey is the key that holds identity of authenticated caller in a request context.
+// It is set by HTTPCtxValuesMiddleware and filled in by SetIdentity after authentication
+const IdentityCtxKey ctxKeyIdentity = 0
+
 // newLogger creates logger with default settings
 func newLogger() rz.Logger {
 	l := rz.New(rz.CallerSkipFrameCount(4))
@@ -109,13 +116,62 @@ func HTTPCtxValuesMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("X-Request-ID", requestID)
 		ctx = context.WithValue(ctx, rzhttp.RequestIDCtxKey, requestID)
 
+		// identity, filled in after authentication
+		ctx = context.WithValue(ctx, IdentityCtxKey, new(string))
+
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 // HTTPRequestMiddleware ->
 func HTTPRequestMiddleware() func(next http.Handler) http.Handler {
-	return rzhttp.Handler(logger)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// caller is authenticated down the chain, so identity
+			// is appended to the access log entry by a hook
+			hook := rz.HookFunc(func(e *rz.Event, level rz.LogLevel, message string) {
+				if identity := identityFromCtx(r.Context()); identity != "" {
+					e.Append(rz.String("identity", identity))
+				}
+			})
+
+			rzhttp.Handler(logger.With(rz.AddHook(hook)))(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetIdentity sets identity of authenticated caller in context prepared by HTTPCtxValuesMiddleware
+func SetIdentity(ctx context.Context, identity string) {
+	if v, ok := ctx.Value(IdentityCtxKey).(*string); ok {
+		*v = identity
+	}
+}
+
+// WithIdentity returns copy of ctx with identity of caller, it's used by background
+// jobs which act on their own, outside of requests
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, IdentityCtxKey, &identity)
+}
+
+// Identity gets identity of caller from context, it's empty when caller hasn't been authenticated
+func Identity(ctx context.Context) string {
+	return identityFromCtx(ctx)
+}
+
+// RequestID gets ID of request from context, it's empty outside of requests
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(rzhttp.RequestIDCtxKey).(string)
+
+	return requestID
+}
+
+// identityFromCtx gets identity of authenticated caller from context
+func identityFromCtx(ctx context.Context) string {
+	if v, ok := ctx.Value(IdentityCtxKey).(*string); ok {
+		return *v
+	}
+
+	return ""
 }
 
 func fields(ctx context.Context, code string) []rz.Field {
@@ -137,6 +193,11 @@ func fieldsFromCtx(ctx context.Context, fields []rz.Field) []rz.Field {
 		fields = append(fields, rz.String("request_id", requestID))
 	}
 
+	// identity
+	if identity := identityFromCtx(ctx); identity != "" {
+		fields = append(fields, rz.String("identity", identity))
+	}
+
 	return fields
 }
 
@@ -0,0 +1,103 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+// Audit is an append-only audit log kept in audit_log table
+type Audit struct {
+	connection *sql.DB
+	dialect    *dialect
+}
+
+// Add appends entry to audit log and returns its ID
+func (a *Audit) Add(ctx context.Context, entry app.AuditEntry) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("audit_add", time.Now(), &err)
+
+	return a.dialect.insert(ctx, a.connection, "INSERT INTO audit_log (operation, actor, team, project, name, version, label, outcome, status, error_code, request_id, created) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.Operation,
+		entry.Actor,
+		entry.Team,
+		entry.Project,
+		entry.Name,
+		entry.Version,
+		entry.Label,
+		entry.Outcome,
+		entry.Status,
+		entry.ErrorCode,
+		entry.RequestID,
+		entry.Created)
+}
+
+// List lists entries of audit log matching query, the latest entry is the first one.
+// At most query.Limit entries are returned
+func (a *Audit) List(ctx context.Context, query app.AuditQuery) (_ []app.AuditEntry, err error) {
+	defer metrics.ObserveMetadataOperation("audit_list", time.Now(), &err)
+
+	var (
+		conditions []string
+		values     []interface{}
+	)
+	for _, filter := range []struct {
+		column string
+		value  string
+	}{
+		{"operation", query.Operation},
+		{"actor", query.Actor},
+		{"team", query.Team},
+		{"project", query.Project},
+		{"name", query.Name},
+		{"outcome", query.Outcome},
+	} {
+		if filter.value != "" {
+			conditions = append(conditions, filter.column+" = ?")
+			values = append(values, filter.value)
+		}
+	}
+	if query.Since > 0 {
+		conditions = append(conditions, "created >= ?")
+		values = append(values, query.Since)
+	}
+	if query.Until > 0 {
+		conditions = append(conditions, "created <= ?")
+		values = append(values, query.Until)
+	}
+	if query.Before > 0 {
+		conditions = append(conditions, "id < ?")
+		values = append(values, query.Before)
+	}
+
+	statement := "SELECT id, operation, actor, team, project, name, version, label, outcome, status, error_code, request_id, created FROM audit_log"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY id DESC LIMIT ?"
+	values = append(values, query.Limit)
+
+	rows, err := a.connection.QueryContext(ctx, a.dialect.rebind(statement), values...)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	var entries []app.AuditEntry
+	for rows.Next() {
+		var entry app.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Operation, &entry.Actor, &entry.Team, &entry.Project, &entry.Name, &entry.Version, &entry.Label, &entry.Outcome, &entry.Status, &entry.ErrorCode, &entry.RequestID, &entry.Created); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,63 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+func TestAudit_List(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []app.AuditEntry{
+		{Operation: "model_upload", Actor: "alice", Team: "team", Project: "project", Name: "a", Version: 1, Outcome: app.AuditSuccess, Status: 200, Created: 100},
+		{Operation: "model_set_label", Actor: "bob", Team: "team", Project: "project", Name: "a", Version: 1, Label: "canary", Outcome: app.AuditSuccess, Status: 200, Created: 200},
+		{Operation: "model_upload", Actor: "alice", Team: "team", Project: "project", Name: "b", Outcome: app.AuditFailure, Status: 307, ErrorCode: "STORAGE-1004", RequestID: "req", Created: 300},
+		{Operation: "module_delete", Actor: "alice", Team: "other", Project: "project", Name: "a", Version: 2, Outcome: app.AuditSuccess, Status: 200, Created: 400},
+	}
+	var ids []int64
+	for _, entry := range entries {
+		id, err := db.Audit.Add(ctx, entry)
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		name  string
+		query app.AuditQuery
+		want  []int
+	}{
+		{name: "All entries should be listed from the latest one", query: app.AuditQuery{Limit: 10}, want: []int{3, 2, 1, 0}},
+		{name: "Limit should be applied", query: app.AuditQuery{Limit: 2}, want: []int{3, 2}},
+		{name: "Entries should be filtered by actor and operation", query: app.AuditQuery{Actor: "alice", Operation: "model_upload", Limit: 10}, want: []int{2, 0}},
+		{name: "Entries should be filtered by model", query: app.AuditQuery{Team: "team", Project: "project", Name: "a", Limit: 10}, want: []int{1, 0}},
+		{name: "Entries should be filtered by outcome", query: app.AuditQuery{Outcome: app.AuditFailure, Limit: 10}, want: []int{2}},
+		{name: "Entries should be filtered by time", query: app.AuditQuery{Since: 200, Until: 300, Limit: 10}, want: []int{2, 1}},
+		{name: "Entries older than cursor should be listed", query: app.AuditQuery{Before: ids[2], Limit: 10}, want: []int{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.Audit.List(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List() = %+v, want %d entries", got, len(tt.want))
+			}
+			for i, entry := range got {
+				want := entries[tt.want[i]]
+				want.ID = ids[tt.want[i]]
+				if entry != want {
+					t.Errorf("List()[%d] = %+v, want %+v", i, entry, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+const (
+	// DriverSQLite3 is a name of SQLite driver
+	DriverSQLite3 = "sqlite3"
+	// DriverPostgres is a name of PostgreSQL driver
+	DriverPostgres = "postgres"
+	// DriverMySQL is a name of MySQL driver
+	DriverMySQL = "mysql"
+)
+
+var (
+	unsupportedDriverErrorCode = 1007
+
+	errorUnsupportedDriver = exterr.NewErrorWithMessage("unsupported sql driver").WithComponent(app.ComponentMetadata).WithCode(unsupportedDriverErrorCode)
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// dialect holds differences between supported SQL databases.
+// Queries are written with "?" placeholders and rebound for given dialect
+type dialect struct {
+	driver string
+
+	// numberedPlaceholders is set when placeholders are $1, $2, ...
+	numberedPlaceholders bool
+	// returningID is set when LastInsertId is not supported and "RETURNING id" has to be used
+	returningID bool
+	// lockingRead is appended to SELECT queries executed in transactions
+	lockingRead string
+	// tryLockMigrations and unlockMigrations take and release advisory lock held by session,
+	// try query returns true when lock is taken. They're empty when there's no such lock
+	tryLockMigrations string
+	unlockMigrations  string
+}
+
+// newDialect returns dialect of given driver
+func newDialect(driver string) (*dialect, error) {
+	switch driver {
+	case DriverSQLite3:
+		return &dialect{driver: driver}, nil
+	case DriverPostgres:
+		return &dialect{driver: driver, numberedPlaceholders: true, returningID: true, lockingRead: "FOR UPDATE",
+			tryLockMigrations: fmt.Sprintf("SELECT pg_try_advisory_lock(%d)", migrationsAdvisoryLockID),
+			unlockMigrations:  fmt.Sprintf("SELECT pg_advisory_unlock(%d)", migrationsAdvisoryLockID),
+		}, nil
+	case DriverMySQL:
+		return &dialect{driver: driver, lockingRead: "FOR UPDATE",
+			tryLockMigrations: fmt.Sprintf("SELECT GET_LOCK('%s', 0)", migrationsLockName),
+			unlockMigrations:  fmt.Sprintf("SELECT RELEASE_LOCK('%s')", migrationsLockName),
+		}, nil
+	}
+
+	return nil, errorUnsupportedDriver
+}
+
+// rebind replaces "?" placeholders with placeholders valid for the dialect
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// forUpdate appends locking clause to SELECT query when dialect supports it
+func (d *dialect) forUpdate(query string) string {
+	if d.lockingRead == "" {
+		return query
+	}
+
+	return query + " " + d.lockingRead
+}
+
+// insert executes INSERT query and returns id of inserted row
+func (d *dialect) insert(ctx context.Context, e execer, query string, args ...interface{}) (int64, error) {
+	if d.returningID {
+		var id int64
+		if err := e.QueryRowContext(ctx, d.rebind(query+" RETURNING id"), args...).Scan(&id); err != nil {
+			return 0, exterr.WrapWithFrame(err)
+		}
+		return id, nil
+	}
+
+	result, err := e.ExecContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return 0, exterr.WrapWithFrame(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, exterr.WrapWithFrame(err)
+	}
+
+	return id, nil
+}
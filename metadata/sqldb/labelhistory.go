@@ -0,0 +1,79 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+const selectLabelHistory = "SELECT id, team, project, name, label, from_version, to_version, actor, request_id, created FROM label_history"
+
+// addLabelHistory records move of label within transaction which moves it. Actor
+// and request are taken from ctx, moves which don't change version aren't recorded
+func (m *Model) addLabelHistory(ctx context.Context, e execer, id app.ServableID, label string, fromVersion, toVersion int64) error {
+	if label == "" || fromVersion == toVersion {
+		return nil
+	}
+
+	_, err := m.dialect.insert(ctx, e, "INSERT INTO label_history (team, project, name, label, from_version, to_version, actor, request_id, created) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id.Team,
+		id.Project,
+		id.Name,
+		label,
+		fromVersion,
+		toVersion,
+		logging.Identity(ctx),
+		logging.RequestID(ctx),
+		time.Now().Unix())
+
+	return err
+}
+
+// ListLabelHistory lists moves of model label, the latest move is the first one
+func (m *Model) ListLabelHistory(ctx context.Context, id app.ServableID, label string) (_ []app.LabelHistoryEntry, err error) {
+	defer metrics.ObserveMetadataOperation("model_list_label_history", time.Now(), &err)
+
+	rows, err := m.connection.QueryContext(ctx, m.dialect.rebind(selectLabelHistory+" WHERE team = ? AND project = ? AND name = ? AND label = ? ORDER BY id DESC"),
+		id.Team, id.Project, id.Name, label)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	var entries []app.LabelHistoryEntry
+	for rows.Next() {
+		var entry app.LabelHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.Team, &entry.Project, &entry.Name, &entry.Label, &entry.FromVersion, &entry.ToVersion, &entry.Actor, &entry.RequestID, &entry.Created); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return entries, nil
+}
+
+// GetLabelHistoryEntry gets single move of model label, nil is returned when it doesn't exist
+func (m *Model) GetLabelHistoryEntry(ctx context.Context, id app.ServableID, label string, entryID int64) (_ *app.LabelHistoryEntry, err error) {
+	defer metrics.ObserveMetadataOperation("model_get_label_history_entry", time.Now(), &err)
+
+	var entry app.LabelHistoryEntry
+	err = m.connection.QueryRowContext(ctx, m.dialect.rebind(selectLabelHistory+" WHERE id = ? AND team = ? AND project = ? AND name = ? AND label = ?"),
+		entryID, id.Team, id.Project, id.Name, label).
+		Scan(&entry.ID, &entry.Team, &entry.Project, &entry.Name, &entry.Label, &entry.FromVersion, &entry.ToVersion, &entry.Actor, &entry.RequestID, &entry.Created)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return &entry, nil
+}
@@ -0,0 +1,61 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+func TestModel_LabelHistory(t *testing.T) {
+	ctx := logging.WithIdentity(context.Background(), "alice")
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	id := app.ServableID{Team: "team", Project: "project", Name: "name"}
+	for _, version := range []int64{1, 2, 2} {
+		model := app.ModelData{ModelID: app.ModelID{ServableID: id, Version: version, Label: "canary"}, Status: app.StatusReady}
+		if err := db.Model.ChangeLabel(ctx, model); err != nil {
+			t.Fatalf("ChangeLabel() error = %v", err)
+		}
+	}
+	labeled, err := db.Model.Get(ctx, app.QueryParameters{"team": "team", "project": "project", "name": "name", "label": "canary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model.Delete(ctx, labeled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model.Delete(ctx, labeled.ID); err != errorDeleteModel {
+		t.Errorf("Delete() of deleted model error = %v, want %v", err, errorDeleteModel)
+	}
+
+	entries, err := db.Model.ListLabelHistory(ctx, id, "canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int64{{2, 0}, {1, 2}, {0, 1}}
+	if len(entries) != len(want) {
+		t.Fatalf("ListLabelHistory() = %+v, want %d entries", entries, len(want))
+	}
+	for i, entry := range entries {
+		if entry.FromVersion != want[i][0] || entry.ToVersion != want[i][1] || entry.Actor != "alice" || entry.Label != "canary" {
+			t.Errorf("ListLabelHistory()[%d] = %+v, want move from %d to %d by alice", i, entry, want[i][0], want[i][1])
+		}
+	}
+
+	if other, err := db.Model.ListLabelHistory(ctx, id, "stable"); err != nil || len(other) != 0 {
+		t.Errorf("ListLabelHistory() of other label = %v, %v, want empty", other, err)
+	}
+
+	entry, err := db.Model.GetLabelHistoryEntry(ctx, id, "canary", entries[1].ID)
+	if err != nil || entry == nil || *entry != entries[1] {
+		t.Errorf("GetLabelHistoryEntry() = %+v, %v, want %+v", entry, err, entries[1])
+	}
+	if entry, err := db.Model.GetLabelHistoryEntry(ctx, id, "stable", entries[1].ID); err != nil || entry != nil {
+		t.Errorf("GetLabelHistoryEntry() of other label = %+v, %v, want nil", entry, err)
+	}
+}
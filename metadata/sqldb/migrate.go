@@ -0,0 +1,224 @@
+package sqldb
+
+import (
+	"context"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const schemaMigrationsDefinition = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT NOT NULL PRIMARY KEY,
+	applied BIGINT NOT NULL)`
+
+const (
+	// migrationsLockName is name of MySQL lock taken while migrations are applied
+	migrationsLockName = "tfd_schema_migrations"
+	// migrationsAdvisoryLockID is key of PostgreSQL advisory lock taken while migrations are applied
+	migrationsAdvisoryLockID = 7406351220
+	// migrationsLockRetryInterval is time after which lock held by other replica is tried again
+	migrationsLockRetryInterval = time.Second
+)
+
+var (
+	schemaOutdatedErrorCode = 1008
+
+	errorSchemaOutdated = exterr.NewErrorWithMessage("metadata schema is outdated, run `bootstrap migrate up`").WithComponent(app.ComponentMetadata).WithCode(schemaOutdatedErrorCode)
+)
+
+// MigrationStatus describes state of single schema migration
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	// Applied holds unix timestamp of migration, zero when migration is pending
+	Applied int64
+}
+
+// MigrationsStatus returns status of every known migration ordered by version
+func (s *SQLDB) MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations[s.driver] {
+		statuses = append(statuses, MigrationStatus{Version: m.version, Description: m.description, Applied: applied[m.version]})
+	}
+
+	return statuses, nil
+}
+
+// MigrateUp applies every pending migration and returns their versions. Replicas
+// sharing database wait for each other, so every migration is applied once
+func (s *SQLDB) MigrateUp(ctx context.Context) ([]int64, error) {
+	unlock, err := s.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int64
+	for _, m := range migrations[s.driver] {
+		if applied[m.version] != 0 {
+			continue
+		}
+
+		if err := s.runMigration(ctx, m.up, "INSERT INTO schema_migrations (version, applied) VALUES (?, ?)", m.version, time.Now().Unix()); err != nil {
+			return versions, err
+		}
+		versions = append(versions, m.version)
+	}
+
+	return versions, nil
+}
+
+// MigrateDown reverts the latest applied migration and returns its version,
+// version is zero when there is nothing to revert
+func (s *SQLDB) MigrateDown(ctx context.Context) (int64, error) {
+	unlock, err := s.lockMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	known := migrations[s.driver]
+	for i := len(known) - 1; i >= 0; i-- {
+		m := known[i]
+		if applied[m.version] == 0 {
+			continue
+		}
+
+		if err := s.runMigration(ctx, m.down, "DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+			return 0, err
+		}
+
+		return m.version, nil
+	}
+
+	return 0, nil
+}
+
+// CheckSchema returns error when any migration is not applied
+func (s *SQLDB) CheckSchema(ctx context.Context) error {
+	statuses, err := s.MigrationsStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.Applied == 0 {
+			return errorSchemaOutdated
+		}
+	}
+
+	return nil
+}
+
+// lockMigrations waits for database lock which serializes migrations of replicas sharing
+// database and returns function releasing it. Lock is held by dedicated connection, so it
+// isn't released by other queries. SQLite has no such lock, its writers are serialized
+// and migration applied concurrently is rolled back by primary key of schema_migrations
+func (s *SQLDB) lockMigrations(ctx context.Context) (func(), error) {
+	if s.dialect.tryLockMigrations == "" {
+		return func() {}, nil
+	}
+
+	conn, err := s.connection.Conn(ctx)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, s.dialect.tryLockMigrations).Scan(&locked); err != nil {
+			conn.Close()
+			return nil, exterr.WrapWithFrame(err)
+		}
+		if locked {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, exterr.WrapWithFrame(ctx.Err())
+		case <-time.After(migrationsLockRetryInterval):
+		}
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), s.dialect.unlockMigrations); err != nil {
+			logging.ErrorWithStackWithoutRequestID(context.Background(), exterr.WrapWithFrame(err))
+		}
+		conn.Close()
+	}, nil
+}
+
+// appliedMigrations returns applied migrations versions mapped to time of migration
+func (s *SQLDB) appliedMigrations(ctx context.Context) (map[int64]int64, error) {
+	if _, err := s.connection.ExecContext(ctx, schemaMigrationsDefinition); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	rows, err := s.connection.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]int64)
+	for rows.Next() {
+		var version, timestamp int64
+		if err := rows.Scan(&version, &timestamp); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		applied[version] = timestamp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return applied, nil
+}
+
+// runMigration executes migration statements and bookkeeping query in single transaction.
+// Note that MySQL commits DDL statements implicitly
+func (s *SQLDB) runMigration(ctx context.Context, statements []string, bookkeeping string, args ...interface{}) error {
+	tx, err := s.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+
+			return exterr.WrapWithFrame(err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(bookkeeping), args...); err != nil {
+		tx.Rollback()
+
+		return exterr.WrapWithFrame(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package sqldb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSQLDB_Migrate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+
+	if err := db.CheckSchema(ctx); err != errorSchemaOutdated {
+		t.Errorf("CheckSchema() of empty database error = %v, want %v", err, errorSchemaOutdated)
+	}
+
+	var wantVersions []int64
+	for _, m := range migrations[DriverSQLite3] {
+		wantVersions = append(wantVersions, m.version)
+	}
+
+	versions, err := db.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+	if !reflect.DeepEqual(versions, wantVersions) {
+		t.Errorf("MigrateUp() = %v, want %v", versions, wantVersions)
+	}
+
+	if versions, err := db.MigrateUp(ctx); err != nil || len(versions) != 0 {
+		t.Errorf("MigrateUp() of migrated database = %v, %v, want no migrations", versions, err)
+	}
+
+	if err := db.CheckSchema(ctx); err != nil {
+		t.Errorf("CheckSchema() of migrated database error = %v", err)
+	}
+
+	latest := wantVersions[len(wantVersions)-1]
+	version, err := db.MigrateDown(ctx)
+	if err != nil {
+		t.Fatalf("MigrateDown() error = %v", err)
+	}
+	if version != latest {
+		t.Errorf("MigrateDown() = %d, want %d", version, latest)
+	}
+
+	statuses, err := db.MigrationsStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationsStatus() error = %v", err)
+	}
+	if status := statuses[len(statuses)-1]; status.Applied != 0 {
+		t.Errorf("MigrationsStatus() of reverted migration = %+v, want pending", status)
+	}
+}
+
+func Test_migrations(t *testing.T) {
+	for driver, driverMigrations := range migrations {
+		if _, err := newDialect(driver); err != nil {
+			t.Errorf("migrations of unsupported driver %s", driver)
+		}
+
+		for i, m := range driverMigrations {
+			if m.version != int64(i+1) {
+				t.Errorf("%s migration %d has version %d, want %d", driver, i, m.version, i+1)
+			}
+			if len(m.up) == 0 || len(m.down) == 0 {
+				t.Errorf("%s migration %d has no up or down statements", driver, m.version)
+			}
+		}
+	}
+
+	if len(migrations[DriverPostgres]) != len(migrations[DriverSQLite3]) || len(migrations[DriverMySQL]) != len(migrations[DriverSQLite3]) {
+		t.Errorf("every driver should have the same number of migrations")
+	}
+}
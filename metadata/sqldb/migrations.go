@@ -0,0 +1,541 @@
+package sqldb
+
+// migration is a single, ordered schema change. Statements are executed
+// one by one, because not every driver allows multiple statements in a single query
+type migration struct {
+	version     int64
+	description string
+	up          []string
+	down        []string
+}
+
+// migrations holds ordered schema migrations of every supported driver.
+// New migrations must be appended with the next version for every driver
+//
+// Migration 1 uses "IF NOT EXISTS" clauses, so it can be safely applied
+// to databases created by bootstrap before migrations were introduced
+var migrations = map[string][]migration{
+	DriverSQLite3: {
+		{
+			version:     1,
+			description: "create model and module tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				status INTEGER NOT NULL,
+				created INTEGER NOT NULL,
+				updated INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model ON model (team, project, name, version, label)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_label ON model (team, project, name, label) WHERE label!=""`,
+				`CREATE TABLE IF NOT EXISTS module (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				created INTEGER NOT NULL,
+				updated INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_module ON module (team, project, name, version)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS module`,
+				`DROP TABLE IF EXISTS model`,
+			},
+		},
+		{
+			version:     2,
+			description: "create auth_token table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS auth_token (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				token_hash CHAR(64) NOT NULL,
+				subject VARCHAR(250) NOT NULL,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				role VARCHAR(16) NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_token ON auth_token (token_hash, team, project)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS auth_token`,
+			},
+		},
+		{
+			version:     3,
+			description: "create model_signature table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_signature (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				signatures TEXT NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_signature ON model_signature (team, project, name, version)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_signature`,
+			},
+		},
+		{
+			version:     4,
+			description: "create model_smoke_test and model_smoke_test_result tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_smoke_test (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				request TEXT NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_smoke_test ON model_smoke_test (team, project, name)`,
+				`CREATE TABLE IF NOT EXISTS model_smoke_test_result (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				instance VARCHAR(250) NOT NULL,
+				passed INTEGER NOT NULL,
+				latency_ms INTEGER NOT NULL,
+				error TEXT NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_smoke_test_result ON model_smoke_test_result (team, project, name, version, instance)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_smoke_test_result`,
+				`DROP TABLE IF EXISTS model_smoke_test`,
+			},
+		},
+		{
+			version:     5,
+			description: "create model_rollout table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_rollout (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				steps TEXT NOT NULL,
+				state VARCHAR(16) NOT NULL,
+				step INTEGER NOT NULL,
+				step_started INTEGER NOT NULL,
+				moves TEXT NOT NULL,
+				error TEXT NOT NULL,
+				created INTEGER NOT NULL,
+				updated INTEGER NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_model_rollout ON model_rollout (team, project, name)`,
+				`CREATE INDEX IF NOT EXISTS idx_model_rollout_state ON model_rollout (state)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_rollout`,
+			},
+		},
+		{
+			version:     6,
+			description: "create label_history table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS label_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				from_version INTEGER NOT NULL,
+				to_version INTEGER NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_label_history ON label_history (team, project, name, label)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS label_history`,
+			},
+		},
+		{
+			version:     7,
+			description: "create audit_log table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				operation VARCHAR(64) NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version INTEGER NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				outcome VARCHAR(16) NOT NULL,
+				status INTEGER NOT NULL,
+				error_code VARCHAR(64) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created INTEGER NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log ON audit_log (team, project, name)`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS audit_log`,
+			},
+		},
+	},
+	DriverPostgres: {
+		{
+			version:     1,
+			description: "create model and module tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				status SMALLINT NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model ON model (team, project, name, version, label)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_label ON model (team, project, name, label) WHERE label <> ''`,
+				`CREATE TABLE IF NOT EXISTS module (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_module ON module (team, project, name, version)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS module`,
+				`DROP TABLE IF EXISTS model`,
+			},
+		},
+		{
+			version:     2,
+			description: "create auth_token table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS auth_token (
+				id BIGSERIAL PRIMARY KEY,
+				token_hash CHAR(64) NOT NULL,
+				subject VARCHAR(250) NOT NULL,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				role VARCHAR(16) NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_token ON auth_token (token_hash, team, project)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS auth_token`,
+			},
+		},
+		{
+			version:     3,
+			description: "create model_signature table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_signature (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				signatures TEXT NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_signature ON model_signature (team, project, name, version)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_signature`,
+			},
+		},
+		{
+			version:     4,
+			description: "create model_smoke_test and model_smoke_test_result tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_smoke_test (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				request TEXT NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_smoke_test ON model_smoke_test (team, project, name)`,
+				`CREATE TABLE IF NOT EXISTS model_smoke_test_result (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				instance VARCHAR(250) NOT NULL,
+				passed SMALLINT NOT NULL,
+				latency_ms BIGINT NOT NULL,
+				error TEXT NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_model_smoke_test_result ON model_smoke_test_result (team, project, name, version, instance)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_smoke_test_result`,
+				`DROP TABLE IF EXISTS model_smoke_test`,
+			},
+		},
+		{
+			version:     5,
+			description: "create model_rollout table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_rollout (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				steps TEXT NOT NULL,
+				state VARCHAR(16) NOT NULL,
+				step INTEGER NOT NULL,
+				step_started BIGINT NOT NULL,
+				moves TEXT NOT NULL,
+				error TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_model_rollout ON model_rollout (team, project, name)`,
+				`CREATE INDEX IF NOT EXISTS idx_model_rollout_state ON model_rollout (state)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_rollout`,
+			},
+		},
+		{
+			version:     6,
+			description: "create label_history table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS label_history (
+				id BIGSERIAL PRIMARY KEY,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				from_version BIGINT NOT NULL,
+				to_version BIGINT NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_label_history ON label_history (team, project, name, label)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS label_history`,
+			},
+		},
+		{
+			version:     7,
+			description: "create audit_log table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS audit_log (
+				id BIGSERIAL PRIMARY KEY,
+				operation VARCHAR(64) NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				team VARCHAR(250) NOT NULL,
+				project VARCHAR(250) NOT NULL,
+				name VARCHAR(250) NOT NULL,
+				version BIGINT NOT NULL,
+				label VARCHAR(250) NOT NULL,
+				outcome VARCHAR(16) NOT NULL,
+				status INTEGER NOT NULL,
+				error_code VARCHAR(64) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created BIGINT NOT NULL)`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log ON audit_log (team, project, name)`,
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor)`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS audit_log`,
+			},
+		},
+	},
+	// MySQL has no partial indexes, uniqueness of labels is guarded by generated
+	// column which is NULL for unlabeled versions. Column lengths are shorter
+	// to fit composite indexes in InnoDB key length limit
+	DriverMySQL: {
+		{
+			version:     1,
+			description: "create model and module tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				label VARCHAR(64) NOT NULL,
+				status TINYINT UNSIGNED NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL,
+				label_key VARCHAR(64) AS (NULLIF(label, '')) STORED,
+				UNIQUE KEY idx_model (team, project, name, version, label),
+				UNIQUE KEY idx_model_label (team, project, name, label_key)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE IF NOT EXISTS module (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL,
+				UNIQUE KEY idx_module (team, project, name, version)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS module`,
+				`DROP TABLE IF EXISTS model`,
+			},
+		},
+		{
+			version:     2,
+			description: "create auth_token table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS auth_token (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				token_hash CHAR(64) NOT NULL,
+				subject VARCHAR(64) NOT NULL,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				role VARCHAR(16) NOT NULL,
+				created BIGINT NOT NULL,
+				UNIQUE KEY idx_auth_token (token_hash, team, project)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS auth_token`,
+			},
+		},
+		{
+			version:     3,
+			description: "create model_signature table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_signature (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				signatures MEDIUMTEXT NOT NULL,
+				created BIGINT NOT NULL,
+				UNIQUE KEY idx_model_signature (team, project, name, version)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_signature`,
+			},
+		},
+		{
+			version:     4,
+			description: "create model_smoke_test and model_smoke_test_result tables",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_smoke_test (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				request MEDIUMTEXT NOT NULL,
+				created BIGINT NOT NULL,
+				UNIQUE KEY idx_model_smoke_test (team, project, name)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE IF NOT EXISTS model_smoke_test_result (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				instance VARCHAR(250) NOT NULL,
+				passed TINYINT UNSIGNED NOT NULL,
+				latency_ms BIGINT NOT NULL,
+				error TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				UNIQUE KEY idx_model_smoke_test_result (team, project, name, version, instance)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_smoke_test_result`,
+				`DROP TABLE IF EXISTS model_smoke_test`,
+			},
+		},
+		{
+			version:     5,
+			description: "create model_rollout table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS model_rollout (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				steps MEDIUMTEXT NOT NULL,
+				state VARCHAR(16) NOT NULL,
+				step INT NOT NULL,
+				step_started BIGINT NOT NULL,
+				moves MEDIUMTEXT NOT NULL,
+				error TEXT NOT NULL,
+				created BIGINT NOT NULL,
+				updated BIGINT NOT NULL,
+				KEY idx_model_rollout (team, project, name),
+				KEY idx_model_rollout_state (state)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS model_rollout`,
+			},
+		},
+		{
+			version:     6,
+			description: "create label_history table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS label_history (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				label VARCHAR(64) NOT NULL,
+				from_version BIGINT NOT NULL,
+				to_version BIGINT NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created BIGINT NOT NULL,
+				KEY idx_label_history (team, project, name, label)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS label_history`,
+			},
+		},
+		{
+			version:     7,
+			description: "create audit_log table",
+			up: []string{
+				`CREATE TABLE IF NOT EXISTS audit_log (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				operation VARCHAR(64) NOT NULL,
+				actor VARCHAR(250) NOT NULL,
+				team VARCHAR(64) NOT NULL,
+				project VARCHAR(64) NOT NULL,
+				name VARCHAR(64) NOT NULL,
+				version BIGINT NOT NULL,
+				label VARCHAR(64) NOT NULL,
+				outcome VARCHAR(16) NOT NULL,
+				status INTEGER NOT NULL,
+				error_code VARCHAR(64) NOT NULL,
+				request_id VARCHAR(64) NOT NULL,
+				created BIGINT NOT NULL,
+				KEY idx_audit_log (team, project, name),
+				KEY idx_audit_log_actor (actor)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+			down: []string{
+				`DROP TABLE IF EXISTS audit_log`,
+			},
+		},
+	},
+}
@@ -8,19 +8,23 @@ import (
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 	"github.com/grupawp/tensorflow-deploy/metadata"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
 // Model ...
 type Model struct {
 	connection *sql.DB
+	dialect    *dialect
 }
 
 // Get gets single model metadata
-func (m *Model) Get(ctx context.Context, parameters app.QueryParameters) (*app.ModelData, error) {
+func (m *Model) Get(ctx context.Context, parameters app.QueryParameters) (_ *app.ModelData, err error) {
+	defer metrics.ObserveMetadataOperation("model_get", time.Now(), &err)
+
 	model := new(app.ModelData)
 	var statusID uint8
 
-	query, queryValues, err := buildExtendedSearchQueryAndValues("SELECT id, team, project, name, version, label, status, created, updated FROM model", "LIMIT 1", parameters)
+	query, queryValues, err := buildExtendedSearchQueryAndValues(m.dialect, "SELECT id, team, project, name, version, label, status, created, updated FROM model", "LIMIT 1", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -42,14 +46,16 @@ func (m *Model) Get(ctx context.Context, parameters app.QueryParameters) (*app.M
 }
 
 // Add inserts model metadata
-func (m *Model) Add(ctx context.Context, model app.ModelData) (int64, error) {
+func (m *Model) Add(ctx context.Context, model app.ModelData) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("model_add", time.Now(), &err)
+
 	status, err := metadata.StatusToID(model.Status)
 	if err != nil {
 		return metadata.InvalidID, err
 	}
 
 	timestamp := time.Now().Unix()
-	result, err := m.connection.ExecContext(ctx, "INSERT INTO model (team, project, name, version, label, status, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+	id, err := m.dialect.insert(ctx, m.connection, "INSERT INTO model (team, project, name, version, label, status, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
 		model.Team,
 		model.Project,
 		model.Name,
@@ -59,25 +65,22 @@ func (m *Model) Add(ctx context.Context, model app.ModelData) (int64, error) {
 		timestamp,
 		timestamp)
 	if err != nil {
-		return metadata.InvalidID, exterr.WrapWithFrame(err)
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return metadata.InvalidID, exterr.WrapWithFrame(err)
+		return metadata.InvalidID, err
 	}
 
 	return id, nil
 }
 
 // UpdateStatus updates model status
-func (m *Model) UpdateStatus(ctx context.Context, id int64, status string) error {
+func (m *Model) UpdateStatus(ctx context.Context, id int64, status string) (err error) {
+	defer metrics.ObserveMetadataOperation("model_update_status", time.Now(), &err)
+
 	statusID, err := metadata.StatusToID(status)
 	if err != nil {
 		return err
 	}
 
-	result, err := m.connection.ExecContext(ctx, "UPDATE model SET status = ?, updated = ? WHERE id = ? AND status != ?",
+	result, err := m.connection.ExecContext(ctx, m.dialect.rebind("UPDATE model SET status = ?, updated = ? WHERE id = ? AND status != ?"),
 		statusID,
 		time.Now().Unix(),
 		id,
@@ -97,25 +100,51 @@ func (m *Model) UpdateStatus(ctx context.Context, id int64, status string) error
 }
 
 // Delete deletes model metadata
-func (m *Model) Delete(ctx context.Context, id int64) error {
-	result, err := m.connection.ExecContext(ctx, "DELETE FROM model WHERE id = ?", id)
+func (m *Model) Delete(ctx context.Context, id int64) (err error) {
+	defer metrics.ObserveMetadataOperation("model_delete", time.Now(), &err)
+
+	tx, err := m.connection.BeginTx(ctx, nil)
 	if err != nil {
 		return exterr.WrapWithFrame(err)
 	}
 
-	affected, err := result.RowsAffected()
+	var model app.ModelData
+	query := m.dialect.forUpdate("SELECT team, project, name, version, label FROM model WHERE id = ?")
+	err = tx.QueryRowContext(ctx, m.dialect.rebind(query), id).Scan(&model.Team, &model.Project, &model.Name, &model.Version, &model.Label)
 	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return errorDeleteModel
+		}
+		return exterr.WrapWithFrame(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM model WHERE id = ?"), id); err != nil {
+		tx.Rollback()
+
+		return exterr.WrapWithFrame(err)
+	}
+
+	// labeled row is deleted when label is removed or its version is deleted
+	if err := m.addLabelHistory(ctx, tx, model.ServableID, model.Label, model.Version, 0); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return exterr.WrapWithFrame(err)
-	} else if affected == 0 {
-		return errorDeleteModel
 	}
 
 	return nil
 }
 
 // NextVersion returns next available model version
-func (m *Model) NextVersion(ctx context.Context, parameters app.QueryParameters) (int64, error) {
-	query, queryValues, err := buildExtendedSearchQueryAndValues("SELECT version FROM model", "ORDER BY version DESC LIMIT 1", parameters)
+func (m *Model) NextVersion(ctx context.Context, parameters app.QueryParameters) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("model_next_version", time.Now(), &err)
+
+	query, queryValues, err := buildExtendedSearchQueryAndValues(m.dialect, "SELECT version FROM model", "ORDER BY version DESC LIMIT 1", parameters)
 	if err != nil {
 		return metadata.InvalidVersion, err
 	}
@@ -133,8 +162,10 @@ func (m *Model) NextVersion(ctx context.Context, parameters app.QueryParameters)
 }
 
 // List lists models metadata
-func (m *Model) List(ctx context.Context, parameters app.QueryParameters) ([]*app.ModelData, error) {
-	query, queryValues, err := buildSearchQueryAndValues("SELECT id, team, project, name, version, label, status, created, updated FROM model", parameters)
+func (m *Model) List(ctx context.Context, parameters app.QueryParameters) (_ []*app.ModelData, err error) {
+	defer metrics.ObserveMetadataOperation("model_list", time.Now(), &err)
+
+	query, queryValues, err := buildSearchQueryAndValues(m.dialect, "SELECT id, team, project, name, version, label, status, created, updated FROM model", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +205,9 @@ func (m *Model) List(ctx context.Context, parameters app.QueryParameters) ([]*ap
 }
 
 // ListUniqueTeamProject lists distinct keys (team, project)
-func (m *Model) ListUniqueTeamProject(ctx context.Context) ([]*app.ServableID, error) {
+func (m *Model) ListUniqueTeamProject(ctx context.Context) (_ []*app.ServableID, err error) {
+	defer metrics.ObserveMetadataOperation("model_list_unique_team_project", time.Now(), &err)
+
 	servables := make([]*app.ServableID, 0)
 
 	rows, err := m.connection.QueryContext(ctx, "SELECT team, project FROM model GROUP BY team, project ORDER BY team, project")
@@ -204,7 +237,9 @@ func (m *Model) ListUniqueTeamProject(ctx context.Context) ([]*app.ServableID, e
 }
 
 // RemoveLabel removes model label from metadata for given ModelData
-func (m *Model) RemoveLabel(ctx context.Context, model app.ModelData) error {
+func (m *Model) RemoveLabel(ctx context.Context, model app.ModelData) (err error) {
+	defer metrics.ObserveMetadataOperation("model_remove_label", time.Now(), &err)
+
 	params := app.QueryParameters{"team": model.Team, "project": model.Project,
 		"name": model.Name, "label": model.Label}
 
@@ -215,22 +250,32 @@ func (m *Model) RemoveLabel(ctx context.Context, model app.ModelData) error {
 	return m.Delete(ctx, currentLabeledModel.ID)
 }
 
-func (m *Model) ChangeLabel(ctx context.Context, model app.ModelData) error {
-	params := app.QueryParameters{"team": model.Team, "project": model.Project,
-		"name": model.Name, "label": model.Label}
+// ChangeLabel moves model label to given model version. Currently labeled
+// row is locked, deleted and replaced within single transaction
+func (m *Model) ChangeLabel(ctx context.Context, model app.ModelData) (err error) {
+	defer metrics.ObserveMetadataOperation("model_change_label", time.Now(), &err)
 
-	currentLabeledModel, err := m.Get(ctx, params)
+	status, err := metadata.StatusToID(model.Status)
 	if err != nil {
 		return err
 	}
 
-	tx, err := m.connection.Begin()
+	tx, err := m.connection.BeginTx(ctx, nil)
 	if err != nil {
 		return exterr.WrapWithFrame(err)
 	}
 
-	if currentLabeledModel != nil {
-		result, err := tx.ExecContext(ctx, "DELETE FROM model WHERE id = ?", currentLabeledModel.ID)
+	var currentID, currentVersion int64
+	query := m.dialect.forUpdate("SELECT id, version FROM model WHERE team = ? AND project = ? AND name = ? AND label = ? LIMIT 1")
+	err = tx.QueryRowContext(ctx, m.dialect.rebind(query), model.Team, model.Project, model.Name, model.Label).Scan(&currentID, &currentVersion)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+
+		return exterr.WrapWithFrame(err)
+	}
+
+	if err == nil {
+		result, err := tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM model WHERE id = ?"), currentID)
 		if err != nil {
 			tx.Rollback()
 
@@ -249,15 +294,8 @@ func (m *Model) ChangeLabel(ctx context.Context, model app.ModelData) error {
 		}
 	}
 
-	status, err := metadata.StatusToID(model.Status)
-	if err != nil {
-		tx.Rollback()
-
-		return err
-	}
-
 	timestamp := time.Now().Unix()
-	_, err = tx.ExecContext(ctx, "INSERT INTO model (team, project, name, version, label, status, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+	_, err = m.dialect.insert(ctx, tx, "INSERT INTO model (team, project, name, version, label, status, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
 		model.Team,
 		model.Project,
 		model.Name,
@@ -269,7 +307,13 @@ func (m *Model) ChangeLabel(ctx context.Context, model app.ModelData) error {
 	if err != nil {
 		tx.Rollback()
 
-		return exterr.WrapWithFrame(err)
+		return err
+	}
+
+	if err := m.addLabelHistory(ctx, tx, model.ServableID, model.Label, currentVersion, model.Version); err != nil {
+		tx.Rollback()
+
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -280,7 +324,9 @@ func (m *Model) ChangeLabel(ctx context.Context, model app.ModelData) error {
 }
 
 // IsStatusPending checks if status is set to pending
-func (m *Model) IsStatusPending(ctx context.Context, servableID app.ServableID) (bool, error) {
+func (m *Model) IsStatusPending(ctx context.Context, servableID app.ServableID) (_ bool, err error) {
+	defer metrics.ObserveMetadataOperation("model_is_status_pending", time.Now(), &err)
+
 	return m.isStatusSet(ctx, servableID, metadata.StatusPending)
 }
 
@@ -288,7 +334,7 @@ func (m *Model) IsStatusPending(ctx context.Context, servableID app.ServableID)
 func (m *Model) isStatusSet(ctx context.Context, servableID app.ServableID, status uint8) (bool, error) {
 	var statusID uint8
 
-	err := m.connection.QueryRowContext(ctx, "SELECT status FROM model WHERE team = ? AND project = ? AND name = ? AND status = ? LIMIT 1", servableID.Team, servableID.Project, servableID.Name, status).Scan(&statusID)
+	err := m.connection.QueryRowContext(ctx, m.dialect.rebind("SELECT status FROM model WHERE team = ? AND project = ? AND name = ? AND status = ? LIMIT 1"), servableID.Team, servableID.Project, servableID.Name, status).Scan(&statusID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
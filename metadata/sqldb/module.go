@@ -8,18 +8,22 @@ import (
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 	"github.com/grupawp/tensorflow-deploy/metadata"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
 // Module ...
 type Module struct {
 	connection *sql.DB
+	dialect    *dialect
 }
 
 // Get gets single module metadata
-func (m *Module) Get(ctx context.Context, parameters app.QueryParameters) (*app.ModuleData, error) {
+func (m *Module) Get(ctx context.Context, parameters app.QueryParameters) (_ *app.ModuleData, err error) {
+	defer metrics.ObserveMetadataOperation("module_get", time.Now(), &err)
+
 	module := new(app.ModuleData)
 
-	query, queryValues, err := buildExtendedSearchQueryAndValues("SELECT id, team, project, name, version, created, updated FROM module", "LIMIT 1", parameters)
+	query, queryValues, err := buildExtendedSearchQueryAndValues(m.dialect, "SELECT id, team, project, name, version, created, updated FROM module", "LIMIT 1", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -36,9 +40,11 @@ func (m *Module) Get(ctx context.Context, parameters app.QueryParameters) (*app.
 }
 
 // Add inserts module metadata
-func (m *Module) Add(ctx context.Context, module app.ModuleData) (int64, error) {
+func (m *Module) Add(ctx context.Context, module app.ModuleData) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("module_add", time.Now(), &err)
+
 	timestamp := time.Now().Unix()
-	result, err := m.connection.ExecContext(ctx, "INSERT INTO module (team, project, name, version, created, updated) VALUES(?, ?, ?, ?, ?, ?)",
+	id, err := m.dialect.insert(ctx, m.connection, "INSERT INTO module (team, project, name, version, created, updated) VALUES(?, ?, ?, ?, ?, ?)",
 		module.Team,
 		module.Project,
 		module.Name,
@@ -46,20 +52,17 @@ func (m *Module) Add(ctx context.Context, module app.ModuleData) (int64, error)
 		timestamp,
 		timestamp)
 	if err != nil {
-		return metadata.InvalidID, exterr.WrapWithFrame(err)
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return metadata.InvalidID, exterr.WrapWithFrame(err)
+		return metadata.InvalidID, err
 	}
 
 	return id, nil
 }
 
 // Delete deletes module metadata
-func (m *Module) Delete(ctx context.Context, id int64) error {
-	result, err := m.connection.ExecContext(ctx, "DELETE FROM module WHERE id = ?", id)
+func (m *Module) Delete(ctx context.Context, id int64) (err error) {
+	defer metrics.ObserveMetadataOperation("module_delete", time.Now(), &err)
+
+	result, err := m.connection.ExecContext(ctx, m.dialect.rebind("DELETE FROM module WHERE id = ?"), id)
 	if err != nil {
 		return exterr.WrapWithFrame(err)
 	}
@@ -75,8 +78,10 @@ func (m *Module) Delete(ctx context.Context, id int64) error {
 }
 
 // NextVersion returns next available module version
-func (m *Module) NextVersion(ctx context.Context, parameters app.QueryParameters) (int64, error) {
-	query, queryValues, err := buildExtendedSearchQueryAndValues("SELECT version FROM module", "ORDER BY version DESC LIMIT 1", parameters)
+func (m *Module) NextVersion(ctx context.Context, parameters app.QueryParameters) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("module_next_version", time.Now(), &err)
+
+	query, queryValues, err := buildExtendedSearchQueryAndValues(m.dialect, "SELECT version FROM module", "ORDER BY version DESC LIMIT 1", parameters)
 	if err != nil {
 		return metadata.InvalidVersion, err
 	}
@@ -95,8 +100,10 @@ func (m *Module) NextVersion(ctx context.Context, parameters app.QueryParameters
 }
 
 // List modules metadata
-func (m *Module) List(ctx context.Context, parameters app.QueryParameters) ([]*app.ModuleData, error) {
-	query, queryValues, err := buildSearchQueryAndValues("SELECT id, team, project, name, version, created, updated FROM module", parameters)
+func (m *Module) List(ctx context.Context, parameters app.QueryParameters) (_ []*app.ModuleData, err error) {
+	defer metrics.ObserveMetadataOperation("module_list", time.Now(), &err)
+
+	query, queryValues, err := buildSearchQueryAndValues(m.dialect, "SELECT id, team, project, name, version, created, updated FROM module", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +137,9 @@ func (m *Module) List(ctx context.Context, parameters app.QueryParameters) ([]*a
 }
 
 // ListUniqueTeamProject lists distinct keys (team, project)
-func (m *Module) ListUniqueTeamProject(ctx context.Context) ([]*app.ServableID, error) {
+func (m *Module) ListUniqueTeamProject(ctx context.Context) (_ []*app.ServableID, err error) {
+	defer metrics.ObserveMetadataOperation("module_list_unique_team_project", time.Now(), &err)
+
 	servables := make([]*app.ServableID, 0)
 
 	rows, err := m.connection.QueryContext(ctx, "SELECT team, project FROM module GROUP BY team, project ORDER BY team, project")
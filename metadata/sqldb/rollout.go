@@ -0,0 +1,157 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+const selectRollout = "SELECT id, team, project, name, version, steps, state, step, step_started, moves, error, created, updated FROM model_rollout"
+
+// AddRollout stores new rollout of model version and returns its ID
+func (m *Model) AddRollout(ctx context.Context, rollout *app.Rollout) (_ int64, err error) {
+	defer metrics.ObserveMetadataOperation("model_add_rollout", time.Now(), &err)
+
+	steps, moves, err := encodeRollout(rollout)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	id, err := m.dialect.insert(ctx, m.connection, "INSERT INTO model_rollout (team, project, name, version, steps, state, step, step_started, moves, error, created, updated) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		rollout.Team,
+		rollout.Project,
+		rollout.Name,
+		rollout.Version,
+		steps,
+		rollout.State,
+		rollout.Step,
+		rollout.StepStarted,
+		moves,
+		rollout.Error,
+		now,
+		now)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateRollout stores progress of rollout, plan and model version can't be changed
+func (m *Model) UpdateRollout(ctx context.Context, rollout *app.Rollout) (err error) {
+	defer metrics.ObserveMetadataOperation("model_update_rollout", time.Now(), &err)
+
+	_, moves, err := encodeRollout(rollout)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.connection.ExecContext(ctx, m.dialect.rebind("UPDATE model_rollout SET state = ?, step = ?, step_started = ?, moves = ?, error = ?, updated = ? WHERE id = ?"),
+		rollout.State,
+		rollout.Step,
+		rollout.StepStarted,
+		moves,
+		rollout.Error,
+		time.Now().Unix(),
+		rollout.ID)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// GetRollout gets rollout of model, nil is returned when it doesn't exist
+func (m *Model) GetRollout(ctx context.Context, id app.ServableID, rolloutID int64) (_ *app.Rollout, err error) {
+	defer metrics.ObserveMetadataOperation("model_get_rollout", time.Now(), &err)
+
+	row := m.connection.QueryRowContext(ctx, m.dialect.rebind(selectRollout+" WHERE id = ? AND team = ? AND project = ? AND name = ?"),
+		rolloutID, id.Team, id.Project, id.Name)
+	rollout, err := scanRollout(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return rollout, nil
+}
+
+// ListRollouts lists rollouts of model, the latest rollout is the first one
+func (m *Model) ListRollouts(ctx context.Context, id app.ServableID) (_ []*app.Rollout, err error) {
+	defer metrics.ObserveMetadataOperation("model_list_rollouts", time.Now(), &err)
+
+	return m.listRollouts(ctx, selectRollout+" WHERE team = ? AND project = ? AND name = ? ORDER BY id DESC", id.Team, id.Project, id.Name)
+}
+
+// ListActiveRollouts lists running and paused rollouts of every model ordered by ID
+func (m *Model) ListActiveRollouts(ctx context.Context) (_ []*app.Rollout, err error) {
+	defer metrics.ObserveMetadataOperation("model_list_active_rollouts", time.Now(), &err)
+
+	return m.listRollouts(ctx, selectRollout+" WHERE state IN (?, ?) ORDER BY id", app.RolloutRunning, app.RolloutPaused)
+}
+
+func (m *Model) listRollouts(ctx context.Context, query string, args ...interface{}) ([]*app.Rollout, error) {
+	rows, err := m.connection.QueryContext(ctx, m.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	var rollouts []*app.Rollout
+	for rows.Next() {
+		rollout, err := scanRollout(rows)
+		if err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		rollouts = append(rollouts, rollout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return rollouts, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRollout(row scanner) (*app.Rollout, error) {
+	var rollout app.Rollout
+	var steps, moves string
+	err := row.Scan(&rollout.ID, &rollout.Team, &rollout.Project, &rollout.Name, &rollout.Version, &steps, &rollout.State,
+		&rollout.Step, &rollout.StepStarted, &moves, &rollout.Error, &rollout.Created, &rollout.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(steps), &rollout.Steps); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(moves), &rollout.Moves); err != nil {
+		return nil, err
+	}
+
+	return &rollout, nil
+}
+
+func encodeRollout(rollout *app.Rollout) (string, string, error) {
+	steps, err := json.Marshal(rollout.Steps)
+	if err != nil {
+		return "", "", exterr.WrapWithFrame(err)
+	}
+	moves, err := json.Marshal(rollout.Moves)
+	if err != nil {
+		return "", "", exterr.WrapWithFrame(err)
+	}
+
+	return string(steps), string(moves), nil
+}
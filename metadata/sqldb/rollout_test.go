@@ -0,0 +1,74 @@
+package sqldb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+func TestModel_Rollout(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	id := app.ServableID{Team: "team", Project: "project", Name: "name"}
+	plan := app.RolloutPlan{Steps: []app.RolloutStep{{Label: "canary"}, {Wait: "30m"}, {Gates: []string{app.GateSmokeTest}}, {Label: app.StableLabel}}}
+
+	var ids []int64
+	for _, version := range []int64{1, 2} {
+		rollout := &app.Rollout{ModelID: app.ModelID{ServableID: id, Version: version}, RolloutPlan: plan, State: app.RolloutRunning, StepStarted: 100}
+		rolloutID, err := db.Model.AddRollout(ctx, rollout)
+		if err != nil {
+			t.Fatalf("AddRollout() error = %v", err)
+		}
+		ids = append(ids, rolloutID)
+	}
+
+	if got, err := db.Model.GetRollout(ctx, app.ServableID{Team: "team", Project: "project", Name: "other"}, ids[0]); err != nil || got != nil {
+		t.Errorf("GetRollout() of other model = %v, %v, want nil", got, err)
+	}
+
+	got, err := db.Model.GetRollout(ctx, id, ids[0])
+	if err != nil || got == nil {
+		t.Fatalf("GetRollout() = %v, %v", got, err)
+	}
+	if got.Version != 1 || got.State != app.RolloutRunning || got.StepStarted != 100 || got.Moves != nil || !reflect.DeepEqual(got.RolloutPlan, plan) {
+		t.Errorf("GetRollout() = %+v", got)
+	}
+
+	got.State = app.RolloutFailed
+	got.Step = 2
+	got.Moves = []app.RolloutLabelMove{{Label: "canary", PreviousVersion: 0}}
+	got.Error = "gate failed"
+	if err := db.Model.UpdateRollout(ctx, got); err != nil {
+		t.Fatalf("UpdateRollout() error = %v", err)
+	}
+
+	updated, err := db.Model.GetRollout(ctx, id, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.State != app.RolloutFailed || updated.Step != 2 || updated.Error != "gate failed" || !reflect.DeepEqual(updated.Moves, got.Moves) {
+		t.Errorf("GetRollout() after update = %+v", updated)
+	}
+
+	active, err := db.Model.ListActiveRollouts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != ids[1] {
+		t.Errorf("ListActiveRollouts() = %+v, want rollout %d", active, ids[1])
+	}
+
+	all, err := db.Model.ListRollouts(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != ids[1] || all[1].ID != ids[0] {
+		t.Errorf("ListRollouts() = %+v, want latest first", all)
+	}
+}
@@ -0,0 +1,91 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+// AddSignatures stores signatures of model version as JSON. Signatures left by
+// removed version with the same number are replaced
+func (m *Model) AddSignatures(ctx context.Context, model app.ModelID, signatures app.Signatures) (err error) {
+	defer metrics.ObserveMetadataOperation("model_add_signatures", time.Now(), &err)
+
+	encoded, err := json.Marshal(signatures)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	tx, err := m.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	_, err = tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_signature WHERE team = ? AND project = ? AND name = ? AND version = ?"),
+		model.Team, model.Project, model.Name, model.Version)
+	if err != nil {
+		tx.Rollback()
+
+		return exterr.WrapWithFrame(err)
+	}
+
+	_, err = m.dialect.insert(ctx, tx, "INSERT INTO model_signature (team, project, name, version, signatures, created) VALUES(?, ?, ?, ?, ?, ?)",
+		model.Team,
+		model.Project,
+		model.Name,
+		model.Version,
+		string(encoded),
+		time.Now().Unix())
+	if err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// GetSignatures gets signatures of model version, nil is returned
+// when signatures of version haven't been stored
+func (m *Model) GetSignatures(ctx context.Context, model app.ModelID) (_ app.Signatures, err error) {
+	defer metrics.ObserveMetadataOperation("model_get_signatures", time.Now(), &err)
+
+	var encoded string
+	err = m.connection.QueryRowContext(ctx, m.dialect.rebind("SELECT signatures FROM model_signature WHERE team = ? AND project = ? AND name = ? AND version = ? LIMIT 1"),
+		model.Team, model.Project, model.Name, model.Version).Scan(&encoded)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	var signatures app.Signatures
+	if err := json.Unmarshal([]byte(encoded), &signatures); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return signatures, nil
+}
+
+// DeleteSignatures deletes signatures of model version
+func (m *Model) DeleteSignatures(ctx context.Context, model app.ModelID) (err error) {
+	defer metrics.ObserveMetadataOperation("model_delete_signatures", time.Now(), &err)
+
+	_, err = m.connection.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_signature WHERE team = ? AND project = ? AND name = ? AND version = ?"),
+		model.Team, model.Project, model.Name, model.Version)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
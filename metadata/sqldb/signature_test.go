@@ -0,0 +1,52 @@
+package sqldb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+func TestModel_Signatures(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	model := app.ModelID{ServableID: app.ServableID{Team: "team", Project: "project", Name: "name"}, Version: 1}
+	signatures := app.Signatures{
+		"serving_default": {
+			MethodName: "tensorflow/serving/predict",
+			Inputs:     map[string]app.TensorInfo{"images": {Name: "images:0", DType: "DT_FLOAT", Shape: []int64{-1, 28, 28}}},
+			Outputs:    map[string]app.TensorInfo{"scores": {Name: "scores:0", DType: "DT_FLOAT", Shape: []int64{}}, "debug": {DType: "DT_STRING"}},
+		},
+	}
+
+	if got, err := db.Model.GetSignatures(ctx, model); err != nil || got != nil {
+		t.Errorf("GetSignatures() of version without signatures = %v, %v, want nil", got, err)
+	}
+
+	// signatures left by removed version are replaced
+	for _, s := range []app.Signatures{{"old": {}}, signatures} {
+		if err := db.Model.AddSignatures(ctx, model, s); err != nil {
+			t.Fatalf("AddSignatures() error = %v", err)
+		}
+	}
+
+	got, err := db.Model.GetSignatures(ctx, model)
+	if err != nil {
+		t.Fatalf("GetSignatures() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, signatures) {
+		t.Errorf("GetSignatures() = %+v, want %+v", got, signatures)
+	}
+
+	if err := db.Model.DeleteSignatures(ctx, model); err != nil {
+		t.Fatalf("DeleteSignatures() error = %v", err)
+	}
+	if got, err := db.Model.GetSignatures(ctx, model); err != nil || got != nil {
+		t.Errorf("GetSignatures() of removed version = %v, %v, want nil", got, err)
+	}
+}
@@ -0,0 +1,177 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+// SetSmokeTest attaches smoke test to model, smoke test attached before is replaced
+func (m *Model) SetSmokeTest(ctx context.Context, id app.ServableID, test app.SmokeTest) (err error) {
+	defer metrics.ObserveMetadataOperation("model_set_smoke_test", time.Now(), &err)
+
+	encoded, err := json.Marshal(test)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	tx, err := m.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	_, err = tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_smoke_test WHERE team = ? AND project = ? AND name = ?"),
+		id.Team, id.Project, id.Name)
+	if err != nil {
+		tx.Rollback()
+
+		return exterr.WrapWithFrame(err)
+	}
+
+	_, err = m.dialect.insert(ctx, tx, "INSERT INTO model_smoke_test (team, project, name, request, created) VALUES(?, ?, ?, ?, ?)",
+		id.Team,
+		id.Project,
+		id.Name,
+		string(encoded),
+		time.Now().Unix())
+	if err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// GetSmokeTest gets smoke test attached to model, nil is returned
+// when smoke test hasn't been attached
+func (m *Model) GetSmokeTest(ctx context.Context, id app.ServableID) (_ *app.SmokeTest, err error) {
+	defer metrics.ObserveMetadataOperation("model_get_smoke_test", time.Now(), &err)
+
+	var encoded string
+	err = m.connection.QueryRowContext(ctx, m.dialect.rebind("SELECT request FROM model_smoke_test WHERE team = ? AND project = ? AND name = ? LIMIT 1"),
+		id.Team, id.Project, id.Name).Scan(&encoded)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	var test app.SmokeTest
+	if err := json.Unmarshal([]byte(encoded), &test); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return &test, nil
+}
+
+// DeleteSmokeTest detaches smoke test from model
+func (m *Model) DeleteSmokeTest(ctx context.Context, id app.ServableID) (err error) {
+	defer metrics.ObserveMetadataOperation("model_delete_smoke_test", time.Now(), &err)
+
+	_, err = m.connection.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_smoke_test WHERE team = ? AND project = ? AND name = ?"),
+		id.Team, id.Project, id.Name)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// AddSmokeTestResults stores results of smoke test of model version, results
+// recorded before for the same instances are replaced
+func (m *Model) AddSmokeTestResults(ctx context.Context, model app.ModelID, results []app.SmokeTestResult) (err error) {
+	defer metrics.ObserveMetadataOperation("model_add_smoke_test_results", time.Now(), &err)
+
+	tx, err := m.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	for _, result := range results {
+		_, err = tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_smoke_test_result WHERE team = ? AND project = ? AND name = ? AND version = ? AND instance = ?"),
+			model.Team, model.Project, model.Name, model.Version, result.Instance)
+		if err != nil {
+			tx.Rollback()
+
+			return exterr.WrapWithFrame(err)
+		}
+
+		passed := 0
+		if result.Passed {
+			passed = 1
+		}
+		_, err = m.dialect.insert(ctx, tx, "INSERT INTO model_smoke_test_result (team, project, name, version, instance, passed, latency_ms, error, created) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			model.Team,
+			model.Project,
+			model.Name,
+			model.Version,
+			result.Instance,
+			passed,
+			result.LatencyMs,
+			result.Error,
+			time.Now().Unix())
+		if err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// GetSmokeTestResults gets results of smoke test of model version ordered by instance
+func (m *Model) GetSmokeTestResults(ctx context.Context, model app.ModelID) (_ []app.SmokeTestResult, err error) {
+	defer metrics.ObserveMetadataOperation("model_get_smoke_test_results", time.Now(), &err)
+
+	rows, err := m.connection.QueryContext(ctx, m.dialect.rebind("SELECT instance, passed, latency_ms, error, created FROM model_smoke_test_result WHERE team = ? AND project = ? AND name = ? AND version = ? ORDER BY instance"),
+		model.Team, model.Project, model.Name, model.Version)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	var results []app.SmokeTestResult
+	for rows.Next() {
+		var result app.SmokeTestResult
+		var passed int
+		if err := rows.Scan(&result.Instance, &passed, &result.LatencyMs, &result.Error, &result.Created); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+		result.Passed = passed == 1
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return results, nil
+}
+
+// DeleteSmokeTestResults deletes results of smoke test of model version
+func (m *Model) DeleteSmokeTestResults(ctx context.Context, model app.ModelID) (err error) {
+	defer metrics.ObserveMetadataOperation("model_delete_smoke_test_results", time.Now(), &err)
+
+	_, err = m.connection.ExecContext(ctx, m.dialect.rebind("DELETE FROM model_smoke_test_result WHERE team = ? AND project = ? AND name = ? AND version = ?"),
+		model.Team, model.Project, model.Name, model.Version)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
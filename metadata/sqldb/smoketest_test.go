@@ -0,0 +1,86 @@
+package sqldb
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+func TestModel_SmokeTest(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	id := app.ServableID{Team: "team", Project: "project", Name: "name"}
+	test := app.SmokeTest{Method: app.SmokeTestPredict, Request: json.RawMessage(`{"inputs":{"x":{"dtype":"DT_FLOAT","floatVal":[1]}}}`)}
+
+	if got, err := db.Model.GetSmokeTest(ctx, id); err != nil || got != nil {
+		t.Errorf("GetSmokeTest() of model without smoke test = %v, %v, want nil", got, err)
+	}
+
+	for _, tt := range []app.SmokeTest{{Method: app.SmokeTestRegress}, test} {
+		if err := db.Model.SetSmokeTest(ctx, id, tt); err != nil {
+			t.Fatalf("SetSmokeTest() error = %v", err)
+		}
+	}
+
+	got, err := db.Model.GetSmokeTest(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSmokeTest() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, &test) {
+		t.Errorf("GetSmokeTest() = %+v, want %+v", got, test)
+	}
+
+	if err := db.Model.DeleteSmokeTest(ctx, id); err != nil {
+		t.Fatalf("DeleteSmokeTest() error = %v", err)
+	}
+	if got, err := db.Model.GetSmokeTest(ctx, id); err != nil || got != nil {
+		t.Errorf("GetSmokeTest() of detached smoke test = %v, %v, want nil", got, err)
+	}
+}
+
+func TestModel_SmokeTestResults(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	model := app.ModelID{ServableID: app.ServableID{Team: "team", Project: "project", Name: "name"}, Version: 1}
+
+	// result of instance tested again is replaced
+	batches := [][]app.SmokeTestResult{
+		{{Instance: "tfs-2:8500", Error: "unavailable"}, {Instance: "tfs-1:8500", Passed: true, LatencyMs: 12}},
+		{{Instance: "tfs-2:8500", Passed: true, LatencyMs: 7}},
+	}
+	for _, results := range batches {
+		if err := db.Model.AddSmokeTestResults(ctx, model, results); err != nil {
+			t.Fatalf("AddSmokeTestResults() error = %v", err)
+		}
+	}
+
+	got, err := db.Model.GetSmokeTestResults(ctx, model)
+	if err != nil {
+		t.Fatalf("GetSmokeTestResults() error = %v", err)
+	}
+	for i := range got {
+		got[i].Created = ""
+	}
+	want := []app.SmokeTestResult{{Instance: "tfs-1:8500", Passed: true, LatencyMs: 12}, {Instance: "tfs-2:8500", Passed: true, LatencyMs: 7}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSmokeTestResults() = %+v, want %+v", got, want)
+	}
+
+	if err := db.Model.DeleteSmokeTestResults(ctx, model); err != nil {
+		t.Fatalf("DeleteSmokeTestResults() error = %v", err)
+	}
+	if got, err := db.Model.GetSmokeTestResults(ctx, model); err != nil || got != nil {
+		t.Errorf("GetSmokeTestResults() of removed version = %v, %v, want nil", got, err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"strings"
 
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/grupawp/tensorflow-deploy/app"
@@ -31,23 +33,34 @@ var (
 type SQLDB struct {
 	Model  *Model
 	Module *Module
+	Token  *Token
+	Audit  *Audit
 
 	driver     string
+	dialect    *dialect
 	connection *sql.DB
 }
 
 // NewSQLDB ...
 func NewSQLDB(ctx context.Context, driver, dsn string) (*SQLDB, error) {
+	d, err := newDialect(driver)
+	if err != nil {
+		return nil, err
+	}
+
 	connection, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, exterr.WrapWithFrame(err)
 	}
 
 	db := &SQLDB{
-		Model:  &Model{connection: connection},
-		Module: &Module{connection: connection},
+		Model:  &Model{connection: connection, dialect: d},
+		Module: &Module{connection: connection, dialect: d},
+		Token:  &Token{connection: connection, dialect: d},
+		Audit:  &Audit{connection: connection, dialect: d},
 
 		driver:     driver,
+		dialect:    d,
 		connection: connection,
 	}
 
@@ -64,14 +77,15 @@ func (s *SQLDB) Close(ctx context.Context) (err error) {
 	return nil
 }
 
-// buildSearchQueryAndValues builds search query with dynamic "WHERE" part and its values
-func buildSearchQueryAndValues(queryPart string, params app.QueryParameters) (query string, values []interface{}, err error) {
+// buildSearchQueryAndValues builds search query with dynamic "WHERE" part and its values,
+// placeholders are valid for given dialect
+func buildSearchQueryAndValues(d *dialect, queryPart string, params app.QueryParameters) (query string, values []interface{}, err error) {
 	var (
 		fields []string
 	)
 
 	if len(params) == 0 {
-		return queryPart, values, nil
+		return d.rebind(queryPart), values, nil
 	}
 
 	for field, v := range params {
@@ -100,12 +114,12 @@ func buildSearchQueryAndValues(queryPart string, params app.QueryParameters) (qu
 		query = queryPart + " WHERE " + strings.Join(fields, " AND ")
 	}
 
-	return query, values, nil
+	return d.rebind(query), values, nil
 }
 
 // buildExtendedSearchQueryAndValues builds search query with dynamic "WHERE" part and its values
-func buildExtendedSearchQueryAndValues(queryPart, queryExtendPart string, params app.QueryParameters) (query string, values []interface{}, err error) {
-	query, values, err = buildSearchQueryAndValues(queryPart, params)
+func buildExtendedSearchQueryAndValues(d *dialect, queryPart, queryExtendPart string, params app.QueryParameters) (query string, values []interface{}, err error) {
+	query, values, err = buildSearchQueryAndValues(d, queryPart, params)
 	if err != nil {
 		return query, values, exterr.WrapWithFrame(err)
 	}
@@ -0,0 +1,122 @@
+package sqldb
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+	"github.com/grupawp/tensorflow-deploy/metadata"
+)
+
+func Test_buildSearchQueryAndValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		driver     string
+		params     app.QueryParameters
+		wantQuery  string
+		wantValues []interface{}
+	}{
+		{
+			name:      "Without parameters query should not be changed",
+			driver:    DriverPostgres,
+			params:    app.QueryParameters{},
+			wantQuery: "SELECT id FROM model",
+		},
+		{
+			name:       "SQLite should use question mark placeholders",
+			driver:     DriverSQLite3,
+			params:     app.QueryParameters{"team": "team"},
+			wantQuery:  "SELECT id FROM model WHERE team=?",
+			wantValues: []interface{}{"team"},
+		},
+		{
+			name:       "MySQL should use question mark placeholders",
+			driver:     DriverMySQL,
+			params:     app.QueryParameters{"version": int64(1)},
+			wantQuery:  "SELECT id FROM model WHERE version=?",
+			wantValues: []interface{}{int64(1)},
+		},
+		{
+			name:       "PostgreSQL should use numbered placeholders",
+			driver:     DriverPostgres,
+			params:     app.QueryParameters{"status": app.StatusPending},
+			wantQuery:  "SELECT id FROM model WHERE status=$1",
+			wantValues: []interface{}{uint8(metadata.StatusPending)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newDialect(tt.driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			query, values, err := buildSearchQueryAndValues(d, "SELECT id FROM model", tt.params)
+			if err != nil {
+				t.Fatalf("buildSearchQueryAndValues() error = %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("buildSearchQueryAndValues() query = %s, want %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("buildSearchQueryAndValues() values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func Test_dialect_rebind(t *testing.T) {
+	d, _ := newDialect(DriverPostgres)
+
+	got := d.rebind("UPDATE model SET status = ?, updated = ? WHERE id = ? AND status != ?")
+	want := "UPDATE model SET status = $1, updated = $2 WHERE id = $3 AND status != $4"
+	if got != want {
+		t.Errorf("rebind() = %s, want %s", got, want)
+	}
+}
+
+func Test_newDialect(t *testing.T) {
+	if _, err := newDialect("oracle"); err != errorUnsupportedDriver {
+		t.Errorf("newDialect() error = %v, want %v", err, errorUnsupportedDriver)
+	}
+}
+
+// newTestSQLDB returns SQLite database in temporary directory without any migration applied
+func newTestSQLDB(t *testing.T) *SQLDB {
+	dir := testutil.TempDir(t)
+
+	ctx := context.Background()
+	db, err := NewSQLDB(ctx, DriverSQLite3, path.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(ctx) })
+
+	return db
+}
+
+func TestModel_ChangeLabel(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []int64{1, 2} {
+		model := app.ModelData{ModelID: app.ModelID{ServableID: app.ServableID{Team: "team", Project: "project", Name: "name"}, Version: version, Label: "stable"}, Status: app.StatusReady}
+		if err := db.Model.ChangeLabel(ctx, model); err != nil {
+			t.Fatalf("ChangeLabel() error = %v", err)
+		}
+	}
+
+	models, err := db.Model.List(ctx, app.QueryParameters{"label": "stable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].Version != 2 {
+		t.Errorf("ChangeLabel() labeled models = %v, want single model in version 2", models)
+	}
+}
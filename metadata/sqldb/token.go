@@ -0,0 +1,105 @@
+package sqldb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+// Token is a token store kept in auth_token table. Only SHA-256 hashes
+// of tokens are stored, every row holds single grant of token owner
+type Token struct {
+	connection *sql.DB
+	dialect    *dialect
+}
+
+// HashToken returns value of token_hash column for given token
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// Authenticate returns identity of token owner
+func (t *Token) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	rows, err := t.connection.QueryContext(ctx, t.dialect.rebind("SELECT subject, team, project, role FROM auth_token WHERE token_hash = ?"), HashToken(token))
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer rows.Close()
+
+	var identity *auth.Identity
+	for rows.Next() {
+		var subject, roleName string
+		var grant auth.Grant
+
+		if err := rows.Scan(&subject, &grant.Team, &grant.Project, &roleName); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+
+		grant.Role, err = auth.ParseRole(roleName)
+		if err != nil {
+			return nil, err
+		}
+
+		if identity == nil {
+			identity = &auth.Identity{Subject: subject}
+		}
+		identity.Grants = append(identity.Grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	if identity == nil {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return identity, nil
+}
+
+// AddToken stores grants of identity for given token, grants are added
+// to these already stored when token is known
+func (t *Token) AddToken(ctx context.Context, token string, identity *auth.Identity) error {
+	tx, err := t.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	created := time.Now().Unix()
+	for _, grant := range identity.Grants {
+		_, err := tx.ExecContext(ctx, t.dialect.rebind("INSERT INTO auth_token (token_hash, subject, team, project, role, created) VALUES (?, ?, ?, ?, ?, ?)"),
+			HashToken(token), identity.Subject, grant.Team, grant.Project, grant.Role.String(), created)
+		if err != nil {
+			tx.Rollback()
+
+			return exterr.WrapWithFrame(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// RevokeTokens deletes every token of subject and returns number of deleted grants
+func (t *Token) RevokeTokens(ctx context.Context, subject string) (int64, error) {
+	result, err := t.connection.ExecContext(ctx, t.dialect.rebind("DELETE FROM auth_token WHERE subject = ?"), subject)
+	if err != nil {
+		return 0, exterr.WrapWithFrame(err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, exterr.WrapWithFrame(err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,73 @@
+package sqldb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/auth"
+)
+
+func TestToken_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, grant := range [][]string{{"team", "project", "admin"}, {"other", "*", "reader"}} {
+		if _, err := db.connection.Exec("INSERT INTO auth_token (token_hash, subject, team, project, role, created) VALUES (?, ?, ?, ?, ?, 0)", HashToken("secret"), "ci", grant[0], grant[1], grant[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	identity, err := db.Token.Authenticate(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if identity.Subject != "ci" || len(identity.Grants) != 2 {
+		t.Errorf("Authenticate() = %+v, want identity with 2 grants", identity)
+	}
+	if !identity.Allowed("team", "project", auth.RoleAdmin) || identity.Allowed("other", "project", auth.RoleDeployer) {
+		t.Errorf("Authenticate() = %+v, grants don't match auth_token rows", identity)
+	}
+
+	if _, err := db.Token.Authenticate(ctx, "unknown"); err != auth.ErrInvalidToken {
+		t.Errorf("Authenticate() of unknown token error = %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+func TestToken_AddToken(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLDB(t)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := &auth.Identity{Subject: "ci", Grants: []auth.Grant{{Team: "team", Project: auth.Wildcard, Role: auth.RoleDeployer}}}
+	if err := db.Token.AddToken(ctx, "secret", identity); err != nil {
+		t.Fatalf("AddToken() error = %v", err)
+	}
+	if err := db.Token.AddToken(ctx, "other", &auth.Identity{Subject: "cd", Grants: []auth.Grant{{Team: "team", Project: "project", Role: auth.RoleReader}}}); err != nil {
+		t.Fatalf("AddToken() error = %v", err)
+	}
+
+	got, err := db.Token.Authenticate(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, identity) {
+		t.Errorf("Authenticate() = %+v, want %+v", got, identity)
+	}
+
+	deleted, err := db.Token.RevokeTokens(ctx, "ci")
+	if err != nil || deleted != 1 {
+		t.Fatalf("RevokeTokens() = %d, %v, want 1 deleted grant", deleted, err)
+	}
+	if _, err := db.Token.Authenticate(ctx, "secret"); err != auth.ErrInvalidToken {
+		t.Errorf("Authenticate() of revoked token error = %v, want %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := db.Token.Authenticate(ctx, "other"); err != nil {
+		t.Errorf("Authenticate() of token of other subject error = %v", err)
+	}
+}
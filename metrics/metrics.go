@@ -0,0 +1,107 @@
+// Package metrics exposes TensorFlow Deploy metrics in Prometheus text format.
+// Names and labels of metrics defined below are stable, they are documented
+// in Docs/metrics.md and must not be changed without updating dashboards
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// ResultSuccess is a value of result label of successful operations
+	ResultSuccess = "success"
+	// ResultFailure is a value of result label of failed operations
+	ResultFailure = "failure"
+)
+
+var (
+	// DurationBuckets are buckets of durations in seconds
+	DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	// SizeBuckets are buckets of sizes in bytes, from 1KiB to 16GiB
+	SizeBuckets = []float64{1 << 10, 1 << 14, 1 << 18, 1 << 20, 1 << 22, 1 << 24, 1 << 26, 1 << 28, 1 << 30, 1 << 32, 1 << 34}
+	// CountBuckets are buckets of number of items
+	CountBuckets = []float64{0, 1, 2, 5, 10, 25, 50, 100}
+
+	// DefaultRegistry holds all TensorFlow Deploy metrics
+	DefaultRegistry = NewRegistry()
+
+	// HTTPRequestsTotal counts handled HTTP requests
+	HTTPRequestsTotal = DefaultRegistry.NewCounterVec("tfd_http_requests_total",
+		"Number of handled HTTP requests by method, route pattern and status code.", "method", "route", "code")
+	// HTTPRequestDuration observes latency of HTTP requests
+	HTTPRequestDuration = DefaultRegistry.NewHistogramVec("tfd_http_request_duration_seconds",
+		"Latency of HTTP requests by method and route pattern.", DurationBuckets, "method", "route")
+
+	// ModelUploadBytes observes sizes of uploaded model archives
+	ModelUploadBytes = DefaultRegistry.NewHistogramVec("tfd_model_upload_bytes",
+		"Size of uploaded model archives by team and project.", SizeBuckets, "team", "project")
+	// ModelUploadDuration observes duration of model uploads
+	ModelUploadDuration = DefaultRegistry.NewHistogramVec("tfd_model_upload_duration_seconds",
+		"Duration of model uploads by team, project and result.", DurationBuckets, "team", "project", "result")
+
+	// ReloadInstanceTotal counts config reloads of single TFS instances
+	ReloadInstanceTotal = DefaultRegistry.NewCounterVec("tfd_reload_instance_total",
+		"Number of config reload requests sent to TFS instances by instance and result.", "instance", "result")
+	// ReloadServableTotal counts config reloads of team-projects
+	ReloadServableTotal = DefaultRegistry.NewCounterVec("tfd_reload_servable_total",
+		"Number of config reloads of all instances serving team and project by result.", "team", "project", "result")
+
+	// SmokeTestTotal counts smoke tests sent to single TFS instances
+	SmokeTestTotal = DefaultRegistry.NewCounterVec("tfd_smoke_test_total",
+		"Number of smoke tests sent to TFS instances by instance and result.", "instance", "result")
+	// SmokeTestDuration observes latency of smoke tests
+	SmokeTestDuration = DefaultRegistry.NewHistogramVec("tfd_smoke_test_duration_seconds",
+		"Latency of smoke tests sent to TFS instances by result.", DurationBuckets, "result")
+
+	// AutoReloadDuration observes duration of auto-reload job cycles
+	AutoReloadDuration = DefaultRegistry.NewHistogramVec("tfd_autoreload_duration_seconds",
+		"Duration of auto-reload job cycles.", DurationBuckets)
+	// AutoReloadServables observes number of servables reloaded in auto-reload job cycles
+	AutoReloadServables = DefaultRegistry.NewHistogramVec("tfd_autoreload_servables_reloaded",
+		"Number of team-projects reloaded in single auto-reload job cycle.", CountBuckets)
+
+	// DiscoveryHealthCheckTotal counts health checks of discovered TFS instances
+	DiscoveryHealthCheckTotal = DefaultRegistry.NewCounterVec("tfd_discovery_health_check_total",
+		"Number of health checks of discovered TFS instances by team, project and result.", "team", "project", "result")
+	// DiscoveryUnhealthyInstances holds number of instances which have failed their last health check
+	DiscoveryUnhealthyInstances = DefaultRegistry.NewGaugeVec("tfd_discovery_unhealthy_instances",
+		"Number of discovered TFS instances which have failed their last health check by team and project.", "team", "project")
+
+	// RetentionRemovedVersions counts versions of models removed by retention policies
+	RetentionRemovedVersions = DefaultRegistry.NewCounterVec("tfd_retention_removed_versions_total",
+		"Number of versions of models removed by retention policies by team, project and result.", "team", "project", "result")
+
+	// StorageOperationDuration observes latency of storage operations
+	StorageOperationDuration = DefaultRegistry.NewHistogramVec("tfd_storage_operation_duration_seconds",
+		"Latency of storage operations by operation and result.", DurationBuckets, "operation", "result")
+	// MetadataOperationDuration observes latency of metadata operations
+	MetadataOperationDuration = DefaultRegistry.NewHistogramVec("tfd_metadata_operation_duration_seconds",
+		"Latency of metadata operations by operation and result.", DurationBuckets, "operation", "result")
+)
+
+// Handler returns handler serving metrics of DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// Result returns value of result label for given error
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+
+	return ResultSuccess
+}
+
+// ObserveStorageOperation observes latency of storage operation started at start,
+// it's meant to be deferred with pointer to named error result
+func ObserveStorageOperation(operation string, start time.Time, err *error) {
+	StorageOperationDuration.Observe(time.Since(start).Seconds(), operation, Result(*err))
+}
+
+// ObserveMetadataOperation observes latency of metadata operation started at start,
+// it's meant to be deferred with pointer to named error result
+func ObserveMetadataOperation(operation string, start time.Time, err *error) {
+	MetadataOperationDuration.Observe(time.Since(start).Seconds(), operation, Result(*err))
+}
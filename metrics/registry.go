@@ -0,0 +1,293 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	contentType    = "text/plain; version=0.0.4; charset=utf-8"
+	labelSeparator = "\xff"
+)
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metrics exposed in Prometheus text format
+type Registry struct {
+	m          sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry returns new empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, registered := range r.collectors {
+		if registered.name() == c.name() {
+			panic(fmt.Sprintf("metric %s is already registered", c.name()))
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Expose writes all registered metrics sorted by name
+func (r *Registry) Expose(w io.Writer) {
+	r.m.Lock()
+	collectors := make([]collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.m.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler returns handler serving registered metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.Expose(w)
+	})
+}
+
+// desc describes metric and keeps its series indexed by label values
+type desc struct {
+	metricName string
+	help       string
+	metricType string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+func (d *desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, d.metricType)
+}
+
+// labelPairs formats label values of series with optional extra label
+func (d *desc) labelPairs(key string, extraName, extraValue string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labels[i], escapeLabelValue(value)))
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a monotonically increasing value partitioned by labels
+type CounterVec struct {
+	desc
+
+	m      sync.Mutex
+	series map[string]float64
+}
+
+// NewCounterVec creates counter and registers it in registry
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{metricName: name, help: help, metricType: "counter", labels: labels}, series: make(map[string]float64)}
+	r.register(c)
+
+	return c
+}
+
+// Inc increments counter of given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments counter of given label values by v, negative values are ignored
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.key(labelValues)
+
+	c.m.Lock()
+	c.series[key] += v
+	c.m.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(key, "", ""), formatFloat(c.series[key]))
+	}
+}
+
+// GaugeVec is a value which may go up and down partitioned by labels
+type GaugeVec struct {
+	desc
+
+	m      sync.Mutex
+	series map[string]float64
+}
+
+// NewGaugeVec creates gauge and registers it in registry
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{desc: desc{metricName: name, help: help, metricType: "gauge", labels: labels}, series: make(map[string]float64)}
+	r.register(g)
+
+	return g
+}
+
+// Set sets gauge of given label values to v
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.m.Lock()
+	g.series[key] = v
+	g.m.Unlock()
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.writeHeader(w)
+	for _, key := range sortedKeys(g.series) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labelPairs(key, "", ""), formatFloat(g.series[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec samples observations into cumulative buckets partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	m      sync.Mutex
+	series map[string]*histogram
+}
+
+// NewHistogramVec creates histogram with given upper bounds of buckets and registers it in registry
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	h := &HistogramVec{desc: desc{metricName: name, help: help, metricType: "histogram", labels: labels}, buckets: sorted, series: make(map[string]*histogram)}
+	r.register(h)
+
+	return h
+}
+
+// Observe adds single observation to histogram of given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upperBound := range h.buckets {
+		if v <= upperBound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.writeHeader(w)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, upperBound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(key, "le", formatFloat(upperBound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(key, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(key, "", ""), s.count)
+	}
+}
+
+func sortedKeys(series map[string]float64) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_Expose(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Number of requests.", "route", "code")
+	latency := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+	last := r.NewGaugeVec("test_last", "Last value.")
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "404")
+	requests.Add(-1, "/a", "404")
+	requests.Inc(`/"quoted"`, "500")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+	last.Set(3)
+
+	want := `# HELP test_last Last value.
+# TYPE test_last gauge
+test_last 3
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 2
+test_latency_seconds_bucket{route="/a",le="+Inf"} 3
+test_latency_seconds_sum{route="/a"} 5.55
+test_latency_seconds_count{route="/a"} 3
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/\"quoted\"",code="500"} 1
+test_requests_total{route="/a",code="404"} 2
+test_requests_total{route="/b",code="200"} 1
+`
+
+	var buf bytes.Buffer
+	r.Expose(&buf)
+	if got := buf.String(); got != want {
+		t.Errorf("Expose() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("Content-Type = %s, want %s", got, contentType)
+	}
+	if got, want := rec.Body.String(), "# HELP test_total Test.\n# TYPE test_total counter\ntest_total 1\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestRegistry_registerDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering metric twice should panic")
+		}
+	}()
+	r.NewGaugeVec("test_total", "Test.")
+}
@@ -2,5 +2,5 @@
 
 CONFIG_FILE="/usr/local/etc/config.yaml"
 
-/bootstrap --config_file $CONFIG_FILE
+/bootstrap --config_file $CONFIG_FILE migrate up
 exec /tensorflow_deploy --config_file $CONFIG_FILE "$@"
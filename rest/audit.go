@@ -0,0 +1,193 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+var (
+	logInvalidAuditQueryErrorCode = 1009
+
+	errorInvalidAuditQuery = exterr.NewErrorWithMessage("invalid audit query").WithComponent(app.ComponentRest).WithCode(logInvalidAuditQueryErrorCode)
+)
+
+// Operations recorded in audit log
+const (
+	auditModelUpload        = "model_upload"
+	auditModelRevert        = "model_revert"
+	auditModelReload        = "model_reload"
+	auditModelSetLabel      = "model_set_label"
+	auditModelDeleteLabel   = "model_delete_label"
+	auditModelRollback      = "model_rollback_label"
+	auditModelDeleteByLabel = "model_delete_by_label"
+	auditModelDelete        = "model_delete_version"
+	auditModelSetSmokeTest  = "model_set_smoke_test"
+	auditModelDelSmokeTest  = "model_delete_smoke_test"
+	auditModelRunSmokeTest  = "model_run_smoke_test"
+	auditUploadCreate       = "upload_create"
+	auditUploadChunk        = "upload_write_chunk"
+	auditUploadFinalize     = "upload_finalize"
+	auditUploadDelete       = "upload_delete"
+	auditRolloutStart       = "rollout_start"
+	auditRolloutPause       = "rollout_pause"
+	auditRolloutResume      = "rollout_resume"
+	auditRolloutAbort       = "rollout_abort"
+	auditModuleUpload       = "module_upload"
+	auditModuleDelete       = "module_delete_version"
+	auditFsckRepair         = "fsck_repair"
+)
+
+// Auditor is the interface that records mutating calls of REST API
+type Auditor interface {
+	Record(ctx context.Context, entry app.AuditEntry)
+	List(ctx context.Context, query app.AuditQuery) (*app.AuditPage, error)
+}
+
+type auditCtxKey struct{}
+
+// auditRecord collects details of audited call which aren't known
+// before its handler finishes, e.g. version of uploaded model
+type auditRecord struct {
+	version int64
+	label   string
+	err     error
+}
+
+// audit records outcome of request in audit log. It has to be used before
+// authorize, so rejected requests are recorded as well
+func (rest *REST) audit(operation string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rest.auditor == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			record := &auditRecord{}
+			recorder := &statusRecorder{ResponseWriter: w}
+			r = r.WithContext(context.WithValue(r.Context(), auditCtxKey{}, record))
+
+			next.ServeHTTP(recorder, r)
+
+			entry := app.AuditEntry{
+				Operation: operation,
+				Actor:     logging.Identity(r.Context()),
+				Team:      strings.ToLower(getParamFromRequest(r, "team")),
+				Project:   strings.ToLower(getParamFromRequest(r, "project")),
+				Name:      strings.ToLower(getParamFromRequest(r, "name")),
+				Label:     strings.ToLower(getParamFromRequest(r, "label")),
+				Status:    recorder.status,
+				Outcome:   app.AuditSuccess,
+				RequestID: logging.RequestID(r.Context()),
+			}
+			entry.Version, _ = strconv.ParseInt(getParamFromRequest(r, "version"), 10, 64)
+			if record.version != 0 {
+				entry.Version = record.version
+			}
+			if record.label != "" {
+				entry.Label = record.label
+			}
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if record.err != nil || entry.Status >= http.StatusBadRequest {
+				entry.Outcome = app.AuditFailure
+			}
+			if codedErr := findCodedError(record.err); codedErr != nil {
+				entry.ErrorCode = fmt.Sprintf("%s-%d", codedErr.Component(), codedErr.Code())
+			}
+
+			rest.auditor.Record(r.Context(), entry)
+		})
+	}
+}
+
+// auditTarget records version and label affected by audited call when they
+// aren't given in request URL, zero values are ignored
+func auditTarget(r *http.Request, version int64, label string) {
+	record, ok := r.Context().Value(auditCtxKey{}).(*auditRecord)
+	if !ok {
+		return
+	}
+	if version != 0 {
+		record.version = version
+	}
+	if label != "" {
+		record.label = label
+	}
+}
+
+// auditError records error returned by audited call
+func auditError(r *http.Request, err error) {
+	if record, ok := r.Context().Value(auditCtxKey{}).(*auditRecord); ok {
+		record.err = err
+	}
+}
+
+func (rest *REST) auditHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorInvalidAuditQuery)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := rest.auditor.List(r.Context(), query)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, page)
+}
+
+// parseAuditQuery parses filters and pagination of audit log from query string
+func parseAuditQuery(r *http.Request) (app.AuditQuery, error) {
+	values := r.URL.Query()
+	query := app.AuditQuery{
+		Operation: values.Get("operation"),
+		Actor:     values.Get("actor"),
+		Team:      strings.ToLower(values.Get("team")),
+		Project:   strings.ToLower(values.Get("project")),
+		Name:      strings.ToLower(values.Get("name")),
+		Outcome:   values.Get("outcome"),
+	}
+	if query.Outcome != "" && query.Outcome != app.AuditSuccess && query.Outcome != app.AuditFailure {
+		return query, fmt.Errorf("invalid outcome %q", query.Outcome)
+	}
+
+	for _, field := range []struct {
+		name  string
+		value *int64
+	}{
+		{"since", &query.Since},
+		{"until", &query.Until},
+		{"before", &query.Before},
+	} {
+		if raw := values.Get(field.name); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 0 {
+				return query, fmt.Errorf("invalid %s %q", field.name, raw)
+			}
+			*field.value = parsed
+		}
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit %q", raw)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+type testAuditor struct {
+	entries []app.AuditEntry
+}
+
+func (a *testAuditor) Record(ctx context.Context, entry app.AuditEntry) {
+	a.entries = append(a.entries, entry)
+}
+
+func (a *testAuditor) List(ctx context.Context, query app.AuditQuery) (*app.AuditPage, error) {
+	return &app.AuditPage{Entries: a.entries}, nil
+}
+
+func TestREST_audit(t *testing.T) {
+	auditor := &testAuditor{}
+	rest := NewREST(nil, nil, "", "").WithAuditor(auditor).WithAuthenticator(tokensAuthenticator{
+		"deployer": {Subject: "ci", Grants: []auth.Grant{{Team: "team", Project: "project", Role: auth.RoleDeployer}}},
+	})
+	errorRejected := exterr.NewErrorWithMessage("rejected").WithComponent(app.ComponentService).WithCode(1008)
+
+	r := chi.NewRouter()
+	r.Use(logging.HTTPCtxValuesMiddleware)
+	r.Use(rest.authenticate)
+	r.Route("/v1/models/{team}/{project}/names/{name}", func(r chi.Router) {
+		r.With(rest.audit(auditModelUpload), rest.authorize(auth.RoleDeployer)).Post("/", func(w http.ResponseWriter, r *http.Request) {
+			auditTarget(r, 3, "")
+			writeJSONSuccessResponse(w, r, http.StatusOK, nil)
+		})
+		r.With(rest.audit(auditModelSetLabel), rest.authorize(auth.RoleDeployer)).Put("/versions/{version}/labels/{label}", func(w http.ResponseWriter, r *http.Request) {
+			writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, exterr.WrapWithFrame(errorRejected))
+		})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   app.AuditEntry
+	}{
+		{
+			name:   "Successful upload should be recorded with uploaded version",
+			method: http.MethodPost,
+			path:   "/v1/models/team/project/names/name",
+			token:  "deployer",
+			want:   app.AuditEntry{Operation: auditModelUpload, Actor: "ci", Team: "team", Project: "project", Name: "name", Version: 3, Outcome: app.AuditSuccess, Status: http.StatusOK},
+		},
+		{
+			name:   "Failed call should be recorded with code of error",
+			method: http.MethodPut,
+			path:   "/v1/models/team/project/names/name/versions/2/labels/canary",
+			token:  "deployer",
+			want:   app.AuditEntry{Operation: auditModelSetLabel, Actor: "ci", Team: "team", Project: "project", Name: "name", Version: 2, Label: "canary", Outcome: app.AuditFailure, Status: http.StatusTemporaryRedirect, ErrorCode: "SERVICE-1008"},
+		},
+		{
+			name:   "Rejected call should be recorded",
+			method: http.MethodPost,
+			path:   "/v1/models/other/project/names/name",
+			token:  "deployer",
+			want:   app.AuditEntry{Operation: auditModelUpload, Actor: "ci", Team: "other", Project: "project", Name: "name", Outcome: app.AuditFailure, Status: http.StatusForbidden, ErrorCode: "REST-1005"},
+		},
+		{
+			name:   "Anonymous call should be recorded",
+			method: http.MethodPost,
+			path:   "/v1/models/team/project/names/name",
+			want:   app.AuditEntry{Operation: auditModelUpload, Team: "team", Project: "project", Name: "name", Outcome: app.AuditFailure, Status: http.StatusUnauthorized, ErrorCode: "REST-1004"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor.entries = nil
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(auditor.entries) != 1 {
+				t.Fatalf("recorded entries = %+v, want one", auditor.entries)
+			}
+			got := auditor.entries[0]
+			if got.RequestID == "" {
+				t.Errorf("recorded entry has no request ID")
+			}
+			got.RequestID = ""
+			if got != tt.want {
+				t.Errorf("recorded entry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseAuditQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    app.AuditQuery
+		wantErr bool
+	}{
+		{name: "Empty query should match everything", query: "", want: app.AuditQuery{}},
+		{
+			name:  "Filters and pagination should be parsed",
+			query: "?operation=model_upload&actor=ci&team=Team&project=project&name=name&outcome=failure&since=100&until=200&before=42&limit=10",
+			want:  app.AuditQuery{Operation: "model_upload", Actor: "ci", Team: "team", Project: "project", Name: "name", Outcome: app.AuditFailure, Since: 100, Until: 200, Before: 42, Limit: 10},
+		},
+		{name: "Unknown outcome should be rejected", query: "?outcome=maybe", wantErr: true},
+		{name: "Invalid time should be rejected", query: "?since=yesterday", wantErr: true},
+		{name: "Non-positive limit should be rejected", query: "?limit=0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuditQuery(httptest.NewRequest(http.MethodGet, "/v1/audit"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuditQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAuditQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type stubUploadSessions struct{ UploadSessions }
+type stubRollouts struct{ Rollouts }
+type stubRetention struct{ Retention }
+type stubFsck struct{ Fsck }
+
+func TestREST_router_auditsMutatingRoutes(t *testing.T) {
+	auditor := &testAuditor{}
+	rest := NewREST(nil, nil, "", "").
+		WithAuditor(auditor).
+		WithAuthenticator(tokensAuthenticator{}).
+		WithUploadSessions(stubUploadSessions{}).
+		WithRollouts(stubRollouts{}).
+		WithRetention(stubRetention{}).
+		WithFsck(stubFsck{})
+	router := rest.router()
+
+	walked := 0
+	err := chi.Walk(router.(chi.Routes), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if method == http.MethodGet || method == http.MethodHead {
+			return nil
+		}
+		walked++
+
+		path := strings.NewReplacer(
+			"/*/", "/",
+			"{team}", "team", "{project}", "project", "{name}", "name", "{label}", "canary",
+			"{version}", "1", "{entry}", "1", "{upload}", "upload", "{rollout}", "1",
+		).Replace(route)
+
+		auditor.entries = nil
+		// anonymous calls are rejected by authorize, so handlers aren't reached
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+		if len(auditor.entries) != 1 {
+			t.Errorf("%s %s recorded %d audit entries, want one", method, route, len(auditor.entries))
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chi.Walk() error = %v", err)
+	}
+	if walked == 0 {
+		t.Fatalf("no mutating routes found")
+	}
+}
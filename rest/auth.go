@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const bearerPrefix = "Bearer "
+
+var (
+	logUnauthorizedErrorCode = 1004
+	logForbiddenErrorCode    = 1005
+
+	errorUnauthorized = exterr.NewErrorWithMessage("missing or invalid bearer token").WithComponent(app.ComponentRest).WithCode(logUnauthorizedErrorCode)
+	errorForbidden    = exterr.NewErrorWithMessage("insufficient role for requested team and project").WithComponent(app.ComponentRest).WithCode(logForbiddenErrorCode)
+)
+
+// authenticate resolves identity of bearer token owner and stores it in request context.
+// Requests without valid token are rejected only by authorize, so public endpoints stay available
+func (rest *REST) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if rest.authenticator == nil || !strings.HasPrefix(header, bearerPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := rest.authenticator.Authenticate(r.Context(), strings.TrimPrefix(header, bearerPrefix))
+		if err != nil {
+			if !errors.Is(err, auth.ErrInvalidToken) {
+				logging.ErrorWithStack(r.Context(), err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logging.SetIdentity(r.Context(), identity.Subject)
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), identity)))
+	})
+}
+
+// authorize checks if caller has at least given role in {team} and {project} of requested URL
+func (rest *REST) authorize(role auth.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rest.authenticator == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity := auth.FromContext(r.Context())
+			if identity == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tensorflow-deploy"`)
+				writeJSONErrorResponse(w, r, http.StatusUnauthorized, errorUnauthorized)
+				return
+			}
+
+			if !identity.Allowed(chi.URLParam(r, "team"), chi.URLParam(r, "project"), role) {
+				writeJSONErrorResponse(w, r, http.StatusForbidden, errorForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
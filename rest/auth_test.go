@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
+)
+
+type tokensAuthenticator map[string]*auth.Identity
+
+func (a tokensAuthenticator) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	if identity, ok := a[token]; ok {
+		return identity, nil
+	}
+
+	return nil, auth.ErrInvalidToken
+}
+
+func TestREST_authorize(t *testing.T) {
+	rest := NewREST(nil, nil, "", "").WithAuthenticator(tokensAuthenticator{
+		"deployer": {Subject: "ci", Grants: []auth.Grant{{Team: "team", Project: "project", Role: auth.RoleDeployer}}},
+	})
+
+	r := chi.NewRouter()
+	r.Use(rest.authenticate)
+	r.Route("/v1/models/{team}/{project}", func(r chi.Router) {
+		r.With(rest.authorize(auth.RoleDeployer)).Post("/reload", func(w http.ResponseWriter, r *http.Request) {
+			if identity := auth.FromContext(r.Context()); identity == nil || identity.Subject != "ci" {
+				t.Errorf("identity in context = %+v, want subject ci", identity)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		r.With(rest.authorize(auth.RoleAdmin)).Delete("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "Request without token should be unauthorized", method: http.MethodPost, path: "/v1/models/team/project/reload", want: http.StatusUnauthorized},
+		{name: "Request with invalid token should be unauthorized", method: http.MethodPost, path: "/v1/models/team/project/reload", token: "invalid", want: http.StatusUnauthorized},
+		{name: "Request with sufficient role should pass", method: http.MethodPost, path: "/v1/models/team/project/reload", token: "deployer", want: http.StatusNoContent},
+		{name: "Request to other team should be forbidden", method: http.MethodPost, path: "/v1/models/other/project/reload", token: "deployer", want: http.StatusForbidden},
+		{name: "Request requiring higher role should be forbidden", method: http.MethodDelete, path: "/v1/models/team/project/", token: "deployer", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusNoContent {
+				return
+			}
+
+			var body app.ErrorBody
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("error body decode error = %v", err)
+			}
+			if body.Error.ErrorCode != strconv.Itoa(tt.want) {
+				t.Errorf("error_code = %s, want %d", body.Error.ErrorCode, tt.want)
+			}
+		})
+	}
+}
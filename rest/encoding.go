@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// supportedEncodings are content encodings of downloaded archives in order of preference
+var supportedEncodings = []string{encodingZstd, encodingGzip}
+
+// negotiateEncoding returns supported encoding with the highest quality accepted by client,
+// empty encoding means archive should be sent as is
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		accepted[coding] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range supportedEncodings {
+		if quality := accepted[encoding]; quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+
+	return best
+}
+
+// newEncoder returns writer compressing data written to w with given encoding
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	if encoding == encodingZstd {
+		return zstd.NewWriter(w)
+	}
+
+	return gzip.NewWriter(w), nil
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/klauspost/compress/zstd"
+)
+
+func Test_negotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{acceptEncoding: "", want: ""},
+		{acceptEncoding: "identity", want: ""},
+		{acceptEncoding: "gzip, deflate", want: encodingGzip},
+		{acceptEncoding: "gzip, zstd", want: encodingZstd},
+		{acceptEncoding: "gzip;q=1.0, zstd;q=0.5", want: encodingGzip},
+		{acceptEncoding: "GZIP", want: encodingGzip},
+		{acceptEncoding: "gzip;q=0", want: ""},
+		{acceptEncoding: "zstd;q=invalid, gzip;q=0.1", want: encodingGzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding); got != tt.want {
+				t.Errorf("negotiateEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type testArchiveContent struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *testArchiveContent) Close() error {
+	c.closed = true
+	return nil
+}
+
+func Test_writeArchiveResponse(t *testing.T) {
+	data := strings.Repeat("archive data ", 100)
+
+	decodeGzip := func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	decodeZstd := func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }
+
+	tests := []struct {
+		name         string
+		header       http.Header
+		wantStatus   int
+		wantEncoding string
+		wantBody     string
+		decode       func(io.Reader) (io.Reader, error)
+		wantNoLength bool
+	}{
+		{name: "Archive should be sent as is", header: http.Header{}, wantStatus: http.StatusOK, wantBody: data},
+		{name: "Archive should be compressed with gzip", header: http.Header{"Accept-Encoding": {"gzip"}}, wantStatus: http.StatusOK, wantEncoding: encodingGzip, wantBody: data, decode: decodeGzip, wantNoLength: true},
+		{name: "Archive should be compressed with zstd", header: http.Header{"Accept-Encoding": {"gzip, zstd"}}, wantStatus: http.StatusOK, wantEncoding: encodingZstd, wantBody: data, decode: decodeZstd, wantNoLength: true},
+		{name: "Range of archive should be sent uncompressed", header: http.Header{"Range": {"bytes=100-199"}, "Accept-Encoding": {"gzip"}}, wantStatus: http.StatusPartialContent, wantBody: data[100:200]},
+		{name: "Range should be ignored when archive changed", header: http.Header{"Range": {"bytes=100-199"}, "If-Range": {`"model-1-1577836900"`}}, wantStatus: http.StatusOK, wantBody: data},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := &testArchiveContent{Reader: strings.NewReader(data)}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+
+			writeArchiveResponse(rec, req, &app.Archive{Content: content, Name: "model.tar", ETag: "model-1-1577836800"})
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("Content-Length"); (got == "") != tt.wantNoLength {
+				t.Errorf("Content-Length = %q", got)
+			}
+			if !content.closed {
+				t.Error("archive content wasn't closed")
+			}
+
+			var body io.Reader = rec.Body
+			if tt.decode != nil {
+				var err error
+				if body, err = tt.decode(bytes.NewReader(rec.Body.Bytes())); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+// Fsck is the interface that finds and repairs inconsistencies between storage,
+// metadata and configs of TFS instances
+type Fsck interface {
+	Check(ctx context.Context, repair bool) (*app.ConsistencyReport, error)
+}
+
+func (rest *REST) fsckHandler(w http.ResponseWriter, r *http.Request) {
+	rest.writeConsistencyReport(w, r, false)
+}
+
+func (rest *REST) fsckRepairHandler(w http.ResponseWriter, r *http.Request) {
+	rest.writeConsistencyReport(w, r, true)
+}
+
+func (rest *REST) writeConsistencyReport(w http.ResponseWriter, r *http.Request, repair bool) {
+	report, err := rest.fsck.Check(r.Context(), repair)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, report)
+}
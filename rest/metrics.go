@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+// unmatchedRoute is a value of route label of requests which don't match any route
+const unmatchedRoute = "unmatched"
+
+// statusRecorder remembers status code written by handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// instrument counts requests and observes their latency per route pattern,
+// patterns are used instead of paths to keep number of series bounded
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		metrics.HTTPRequestsTotal.Inc(r.Method, route, strconv.Itoa(recorder.status))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+func Test_instrument(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(instrument)
+	r.Route("/v1/models/{team}/{project}", func(r chi.Router) {
+		r.Get("/list", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+	})
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	for _, path := range []string{"/v1/models/team/project/list", "/v1/models/other/project/list", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`tfd_http_requests_total{method="GET",route="/v1/models/{team}/{project}/list",code="418"} 2`,
+		`tfd_http_requests_total{method="GET",route="unmatched",code="404"} 1`,
+		`tfd_http_request_duration_seconds_count{method="GET",route="/v1/models/{team}/{project}/list"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics don't contain %s:\n%s", want, rec.Body.String())
+		}
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
 const labelStable = "stable"
@@ -38,14 +42,25 @@ type ModelsService interface {
 	ListModelsByProject(ctx context.Context, team, project string) ([]*app.ModelData, error)
 	ListModelsByName(ctx context.Context, id app.ServableID) ([]*app.ModelData, error)
 	ReloadModels(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error)
-	UploadModel(ctx context.Context, model app.ServableID, file io.Reader, label ...string) (*app.ModelID, error)
+	UploadModel(ctx context.Context, model app.ServableID, file io.Reader, force bool, label ...string) (*app.ModelID, error)
 
 	RemoveByLabel(ctx context.Context, id app.ServableID, label string) error
 	RemoveByVersion(ctx context.Context, id app.ServableID, version int64) error
 	RemoveModelLabel(ctx context.Context, id app.ServableID, label string) error
 
 	Revert(ctx context.Context, id app.ServableID) (*app.LabelChanged, error)
-	SetLabel(ctx context.Context, model app.ModelID) (*app.LabelChanged, error)
+	SetLabel(ctx context.Context, model app.ModelID, force bool) (*app.LabelChanged, error)
+	LabelHistory(ctx context.Context, id app.ServableID, label string) ([]app.LabelHistoryEntry, error)
+	RollbackLabel(ctx context.Context, id app.ServableID, label string, entryID int64, force bool) (*app.LabelChanged, error)
+
+	SignaturesByLabel(ctx context.Context, id app.ServableID, label string) (*app.ModelSignatures, error)
+	SignaturesByVersion(ctx context.Context, id app.ServableID, version int64) (*app.ModelSignatures, error)
+
+	SetSmokeTest(ctx context.Context, id app.ServableID, test app.SmokeTest) error
+	SmokeTest(ctx context.Context, id app.ServableID) (*app.ModelSmokeTest, error)
+	DeleteSmokeTest(ctx context.Context, id app.ServableID) error
+	RunSmokeTest(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error)
+	SmokeTestResults(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error)
 }
 
 func (rest *REST) listModelsHandler(w http.ResponseWriter, r *http.Request) {
@@ -121,6 +136,7 @@ func (rest *REST) revertModelHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
 		return
 	}
+	auditTarget(r, revertResp.NewVersion, revertResp.Label)
 
 	writeJSONSuccessResponse(w, r, http.StatusOK, fmt.Sprintf("model[%s-%s-%s] label '%s' changed from version [%d] to [%d]",
 		revertResp.Team, revertResp.Project, revertResp.Name, revertResp.Label, revertResp.PreviousVersion, revertResp.NewVersion))
@@ -178,7 +194,7 @@ func (rest *REST) downloadModelByLabelHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	writeBinaryDataResponse(w, r, archive.Data, archive.Name)
+	writeArchiveResponse(w, r, archive)
 }
 
 func (rest *REST) deleteModelByLabelHandler(w http.ResponseWriter, r *http.Request) {
@@ -250,10 +266,28 @@ func (rest *REST) downloadModelByVersionHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	writeBinaryDataResponse(w, r, archive.Data, archive.Name)
+	writeArchiveResponse(w, r, archive)
 }
 
-func (rest *REST) setModelLabelToStableHandler(w http.ResponseWriter, r *http.Request) {
+func (rest *REST) modelSignaturesByLabelHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	signatures, err := rest.modelsService.SignaturesByLabel(r.Context(), urlParams.ServableID(), urlParams.Label)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, signatures)
+}
+
+func (rest *REST) modelSignaturesByVersionHandler(w http.ResponseWriter, r *http.Request) {
 	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion)
 	if err != nil {
 		err = exterr.WrapWithErr(err, errorModelBadRequest)
@@ -262,8 +296,27 @@ func (rest *REST) setModelLabelToStableHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	signatures, err := rest.modelsService.SignaturesByVersion(r.Context(), urlParams.ServableID(), urlParams.Version)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, signatures)
+}
+
+func (rest *REST) setModelLabelToStableHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion, urlForce)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
 	modelID := app.ModelID{ServableID: urlParams.ServableID(), Version: urlParams.Version, Label: labelStable}
-	lChangedResp, err := rest.modelsService.SetLabel(r.Context(), modelID)
+	auditTarget(r, 0, labelStable)
+	lChangedResp, err := rest.modelsService.SetLabel(r.Context(), modelID, urlParams.Force)
 	if err != nil {
 		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
 		return
@@ -274,7 +327,7 @@ func (rest *REST) setModelLabelToStableHandler(w http.ResponseWriter, r *http.Re
 }
 
 func (rest *REST) setModelLabelHandler(w http.ResponseWriter, r *http.Request) {
-	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion, urlLabel)
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion, urlLabel, urlForce)
 	if err != nil {
 		err = exterr.WrapWithErr(err, errorModelBadRequest)
 		logging.ErrorWithStack(r.Context(), err)
@@ -283,7 +336,7 @@ func (rest *REST) setModelLabelHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	modelID := app.ModelID{ServableID: urlParams.ServableID(), Version: urlParams.Version, Label: urlParams.Label}
-	lChangedResp, err := rest.modelsService.SetLabel(r.Context(), modelID)
+	lChangedResp, err := rest.modelsService.SetLabel(r.Context(), modelID, urlParams.Force)
 	if err != nil {
 		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
 		return
@@ -293,8 +346,54 @@ func (rest *REST) setModelLabelHandler(w http.ResponseWriter, r *http.Request) {
 		lChangedResp.Team, lChangedResp.Project, lChangedResp.Name, lChangedResp.Label, lChangedResp.PreviousVersion, lChangedResp.NewVersion))
 }
 
+func (rest *REST) labelHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := rest.modelsService.LabelHistory(r.Context(), urlParams.ServableID(), urlParams.Label)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, entries)
+}
+
+func (rest *REST) rollbackModelLabelHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel, urlForce)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	entryID, err := strconv.ParseInt(chi.URLParam(r, "entry"), 10, 64)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	lChangedResp, err := rest.modelsService.RollbackLabel(r.Context(), urlParams.ServableID(), urlParams.Label, entryID, urlParams.Force)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+	auditTarget(r, lChangedResp.NewVersion, "")
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, fmt.Sprintf("model[%s-%s-%s] label '%s' changed from version [%d] to [%d]",
+		lChangedResp.Team, lChangedResp.Project, lChangedResp.Name, lChangedResp.Label, lChangedResp.PreviousVersion, lChangedResp.NewVersion))
+}
+
 func (rest *REST) uploadModelHandler(w http.ResponseWriter, r *http.Request) {
-	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlForce)
 	if err != nil {
 		err = exterr.WrapWithErr(err, errorModelBadRequest)
 		logging.ErrorWithStack(r.Context(), err)
@@ -310,7 +409,7 @@ func (rest *REST) uploadModelHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rest.lock.UnLock(modelID)
 
-	resp, err := rest.uploadModel(r, modelID)
+	resp, err := rest.uploadModel(r, modelID, urlParams.Force)
 	if err != nil {
 		logging.ErrorWithStack(r.Context(), err)
 		writeJSONErrorResponse(w, r, resp.responseCode, err)
@@ -320,7 +419,7 @@ func (rest *REST) uploadModelHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (rest *REST) uploadModelWithLabelHandler(w http.ResponseWriter, r *http.Request) {
-	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel)
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel, urlForce)
 	if err != nil {
 		err = exterr.WrapWithErr(err, errorModelBadRequest)
 		logging.ErrorWithStack(r.Context(), err)
@@ -336,7 +435,7 @@ func (rest *REST) uploadModelWithLabelHandler(w http.ResponseWriter, r *http.Req
 	}
 	defer rest.lock.UnLock(modelID)
 
-	resp, err := rest.uploadModel(r, modelID, urlParams.Label)
+	resp, err := rest.uploadModel(r, modelID, urlParams.Force, urlParams.Label)
 	if err != nil {
 		logging.ErrorWithStack(r.Context(), err)
 		writeJSONErrorResponse(w, r, resp.responseCode, err)
@@ -346,14 +445,21 @@ func (rest *REST) uploadModelWithLabelHandler(w http.ResponseWriter, r *http.Req
 	writeJSONSuccessResponse(w, r, resp.responseCode, resp.modelID)
 }
 
-func (rest *REST) uploadModel(r *http.Request, id app.ServableID, label ...string) (*UploadModelResponse, error) {
-	file, _, err := r.FormFile(rest.uploadFileName)
+func (rest *REST) uploadModel(r *http.Request, id app.ServableID, force bool, label ...string) (_ *UploadModelResponse, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ModelUploadDuration.Observe(time.Since(start).Seconds(), id.Team, id.Project, metrics.Result(err))
+	}()
+
+	file, header, err := r.FormFile(rest.uploadFileName)
 	if err != nil {
 		return &UploadModelResponse{responseCode: http.StatusTemporaryRedirect}, exterr.WrapWithFrame(err)
 	}
 	defer file.Close()
 	defer r.MultipartForm.RemoveAll()
 
+	metrics.ModelUploadBytes.Observe(float64(header.Size), id.Team, id.Project)
+
 	var dup bytes.Buffer
 	tee := io.TeeReader(file, &dup)
 
@@ -364,10 +470,11 @@ func (rest *REST) uploadModel(r *http.Request, id app.ServableID, label ...strin
 		tee = bytes.NewReader(dup.Bytes())
 	}
 
-	model, err := rest.modelsService.UploadModel(r.Context(), id, tee, label...)
+	model, err := rest.modelsService.UploadModel(r.Context(), id, tee, force, label...)
 	if err != nil {
 		return &UploadModelResponse{responseCode: http.StatusTemporaryRedirect}, exterr.WrapWithFrame(err)
 	}
+	auditTarget(r, model.Version, "")
 
 	return &UploadModelResponse{modelID: model, responseCode: http.StatusOK}, nil
 }
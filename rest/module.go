@@ -154,6 +154,7 @@ func (rest *REST) uploadModule(r *http.Request, id app.ServableID) (*UploadModul
 	if err != nil {
 		return &UploadModuleResponse{responseCode: http.StatusTemporaryRedirect}, err
 	}
+	auditTarget(r, module.Version, "")
 
 	return &UploadModuleResponse{moduleID: module, responseCode: http.StatusOK}, nil
 }
@@ -173,5 +174,5 @@ func (rest *REST) downloadModuleByVersionHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	writeBinaryDataResponse(w, r, archive.Data, archive.Name)
+	writeArchiveResponse(w, r, archive)
 }
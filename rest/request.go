@@ -28,6 +28,7 @@ type URLParams struct {
 	Label           string `validate:"omitempty,max=32,min=1"`
 	Status          string `validate:"omitempty,max=32,min=1"`
 	SkipShortConfig bool   `validate:"omitempty"`
+	Force           bool   `validate:"omitempty"`
 }
 
 // ServableID returns a ServableID struct based on
@@ -70,6 +71,7 @@ const (
 	urlVersion         = "Version"
 	urlLabel           = "Label"
 	urlSkipShortConfig = "SkipShortConfig"
+	urlForce           = "Force"
 )
 
 func parseAndValidateParamsFromRequest(r *http.Request, allowQueryStrings bool, fields ...string) (*URLParams, error) {
@@ -2,11 +2,15 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
 )
 
 func writeJSONSuccessResponse(w http.ResponseWriter, r *http.Request, statusCode int, body interface{}) {
@@ -24,6 +28,8 @@ func writeJSONSuccessResponse(w http.ResponseWriter, r *http.Request, statusCode
 }
 
 func writeJSONErrorResponse(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	auditError(r, err)
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
 
@@ -42,15 +48,99 @@ func prepareErrorDetails(err error, errorCode int) app.ErrorBody {
 	var result app.ErrorBody
 	result.Error.ErrorCode = strconv.Itoa(errorCode)
 	result.Error.ErrorMessage = err.Error()
-	if newErr, ok := err.(*exterr.Error); ok {
-		result.Error.ErrorMessage = newErr.Error()
+	if codedErr := findCodedError(err); codedErr != nil {
+		result.Error.ErrorMessage = codedErr.Error()
+	}
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		result.Error.Details = detailed.Details()
 	}
 	return result
 }
 
+// detailedError is implemented by errors which carry structured details of failure,
+// e.g. diff of incompatible signatures
+type detailedError interface {
+	error
+	Details() interface{}
+}
+
+// findCodedError returns the outermost error with component code, errors wrapped
+// only to record stack frames hide codes of errors they wrap, e.g. storage rejections
+func findCodedError(err error) *exterr.Error {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if extErr, ok := err.(*exterr.Error); ok && extErr.Code() != 0 {
+			return extErr
+		}
+	}
+
+	return nil
+}
+
 func writeBinaryDataResponse(w http.ResponseWriter, r *http.Request, data []byte, filename string) {
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Header().Set("Content-Type", "application/octet-stream;")
 	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
 	w.Write(data)
 }
+
+// writeArchiveResponse streams archive into response. Archive is compressed when client
+// accepts gzip or zstd encoding, otherwise it's sent as is and range requests are supported,
+// so interrupted downloads may be resumed
+func writeArchiveResponse(w http.ResponseWriter, r *http.Request, archive *app.Archive) {
+	defer archive.Content.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream;")
+	w.Header().Set("Content-Disposition", "attachment; filename="+archive.Name)
+	w.Header().Set("Vary", "Accept-Encoding")
+
+	content := &loggingReadSeeker{ReadSeeker: archive.Content, r: r}
+
+	encoding := ""
+	if r.Header.Get("Range") == "" {
+		encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	}
+	if encoding == "" {
+		if archive.ETag != "" {
+			w.Header().Set("ETag", strconv.Quote(archive.ETag))
+		}
+		http.ServeContent(w, r, archive.Name, time.Time{}, content)
+		return
+	}
+
+	encoder, err := newEncoder(encoding, w)
+	if err != nil {
+		logging.ErrorWithStack(r.Context(), exterr.WrapWithFrame(err))
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(encoder, content); err != nil {
+		return
+	}
+	if err := encoder.Close(); err != nil {
+		logging.ErrorWithStack(r.Context(), exterr.WrapWithFrame(err))
+	}
+}
+
+// loggingReadSeeker logs read errors, which can't be returned to client
+// once response status has been sent
+type loggingReadSeeker struct {
+	io.ReadSeeker
+	r *http.Request
+}
+
+func (l *loggingReadSeeker) Read(p []byte) (int, error) {
+	n, err := l.ReadSeeker.Read(p)
+	if err != nil && err != io.EOF {
+		logging.ErrorWithStack(l.r.Context(), err)
+	}
+
+	return n, err
+}
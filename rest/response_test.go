@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+func Test_prepareErrorDetails(t *testing.T) {
+	coded := func() error {
+		return exterr.NewErrorWithMessage("archive links are not allowed: saved_model.pb").WithComponent("STORAGE").WithCode(1013)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "Plain error", err: errors.New("plain"), want: "plain"},
+		{name: "Coded error", err: coded(), want: "STORAGE-1013 archive links are not allowed: saved_model.pb"},
+		{name: "Coded error wrapped with frames", err: exterr.WrapWithFrame(exterr.WrapWithFrame(coded())), want: "STORAGE-1013 archive links are not allowed: saved_model.pb"},
+		{name: "Error wrapped with coded error", err: exterr.WrapWithErr(errors.New("cause"), errorModelBadRequest), want: errorModelBadRequest.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prepareErrorDetails(tt.err, 400).Error.ErrorMessage; got != tt.want {
+				t.Errorf("prepareErrorDetails() message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type testDetailedError struct{ details interface{} }
+
+func (e *testDetailedError) Error() string        { return "detailed" }
+func (e *testDetailedError) Details() interface{} { return e.details }
+
+func Test_prepareErrorDetails_details(t *testing.T) {
+	details := map[string]int{"current_version": 1}
+
+	if got := prepareErrorDetails(exterr.WrapWithFrame(&testDetailedError{details: details}), 307).Error.Details; !reflect.DeepEqual(got, details) {
+		t.Errorf("prepareErrorDetails() details = %v, want %v", got, details)
+	}
+	if got := prepareErrorDetails(errors.New("plain"), 307).Error.Details; got != nil {
+		t.Errorf("prepareErrorDetails() details = %v, want nil", got)
+	}
+}
@@ -1,32 +1,58 @@
 package rest
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/auth"
 	"github.com/grupawp/tensorflow-deploy/exterr"
 	"github.com/grupawp/tensorflow-deploy/lock"
 	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
 var (
+	infoShutdown         = "REST shutdown, waiting for in-flight requests"
+	infoShutdownComplete = "REST shutdown complete"
+
 	logInvalidChecksumErrorCode = 1001
-	errorInvalidChecksum        = exterr.NewErrorWithMessage("invalid checksum").WithComponent(app.ComponentRest).WithCode(logInvalidChecksumErrorCode)
+	logShutdownErrorCode        = 1006
+
+	errorInvalidChecksum = exterr.NewErrorWithMessage("invalid checksum").WithComponent(app.ComponentRest).WithCode(logInvalidChecksumErrorCode)
+	errorShutdown        = exterr.NewErrorWithMessage("in-flight requests haven't finished before shutdown timeout").WithComponent(app.ComponentRest).WithCode(logShutdownErrorCode)
 )
 
 // REST represents restful API methods
 type REST struct {
 	modelsService  ModelsService
 	modulesService ModulesService
+	authenticator  auth.Authenticator
+	uploadSessions UploadSessions
+	rollouts       Rollouts
+	auditor        Auditor
+	retention      Retention
+	fsck           Fsck
+	// statusReporters are reported by /ping by name of component
+	statusReporters map[string]StatusReporter
 
 	uploadFileName     string
 	uploadFileChecksum string
 	listenPort         string
 	version            string
+	shutdownTimeout    time.Duration
 
 	lock *lock.Lock
+	// uploads tracks in-flight uploads, so they can clean up
+	// incoming archives when interrupted during shutdown
+	uploads sync.WaitGroup
 }
 
 // NewREST returns new instance of REST struct
@@ -40,76 +66,264 @@ func NewREST(modelsSrv ModelsService, modulesSrv ModulesService, listenPort, ver
 		uploadFileChecksum: "archive_hash",
 		listenPort:         listenPort,
 		version:            version,
+		shutdownTimeout:    defaultShutdownTimeout,
 		lock:               l,
 	}
 }
 
-// Mount mounts each restful endpoints into router
-func (rest *REST) Mount() {
+// WithAuthenticator enables authentication and authorization of endpoints
+// bound to teams and projects, REST API is public by default
+func (rest *REST) WithAuthenticator(authenticator auth.Authenticator) *REST {
+	rest.authenticator = authenticator
+
+	return rest
+}
+
+// WithUploadSessions enables resumable uploads of models
+func (rest *REST) WithUploadSessions(sessions UploadSessions) *REST {
+	rest.uploadSessions = sessions
+
+	return rest
+}
+
+// WithRollouts enables staged promotions of versions of models
+func (rest *REST) WithRollouts(rollouts Rollouts) *REST {
+	rest.rollouts = rollouts
+
+	return rest
+}
+
+// WithAuditor enables audit log of mutating calls
+func (rest *REST) WithAuditor(auditor Auditor) *REST {
+	rest.auditor = auditor
+
+	return rest
+}
+
+// WithRetention enables preview of versions removed by retention policies
+func (rest *REST) WithRetention(retention Retention) *REST {
+	rest.retention = retention
+
+	return rest
+}
+
+// WithFsck enables consistency check of storage, metadata and configs of TFS instances
+func (rest *REST) WithFsck(fsck Fsck) *REST {
+	rest.fsck = fsck
+
+	return rest
+}
+
+// StatusReporter reports problem of component which doesn't stop service,
+// like rejected change of configuration reloaded at runtime
+type StatusReporter interface {
+	Status() error
+}
+
+// WithStatusReporter makes /ping report errors of component
+func (rest *REST) WithStatusReporter(component string, reporter StatusReporter) *REST {
+	if rest.statusReporters == nil {
+		rest.statusReporters = make(map[string]StatusReporter)
+	}
+	rest.statusReporters[component] = reporter
+
+	return rest
+}
+
+// WithShutdownTimeout sets time given to in-flight requests to finish after context of Mount is done
+func (rest *REST) WithShutdownTimeout(timeout time.Duration) *REST {
+	rest.shutdownTimeout = timeout
+
+	return rest
+}
+
+// Mount mounts each restful endpoints into router and serves them until ctx is done.
+// Then it stops accepting new connections and waits for in-flight requests
+func (rest *REST) Mount(ctx context.Context) error {
+	listener, err := net.Listen("tcp", rest.listenPort)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return rest.serve(ctx, listener, rest.router())
+}
+
+// serve serves handler on listener until ctx is done and shuts server down gracefully.
+// Connections which are still active after shutdown timeout are closed and interrupted
+// uploads are awaited, so they don't leave partially written archives behind
+func (rest *REST) serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return exterr.WrapWithFrame(err)
+	case <-ctx.Done():
+	}
+
+	logging.Info(ctx, infoShutdown)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), rest.shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		rest.uploads.Wait()
+		return exterr.WrapWithErr(err, errorShutdown)
+	}
+
+	logging.Info(ctx, infoShutdownComplete)
+
+	return nil
+}
+
+// trackUpload marks request as in-flight upload
+func (rest *REST) trackUpload(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest.uploads.Add(1)
+		defer rest.uploads.Done()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// router returns router with each restful endpoints
+func (rest *REST) router() http.Handler {
 	r := chi.NewRouter()
 
+	// metrics middleware
+	r.Use(instrument)
+
 	// logging middlewares
 	r.Use(logging.HTTPCtxValuesMiddleware)
 	r.Use(logging.HTTPRequestMiddleware())
 
+	// auth middlewares
+	r.Use(rest.authenticate)
+	reader := rest.authorize(auth.RoleReader)
+	deployer := rest.authorize(auth.RoleDeployer)
+	admin := rest.authorize(auth.RoleAdmin)
+
 	// common
 	r.Get("/ping", rest.pingHandler)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 
 	// v3: model
 	r.Route("/v1/models", func(r chi.Router) {
-		r.Get("/list", rest.listModelsHandler)
+		r.With(reader).Get("/list", rest.listModelsHandler)
 	})
 
 	r.Route("/v1/models/{team}/{project}", func(r chi.Router) {
-		r.Get("/config", rest.configFileHandler)
-		r.Get("/list", rest.listModelsByProjectHandler)
-		r.Post("/reload", rest.reloadHandler)
+		r.With(reader).Get("/config", rest.configFileHandler)
+		r.With(reader).Get("/list", rest.listModelsByProjectHandler)
+		r.With(rest.audit(auditModelReload), deployer).Post("/reload", rest.reloadHandler)
 	})
 
 	r.Route("/v1/models/{team}/{project}/names/{name}", func(r chi.Router) {
-		r.Post("/", rest.uploadModelHandler)
-		r.Get("/list", rest.listModelsByNameHandler)
-		r.Put("/revert", rest.revertModelHandler)
+		r.With(rest.audit(auditModelUpload), deployer, rest.trackUpload).Post("/", rest.uploadModelHandler)
+		r.With(reader).Get("/list", rest.listModelsByNameHandler)
+		r.With(rest.audit(auditModelRevert), deployer).Put("/revert", rest.revertModelHandler)
+		r.With(reader).Get("/smoke_test", rest.smokeTestHandler)
+		r.With(rest.audit(auditModelSetSmokeTest), deployer).Put("/smoke_test", rest.setSmokeTestHandler)
+		r.With(rest.audit(auditModelDelSmokeTest), deployer).Delete("/smoke_test", rest.deleteSmokeTestHandler)
 	})
 
+	if rest.retention != nil {
+		r.With(reader).Get("/v1/models/{team}/{project}/retention", rest.retentionCandidatesByProjectHandler)
+		r.With(reader).Get("/v1/models/{team}/{project}/names/{name}/retention", rest.retentionCandidatesByNameHandler)
+	}
+
+	if rest.uploadSessions != nil {
+		r.Route("/v1/models/{team}/{project}/names/{name}/uploads", func(r chi.Router) {
+			r.With(rest.audit(auditUploadCreate), deployer).Post("/", rest.createModelUploadHandler)
+			r.With(deployer).Head("/{upload}", rest.modelUploadHandler)
+			r.With(deployer).Get("/{upload}", rest.modelUploadHandler)
+			r.With(rest.audit(auditUploadChunk), deployer, rest.trackUpload).Patch("/{upload}", rest.writeModelUploadChunkHandler)
+			r.With(rest.audit(auditUploadFinalize), deployer, rest.trackUpload).Post("/{upload}/finalize", rest.finalizeModelUploadHandler)
+			r.With(rest.audit(auditUploadDelete), deployer).Delete("/{upload}", rest.deleteModelUploadHandler)
+		})
+	}
+
+	if rest.rollouts != nil {
+		r.Route("/v1/models/{team}/{project}/names/{name}/rollouts", func(r chi.Router) {
+			r.With(reader).Get("/", rest.listRolloutsHandler)
+			r.With(rest.audit(auditRolloutStart), deployer).Post("/", rest.startRolloutHandler)
+			r.With(reader).Get("/{rollout}", rest.rolloutHandler)
+			r.With(rest.audit(auditRolloutPause), deployer).Put("/{rollout}/pause", rest.pauseRolloutHandler)
+			r.With(rest.audit(auditRolloutResume), deployer).Put("/{rollout}/resume", rest.resumeRolloutHandler)
+			r.With(rest.audit(auditRolloutAbort), deployer).Put("/{rollout}/abort", rest.abortRolloutHandler)
+		})
+	}
+
 	r.Route("/v1/models/{team}/{project}/names/{name}/labels/{label}", func(r chi.Router) {
-		r.Get("/", rest.downloadModelByLabelHandler)
-		r.Delete("/", rest.deleteModelLabelHandler)
-		r.Post("/", rest.uploadModelWithLabelHandler)
-		r.Delete("/remove_version", rest.deleteModelByLabelHandler)
+		r.With(reader).Get("/", rest.downloadModelByLabelHandler)
+		r.With(reader).Get("/signatures", rest.modelSignaturesByLabelHandler)
+		r.With(reader).Get("/history", rest.labelHistoryHandler)
+		r.With(rest.audit(auditModelRollback), deployer).Put("/history/{entry}/rollback", rest.rollbackModelLabelHandler)
+		r.With(rest.audit(auditModelDeleteLabel), admin).Delete("/", rest.deleteModelLabelHandler)
+		r.With(rest.audit(auditModelUpload), deployer, rest.trackUpload).Post("/", rest.uploadModelWithLabelHandler)
+		r.With(rest.audit(auditModelDeleteByLabel), admin).Delete("/remove_version", rest.deleteModelByLabelHandler)
 	})
 
 	r.Route("/v1/models/{team}/{project}/names/{name}/versions/{version}", func(r chi.Router) {
-		r.Get("/", rest.downloadModelByVersionHandler)
-		r.Delete("/", rest.deleteModelByVersionHandler)
-		r.Put("/labels/stable", rest.setModelLabelToStableHandler)
-		r.Put("/labels/{label}", rest.setModelLabelHandler)
+		r.With(reader).Get("/", rest.downloadModelByVersionHandler)
+		r.With(reader).Get("/signatures", rest.modelSignaturesByVersionHandler)
+		r.With(reader).Get("/smoke_test", rest.smokeTestResultsHandler)
+		r.With(rest.audit(auditModelRunSmokeTest), deployer).Post("/smoke_test", rest.runSmokeTestHandler)
+		r.With(rest.audit(auditModelDelete), admin).Delete("/", rest.deleteModelByVersionHandler)
+		r.With(rest.audit(auditModelSetLabel), deployer).Put("/labels/stable", rest.setModelLabelToStableHandler)
+		r.With(rest.audit(auditModelSetLabel), deployer).Put("/labels/{label}", rest.setModelLabelHandler)
 	})
 
+	if rest.auditor != nil {
+		r.With(admin).Get("/v1/audit", rest.auditHandler)
+	}
+
+	if rest.fsck != nil {
+		r.With(admin).Get("/v1/fsck", rest.fsckHandler)
+		r.With(rest.audit(auditFsckRepair), admin).Post("/v1/fsck/repair", rest.fsckRepairHandler)
+	}
+
 	// v3: module
 	r.Route("/v1/modules", func(r chi.Router) {
-		r.Get("/list", rest.listModulesHandler)
+		r.With(reader).Get("/list", rest.listModulesHandler)
 	})
 	r.Route("/v1/modules/{team}/{project}", func(r chi.Router) {
-		r.Get("/list", rest.listModulesByProjectHandler)
+		r.With(reader).Get("/list", rest.listModulesByProjectHandler)
 	})
 	r.Route("/v1/modules/{team}/{project}/names/{name}", func(r chi.Router) {
-		r.Post("/", rest.uploadModuleHandler)
-		r.Get("/list", rest.listModulesByNameHandler)
-		r.Get("/versions/{version}", rest.downloadModuleByVersionHandler)
-		r.Delete("/versions/{version}", rest.deleteModuleHandler)
+		r.With(rest.audit(auditModuleUpload), deployer, rest.trackUpload).Post("/", rest.uploadModuleHandler)
+		r.With(reader).Get("/list", rest.listModulesByNameHandler)
+		r.With(reader).Get("/versions/{version}", rest.downloadModuleByVersionHandler)
+		r.With(rest.audit(auditModuleDelete), admin).Delete("/versions/{version}", rest.deleteModuleHandler)
 	})
 
-	server := &http.Server{Addr: rest.listenPort, Handler: r}
-	server.ListenAndServe()
+	return r
 }
 
 func (rest *REST) pingHandler(w http.ResponseWriter, r *http.Request) {
+	// errors of components don't fail ping, service still works with their previous state
+	var errs map[string]string
+	for component, reporter := range rest.statusReporters {
+		if err := reporter.Status(); err != nil {
+			if errs == nil {
+				errs = make(map[string]string)
+			}
+			errs[component] = err.Error()
+		}
+	}
+
 	writeJSONSuccessResponse(w, r, http.StatusOK, struct {
-		Name    string `json:"name"`
-		Version string `json:"version"`
+		Name    string            `json:"name"`
+		Version string            `json:"version"`
+		Errors  map[string]string `json:"errors,omitempty"`
 	}{
 		Name:    fmt.Sprintf("%s:%s", "tensorflow-deploy", rest.version),
 		Version: rest.version,
+		Errors:  errs,
 	})
 }
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestREST_serve(t *testing.T) {
+	tests := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		wantErr         error
+		wantStatus      int
+	}{
+		{name: "In-flight upload should finish before shutdown", shutdownTimeout: time.Second, wantStatus: http.StatusOK},
+		{name: "In-flight upload should be interrupted after shutdown timeout", shutdownTimeout: 10 * time.Millisecond, wantErr: errorShutdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest := NewREST(nil, nil, "", "").WithShutdownTimeout(tt.shutdownTimeout)
+
+			started := make(chan struct{})
+			finished := false
+			handler := rest.trackUpload(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(200 * time.Millisecond):
+				case <-r.Context().Done():
+				}
+				finished = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- rest.serve(ctx, listener, handler)
+			}()
+
+			status := make(chan int, 1)
+			go func() {
+				resp, err := http.Post("http://"+listener.Addr().String()+"/", "application/octet-stream", nil)
+				if err != nil {
+					status <- 0
+					return
+				}
+				resp.Body.Close()
+				status <- resp.StatusCode
+			}()
+
+			<-started
+			cancel()
+
+			if err := <-serveErr; !errors.Is(err, tt.wantErr) {
+				t.Errorf("serve() error = %v, want %v", err, tt.wantErr)
+			}
+			if !finished {
+				t.Error("serve() returned before upload handler finished")
+			}
+			if got := <-status; got != tt.wantStatus {
+				t.Errorf("response status = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+type fakeStatusReporter struct {
+	err error
+}
+
+func (f fakeStatusReporter) Status() error {
+	return f.err
+}
+
+func TestREST_pingHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		reporter StatusReporter
+		want     string
+	}{
+		{name: "Ping without status reporters shouldn't report errors", want: `"version":"1.0.0"}`},
+		{name: "Ping shouldn't report errors of healthy component", reporter: fakeStatusReporter{}, want: `"version":"1.0.0"}`},
+		{name: "Ping should report error of component", reporter: fakeStatusReporter{err: errors.New("invalid hosts file")}, want: `"errors":{"discovery":"invalid hosts file"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest := NewREST(nil, nil, "", "1.0.0")
+			if tt.reporter != nil {
+				rest.WithStatusReporter("discovery", tt.reporter)
+			}
+
+			w := httptest.NewRecorder()
+			rest.pingHandler(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("pingHandler() = %d %s, want %d containing %s", w.Code, w.Body.String(), http.StatusOK, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+// Retention is the interface that previews versions of models removed by retention policies
+type Retention interface {
+	Candidates(ctx context.Context, params app.QueryParameters) ([]app.RetentionCandidate, error)
+}
+
+func (rest *REST) retentionCandidatesByProjectHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rest.writeRetentionCandidates(w, r, app.QueryParameters{"team": urlParams.Team, "project": urlParams.Project})
+}
+
+func (rest *REST) retentionCandidatesByNameHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rest.writeRetentionCandidates(w, r, app.QueryParameters{"team": urlParams.Team, "project": urlParams.Project, "name": urlParams.Name})
+}
+
+// writeRetentionCandidates writes versions of models matching params which are going to be removed
+func (rest *REST) writeRetentionCandidates(w http.ResponseWriter, r *http.Request, params app.QueryParameters) {
+	candidates, err := rest.retention.Candidates(r.Context(), params)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, candidates)
+}
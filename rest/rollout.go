@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+// maxRolloutPlanSize limits size of rollout plan sent in request body
+const maxRolloutPlanSize = 1 << 20
+
+// Rollouts is the interface that runs staged promotions of versions of models
+type Rollouts interface {
+	Start(ctx context.Context, model app.ModelID, plan app.RolloutPlan) (*app.Rollout, error)
+	Get(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)
+	List(ctx context.Context, id app.ServableID) ([]*app.Rollout, error)
+	Pause(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)
+	Resume(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)
+	Abort(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)
+}
+
+// rolloutRequest starts rollout of model version
+type rolloutRequest struct {
+	Version int64 `json:"version"`
+	app.RolloutPlan
+}
+
+func (rest *REST) listRolloutsHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rollouts, err := rest.rollouts.List(r.Context(), urlParams.ServableID())
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, rollouts)
+}
+
+func (rest *REST) startRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var request rolloutRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRolloutPlanSize)).Decode(&request); err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if request.Version < 1 {
+		logging.ErrorWithStack(r.Context(), errorModelBadRequest)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, errorModelBadRequest)
+		return
+	}
+
+	model := app.ModelID{ServableID: urlParams.ServableID(), Version: request.Version}
+	auditTarget(r, request.Version, "")
+	rollout, err := rest.rollouts.Start(r.Context(), model, request.RolloutPlan)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusCreated, rollout)
+}
+
+func (rest *REST) rolloutHandler(w http.ResponseWriter, r *http.Request) {
+	rest.changeRollout(w, r, rest.rollouts.Get)
+}
+
+func (rest *REST) pauseRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	rest.changeRollout(w, r, rest.rollouts.Pause)
+}
+
+func (rest *REST) resumeRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	rest.changeRollout(w, r, rest.rollouts.Resume)
+}
+
+func (rest *REST) abortRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	rest.changeRollout(w, r, rest.rollouts.Abort)
+}
+
+// changeRollout calls action with rollout identified by request URL and writes rollout returned by it
+func (rest *REST) changeRollout(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rolloutID, err := strconv.ParseInt(chi.URLParam(r, "rollout"), 10, 64)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	rollout, err := action(r.Context(), urlParams.ServableID(), rolloutID)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+	auditTarget(r, rollout.Version, "")
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, rollout)
+}
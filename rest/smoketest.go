@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+// maxSmokeTestSize limits size of smoke test sent in request body
+const maxSmokeTestSize = 4 << 20
+
+func (rest *REST) smokeTestHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	test, err := rest.modelsService.SmokeTest(r.Context(), urlParams.ServableID())
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, test)
+}
+
+func (rest *REST) setSmokeTestHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var test app.SmokeTest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSmokeTestSize)).Decode(&test); err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := rest.modelsService.SetSmokeTest(r.Context(), urlParams.ServableID(), test); err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, app.ModelSmokeTest{ServableID: urlParams.ServableID(), SmokeTest: test})
+}
+
+func (rest *REST) deleteSmokeTestHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := rest.modelsService.DeleteSmokeTest(r.Context(), urlParams.ServableID()); err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, nil)
+}
+
+func (rest *REST) runSmokeTestHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := rest.modelsService.RunSmokeTest(r.Context(), urlParams.ServableID(), urlParams.Version)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, results)
+}
+
+func (rest *REST) smokeTestResultsHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlVersion)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := rest.modelsService.SmokeTestResults(r.Context(), urlParams.ServableID(), urlParams.Version)
+	if err != nil {
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, results)
+}
@@ -0,0 +1,250 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+	"github.com/grupawp/tensorflow-deploy/upload"
+)
+
+const (
+	headerUploadLength   = "Upload-Length"
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadChecksum = "Upload-Checksum"
+	headerUploadExpires  = "Upload-Expires"
+
+	chunkContentType = "application/offset+octet-stream"
+	checksumAlgo     = "sha256"
+)
+
+var (
+	logInvalidUploadHeaderErrorCode = 1007
+	logInvalidChunkTypeErrorCode    = 1008
+
+	errorInvalidUploadHeader = exterr.NewErrorWithMessage("invalid upload header").WithComponent(app.ComponentRest).WithCode(logInvalidUploadHeaderErrorCode)
+	errorInvalidChunkType    = exterr.NewErrorWithMessage("chunk content type has to be " + chunkContentType).WithComponent(app.ComponentRest).WithCode(logInvalidChunkTypeErrorCode)
+)
+
+// UploadSessions is the interface that keeps state of resumable uploads
+type UploadSessions interface {
+	Create(ctx context.Context, id app.ServableID, label string, length int64, checksum string) (*upload.Session, error)
+	Get(ctx context.Context, id app.ServableID, sessionID string) (*upload.Session, error)
+	Write(ctx context.Context, id app.ServableID, sessionID string, offset int64, chunk io.Reader) (*upload.Session, error)
+	Open(ctx context.Context, id app.ServableID, sessionID string) (*upload.Session, io.ReadCloser, error)
+	Remove(ctx context.Context, id app.ServableID, sessionID string) error
+}
+
+func (rest *REST) createModelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlLabel)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get(headerUploadLength), 10, 64)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorInvalidUploadHeader)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	checksum, err := parseUploadChecksum(r.Header.Get(headerUploadChecksum))
+	if err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	session, err := rest.uploadSessions.Create(r.Context(), urlParams.ServableID(), urlParams.Label, length, checksum)
+	if err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, uploadErrorStatus(err), err)
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+session.ID)
+	writeUploadHeaders(w, session)
+	writeJSONSuccessResponse(w, r, http.StatusCreated, session)
+}
+
+func (rest *REST) modelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	session, err := rest.uploadSessions.Get(r.Context(), urlParams.ServableID(), chi.URLParam(r, "upload"))
+	if err != nil {
+		writeJSONErrorResponse(w, r, uploadErrorStatus(err), err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeUploadHeaders(w, session)
+	writeJSONSuccessResponse(w, r, http.StatusOK, session)
+}
+
+func (rest *REST) writeModelUploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != chunkContentType {
+		writeJSONErrorResponse(w, r, http.StatusUnsupportedMediaType, errorInvalidChunkType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(headerUploadOffset), 10, 64)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorInvalidUploadHeader)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	session, err := rest.uploadSessions.Write(r.Context(), urlParams.ServableID(), chi.URLParam(r, "upload"), offset, r.Body)
+	if session != nil {
+		writeUploadHeaders(w, session)
+	}
+	if err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, uploadErrorStatus(err), err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, session)
+}
+
+func (rest *REST) finalizeModelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName, urlForce)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	modelID := urlParams.ServableID()
+	if err := rest.lock.Lock(modelID); err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusTemporaryRedirect, err)
+		return
+	}
+	defer rest.lock.UnLock(modelID)
+
+	resp, err := rest.finalizeModelUpload(r, modelID, chi.URLParam(r, "upload"), urlParams.Force)
+	if err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, resp.responseCode, err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, resp.responseCode, resp.modelID)
+}
+
+// finalizeModelUpload hands content of complete upload over to models service. Session
+// is removed once model is saved, otherwise finalization may be retried
+func (rest *REST) finalizeModelUpload(r *http.Request, id app.ServableID, sessionID string, force bool) (_ *UploadModelResponse, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ModelUploadDuration.Observe(time.Since(start).Seconds(), id.Team, id.Project, metrics.Result(err))
+	}()
+
+	session, archive, err := rest.uploadSessions.Open(r.Context(), id, sessionID)
+	if err != nil {
+		return &UploadModelResponse{responseCode: uploadErrorStatus(err)}, err
+	}
+	defer archive.Close()
+
+	metrics.ModelUploadBytes.Observe(float64(session.Length), id.Team, id.Project)
+
+	var label []string
+	if session.Label != "" {
+		label = append(label, session.Label)
+	}
+
+	model, err := rest.modelsService.UploadModel(r.Context(), id, archive, force, label...)
+	if err != nil {
+		return &UploadModelResponse{responseCode: http.StatusTemporaryRedirect}, exterr.WrapWithFrame(err)
+	}
+	auditTarget(r, model.Version, session.Label)
+
+	if err := rest.uploadSessions.Remove(r.Context(), id, sessionID); err != nil {
+		logging.ErrorWithStack(r.Context(), err)
+	}
+
+	return &UploadModelResponse{modelID: model, responseCode: http.StatusOK}, nil
+}
+
+func (rest *REST) deleteModelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	urlParams, err := parseAndValidateParamsFromRequest(r, false, urlTeam, urlProject, urlName)
+	if err != nil {
+		err = exterr.WrapWithErr(err, errorModelBadRequest)
+		logging.ErrorWithStack(r.Context(), err)
+		writeJSONErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := rest.uploadSessions.Remove(r.Context(), urlParams.ServableID(), chi.URLParam(r, "upload")); err != nil {
+		writeJSONErrorResponse(w, r, uploadErrorStatus(err), err)
+		return
+	}
+
+	writeJSONSuccessResponse(w, r, http.StatusOK, nil)
+}
+
+// parseUploadChecksum parses Upload-Checksum header in form of "sha256 <hex digest>"
+func parseUploadChecksum(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 2 || strings.ToLower(fields[0]) != checksumAlgo {
+		return "", errorInvalidUploadHeader
+	}
+
+	return fields[1], nil
+}
+
+func writeUploadHeaders(w http.ResponseWriter, session *upload.Session) {
+	w.Header().Set(headerUploadOffset, strconv.FormatInt(session.Offset, 10))
+	w.Header().Set(headerUploadLength, strconv.FormatInt(session.Length, 10))
+	w.Header().Set(headerUploadExpires, session.Expires.UTC().Format(http.TimeFormat))
+}
+
+// uploadErrorStatus maps errors of upload sessions to response status codes
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, upload.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrSessionBusy), errors.Is(err, upload.ErrUploadIncomplete):
+		return http.StatusConflict
+	case errors.Is(err, upload.ErrChunkTooLarge), errors.Is(err, upload.ErrLengthTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, upload.ErrInvalidLength), errors.Is(err, upload.ErrInvalidChecksum), errors.Is(err, upload.ErrChecksumMismatch):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,118 @@
+package retention
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+var (
+	invalidRuleErrorCode = 1001
+
+	errorInvalidRule = exterr.NewErrorWithMessage("invalid retention rule").WithComponent(app.ComponentRetention).WithCode(invalidRuleErrorCode)
+)
+
+// rule overrides policy of team, project or model, rules which aren't set are inherited
+type rule struct {
+	Team             string `yaml:"team"`
+	Project          string `yaml:"project"`
+	Name             string `yaml:"name"`
+	KeepLastVersions *int   `yaml:"keepLastVersions"`
+	MaxAgeInDays     *int   `yaml:"maxAgeInDays"`
+}
+
+// rulesFile is a structure of YAML file with retention rules, e.g.
+//
+//	rules:
+//	  - team: team-a
+//	    keepLastVersions: 10
+//	  - team: team-a
+//	    project: project-x
+//	    name: model
+//	    maxAgeInDays: 7
+//
+// rules of projects override rules of teams and rules of models override rules
+// of projects, rules which aren't set are inherited from less specific ones
+type rulesFile struct {
+	Rules []rule `yaml:"rules"`
+}
+
+// Policies holds default retention policy and rules of teams,
+// projects and models which override it
+type Policies struct {
+	defaults app.RetentionPolicy
+	rules    map[app.ServableID]rule
+}
+
+// NewPolicies returns policies applying given policy to every model
+func NewPolicies(defaults app.RetentionPolicy) *Policies {
+	return &Policies{defaults: defaults, rules: make(map[app.ServableID]rule)}
+}
+
+// WithRules loads rules of teams, projects and models from given YAML file
+func (p *Policies) WithRules(rulesPath string) (*Policies, error) {
+	f, err := os.Open(rulesPath)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer f.Close()
+
+	var file rulesFile
+	if err := yaml.NewDecoder(f).Decode(&file); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	for _, r := range file.Rules {
+		if err := validateRule(r); err != nil {
+			return nil, err
+		}
+		id := app.ServableID{Team: r.Team, Project: r.Project, Name: r.Name}
+		if _, ok := p.rules[id]; ok {
+			return nil, exterr.WrapWithErr(fmt.Errorf("duplicated rule of %s/%s/%s", r.Team, r.Project, r.Name), errorInvalidRule)
+		}
+		p.rules[id] = r
+	}
+
+	return p, nil
+}
+
+// For returns retention policy of model
+func (p *Policies) For(id app.ServableID) app.RetentionPolicy {
+	policy := p.defaults
+	for _, scope := range []app.ServableID{
+		{Team: id.Team},
+		{Team: id.Team, Project: id.Project},
+		id,
+	} {
+		r, ok := p.rules[scope]
+		if !ok {
+			continue
+		}
+		if r.KeepLastVersions != nil {
+			policy.KeepLastVersions = *r.KeepLastVersions
+		}
+		if r.MaxAgeInDays != nil {
+			policy.MaxAgeInDays = *r.MaxAgeInDays
+		}
+	}
+
+	return policy
+}
+
+func validateRule(r rule) error {
+	switch {
+	case r.Team == "":
+		return exterr.WrapWithErr(fmt.Errorf("rule without team"), errorInvalidRule)
+	case r.Name != "" && r.Project == "":
+		return exterr.WrapWithErr(fmt.Errorf("rule of model %s without project", r.Name), errorInvalidRule)
+	case r.KeepLastVersions != nil && *r.KeepLastVersions < 0,
+		r.MaxAgeInDays != nil && *r.MaxAgeInDays < 0:
+		return exterr.WrapWithErr(fmt.Errorf("negative value in rule of %s/%s/%s", r.Team, r.Project, r.Name), errorInvalidRule)
+	}
+
+	return nil
+}
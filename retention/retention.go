@@ -0,0 +1,228 @@
+// Package retention removes unlabeled versions of models selected by retention
+// policies, so storage and configs of TFS instances don't grow forever. Versions
+// carrying any label, including stable and last_stable, are never removed
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+)
+
+// actor is identity of retention job recorded e.g. in logs
+const actor = "retention"
+
+const day = 24 * time.Hour
+
+var (
+	infoRetentionJob     = "removing versions selected by retention policies"
+	infoRetentionJobStop = "retention job stopped"
+	infoVersionRemoved   = "version removed by retention policy"
+	infoVersionSkipped   = "version isn't removed, it has been labeled in the meantime"
+)
+
+// ModelsService lists and removes versions of models
+type ModelsService interface {
+	ListModels(ctx context.Context, params app.QueryParameters) ([]*app.ModelData, error)
+	RemoveByVersion(ctx context.Context, id app.ServableID, version int64) error
+}
+
+// Collector removes versions of models selected by retention policies
+type Collector struct {
+	models   ModelsService
+	policies *Policies
+	interval time.Duration
+
+	now func() time.Time
+}
+
+// NewCollector returns collector which removes versions every interval
+func NewCollector(models ModelsService, policies *Policies, interval time.Duration) *Collector {
+	return &Collector{models: models, policies: policies, interval: interval, now: time.Now}
+}
+
+// Candidates lists versions of models matching params which are going to be removed
+// by retention policies, the latest version of each model is the first one
+func (c *Collector) Candidates(ctx context.Context, params app.QueryParameters) ([]app.RetentionCandidate, error) {
+	models, err := c.models.ListModels(ctx, params)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	candidates := []app.RetentionCandidate{}
+	for _, versions := range groupVersions(models) {
+		candidates = append(candidates, c.selectVersions(versions)...)
+	}
+
+	return candidates, nil
+}
+
+// Collect removes versions of models selected by retention policies
+func (c *Collector) Collect(ctx context.Context) {
+	ctx = logging.WithIdentity(ctx, actor)
+
+	candidates, err := c.Candidates(ctx, app.QueryParameters{})
+	if err != nil {
+		return
+	}
+
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// version might have been labeled since candidates were listed
+		labeled, err := c.labeled(ctx, candidate)
+		if err != nil {
+			continue
+		}
+		if labeled {
+			logging.Info(ctx, fmt.Sprintf("%s: model[%s-%s-%s] version [%d]", infoVersionSkipped, candidate.Team, candidate.Project, candidate.Name, candidate.Version))
+			continue
+		}
+
+		err = c.models.RemoveByVersion(ctx, candidate.ServableID, candidate.Version)
+		metrics.RetentionRemovedVersions.Inc(candidate.Team, candidate.Project, metrics.Result(err))
+		if err != nil {
+			continue
+		}
+		logging.Info(ctx, fmt.Sprintf("%s: model[%s-%s-%s] version [%d]", infoVersionRemoved, candidate.Team, candidate.Project, candidate.Name, candidate.Version))
+	}
+}
+
+// CollectJob removes versions selected by retention policies every interval until ctx is done
+func (c *Collector) CollectJob(ctx context.Context) {
+	for {
+		logging.Debug(ctx, infoRetentionJob)
+		c.Collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			logging.Info(ctx, infoRetentionJobStop)
+			return
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+func (c *Collector) labeled(ctx context.Context, candidate app.RetentionCandidate) (bool, error) {
+	params := app.QueryParameters{"team": candidate.Team, "project": candidate.Project, "name": candidate.Name, "version": candidate.Version}
+	models, err := c.models.ListModels(ctx, params)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return false, err
+	}
+
+	for _, model := range models {
+		if model.Label != "" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// version describes single version of model
+type version struct {
+	app.ServableID
+	version int64
+	labeled bool
+	ready   bool
+	created int64
+}
+
+// selectVersions selects versions of single model which are removed by its policy,
+// versions have to be sorted from the latest one
+func (c *Collector) selectVersions(versions []*version) []app.RetentionCandidate {
+	if len(versions) == 0 {
+		return nil
+	}
+	policy := c.policies.For(versions[0].ServableID)
+	if !policy.Enabled() {
+		return nil
+	}
+
+	maxCreated := c.now().Add(-time.Duration(policy.MaxAgeInDays) * day).Unix()
+
+	var candidates []app.RetentionCandidate
+	unlabeled := 0
+	for _, v := range versions {
+		if v.labeled || !v.ready {
+			continue
+		}
+		unlabeled++
+
+		if unlabeled <= policy.KeepLastVersions {
+			continue
+		}
+		if policy.MaxAgeInDays > 0 && (v.created == 0 || v.created > maxCreated) {
+			continue
+		}
+
+		candidates = append(candidates, app.RetentionCandidate{ServableID: v.ServableID, Version: v.version, Created: v.created, Policy: policy})
+	}
+
+	return candidates
+}
+
+// groupVersions merges rows of metadata into versions and groups them by models,
+// versions of each model are sorted from the latest one
+func groupVersions(models []*app.ModelData) [][]*version {
+	type versionID struct {
+		app.ServableID
+		version int64
+	}
+
+	versions := make(map[versionID]*version)
+	for _, model := range models {
+		id := versionID{ServableID: model.ServableID, version: model.Version}
+		v, ok := versions[id]
+		if !ok {
+			v = &version{ServableID: model.ServableID, version: model.Version, ready: true}
+			versions[id] = v
+		}
+		if model.Label != "" {
+			v.labeled = true
+		}
+		if model.Status != app.StatusReady {
+			v.ready = false
+		}
+		// unlabeled row is added when version is uploaded, labeled rows when labels are moved
+		if created, err := strconv.ParseInt(model.Created, 10, 64); err == nil && (v.created == 0 || created < v.created) {
+			v.created = created
+		}
+	}
+
+	servables := make(map[app.ServableID][]*version)
+	for _, v := range versions {
+		servables[v.ServableID] = append(servables[v.ServableID], v)
+	}
+
+	grouped := make([][]*version, 0, len(servables))
+	for _, servableVersions := range servables {
+		sort.Slice(servableVersions, func(i, j int) bool {
+			return servableVersions[i].version > servableVersions[j].version
+		})
+		grouped = append(grouped, servableVersions)
+	}
+	sort.Slice(grouped, func(i, j int) bool {
+		a, b := grouped[i][0].ServableID, grouped[j][0].ServableID
+		if a.Team != b.Team {
+			return a.Team < b.Team
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Name < b.Name
+	})
+
+	return grouped
+}
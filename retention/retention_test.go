@@ -0,0 +1,182 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+)
+
+const now = 1600000000
+
+// fakeModels keeps rows of model metadata in memory
+type fakeModels struct {
+	rows      []*app.ModelData
+	removed   []int64
+	removeErr error
+	// labelOnList labels version when it's listed alone, like concurrent request would
+	labelOnList int64
+}
+
+func (f *fakeModels) ListModels(ctx context.Context, params app.QueryParameters) ([]*app.ModelData, error) {
+	version, _ := params["version"].(int64)
+	if version != 0 && version == f.labelOnList {
+		f.rows = append(f.rows, row(version, "canary", 0))
+	}
+
+	var rows []*app.ModelData
+	for _, r := range f.rows {
+		if version == 0 || r.Version == version {
+			rows = append(rows, r)
+		}
+	}
+
+	return rows, nil
+}
+
+func (f *fakeModels) RemoveByVersion(ctx context.Context, id app.ServableID, version int64) error {
+	if f.removeErr != nil {
+		return f.removeErr
+	}
+	f.removed = append(f.removed, version)
+
+	return nil
+}
+
+func row(version int64, label string, ageInDays int64) *app.ModelData {
+	return &app.ModelData{
+		ModelID: app.ModelID{ServableID: app.ServableID{Team: "team", Project: "project", Name: "name"}, Version: version, Label: label},
+		Status:  app.StatusReady,
+		Created: strconv.FormatInt(now-ageInDays*24*3600, 10),
+	}
+}
+
+// history holds versions 1-6 of model, version 5 is stable, version 2 was stable
+// before and version 6 is still being uploaded
+func history() []*app.ModelData {
+	pending := row(6, "", 0)
+	pending.Status = app.StatusPending
+
+	return []*app.ModelData{
+		row(1, "", 50), row(2, "last_stable", 40), row(3, "", 30), row(4, "", 20), row(5, "stable", 10), pending,
+	}
+}
+
+func TestCollector_Candidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy app.RetentionPolicy
+		want   []int64
+	}{
+		{name: "Disabled policy should keep every version", policy: app.RetentionPolicy{}},
+		{name: "Only the latest unlabeled versions should be kept", policy: app.RetentionPolicy{KeepLastVersions: 1}, want: []int64{3, 1}},
+		{name: "Old unlabeled versions should be removed", policy: app.RetentionPolicy{MaxAgeInDays: 25}, want: []int64{3, 1}},
+		{name: "Latest unlabeled versions should be kept even if they are old", policy: app.RetentionPolicy{KeepLastVersions: 2, MaxAgeInDays: 10}, want: []int64{1}},
+		{name: "Versions younger than max age should be kept", policy: app.RetentionPolicy{KeepLastVersions: 1, MaxAgeInDays: 40}, want: []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(&fakeModels{rows: history()}, NewPolicies(tt.policy), time.Hour)
+			c.now = func() time.Time { return time.Unix(now, 0) }
+
+			candidates, err := c.Candidates(context.Background(), app.QueryParameters{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int64
+			for _, candidate := range candidates {
+				if candidate.Policy != tt.policy {
+					t.Errorf("candidate %d policy = %+v, want %+v", candidate.Version, candidate.Policy, tt.policy)
+				}
+				got = append(got, candidate.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollector_Collect(t *testing.T) {
+	tests := []struct {
+		name   string
+		models *fakeModels
+		want   []int64
+	}{
+		{name: "Selected versions should be removed", models: &fakeModels{rows: history()}, want: []int64{3, 1}},
+		{name: "Version labeled in the meantime should be kept", models: &fakeModels{rows: history(), labelOnList: 3}, want: []int64{1}},
+		{name: "Failed removal shouldn't stop collection", models: &fakeModels{rows: history(), removeErr: errors.New("remove failed")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(tt.models, NewPolicies(app.RetentionPolicy{KeepLastVersions: 1}), time.Hour)
+			c.now = func() time.Time { return time.Unix(now, 0) }
+
+			c.Collect(context.Background())
+
+			if !reflect.DeepEqual(tt.models.removed, tt.want) {
+				t.Errorf("removed versions = %v, want %v", tt.models.removed, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicies_For(t *testing.T) {
+	dir := testutil.TempDir(t)
+
+	path := filepath.Join(dir, "rules.yaml")
+	rules := `
+rules:
+  - team: team-a
+    keepLastVersions: 10
+  - team: team-a
+    project: project-x
+    maxAgeInDays: 7
+  - team: team-a
+    project: project-x
+    name: model
+    keepLastVersions: 0
+`
+	if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policies, err := NewPolicies(app.RetentionPolicy{KeepLastVersions: 5, MaxAgeInDays: 30}).WithRules(path)
+	if err != nil {
+		t.Fatalf("WithRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   app.ServableID
+		want app.RetentionPolicy
+	}{
+		{name: "Model without rules should get defaults", id: app.ServableID{Team: "team-b", Project: "project-x", Name: "model"}, want: app.RetentionPolicy{KeepLastVersions: 5, MaxAgeInDays: 30}},
+		{name: "Rule of team should override defaults", id: app.ServableID{Team: "team-a", Project: "project-y", Name: "model"}, want: app.RetentionPolicy{KeepLastVersions: 10, MaxAgeInDays: 30}},
+		{name: "Rule of project should inherit rule of team", id: app.ServableID{Team: "team-a", Project: "project-x", Name: "other"}, want: app.RetentionPolicy{KeepLastVersions: 10, MaxAgeInDays: 7}},
+		{name: "Rule of model should override rule of project", id: app.ServableID{Team: "team-a", Project: "project-x", Name: "model"}, want: app.RetentionPolicy{KeepLastVersions: 0, MaxAgeInDays: 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policies.For(tt.id); got != tt.want {
+				t.Errorf("For() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	invalid := "rules:\n  - team: team-a\n    name: model\n    keepLastVersions: 1\n"
+	if err := ioutil.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPolicies(app.RetentionPolicy{}).WithRules(path); !errors.Is(err, errorInvalidRule) {
+		t.Errorf("WithRules() of model rule without project error = %v, want %v", err, errorInvalidRule)
+	}
+}
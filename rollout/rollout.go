@@ -0,0 +1,463 @@
+// Package rollout runs staged promotions of versions of models. Rollout follows a plan
+// of steps which move labels to rolled out version, wait and check health gates. When
+// step fails, labels moved by rollout are restored. Progress is persisted in metadata,
+// so rollouts are continued after TensorFlow Deploy restarts
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const maxLabelLength = 32
+
+var (
+	invalidPlanErrorCode     = 1001
+	rolloutNotFoundErrorCode = 1002
+	rolloutActiveErrorCode   = 1003
+	invalidStateErrorCode    = 1004
+	gateFailedErrorCode      = 1005
+
+	// ErrRolloutNotFound is returned when rollout doesn't exist or belongs to other model
+	ErrRolloutNotFound = exterr.NewErrorWithMessage("rollout not found").WithComponent(app.ComponentRollout).WithCode(rolloutNotFoundErrorCode)
+	// ErrRolloutActive is returned when rollout is started while other rollout of model is running or paused
+	ErrRolloutActive = exterr.NewErrorWithMessage("other rollout of model is in progress").WithComponent(app.ComponentRollout).WithCode(rolloutActiveErrorCode)
+
+	infoRolloutsJob     = "advancing rollouts"
+	infoRolloutsJobStop = "rollouts job stopped"
+	infoRolloutStep     = "rollout step done"
+	infoRolloutFinished = "rollout finished"
+)
+
+// ModelsService changes labels of models and reports their health
+type ModelsService interface {
+	SetLabel(ctx context.Context, model app.ModelID, force bool) (*app.LabelChanged, error)
+	Revert(ctx context.Context, id app.ServableID) (*app.LabelChanged, error)
+	RemoveModelLabel(ctx context.Context, id app.ServableID, label string) error
+	ReloadModels(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error)
+	SmokeTestResults(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error)
+}
+
+// Availability checks state of model version on instances of TFS
+type Availability interface {
+	ModelAvailability(ctx context.Context, model app.ModelID) (instances, unavailable []string, err error)
+}
+
+// Metadata persists rollouts
+type Metadata interface {
+	AddRollout(ctx context.Context, rollout *app.Rollout) (int64, error)
+	UpdateRollout(ctx context.Context, rollout *app.Rollout) error
+	GetRollout(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error)
+	ListRollouts(ctx context.Context, id app.ServableID) ([]*app.Rollout, error)
+	ListActiveRollouts(ctx context.Context) ([]*app.Rollout, error)
+}
+
+// Rollouts starts rollouts and advances them in background job
+type Rollouts struct {
+	models       ModelsService
+	availability Availability
+	metadata     Metadata
+	interval     time.Duration
+	now          func() time.Time
+
+	// m serializes changes of rollouts made by job and by requests
+	m    sync.Mutex
+	wake chan struct{}
+}
+
+// NewRollouts returns new instance of Rollouts, running rollouts are advanced every interval
+func NewRollouts(models ModelsService, availability Availability, metadata Metadata, interval time.Duration) *Rollouts {
+	return &Rollouts{
+		models:       models,
+		availability: availability,
+		metadata:     metadata,
+		interval:     interval,
+		now:          time.Now,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start starts rollout of model version according to plan, its first
+// steps are run by job right after rollout is stored
+func (r *Rollouts) Start(ctx context.Context, model app.ModelID, plan app.RolloutPlan) (*app.Rollout, error) {
+	if err := validatePlan(plan); err != nil {
+		logging.ErrorWithStack(ctx, err)
+		return nil, err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rollouts, err := r.metadata.ListRollouts(ctx, model.ServableID)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	for _, rollout := range rollouts {
+		if rollout.Active() {
+			logging.ErrorWithStack(ctx, ErrRolloutActive)
+			return nil, ErrRolloutActive
+		}
+	}
+
+	rollout := &app.Rollout{
+		ModelID:     app.ModelID{ServableID: model.ServableID, Version: model.Version},
+		RolloutPlan: plan,
+		State:       app.RolloutRunning,
+		StepStarted: r.now().Unix(),
+	}
+	if rollout.ID, err = r.metadata.AddRollout(ctx, rollout); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	r.wakeUp()
+
+	return rollout, nil
+}
+
+// Get returns rollout of model
+func (r *Rollouts) Get(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error) {
+	rollout, err := r.metadata.GetRollout(ctx, id, rolloutID)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if rollout == nil {
+		logging.ErrorWithStack(ctx, ErrRolloutNotFound)
+		return nil, ErrRolloutNotFound
+	}
+
+	return rollout, nil
+}
+
+// List returns rollouts of model, the latest rollout is the first one
+func (r *Rollouts) List(ctx context.Context, id app.ServableID) ([]*app.Rollout, error) {
+	rollouts, err := r.metadata.ListRollouts(ctx, id)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if rollouts == nil {
+		rollouts = []*app.Rollout{}
+	}
+
+	return rollouts, nil
+}
+
+// Pause stops running rollout before its next step
+func (r *Rollouts) Pause(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error) {
+	return r.change(ctx, id, rolloutID, "paused", func(rollout *app.Rollout) bool {
+		if rollout.State != app.RolloutRunning {
+			return false
+		}
+		rollout.State = app.RolloutPaused
+		return true
+	})
+}
+
+// Resume continues paused rollout, its current step is started again
+func (r *Rollouts) Resume(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error) {
+	rollout, err := r.change(ctx, id, rolloutID, "resumed", func(rollout *app.Rollout) bool {
+		if rollout.State != app.RolloutPaused {
+			return false
+		}
+		rollout.State = app.RolloutRunning
+		rollout.StepStarted = r.now().Unix()
+		return true
+	})
+	if err == nil {
+		r.wakeUp()
+	}
+
+	return rollout, err
+}
+
+// Abort stops rollout for good and restores labels moved by it
+func (r *Rollouts) Abort(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error) {
+	return r.change(ctx, id, rolloutID, "aborted", func(rollout *app.Rollout) bool {
+		if !rollout.Active() {
+			return false
+		}
+		rollout.State = app.RolloutAborted
+		if err := r.revert(ctx, rollout); err != nil {
+			logging.ErrorWithStack(ctx, err)
+			rollout.Error = fmt.Sprintf("revert failed: %v", err)
+		}
+		return true
+	})
+}
+
+// change applies change to rollout and persists it, change returns false when
+// rollout can't be changed in its current state
+func (r *Rollouts) change(ctx context.Context, id app.ServableID, rolloutID int64, action string, change func(rollout *app.Rollout) bool) (*app.Rollout, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rollout, err := r.Get(ctx, id, rolloutID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !change(rollout) {
+		err := invalidStateError(fmt.Sprintf("%s rollout can't be %s", rollout.State, action))
+		logging.ErrorWithStack(ctx, err)
+		return nil, err
+	}
+
+	if err := r.metadata.UpdateRollout(ctx, rollout); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	return rollout, nil
+}
+
+// RolloutsJob advances running rollouts every interval and right after rollout
+// is started or resumed, it returns when ctx is done
+func (r *Rollouts) RolloutsJob(ctx context.Context) {
+	for {
+		logging.Debug(ctx, infoRolloutsJob)
+		r.advanceRollouts(ctx)
+
+		select {
+		case <-ctx.Done():
+			logging.Info(ctx, infoRolloutsJobStop)
+			return
+		case <-r.wake:
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Rollouts) wakeUp() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Rollouts) advanceRollouts(ctx context.Context) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rollouts, err := r.metadata.ListActiveRollouts(ctx)
+	if err != nil {
+		logging.ErrorWithStackWithoutRequestID(ctx, exterr.WrapWithFrame(err))
+		return
+	}
+
+	for _, rollout := range rollouts {
+		if rollout.State != app.RolloutRunning {
+			continue
+		}
+		// labels moved by job are recorded in their history as moved by rollout
+		rolloutCtx := logging.WithIdentity(ctx, fmt.Sprintf("rollout-%d", rollout.ID))
+		if err := r.advance(rolloutCtx, rollout); err != nil {
+			logging.ErrorWithStackWithoutRequestID(ctx, exterr.WrapWithFrame(err))
+		}
+	}
+}
+
+// advance runs steps of rollout until it has to wait or finishes, progress
+// is persisted after every step. Failed step fails rollout and restores labels
+func (r *Rollouts) advance(ctx context.Context, rollout *app.Rollout) error {
+	for rollout.Step < len(rollout.Steps) {
+		done, err := r.runStep(ctx, rollout, rollout.Steps[rollout.Step])
+		if err != nil {
+			if ctx.Err() != nil {
+				// tfd is shutting down, step is run again after restart
+				return nil
+			}
+			r.fail(ctx, rollout, err)
+			return r.metadata.UpdateRollout(ctx, rollout)
+		}
+		if !done {
+			return nil
+		}
+
+		logging.Info(ctx, fmt.Sprintf("%s %d %s %d %d", infoRolloutStep, rollout.ID, rollout.InstanceName(), rollout.Version, rollout.Step))
+		rollout.Step++
+		rollout.StepStarted = r.now().Unix()
+		if rollout.Step == len(rollout.Steps) {
+			rollout.State = app.RolloutSucceeded
+			logging.Info(ctx, fmt.Sprintf("%s %d %s", infoRolloutFinished, rollout.ID, rollout.State))
+		}
+		if err := r.metadata.UpdateRollout(ctx, rollout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runStep runs single step of rollout, false is returned when step has to wait
+func (r *Rollouts) runStep(ctx context.Context, rollout *app.Rollout, step app.RolloutStep) (bool, error) {
+	switch {
+	case step.Label != "":
+		model := app.ModelID{ServableID: rollout.ServableID, Version: rollout.Version, Label: step.Label}
+		changed, err := r.models.SetLabel(ctx, model, false)
+		if err != nil {
+			return false, err
+		}
+		if changed.PreviousVersion != rollout.Version {
+			rollout.Moves = append(rollout.Moves, app.RolloutLabelMove{Label: step.Label, PreviousVersion: changed.PreviousVersion})
+			// move is persisted before reload, so label can be restored when
+			// reload is interrupted and step finds label already moved after restart
+			if err := r.metadata.UpdateRollout(ctx, rollout); err != nil {
+				return false, err
+			}
+		}
+		if _, err := r.models.ReloadModels(ctx, rollout.Team, rollout.Project, false); err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case step.Wait != "":
+		wait, err := time.ParseDuration(step.Wait)
+		if err != nil {
+			return false, exterr.WrapWithFrame(err)
+		}
+		return !r.now().Before(time.Unix(rollout.StepStarted, 0).Add(wait)), nil
+	}
+
+	for _, gate := range step.Gates {
+		if err := r.checkGate(ctx, rollout.ModelID, gate); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (r *Rollouts) checkGate(ctx context.Context, model app.ModelID, gate string) error {
+	switch gate {
+	case app.GateSmokeTest:
+		results, err := r.models.SmokeTestResults(ctx, model.ServableID, model.Version)
+		if err != nil {
+			return err
+		}
+		if !results.Passed {
+			return gateFailedError(gate, "smoke test hasn't passed on every instance")
+		}
+
+	case app.GateAvailable:
+		instances, unavailable, err := r.availability.ModelAvailability(ctx, model)
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			return gateFailedError(gate, "no instances of TFS have been discovered")
+		}
+		if len(unavailable) != 0 {
+			return gateFailedError(gate, "model version isn't available on "+strings.Join(unavailable, ", "))
+		}
+	}
+
+	return nil
+}
+
+// fail marks rollout as failed and restores labels moved by it
+func (r *Rollouts) fail(ctx context.Context, rollout *app.Rollout, err error) {
+	logging.ErrorWithStackWithoutRequestID(ctx, err)
+
+	rollout.State = app.RolloutFailed
+	rollout.Error = err.Error()
+	if err := r.revert(ctx, rollout); err != nil {
+		logging.ErrorWithStackWithoutRequestID(ctx, err)
+		rollout.Error += fmt.Sprintf("; revert failed: %v", err)
+	}
+	logging.Info(ctx, fmt.Sprintf("%s %d %s", infoRolloutFinished, rollout.ID, rollout.State))
+}
+
+// revert restores labels moved by rollout in reverse order. Label stable is reverted to
+// last stable version, other labels are moved back or removed when they haven't existed
+func (r *Rollouts) revert(ctx context.Context, rollout *app.Rollout) error {
+	if len(rollout.Moves) == 0 {
+		return nil
+	}
+
+	for len(rollout.Moves) > 0 {
+		move := rollout.Moves[len(rollout.Moves)-1]
+
+		var err error
+		switch {
+		case move.PreviousVersion == 0:
+			err = r.models.RemoveModelLabel(ctx, rollout.ServableID, move.Label)
+		case move.Label == app.StableLabel:
+			_, err = r.models.Revert(ctx, rollout.ServableID)
+		default:
+			model := app.ModelID{ServableID: rollout.ServableID, Version: move.PreviousVersion, Label: move.Label}
+			_, err = r.models.SetLabel(ctx, model, true)
+		}
+		if err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+
+		rollout.Moves = rollout.Moves[:len(rollout.Moves)-1]
+	}
+	rollout.Moves = nil
+
+	if _, err := r.models.ReloadModels(ctx, rollout.Team, rollout.Project, false); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// validatePlan checks that every step of plan sets exactly one action
+func validatePlan(plan app.RolloutPlan) error {
+	if len(plan.Steps) == 0 {
+		return invalidPlanError("plan doesn't contain steps")
+	}
+
+	for i, step := range plan.Steps {
+		set := 0
+		for _, isSet := range []bool{step.Label != "", step.Wait != "", len(step.Gates) != 0} {
+			if isSet {
+				set++
+			}
+		}
+		if set != 1 {
+			return invalidPlanError(fmt.Sprintf("step %d has to set exactly one of label, wait and gates", i))
+		}
+
+		switch {
+		case step.Label != "":
+			if len(step.Label) > maxLabelLength || step.Label == app.PrevStableLabel {
+				return invalidPlanError(fmt.Sprintf("step %d sets invalid label %q", i, step.Label))
+			}
+		case step.Wait != "":
+			wait, err := time.ParseDuration(step.Wait)
+			if err != nil || wait <= 0 {
+				return invalidPlanError(fmt.Sprintf("step %d has invalid wait %q, positive duration (e.g. 30m) expected", i, step.Wait))
+			}
+		default:
+			for _, gate := range step.Gates {
+				if gate != app.GateSmokeTest && gate != app.GateAvailable {
+					return invalidPlanError(fmt.Sprintf("step %d has unknown gate %q, use %s or %s", i, gate, app.GateSmokeTest, app.GateAvailable))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func invalidPlanError(msg string) error {
+	return exterr.NewErrorWithMessage("invalid rollout plan: " + msg).WithComponent(app.ComponentRollout).WithCode(invalidPlanErrorCode)
+}
+
+func invalidStateError(msg string) error {
+	return exterr.NewErrorWithMessage(msg).WithComponent(app.ComponentRollout).WithCode(invalidStateErrorCode)
+}
+
+func gateFailedError(gate, msg string) error {
+	return exterr.NewErrorWithMessage(fmt.Sprintf("gate %s failed: %s", gate, msg)).WithComponent(app.ComponentRollout).WithCode(gateFailedErrorCode)
+}
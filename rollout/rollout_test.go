@@ -0,0 +1,344 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+type fakeModels struct {
+	labels    map[string]int64
+	calls     []string
+	passed    bool
+	setErrors map[string]error
+	// cancelReload is called by next reload, which is interrupted then
+	cancelReload context.CancelFunc
+}
+
+func (f *fakeModels) SetLabel(ctx context.Context, model app.ModelID, force bool) (*app.LabelChanged, error) {
+	f.calls = append(f.calls, "set "+model.Label)
+	if err := f.setErrors[model.Label]; err != nil {
+		return nil, err
+	}
+	prev := f.labels[model.Label]
+	f.labels[model.Label] = model.Version
+	if model.Label == app.StableLabel {
+		f.labels[app.PrevStableLabel] = prev
+	}
+	return &app.LabelChanged{ServableID: model.ServableID, Label: model.Label, PreviousVersion: prev, NewVersion: model.Version}, nil
+}
+
+func (f *fakeModels) Revert(ctx context.Context, id app.ServableID) (*app.LabelChanged, error) {
+	f.calls = append(f.calls, "revert")
+	f.labels[app.StableLabel] = f.labels[app.PrevStableLabel]
+	delete(f.labels, app.PrevStableLabel)
+	return &app.LabelChanged{}, nil
+}
+
+func (f *fakeModels) RemoveModelLabel(ctx context.Context, id app.ServableID, label string) error {
+	f.calls = append(f.calls, "remove "+label)
+	delete(f.labels, label)
+	return nil
+}
+
+func (f *fakeModels) ReloadModels(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error) {
+	f.calls = append(f.calls, "reload")
+	if f.cancelReload != nil {
+		f.cancelReload()
+		f.cancelReload = nil
+		return nil, ctx.Err()
+	}
+	return nil, nil
+}
+
+func (f *fakeModels) SmokeTestResults(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error) {
+	return &app.ModelSmokeTestResults{Passed: f.passed}, nil
+}
+
+type fakeAvailability struct {
+	instances, unavailable []string
+}
+
+func (f *fakeAvailability) ModelAvailability(ctx context.Context, model app.ModelID) ([]string, []string, error) {
+	return f.instances, f.unavailable, nil
+}
+
+type fakeMetadata struct {
+	rollouts []app.Rollout
+}
+
+func (f *fakeMetadata) AddRollout(ctx context.Context, rollout *app.Rollout) (int64, error) {
+	stored := *rollout
+	stored.ID = int64(len(f.rollouts) + 1)
+	f.rollouts = append(f.rollouts, stored)
+	return stored.ID, nil
+}
+
+func (f *fakeMetadata) UpdateRollout(ctx context.Context, rollout *app.Rollout) error {
+	f.rollouts[rollout.ID-1] = *rollout
+	return nil
+}
+
+func (f *fakeMetadata) GetRollout(ctx context.Context, id app.ServableID, rolloutID int64) (*app.Rollout, error) {
+	if rolloutID < 1 || rolloutID > int64(len(f.rollouts)) || f.rollouts[rolloutID-1].ServableID != id {
+		return nil, nil
+	}
+	rollout := f.rollouts[rolloutID-1]
+	return &rollout, nil
+}
+
+func (f *fakeMetadata) ListRollouts(ctx context.Context, id app.ServableID) ([]*app.Rollout, error) {
+	var rollouts []*app.Rollout
+	for i := len(f.rollouts) - 1; i >= 0; i-- {
+		if f.rollouts[i].ServableID == id {
+			rollout := f.rollouts[i]
+			rollouts = append(rollouts, &rollout)
+		}
+	}
+	return rollouts, nil
+}
+
+func (f *fakeMetadata) ListActiveRollouts(ctx context.Context) ([]*app.Rollout, error) {
+	var rollouts []*app.Rollout
+	for i := range f.rollouts {
+		if f.rollouts[i].Active() {
+			rollout := f.rollouts[i]
+			rollouts = append(rollouts, &rollout)
+		}
+	}
+	return rollouts, nil
+}
+
+var (
+	testID   = app.ServableID{Team: "team", Project: "project", Name: "name"}
+	testPlan = app.RolloutPlan{Steps: []app.RolloutStep{
+		{Label: "canary"},
+		{Wait: "30m"},
+		{Gates: []string{app.GateSmokeTest, app.GateAvailable}},
+		{Label: app.StableLabel},
+	}}
+)
+
+func newTestRollouts(models *fakeModels, availability *fakeAvailability) (*Rollouts, *fakeMetadata, *time.Time) {
+	metadata := &fakeMetadata{}
+	now := time.Unix(1000, 0)
+	r := NewRollouts(models, availability, metadata, time.Minute)
+	r.now = func() time.Time { return now }
+	return r, metadata, &now
+}
+
+func TestRollouts_advanceRollouts(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name         string
+		models       *fakeModels
+		availability *fakeAvailability
+		wantState    string
+		wantLabels   map[string]int64
+		wantCalls    []string
+	}{
+		{
+			name:         "succeeded",
+			models:       &fakeModels{labels: map[string]int64{"canary": 1, app.StableLabel: 1}, passed: true},
+			availability: &fakeAvailability{instances: []string{"a:8500"}},
+			wantState:    app.RolloutSucceeded,
+			wantLabels:   map[string]int64{"canary": 2, app.StableLabel: 2, app.PrevStableLabel: 1},
+			wantCalls:    []string{"set canary", "reload", "set stable", "reload"},
+		},
+		{
+			name:         "smoke test gate failed",
+			models:       &fakeModels{labels: map[string]int64{"canary": 1, app.StableLabel: 1}},
+			availability: &fakeAvailability{instances: []string{"a:8500"}},
+			wantState:    app.RolloutFailed,
+			wantLabels:   map[string]int64{"canary": 1, app.StableLabel: 1},
+			wantCalls:    []string{"set canary", "reload", "set canary", "reload"},
+		},
+		{
+			name:         "available gate failed on new label",
+			models:       &fakeModels{labels: map[string]int64{app.StableLabel: 1}, passed: true},
+			availability: &fakeAvailability{instances: []string{"a:8500", "b:8500"}, unavailable: []string{"b:8500"}},
+			wantState:    app.RolloutFailed,
+			wantLabels:   map[string]int64{app.StableLabel: 1},
+			wantCalls:    []string{"set canary", "reload", "remove canary", "reload"},
+		},
+		{
+			name:         "no instances discovered",
+			models:       &fakeModels{labels: map[string]int64{"canary": 2, app.StableLabel: 1}, passed: true},
+			availability: &fakeAvailability{},
+			wantState:    app.RolloutFailed,
+			wantLabels:   map[string]int64{"canary": 2, app.StableLabel: 1},
+			wantCalls:    []string{"set canary", "reload"},
+		},
+		{
+			name:         "setting stable failed",
+			models:       &fakeModels{labels: map[string]int64{"canary": 1, app.StableLabel: 1}, passed: true, setErrors: map[string]error{app.StableLabel: errors.New("incompatible signatures")}},
+			availability: &fakeAvailability{instances: []string{"a:8500"}},
+			wantState:    app.RolloutFailed,
+			wantLabels:   map[string]int64{"canary": 1, app.StableLabel: 1},
+			wantCalls:    []string{"set canary", "reload", "set stable", "set canary", "reload"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, metadata, now := newTestRollouts(tt.models, tt.availability)
+			rollout, err := r.Start(ctx, app.ModelID{ServableID: testID, Version: 2}, testPlan)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.advanceRollouts(ctx)
+			if got := metadata.rollouts[0]; got.State != app.RolloutRunning || got.Step != 1 {
+				t.Fatalf("rollout before wait has elapsed = %+v, want running at step 1", got)
+			}
+
+			*now = now.Add(30 * time.Minute)
+			r.advanceRollouts(ctx)
+
+			got, err := r.Get(ctx, testID, rollout.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State != tt.wantState {
+				t.Errorf("State = %s, want %s (error %q)", got.State, tt.wantState, got.Error)
+			}
+			if (got.Error != "") != (tt.wantState == app.RolloutFailed) {
+				t.Errorf("Error = %q", got.Error)
+			}
+			if !reflect.DeepEqual(tt.models.labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", tt.models.labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(tt.models.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", tt.models.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRollouts_advanceRollouts_stableFailure(t *testing.T) {
+	ctx := context.Background()
+	models := &fakeModels{labels: map[string]int64{"canary": 1, app.StableLabel: 1}}
+	r, metadata, _ := newTestRollouts(models, &fakeAvailability{})
+
+	plan := app.RolloutPlan{Steps: []app.RolloutStep{{Label: app.StableLabel}, {Gates: []string{app.GateSmokeTest}}}}
+	if _, err := r.Start(ctx, app.ModelID{ServableID: testID, Version: 2}, plan); err != nil {
+		t.Fatal(err)
+	}
+	r.advanceRollouts(ctx)
+
+	if got := metadata.rollouts[0]; got.State != app.RolloutFailed || got.Moves != nil {
+		t.Errorf("rollout = %+v, want failed without moves", got)
+	}
+	wantCalls := []string{"set stable", "reload", "revert", "reload"}
+	if !reflect.DeepEqual(models.calls, wantCalls) {
+		t.Errorf("calls = %v, want %v", models.calls, wantCalls)
+	}
+	if models.labels[app.StableLabel] != 1 {
+		t.Errorf("stable = %d, want 1", models.labels[app.StableLabel])
+	}
+}
+
+func TestRollouts_advanceRollouts_interruptedReload(t *testing.T) {
+	models := &fakeModels{labels: map[string]int64{"canary": 1, app.StableLabel: 1}}
+	r, metadata, _ := newTestRollouts(models, &fakeAvailability{})
+	rollout, err := r.Start(context.Background(), app.ModelID{ServableID: testID, Version: 2}, testPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// shutdown interrupts reload after canary has been moved
+	ctx, cancel := context.WithCancel(context.Background())
+	models.cancelReload = cancel
+	r.advanceRollouts(ctx)
+	if got := metadata.rollouts[0]; got.State != app.RolloutRunning || got.Step != 0 {
+		t.Fatalf("interrupted rollout = %+v, want running at step 0", got)
+	}
+
+	// after restart rollout is aborted before step is run again
+	restarted := NewRollouts(models, &fakeAvailability{}, metadata, time.Minute)
+	if _, err := restarted.Abort(context.Background(), testID, rollout.ID); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+	if want := map[string]int64{"canary": 1, app.StableLabel: 1}; !reflect.DeepEqual(models.labels, want) {
+		t.Errorf("labels = %v, want %v", models.labels, want)
+	}
+}
+
+func TestRollouts_changeState(t *testing.T) {
+	ctx := context.Background()
+	models := &fakeModels{labels: map[string]int64{"canary": 1}}
+	r, metadata, now := newTestRollouts(models, &fakeAvailability{})
+
+	rollout, err := r.Start(ctx, app.ModelID{ServableID: testID, Version: 2}, testPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Start(ctx, app.ModelID{ServableID: testID, Version: 3}, testPlan); err != ErrRolloutActive {
+		t.Errorf("Start() of second rollout error = %v, want %v", err, ErrRolloutActive)
+	}
+	r.advanceRollouts(ctx)
+
+	if _, err := r.Resume(ctx, testID, rollout.ID); err == nil {
+		t.Error("Resume() of running rollout succeeded")
+	}
+	if _, err := r.Pause(ctx, testID, rollout.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// paused rollout isn't advanced
+	*now = now.Add(time.Hour)
+	r.advanceRollouts(ctx)
+	if got := metadata.rollouts[0]; got.State != app.RolloutPaused || got.Step != 1 {
+		t.Fatalf("paused rollout = %+v", got)
+	}
+
+	resumed, err := r.Resume(ctx, testID, rollout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.State != app.RolloutRunning || resumed.StepStarted != now.Unix() {
+		t.Errorf("Resume() = %+v, want running with restarted step", resumed)
+	}
+
+	aborted, err := r.Abort(ctx, testID, rollout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aborted.State != app.RolloutAborted || aborted.Moves != nil || models.labels["canary"] != 1 {
+		t.Errorf("Abort() = %+v, canary %d, want aborted with canary restored", aborted, models.labels["canary"])
+	}
+	if _, err := r.Abort(ctx, testID, rollout.ID); err == nil {
+		t.Error("Abort() of aborted rollout succeeded")
+	}
+
+	if _, err := r.Pause(ctx, app.ServableID{Team: "team", Project: "project", Name: "other"}, rollout.ID); err != ErrRolloutNotFound {
+		t.Errorf("Pause() of other model error = %v, want %v", err, ErrRolloutNotFound)
+	}
+}
+
+func Test_validatePlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    app.RolloutPlan
+		wantErr bool
+	}{
+		{name: "valid", plan: testPlan},
+		{name: "empty", plan: app.RolloutPlan{}, wantErr: true},
+		{name: "empty step", plan: app.RolloutPlan{Steps: []app.RolloutStep{{}}}, wantErr: true},
+		{name: "two actions", plan: app.RolloutPlan{Steps: []app.RolloutStep{{Label: "canary", Wait: "1m"}}}, wantErr: true},
+		{name: "invalid wait", plan: app.RolloutPlan{Steps: []app.RolloutStep{{Wait: "30"}}}, wantErr: true},
+		{name: "negative wait", plan: app.RolloutPlan{Steps: []app.RolloutStep{{Wait: "-1m"}}}, wantErr: true},
+		{name: "unknown gate", plan: app.RolloutPlan{Steps: []app.RolloutStep{{Gates: []string{"latency"}}}}, wantErr: true},
+		{name: "last stable label", plan: app.RolloutPlan{Steps: []app.RolloutStep{{Label: app.PrevStableLabel}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePlan(tt.plan); (err != nil) != tt.wantErr {
+				t.Errorf("validatePlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
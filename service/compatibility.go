@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+// incompatibleSignaturesError is returned when label stable would be moved to version
+// which can't serve requests sent to current stable version, it carries diff of signatures
+type incompatibleSignaturesError struct {
+	err  *exterr.Error
+	diff *app.SignaturesDiff
+}
+
+func newIncompatibleSignaturesError(diff *app.SignaturesDiff) *incompatibleSignaturesError {
+	msg := fmt.Sprintf("signatures of version %d are incompatible with signatures of stable version %d", diff.NewVersion, diff.CurrentVersion)
+	return &incompatibleSignaturesError{
+		err:  exterr.NewErrorWithMessage(msg).WithComponent(app.ComponentService).WithCode(incompatibleSignaturesErrorCode),
+		diff: diff,
+	}
+}
+
+func (e *incompatibleSignaturesError) Error() string {
+	return e.err.Error()
+}
+
+func (e *incompatibleSignaturesError) Unwrap() error {
+	return e.err
+}
+
+// Details returns diff of signatures, it's sent to client in error body
+func (e *incompatibleSignaturesError) Details() interface{} {
+	return e.diff
+}
+
+// compareSignatures returns changes which break clients of current signatures. Signatures
+// and outputs may be added, anything else that clients rely on has to stay the same.
+// Shapes of inputs may only become less specific, shapes of outputs only more specific
+func compareSignatures(current, new app.Signatures) []app.SignatureChange {
+	var changes []app.SignatureChange
+	for _, key := range signatureKeys(current) {
+		currentSignature := current[key]
+		newSignature, ok := new[key]
+		if !ok {
+			changes = append(changes, app.SignatureChange{Kind: app.SignatureRemoved, Signature: key})
+			continue
+		}
+
+		if currentSignature.MethodName != newSignature.MethodName {
+			changes = append(changes, app.SignatureChange{Kind: app.MethodNameChanged, Signature: key, Current: currentSignature.MethodName, New: newSignature.MethodName})
+		}
+
+		for _, name := range tensorNames(currentSignature.Inputs) {
+			newTensor, ok := newSignature.Inputs[name]
+			if !ok {
+				changes = append(changes, app.SignatureChange{Kind: app.InputRemoved, Signature: key, Tensor: "inputs/" + name})
+				continue
+			}
+			changes = append(changes, compareTensors(key, "inputs/"+name, currentSignature.Inputs[name], newTensor, true)...)
+		}
+		for _, name := range tensorNames(newSignature.Inputs) {
+			if _, ok := currentSignature.Inputs[name]; !ok {
+				changes = append(changes, app.SignatureChange{Kind: app.InputAdded, Signature: key, Tensor: "inputs/" + name})
+			}
+		}
+
+		for _, name := range tensorNames(currentSignature.Outputs) {
+			newTensor, ok := newSignature.Outputs[name]
+			if !ok {
+				changes = append(changes, app.SignatureChange{Kind: app.OutputRemoved, Signature: key, Tensor: "outputs/" + name})
+				continue
+			}
+			changes = append(changes, compareTensors(key, "outputs/"+name, currentSignature.Outputs[name], newTensor, false)...)
+		}
+	}
+
+	return changes
+}
+
+func compareTensors(signature, tensor string, current, new app.TensorInfo, input bool) []app.SignatureChange {
+	var changes []app.SignatureChange
+	if current.DType != new.DType {
+		changes = append(changes, app.SignatureChange{Kind: app.TensorDTypeChanged, Signature: signature, Tensor: tensor, Current: current.DType, New: new.DType})
+	}
+	if !shapesCompatible(current.Shape, new.Shape, input) {
+		changes = append(changes, app.SignatureChange{Kind: app.TensorShapeChanged, Signature: signature, Tensor: tensor, Current: current.Shape, New: new.Shape})
+	}
+
+	return changes
+}
+
+// shapesCompatible reports whether new shape of input accepts every tensor accepted by
+// current shape, or whether new shape of output is returned only as tensors matching
+// current shape. Unknown dimensions (-1) and unknown rank (nil) match any tensor
+func shapesCompatible(current, new []int64, input bool) bool {
+	narrow, wide := current, new
+	if !input {
+		narrow, wide = new, current
+	}
+
+	if wide == nil {
+		return true
+	}
+	if narrow == nil || len(narrow) != len(wide) {
+		return false
+	}
+	for i := range wide {
+		if wide[i] != narrow[i] && wide[i] != -1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func signatureKeys(signatures app.Signatures) []string {
+	keys := make([]string, 0, len(signatures))
+	for key := range signatures {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func tensorNames(tensors map[string]app.TensorInfo) []string {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
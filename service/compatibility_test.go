@@ -0,0 +1,121 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+)
+
+func Test_compareSignatures(t *testing.T) {
+	tensor := func(dtype string, shape ...int64) app.TensorInfo {
+		return app.TensorInfo{DType: dtype, Shape: shape}
+	}
+	signatures := func(inputs, outputs map[string]app.TensorInfo) app.Signatures {
+		return app.Signatures{"serving_default": {MethodName: "tensorflow/serving/predict", Inputs: inputs, Outputs: outputs}}
+	}
+	current := signatures(
+		map[string]app.TensorInfo{"images": tensor("DT_FLOAT", -1, 28, 28)},
+		map[string]app.TensorInfo{"scores": tensor("DT_FLOAT", -1, 10)},
+	)
+
+	tests := []struct {
+		name string
+		new  app.Signatures
+		want []app.SignatureChange
+	}{
+		{
+			name: "Identical signatures should be compatible",
+			new:  current,
+		},
+		{
+			name: "Less specific inputs, more specific outputs and added outputs and signatures should be compatible",
+			new: app.Signatures{
+				"serving_default": {
+					MethodName: "tensorflow/serving/predict",
+					Inputs:     map[string]app.TensorInfo{"images": tensor("DT_FLOAT", -1, -1, 28)},
+					Outputs:    map[string]app.TensorInfo{"scores": tensor("DT_FLOAT", 32, 10), "classes": tensor("DT_INT64", -1)},
+				},
+				"classify": {MethodName: "tensorflow/serving/classify"},
+			},
+		},
+		{
+			name: "Removed signature should be reported",
+			new:  app.Signatures{"predict": current["serving_default"]},
+			want: []app.SignatureChange{{Kind: app.SignatureRemoved, Signature: "serving_default"}},
+		},
+		{
+			name: "Changed dtypes and shapes should be reported",
+			new: signatures(
+				map[string]app.TensorInfo{"images": tensor("DT_HALF", -1, 28, 28, 1)},
+				map[string]app.TensorInfo{"scores": tensor("DT_FLOAT", -1, 100)},
+			),
+			want: []app.SignatureChange{
+				{Kind: app.TensorDTypeChanged, Signature: "serving_default", Tensor: "inputs/images", Current: "DT_FLOAT", New: "DT_HALF"},
+				{Kind: app.TensorShapeChanged, Signature: "serving_default", Tensor: "inputs/images", Current: []int64{-1, 28, 28}, New: []int64{-1, 28, 28, 1}},
+				{Kind: app.TensorShapeChanged, Signature: "serving_default", Tensor: "outputs/scores", Current: []int64{-1, 10}, New: []int64{-1, 100}},
+			},
+		},
+		{
+			name: "More specific inputs and less specific outputs should be reported",
+			new: signatures(
+				map[string]app.TensorInfo{"images": tensor("DT_FLOAT", 1, 28, 28)},
+				map[string]app.TensorInfo{"scores": tensor("DT_FLOAT", -1, -1)},
+			),
+			want: []app.SignatureChange{
+				{Kind: app.TensorShapeChanged, Signature: "serving_default", Tensor: "inputs/images", Current: []int64{-1, 28, 28}, New: []int64{1, 28, 28}},
+				{Kind: app.TensorShapeChanged, Signature: "serving_default", Tensor: "outputs/scores", Current: []int64{-1, 10}, New: []int64{-1, -1}},
+			},
+		},
+		{
+			name: "Renamed input and removed output should be reported",
+			new: signatures(
+				map[string]app.TensorInfo{"pixels": tensor("DT_FLOAT", -1, 28, 28)},
+				map[string]app.TensorInfo{"classes": tensor("DT_INT64", -1)},
+			),
+			want: []app.SignatureChange{
+				{Kind: app.InputRemoved, Signature: "serving_default", Tensor: "inputs/images"},
+				{Kind: app.InputAdded, Signature: "serving_default", Tensor: "inputs/pixels"},
+				{Kind: app.OutputRemoved, Signature: "serving_default", Tensor: "outputs/scores"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareSignatures(current, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareSignatures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shapesCompatible(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []int64
+		new     []int64
+		input   bool
+		want    bool
+	}{
+		{name: "Same shape of input should be compatible", current: []int64{-1, 10}, new: []int64{-1, 10}, input: true, want: true},
+		{name: "Input with known dimension becoming unknown should be compatible", current: []int64{1, 10}, new: []int64{-1, 10}, input: true, want: true},
+		{name: "Input with unknown dimension becoming known shouldn't be compatible", current: []int64{-1, 10}, new: []int64{1, 10}, input: true},
+		{name: "Input with changed dimension shouldn't be compatible", current: []int64{-1, 10}, new: []int64{-1, 20}, input: true},
+		{name: "Input with changed rank shouldn't be compatible", current: []int64{-1, 10}, new: []int64{-1, 10, 1}, input: true},
+		{name: "Input becoming unknown rank should be compatible", current: []int64{-1, 10}, input: true, want: true},
+		{name: "Input of unknown rank becoming known shouldn't be compatible", new: []int64{-1, 10}, input: true},
+		{name: "Same shape of output should be compatible", current: []int64{-1, 10}, new: []int64{-1, 10}, want: true},
+		{name: "Output with unknown dimension becoming known should be compatible", current: []int64{-1, 10}, new: []int64{1, 10}, want: true},
+		{name: "Output with known dimension becoming unknown shouldn't be compatible", current: []int64{-1, 10}, new: []int64{-1, -1}},
+		{name: "Output with changed dimension shouldn't be compatible", current: []int64{-1, 10}, new: []int64{-1, 20}},
+		{name: "Output of unknown rank becoming known should be compatible", new: []int64{-1, 10}, want: true},
+		{name: "Output becoming unknown rank shouldn't be compatible", current: []int64{-1, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shapesCompatible(tt.current, tt.new, tt.input); got != tt.want {
+				t.Errorf("shapesCompatible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+func (s *ModelsService) LabelHistory(ctx context.Context, id app.ServableID, label string) ([]app.LabelHistoryEntry, error) {
+	entries, err := s.metadata.ListLabelHistory(ctx, id, label)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if entries == nil {
+		entries = []app.LabelHistoryEntry{}
+	}
+
+	return entries, nil
+}
+
+// RollbackLabel restores assignment of label recorded by entry of its history. Label
+// is removed when entry records its removal
+func (s *ModelsService) RollbackLabel(ctx context.Context, id app.ServableID, label string, entryID int64, force bool) (*app.LabelChanged, error) {
+	entry, err := s.metadata.GetLabelHistoryEntry(ctx, id, label, entryID)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if entry == nil {
+		logging.ErrorWithStack(ctx, errorLabelHistoryNotFound)
+		return nil, errorLabelHistoryNotFound
+	}
+
+	if entry.ToVersion != 0 {
+		return s.SetLabel(ctx, app.ModelID{ServableID: id, Version: entry.ToVersion, Label: label}, force)
+	}
+
+	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "label": label}
+	modelMeta, err := s.metadata.Get(ctx, params)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if modelMeta == nil {
+		// label has been removed already
+		return &app.LabelChanged{ServableID: id, Label: label}, nil
+	}
+
+	if err := s.removeModelLabel(ctx, id, params); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	return &app.LabelChanged{ServableID: id, Label: label, PreviousVersion: modelMeta.Version}, nil
+}
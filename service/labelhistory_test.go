@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/service/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestModelsService_RollbackLabel(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	canary := modelID("testTeam", "testProject", "testName", "canary", 3)
+	canaryParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "label": "canary"}
+
+	tests := []struct {
+		name    string
+		mock    func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig)
+		want    *app.LabelChanged
+		wantErr error
+	}{
+		{
+			name: "Label should be moved back to recorded version",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("GetLabelHistoryEntry", mock.Anything, id, "canary", int64(7)).Return(&app.LabelHistoryEntry{ServableID: id, Label: "canary", FromVersion: 2, ToVersion: 3}, nil)
+				mm.On("Get", mock.Anything, app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "version": int64(3)}).Return(&app.ModelData{ModelID: canary}, nil)
+				mc.On("UpdateLabel", mock.Anything, canary).Return(int64(4), nil)
+				mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: canary, Status: app.StatusReady}).Return(nil)
+			},
+			want: &app.LabelChanged{ServableID: id, Label: "canary", PreviousVersion: 4, NewVersion: 3},
+		},
+		{
+			name: "Label should be removed when entry records its removal",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("GetLabelHistoryEntry", mock.Anything, id, "canary", int64(7)).Return(&app.LabelHistoryEntry{ServableID: id, Label: "canary", FromVersion: 3, ToVersion: 0}, nil)
+				mm.On("Get", mock.Anything, canaryParams).Return(&app.ModelData{ModelID: canary}, nil)
+				mc.On("RemoveModelLabel", mock.Anything, app.ModelID{ServableID: id, Label: "canary"}).Return(nil)
+				mm.On("RemoveLabel", mock.Anything, app.ModelData{ModelID: app.ModelID{ServableID: id, Label: "canary"}}).Return(nil)
+			},
+			want: &app.LabelChanged{ServableID: id, Label: "canary", PreviousVersion: 3},
+		},
+		{
+			name: "Unknown entry should return error",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("GetLabelHistoryEntry", mock.Anything, id, "canary", int64(7)).Return(nil, nil)
+			},
+			wantErr: errorLabelHistoryNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := new(mocks.ModelsMetadata)
+			mc := new(mocks.ModelsConfig)
+			tt.mock(mm, mc)
+
+			s := &ModelsService{metadata: mm, servingConfig: mc}
+			got, err := s.RollbackLabel(context.Background(), id, "canary", 7, false)
+			if err != tt.wantErr {
+				t.Fatalf("ModelsService.RollbackLabel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ModelsService.RollbackLabel() = %+v, want %+v", got, tt.want)
+			}
+			mm.AssertExpectations(t)
+			mc.AssertExpectations(t)
+		})
+	}
+}
@@ -19,6 +19,19 @@ type ModelsMetadata interface {
 	NextVersion(ctx context.Context, parameters app.QueryParameters) (int64, error)
 	UpdateStatus(ctx context.Context, id int64, status string) error
 	IsStatusPending(ctx context.Context, servableID app.ServableID) (bool, error)
+
+	AddSignatures(ctx context.Context, model app.ModelID, signatures app.Signatures) error
+	GetSignatures(ctx context.Context, model app.ModelID) (app.Signatures, error)
+	DeleteSignatures(ctx context.Context, model app.ModelID) error
+
+	SetSmokeTest(ctx context.Context, id app.ServableID, test app.SmokeTest) error
+	GetSmokeTest(ctx context.Context, id app.ServableID) (*app.SmokeTest, error)
+	DeleteSmokeTest(ctx context.Context, id app.ServableID) error
+	GetSmokeTestResults(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error)
+	DeleteSmokeTestResults(ctx context.Context, model app.ModelID) error
+
+	ListLabelHistory(ctx context.Context, id app.ServableID, label string) ([]app.LabelHistoryEntry, error)
+	GetLabelHistoryEntry(ctx context.Context, id app.ServableID, label string, entryID int64) (*app.LabelHistoryEntry, error)
 }
 
 // ModulesMetadata is an interface that contains necessary methods required to
@@ -14,16 +14,30 @@ type ModelStorage struct {
 	mock.Mock
 }
 
+// DiscardStagedModel provides a mock function with given fields: staged
+func (_m *ModelStorage) DiscardStagedModel(staged *storage.StagedModel) error {
+	ret := _m.Called(staged)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*storage.StagedModel) error); ok {
+		r0 = rf(staged)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ReadAllModels provides a mock function with given fields: ctx, modelID
-func (_m *ModelStorage) ReadAllModels(ctx context.Context, modelID app.ServableID) ([]byte, error) {
+func (_m *ModelStorage) ReadAllModels(ctx context.Context, modelID app.ServableID) (app.ArchiveContent, error) {
 	ret := _m.Called(ctx, modelID)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID) []byte); ok {
+	var r0 app.ArchiveContent
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID) app.ArchiveContent); ok {
 		r0 = rf(ctx, modelID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).(app.ArchiveContent)
 		}
 	}
 
@@ -38,15 +52,15 @@ func (_m *ModelStorage) ReadAllModels(ctx context.Context, modelID app.ServableI
 }
 
 // ReadModel provides a mock function with given fields: ctx, modelID, version
-func (_m *ModelStorage) ReadModel(ctx context.Context, modelID app.ServableID, version int) ([]byte, error) {
+func (_m *ModelStorage) ReadModel(ctx context.Context, modelID app.ServableID, version int) (app.ArchiveContent, error) {
 	ret := _m.Called(ctx, modelID, version)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, int) []byte); ok {
+	var r0 app.ArchiveContent
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, int) app.ArchiveContent); ok {
 		r0 = rf(ctx, modelID, version)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).(app.ArchiveContent)
 		}
 	}
 
@@ -74,13 +88,13 @@ func (_m *ModelStorage) RemoveModel(ctx context.Context, id app.ServableID, vers
 	return r0
 }
 
-// SaveModel provides a mock function with given fields: ctx, modelID, version, archive
-func (_m *ModelStorage) SaveModel(ctx context.Context, modelID app.ServableID, version int, archive io.Reader) (*storage.SaveModelResponse, error) {
-	ret := _m.Called(ctx, modelID, version, archive)
+// SaveStagedModel provides a mock function with given fields: ctx, staged, version
+func (_m *ModelStorage) SaveStagedModel(ctx context.Context, staged *storage.StagedModel, version int) (*storage.SaveModelResponse, error) {
+	ret := _m.Called(ctx, staged, version)
 
 	var r0 *storage.SaveModelResponse
-	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, int, io.Reader) *storage.SaveModelResponse); ok {
-		r0 = rf(ctx, modelID, version, archive)
+	if rf, ok := ret.Get(0).(func(context.Context, *storage.StagedModel, int) *storage.SaveModelResponse); ok {
+		r0 = rf(ctx, staged, version)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*storage.SaveModelResponse)
@@ -88,8 +102,31 @@ func (_m *ModelStorage) SaveModel(ctx context.Context, modelID app.ServableID, v
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, app.ServableID, int, io.Reader) error); ok {
-		r1 = rf(ctx, modelID, version, archive)
+	if rf, ok := ret.Get(1).(func(context.Context, *storage.StagedModel, int) error); ok {
+		r1 = rf(ctx, staged, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StageModel provides a mock function with given fields: ctx, modelID, archive
+func (_m *ModelStorage) StageModel(ctx context.Context, modelID app.ServableID, archive io.Reader) (*storage.StagedModel, error) {
+	ret := _m.Called(ctx, modelID, archive)
+
+	var r0 *storage.StagedModel
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, io.Reader) *storage.StagedModel); ok {
+		r0 = rf(ctx, modelID, archive)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.StagedModel)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ServableID, io.Reader) error); ok {
+		r1 = rf(ctx, modelID, archive)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -32,6 +32,20 @@ func (_m *ModelsMetadata) Add(ctx context.Context, model app.ModelData) (int64,
 	return r0, r1
 }
 
+// AddSignatures provides a mock function with given fields: ctx, model, signatures
+func (_m *ModelsMetadata) AddSignatures(ctx context.Context, model app.ModelID, signatures app.Signatures) error {
+	ret := _m.Called(ctx, model, signatures)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, app.ModelID, app.Signatures) error); ok {
+		r0 = rf(ctx, model, signatures)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ChangeLabel provides a mock function with given fields: ctx, model
 func (_m *ModelsMetadata) ChangeLabel(ctx context.Context, model app.ModelData) error {
 	ret := _m.Called(ctx, model)
@@ -60,6 +74,48 @@ func (_m *ModelsMetadata) Delete(ctx context.Context, id int64) error {
 	return r0
 }
 
+// DeleteSignatures provides a mock function with given fields: ctx, model
+func (_m *ModelsMetadata) DeleteSignatures(ctx context.Context, model app.ModelID) error {
+	ret := _m.Called(ctx, model)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, app.ModelID) error); ok {
+		r0 = rf(ctx, model)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSmokeTest provides a mock function with given fields: ctx, id
+func (_m *ModelsMetadata) DeleteSmokeTest(ctx context.Context, id app.ServableID) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteSmokeTestResults provides a mock function with given fields: ctx, model
+func (_m *ModelsMetadata) DeleteSmokeTestResults(ctx context.Context, model app.ModelID) error {
+	ret := _m.Called(ctx, model)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, app.ModelID) error); ok {
+		r0 = rf(ctx, model)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: ctx, parameters
 func (_m *ModelsMetadata) Get(ctx context.Context, parameters app.QueryParameters) (*app.ModelData, error) {
 	ret := _m.Called(ctx, parameters)
@@ -83,6 +139,98 @@ func (_m *ModelsMetadata) Get(ctx context.Context, parameters app.QueryParameter
 	return r0, r1
 }
 
+// GetLabelHistoryEntry provides a mock function with given fields: ctx, id, label, entryID
+func (_m *ModelsMetadata) GetLabelHistoryEntry(ctx context.Context, id app.ServableID, label string, entryID int64) (*app.LabelHistoryEntry, error) {
+	ret := _m.Called(ctx, id, label, entryID)
+
+	var r0 *app.LabelHistoryEntry
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, string, int64) *app.LabelHistoryEntry); ok {
+		r0 = rf(ctx, id, label, entryID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*app.LabelHistoryEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ServableID, string, int64) error); ok {
+		r1 = rf(ctx, id, label, entryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSignatures provides a mock function with given fields: ctx, model
+func (_m *ModelsMetadata) GetSignatures(ctx context.Context, model app.ModelID) (app.Signatures, error) {
+	ret := _m.Called(ctx, model)
+
+	var r0 app.Signatures
+	if rf, ok := ret.Get(0).(func(context.Context, app.ModelID) app.Signatures); ok {
+		r0 = rf(ctx, model)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(app.Signatures)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ModelID) error); ok {
+		r1 = rf(ctx, model)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSmokeTest provides a mock function with given fields: ctx, id
+func (_m *ModelsMetadata) GetSmokeTest(ctx context.Context, id app.ServableID) (*app.SmokeTest, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *app.SmokeTest
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID) *app.SmokeTest); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*app.SmokeTest)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ServableID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSmokeTestResults provides a mock function with given fields: ctx, model
+func (_m *ModelsMetadata) GetSmokeTestResults(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error) {
+	ret := _m.Called(ctx, model)
+
+	var r0 []app.SmokeTestResult
+	if rf, ok := ret.Get(0).(func(context.Context, app.ModelID) []app.SmokeTestResult); ok {
+		r0 = rf(ctx, model)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]app.SmokeTestResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ModelID) error); ok {
+		r1 = rf(ctx, model)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IsStatusPending provides a mock function with given fields: ctx, servableID
 func (_m *ModelsMetadata) IsStatusPending(ctx context.Context, servableID app.ServableID) (bool, error) {
 	ret := _m.Called(ctx, servableID)
@@ -127,6 +275,29 @@ func (_m *ModelsMetadata) List(ctx context.Context, parameters app.QueryParamete
 	return r0, r1
 }
 
+// ListLabelHistory provides a mock function with given fields: ctx, id, label
+func (_m *ModelsMetadata) ListLabelHistory(ctx context.Context, id app.ServableID, label string) ([]app.LabelHistoryEntry, error) {
+	ret := _m.Called(ctx, id, label)
+
+	var r0 []app.LabelHistoryEntry
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, string) []app.LabelHistoryEntry); ok {
+		r0 = rf(ctx, id, label)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]app.LabelHistoryEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, app.ServableID, string) error); ok {
+		r1 = rf(ctx, id, label)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListUniqueTeamProject provides a mock function with given fields: ctx
 func (_m *ModelsMetadata) ListUniqueTeamProject(ctx context.Context) ([]*app.ServableID, error) {
 	ret := _m.Called(ctx)
@@ -185,6 +356,20 @@ func (_m *ModelsMetadata) RemoveLabel(ctx context.Context, model app.ModelData)
 	return r0
 }
 
+// SetSmokeTest provides a mock function with given fields: ctx, id, test
+func (_m *ModelsMetadata) SetSmokeTest(ctx context.Context, id app.ServableID, test app.SmokeTest) error {
+	ret := _m.Called(ctx, id, test)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, app.SmokeTest) error); ok {
+		r0 = rf(ctx, id, test)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateStatus provides a mock function with given fields: ctx, id, status
 func (_m *ModelsMetadata) UpdateStatus(ctx context.Context, id int64, status string) error {
 	ret := _m.Called(ctx, id, status)
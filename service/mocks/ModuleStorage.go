@@ -13,15 +13,15 @@ type ModuleStorage struct {
 }
 
 // ReadModule provides a mock function with given fields: ctx, moduleID, version
-func (_m *ModuleStorage) ReadModule(ctx context.Context, moduleID app.ServableID, version int) ([]byte, error) {
+func (_m *ModuleStorage) ReadModule(ctx context.Context, moduleID app.ServableID, version int) (app.ArchiveContent, error) {
 	ret := _m.Called(ctx, moduleID, version)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, int) []byte); ok {
+	var r0 app.ArchiveContent
+	if rf, ok := ret.Get(0).(func(context.Context, app.ServableID, int) app.ArchiveContent); ok {
 		r0 = rf(ctx, moduleID, version)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).(app.ArchiveContent)
 		}
 	}
 
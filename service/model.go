@@ -14,10 +14,21 @@ var (
 	modelNotFoundErrorCode           = 1001
 	stableModelNotFoundErrorCode     = 1002
 	prevStableModelNotFoundErrorCode = 1003
+	signaturesNotFoundErrorCode      = 1005
+	incompatibleSignaturesErrorCode  = 1006
+	smokeTestNotFoundErrorCode       = 1007
+	smokeTestNotPassedErrorCode      = 1008
+	smokeTestsDisabledErrorCode      = 1009
+	labelHistoryNotFoundErrorCode    = 1010
 
 	errorModelNotFound           = exterr.NewErrorWithMessage("model not found").WithComponent(app.ComponentService).WithCode(modelNotFoundErrorCode)
 	errorStableModelNotFound     = exterr.NewErrorWithMessage("model with label 'stable' not found").WithComponent(app.ComponentService).WithCode(stableModelNotFoundErrorCode)
 	errorPrevStableModelNotFound = exterr.NewErrorWithMessage("model with label 'prev_stable' not found").WithComponent(app.ComponentService).WithCode(prevStableModelNotFoundErrorCode)
+	errorSignaturesNotFound      = exterr.NewErrorWithMessage("signatures of model not found").WithComponent(app.ComponentService).WithCode(signaturesNotFoundErrorCode)
+	errorSmokeTestNotFound       = exterr.NewErrorWithMessage("smoke test of model not found").WithComponent(app.ComponentService).WithCode(smokeTestNotFoundErrorCode)
+	errorSmokeTestNotPassed      = exterr.NewErrorWithMessage("smoke test of model version hasn't passed").WithComponent(app.ComponentService).WithCode(smokeTestNotPassedErrorCode)
+	errorSmokeTestsDisabled      = exterr.NewErrorWithMessage("smoke tests are disabled").WithComponent(app.ComponentService).WithCode(smokeTestsDisabledErrorCode)
+	errorLabelHistoryNotFound    = exterr.NewErrorWithMessage("entry of label history not found").WithComponent(app.ComponentService).WithCode(labelHistoryNotFoundErrorCode)
 )
 
 func cleanList(models []*app.ModelData) []*app.ModelData {
@@ -106,7 +117,7 @@ func (s *ModelsService) ArchiveByLabel(ctx context.Context, id app.ServableID, l
 		return nil, err
 	}
 
-	return &app.Archive{Data: archive, Name: id.ArchiveName(s.archivePrefix(), modelMeta.Version)}, nil
+	return &app.Archive{Content: archive, Name: id.ArchiveName(s.archivePrefix(), modelMeta.Version), ETag: archiveETag(s.archivePrefix(), modelMeta.ID, modelMeta.Created)}, nil
 }
 
 func (s *ModelsService) ArchiveByVersion(ctx context.Context, id app.ServableID, version int64) (*app.Archive, error) {
@@ -128,7 +139,46 @@ func (s *ModelsService) ArchiveByVersion(ctx context.Context, id app.ServableID,
 		return nil, err
 	}
 
-	return &app.Archive{Data: archive, Name: id.ArchiveName(s.archivePrefix(), version)}, nil
+	return &app.Archive{Content: archive, Name: id.ArchiveName(s.archivePrefix(), version), ETag: archiveETag(s.archivePrefix(), modelMeta.ID, modelMeta.Created)}, nil
+}
+
+func (s *ModelsService) SignaturesByLabel(ctx context.Context, id app.ServableID, label string) (*app.ModelSignatures, error) {
+	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "label": label}
+
+	return s.signatures(ctx, params)
+}
+
+func (s *ModelsService) SignaturesByVersion(ctx context.Context, id app.ServableID, version int64) (*app.ModelSignatures, error) {
+	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "version": version}
+
+	return s.signatures(ctx, params)
+}
+
+func (s *ModelsService) signatures(ctx context.Context, params app.QueryParameters) (*app.ModelSignatures, error) {
+	modelMeta, err := s.metadata.Get(ctx, params)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	if modelMeta == nil {
+		logging.ErrorWithStack(ctx, errorModelNotFound)
+		return nil, errorModelNotFound
+	}
+
+	signatures, err := s.metadata.GetSignatures(ctx, modelMeta.ModelID)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
+	// signatures aren't stored for models uploaded before signatures were extracted
+	if signatures == nil {
+		logging.ErrorWithStack(ctx, errorSignaturesNotFound)
+		return nil, errorSignaturesNotFound
+	}
+
+	return &app.ModelSignatures{ModelID: modelMeta.ModelID, Signatures: signatures}, nil
 }
 
 func (s *ModelsService) ReloadModels(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error) {
@@ -141,7 +191,7 @@ func (s *ModelsService) ReloadModels(ctx context.Context, team, project string,
 	return reloadStatus, nil
 }
 
-func (s *ModelsService) SetLabel(ctx context.Context, model app.ModelID) (*app.LabelChanged, error) {
+func (s *ModelsService) SetLabel(ctx context.Context, model app.ModelID, force bool) (*app.LabelChanged, error) {
 	params := app.QueryParameters{"team": model.Team, "project": model.Project, "name": model.Name, "version": model.Version}
 	modelMeta, err := s.metadata.Get(ctx, params)
 	if err != nil {
@@ -153,6 +203,20 @@ func (s *ModelsService) SetLabel(ctx context.Context, model app.ModelID) (*app.L
 		return nil, errorModelNotFound
 	}
 
+	// force overrides compatibility of signatures only, required smoke test can't be skipped
+	if model.Label == app.StableLabel && !force {
+		if err := s.checkStableCompatibility(ctx, model); err != nil {
+			logging.ErrorWithStack(ctx, err)
+			return nil, err
+		}
+	}
+	if model.Label == app.StableLabel && s.requireSmokeTest {
+		if err := s.checkSmokeTestPassed(ctx, model); err != nil {
+			logging.ErrorWithStack(ctx, err)
+			return nil, err
+		}
+	}
+
 	prevVersion, err := s.servingConfig.UpdateLabel(ctx, model)
 	if err != nil {
 		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
@@ -175,6 +239,38 @@ func (s *ModelsService) SetLabel(ctx context.Context, model app.ModelID) (*app.L
 	return &app.LabelChanged{ServableID: model.ServableID, Label: model.Label, PreviousVersion: prevVersion, NewVersion: model.Version}, nil
 }
 
+// checkStableCompatibility compares signatures of model version with signatures of
+// current stable version. Versions uploaded before signatures were stored aren't compared
+func (s *ModelsService) checkStableCompatibility(ctx context.Context, model app.ModelID) error {
+	params := app.QueryParameters{"team": model.Team, "project": model.Project, "name": model.Name, "label": app.StableLabel}
+	stableMeta, err := s.metadata.Get(ctx, params)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if stableMeta == nil || stableMeta.Version == model.Version {
+		return nil
+	}
+
+	stableSignatures, err := s.metadata.GetSignatures(ctx, stableMeta.ModelID)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	newSignatures, err := s.metadata.GetSignatures(ctx, model)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if stableSignatures == nil || newSignatures == nil {
+		return nil
+	}
+
+	changes := compareSignatures(stableSignatures, newSignatures)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return newIncompatibleSignaturesError(&app.SignaturesDiff{CurrentVersion: stableMeta.Version, NewVersion: model.Version, Changes: changes})
+}
+
 func (s *ModelsService) Revert(ctx context.Context, id app.ServableID) (*app.LabelChanged, error) {
 
 	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "label": app.StableLabel}
@@ -218,15 +314,40 @@ func (s *ModelsService) Revert(ctx context.Context, id app.ServableID) (*app.Lab
 	return &app.LabelChanged{ServableID: model.ServableID, Label: model.Label, PreviousVersion: currentStableMeta.Version, NewVersion: model.Version}, nil
 }
 
-func (s *ModelsService) UploadModel(ctx context.Context, id app.ServableID, file io.Reader, label ...string) (*app.ModelID, error) {
+// UploadModel saves model under next version and assigns label to it. Signatures of model
+// uploaded with label stable have to be compatible with current stable version unless forced,
+// when smoke test is required model with smoke test can't be uploaded with label stable at all
+func (s *ModelsService) UploadModel(ctx context.Context, id app.ServableID, file io.Reader, force bool, label ...string) (*app.ModelID, error) {
+	uploadLabel := s.servingConfig.DefaultLabel()
+	if len(label) != 0 {
+		uploadLabel = label[0]
+	}
+
+	if uploadLabel == app.StableLabel && s.requireSmokeTest {
+		if err := s.checkSmokeTestNotAttached(ctx, id); err != nil {
+			logging.ErrorWithStack(ctx, err)
+			return nil, err
+		}
+	}
+
+	// model is validated before version is assigned, so invalid models don't consume versions
+	staged, err := s.storage.StageModel(ctx, id, file)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+
 	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name}
 	version, err := s.metadata.NextVersion(ctx, params)
 	if err != nil {
+		if errDiscard := s.storage.DiscardStagedModel(staged); errDiscard != nil {
+			err = exterr.WrapWithErr(err, errDiscard)
+		}
 		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
 		return nil, err
 	}
 
-	_, err = s.storage.SaveModel(ctx, id, int(version), file)
+	_, err = s.storage.SaveStagedModel(ctx, staged, int(version))
 	if err != nil {
 		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
 		return nil, err
@@ -244,10 +365,24 @@ func (s *ModelsService) UploadModel(ctx context.Context, id app.ServableID, file
 		return nil, err
 	}
 
+	if len(staged.Signatures) != 0 {
+		if err := s.metadata.AddSignatures(ctx, modelID, staged.Signatures); err != nil {
+			logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+			return nil, err
+		}
+	}
+
 	modelIDWithLabel := modelID
-	modelIDWithLabel.Label = s.servingConfig.DefaultLabel()
-	if len(label) != 0 {
-		modelIDWithLabel.Label = label[0]
+	modelIDWithLabel.Label = uploadLabel
+
+	if modelIDWithLabel.Label == app.StableLabel && !force {
+		if err := s.checkStableCompatibility(ctx, modelIDWithLabel); err != nil {
+			if errDiscard := s.discardModel(ctx, modelID, metaID); errDiscard != nil {
+				logging.ErrorWithStack(ctx, errDiscard)
+			}
+			logging.ErrorWithStack(ctx, err)
+			return nil, err
+		}
 	}
 
 	if err := s.servingConfig.AddModel(ctx, modelIDWithLabel); err != nil {
@@ -269,6 +404,22 @@ func (s *ModelsService) UploadModel(ctx context.Context, id app.ServableID, file
 	return &modelID, nil
 }
 
+// discardModel removes files, metadata and signatures of uploaded version which is
+// rejected before it's added to config of TFS
+func (s *ModelsService) discardModel(ctx context.Context, model app.ModelID, metaID int64) error {
+	if err := s.metadata.DeleteSignatures(ctx, model); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if err := s.metadata.Delete(ctx, metaID); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if err := s.storage.RemoveModel(ctx, model.ServableID, model.Version); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
 func (s *ModelsService) RemoveByLabel(ctx context.Context, id app.ServableID, label string) error {
 	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "label": label}
 	err := s.removeModel(ctx, id, params)
@@ -358,5 +509,13 @@ func (s *ModelsService) removeModel(ctx context.Context, id app.ServableID, para
 		}
 	}
 
+	if err := s.metadata.DeleteSignatures(ctx, modelID); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	if err := s.metadata.DeleteSmokeTestResults(ctx, modelID); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
 	return nil
 }
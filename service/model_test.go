@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/service/mocks"
+	"github.com/grupawp/tensorflow-deploy/storage"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -314,7 +317,17 @@ var GetMetaOnGetResponse = func(params app.QueryParameters, metaResponse *app.Mo
 	return mm
 }
 
-var GetStorageReadModelResponse = func(id app.ServableID, version int, storageResponse []byte, err error) ModelStorage {
+type nopCloseReadSeeker struct {
+	io.ReadSeeker
+}
+
+func (nopCloseReadSeeker) Close() error {
+	return nil
+}
+
+var testArchiveContent app.ArchiveContent = nopCloseReadSeeker{strings.NewReader("archive")}
+
+var GetStorageReadModelResponse = func(id app.ServableID, version int, storageResponse app.ArchiveContent, err error) ModelStorage {
 	mm := new(mocks.ModelStorage)
 	mm.On("ReadModel", mock.Anything, id, version).Return(storageResponse, err)
 	return mm
@@ -386,13 +399,13 @@ func TestModelsService_ArchiveByLabel(t *testing.T) {
 							Version:    1, Label: "testLabel"}},
 					nil),
 				storage: GetStorageReadModelResponse(app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}, 1,
-					[]byte("archive"), nil),
+					testArchiveContent, nil),
 			},
 			args: args{
 				id:    app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"},
 				label: "testLabel",
 			},
-			want: &app.Archive{Data: []byte("archive"), Name: app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}.ArchiveName((&ModelsService{}).archivePrefix(), int64(1))},
+			want: &app.Archive{Content: testArchiveContent, Name: app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}.ArchiveName((&ModelsService{}).archivePrefix(), int64(1)), ETag: "model-0-"},
 		},
 	}
 	for _, tt := range tests {
@@ -480,13 +493,13 @@ func TestModelsService_ArchiveByVersion(t *testing.T) {
 							Version:    1, Label: "testLabel"}},
 					nil),
 				storage: GetStorageReadModelResponse(app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}, 1,
-					[]byte("archive"), nil),
+					testArchiveContent, nil),
 			},
 			args: args{
 				id:      app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"},
 				version: 1,
 			},
-			want: &app.Archive{Data: []byte("archive"), Name: app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}.ArchiveName((&ModelsService{}).archivePrefix(), int64(1))},
+			want: &app.Archive{Content: testArchiveContent, Name: app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}.ArchiveName((&ModelsService{}).archivePrefix(), int64(1)), ETag: "model-0-"},
 		},
 	}
 	for _, tt := range tests {
@@ -569,3 +582,272 @@ func TestModelsService_ReloadModels(t *testing.T) {
 		})
 	}
 }
+
+func TestModelsService_UploadModel(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	params := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName"}
+	staged := &storage.StagedModel{Signatures: app.Signatures{"serving_default": {MethodName: "tensorflow/serving/predict"}}}
+	stableParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "label": "stable"}
+	currentStable := app.ModelID{ServableID: id, Version: 2, Label: "stable"}
+	newStable := app.ModelID{ServableID: id, Version: 3, Label: "stable"}
+
+	signatures := func(dtype string) app.Signatures {
+		return app.Signatures{"serving_default": {Inputs: map[string]app.TensorInfo{"x": {DType: dtype, Shape: []int64{-1}}}}}
+	}
+	saved := func(ms *mocks.ModelStorage, mm *mocks.ModelsMetadata) {
+		ms.On("StageModel", mock.Anything, id, mock.Anything).Return(staged, nil)
+		ms.On("SaveStagedModel", mock.Anything, staged, 3).Return(&storage.SaveModelResponse{}, nil)
+		mm.On("NextVersion", mock.Anything, params).Return(int64(3), nil)
+		mm.On("Add", mock.Anything, app.ModelData{ModelID: app.ModelID{ServableID: id, Version: 3}, Status: app.StatusPending}).Return(int64(10), nil)
+		mm.On("AddSignatures", mock.Anything, app.ModelID{ServableID: id, Version: 3}, staged.Signatures).Return(nil)
+	}
+
+	tests := []struct {
+		name     string
+		force    bool
+		label    []string
+		storage  func(ms *mocks.ModelStorage)
+		metadata func(mm *mocks.ModelsMetadata)
+		config   func(mc *mocks.ModelsConfig)
+		want     *app.ModelID
+		wantErr  bool
+	}{
+		{
+			name: "Invalid model should be rejected before version is assigned",
+			storage: func(ms *mocks.ModelStorage) {
+				ms.On("StageModel", mock.Anything, id, mock.Anything).Return(nil, errors.New("invalid model"))
+			},
+			metadata: func(mm *mocks.ModelsMetadata) {},
+			config:   func(mc *mocks.ModelsConfig) {},
+			wantErr:  true,
+		},
+		{
+			name: "Error on metadata NextVersion() should discard staged model",
+			storage: func(ms *mocks.ModelStorage) {
+				ms.On("StageModel", mock.Anything, id, mock.Anything).Return(staged, nil)
+				ms.On("DiscardStagedModel", staged).Return(nil)
+			},
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("NextVersion", mock.Anything, params).Return(int64(0), errors.New("random error"))
+			},
+			config:  func(mc *mocks.ModelsConfig) {},
+			wantErr: true,
+		},
+		{
+			name: "Valid model should be saved under next version",
+			storage: func(ms *mocks.ModelStorage) {
+				ms.On("StageModel", mock.Anything, id, mock.Anything).Return(staged, nil)
+				ms.On("SaveStagedModel", mock.Anything, staged, 3).Return(&storage.SaveModelResponse{}, nil)
+			},
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("NextVersion", mock.Anything, params).Return(int64(3), nil)
+				mm.On("Add", mock.Anything, app.ModelData{ModelID: app.ModelID{ServableID: id, Version: 3}, Status: app.StatusPending}).Return(int64(10), nil)
+				mm.On("AddSignatures", mock.Anything, app.ModelID{ServableID: id, Version: 3}, staged.Signatures).Return(nil)
+				mm.On("UpdateStatus", mock.Anything, int64(10), app.StatusReady).Return(nil)
+				mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: app.ModelID{ServableID: id, Version: 3, Label: "canary"}, Status: app.StatusReady}).Return(nil)
+			},
+			config: func(mc *mocks.ModelsConfig) {
+				mc.On("AddModel", mock.Anything, app.ModelID{ServableID: id, Version: 3, Label: "canary"}).Return(nil)
+			},
+			want: &app.ModelID{ServableID: id, Version: 3},
+		},
+		{
+			name:  "Incompatible model uploaded with label stable should be discarded",
+			label: []string{"stable"},
+			storage: func(ms *mocks.ModelStorage) {
+				ms.On("RemoveModel", mock.Anything, id, int64(3)).Return(nil)
+			},
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("Get", mock.Anything, stableParams).Return(&app.ModelData{ModelID: currentStable}, nil)
+				mm.On("GetSignatures", mock.Anything, currentStable).Return(signatures("DT_FLOAT"), nil)
+				mm.On("GetSignatures", mock.Anything, newStable).Return(signatures("DT_STRING"), nil)
+				mm.On("DeleteSignatures", mock.Anything, app.ModelID{ServableID: id, Version: 3}).Return(nil)
+				mm.On("Delete", mock.Anything, int64(10)).Return(nil)
+			},
+			config:  func(mc *mocks.ModelsConfig) {},
+			wantErr: true,
+		},
+		{
+			name:    "Incompatible model uploaded with label stable should be saved when forced",
+			force:   true,
+			label:   []string{"stable"},
+			storage: func(ms *mocks.ModelStorage) {},
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("UpdateStatus", mock.Anything, int64(10), app.StatusReady).Return(nil)
+				mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: newStable, Status: app.StatusReady}).Return(nil)
+			},
+			config: func(mc *mocks.ModelsConfig) {
+				mc.On("AddModel", mock.Anything, newStable).Return(nil)
+			},
+			want: &app.ModelID{ServableID: id, Version: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, mm, mc := new(mocks.ModelStorage), new(mocks.ModelsMetadata), new(mocks.ModelsConfig)
+			mc.On("DefaultLabel").Return("canary")
+			if tt.label != nil {
+				saved(ms, mm)
+			}
+			tt.storage(ms)
+			tt.metadata(mm)
+			tt.config(mc)
+
+			s := &ModelsService{storage: ms, metadata: mm, servingConfig: mc}
+			got, err := s.UploadModel(context.Background(), id, strings.NewReader("archive"), tt.force, tt.label...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ModelsService.UploadModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ModelsService.UploadModel() = %v, want %v", got, tt.want)
+			}
+			ms.AssertExpectations(t)
+			mm.AssertExpectations(t)
+			mc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestModelsService_SignaturesByLabel(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	params := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "label": "stable"}
+	model := modelID("testTeam", "testProject", "testName", "stable", 2)
+	signatures := app.Signatures{"serving_default": {MethodName: "tensorflow/serving/predict"}}
+
+	tests := []struct {
+		name     string
+		metadata func(mm *mocks.ModelsMetadata)
+		want     *app.ModelSignatures
+		wantErr  error
+	}{
+		{
+			name: "Unknown label should return an error",
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("Get", mock.Anything, params).Return(nil, nil)
+			},
+			wantErr: errorModelNotFound,
+		},
+		{
+			name: "Version without signatures should return an error",
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("Get", mock.Anything, params).Return(&app.ModelData{ModelID: model}, nil)
+				mm.On("GetSignatures", mock.Anything, model).Return(nil, nil)
+			},
+			wantErr: errorSignaturesNotFound,
+		},
+		{
+			name: "Signatures of labeled version should be returned",
+			metadata: func(mm *mocks.ModelsMetadata) {
+				mm.On("Get", mock.Anything, params).Return(&app.ModelData{ModelID: model}, nil)
+				mm.On("GetSignatures", mock.Anything, model).Return(signatures, nil)
+			},
+			want: &app.ModelSignatures{ModelID: model, Signatures: signatures},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := new(mocks.ModelsMetadata)
+			tt.metadata(mm)
+
+			s := &ModelsService{metadata: mm}
+			got, err := s.SignaturesByLabel(context.Background(), id, "stable")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ModelsService.SignaturesByLabel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ModelsService.SignaturesByLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelsService_SetLabel(t *testing.T) {
+	newStable := modelID("testTeam", "testProject", "testName", "stable", 2)
+	versionParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "version": int64(2)}
+	stableParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "label": "stable"}
+	currentStable := modelID("testTeam", "testProject", "testName", "stable", 1)
+	prevStable := modelID("testTeam", "testProject", "testName", app.PrevStableLabel, 1)
+
+	signatures := func(dtype string) app.Signatures {
+		return app.Signatures{"serving_default": {Inputs: map[string]app.TensorInfo{"x": {DType: dtype, Shape: []int64{-1}}}}}
+	}
+	moved := func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+		mc.On("UpdateLabel", mock.Anything, newStable).Return(int64(1), nil)
+		mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: newStable, Status: app.StatusReady}).Return(nil)
+		mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: prevStable, Status: app.StatusReady}).Return(nil)
+	}
+	labelChanged := &app.LabelChanged{ServableID: newStable.ServableID, Label: "stable", PreviousVersion: 1, NewVersion: 2}
+
+	tests := []struct {
+		name       string
+		force      bool
+		mock       func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig)
+		want       *app.LabelChanged
+		wantDetail bool
+	}{
+		{
+			name: "Compatible version should become stable",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("Get", mock.Anything, versionParams).Return(&app.ModelData{ModelID: newStable}, nil)
+				mm.On("Get", mock.Anything, stableParams).Return(&app.ModelData{ModelID: currentStable}, nil)
+				mm.On("GetSignatures", mock.Anything, currentStable).Return(signatures("DT_FLOAT"), nil)
+				mm.On("GetSignatures", mock.Anything, newStable).Return(signatures("DT_FLOAT"), nil)
+				moved(mm, mc)
+			},
+			want: labelChanged,
+		},
+		{
+			name: "Incompatible version shouldn't become stable",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("Get", mock.Anything, versionParams).Return(&app.ModelData{ModelID: newStable}, nil)
+				mm.On("Get", mock.Anything, stableParams).Return(&app.ModelData{ModelID: currentStable}, nil)
+				mm.On("GetSignatures", mock.Anything, currentStable).Return(signatures("DT_FLOAT"), nil)
+				mm.On("GetSignatures", mock.Anything, newStable).Return(signatures("DT_STRING"), nil)
+			},
+			wantDetail: true,
+		},
+		{
+			name:  "Incompatible version should become stable when forced",
+			force: true,
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("Get", mock.Anything, versionParams).Return(&app.ModelData{ModelID: newStable}, nil)
+				moved(mm, mc)
+			},
+			want: labelChanged,
+		},
+		{
+			name: "Version without signatures should become stable",
+			mock: func(mm *mocks.ModelsMetadata, mc *mocks.ModelsConfig) {
+				mm.On("Get", mock.Anything, versionParams).Return(&app.ModelData{ModelID: newStable}, nil)
+				mm.On("Get", mock.Anything, stableParams).Return(&app.ModelData{ModelID: currentStable}, nil)
+				mm.On("GetSignatures", mock.Anything, currentStable).Return(signatures("DT_FLOAT"), nil)
+				mm.On("GetSignatures", mock.Anything, newStable).Return(nil, nil)
+				moved(mm, mc)
+			},
+			want: labelChanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := new(mocks.ModelsMetadata)
+			mc := new(mocks.ModelsConfig)
+			tt.mock(mm, mc)
+
+			s := &ModelsService{metadata: mm, servingConfig: mc}
+			got, err := s.SetLabel(context.Background(), newStable, tt.force)
+
+			var incompatible *incompatibleSignaturesError
+			if errors.As(err, &incompatible) != tt.wantDetail {
+				t.Fatalf("ModelsService.SetLabel() error = %v, wantDetail %v", err, tt.wantDetail)
+			}
+			if !tt.wantDetail && err != nil {
+				t.Fatalf("ModelsService.SetLabel() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ModelsService.SetLabel() = %v, want %v", got, tt.want)
+			}
+			mm.AssertExpectations(t)
+			mc.AssertExpectations(t)
+		})
+	}
+}
@@ -92,7 +92,7 @@ func (s *ModulesService) GetArchiveByVersion(ctx context.Context, id app.Servabl
 		return nil, err
 	}
 
-	return &app.Archive{Data: archive, Name: id.ArchiveName(s.archivePrefix(), version)}, nil
+	return &app.Archive{Content: archive, Name: id.ArchiveName(s.archivePrefix(), version), ETag: archiveETag(s.archivePrefix(), moduleMeta.ID, moduleMeta.Created)}, nil
 }
 
 func (s *ModulesService) RemoveByVersion(ctx context.Context, id app.ServableID, version int64) error {
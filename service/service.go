@@ -1,16 +1,27 @@
 package service
 
+import "fmt"
+
 type ModelsService struct {
 	metadata      ModelsMetadata
 	servingConfig ModelsConfig
 	servingReload ModelsReload
 	storage       ModelStorage
+	smokeTest     ModelsSmokeTest
+	// requireSmokeTest makes passing smoke test required
+	// before version of model with smoke test becomes stable
+	requireSmokeTest bool
 }
 
 func (s *ModelsService) archivePrefix() string {
 	return "model"
 }
 
+// archiveETag returns entity tag of archive of servable stored in metadata under given ID
+func archiveETag(prefix string, id int64, created string) string {
+	return fmt.Sprintf("%s-%d-%s", prefix, id, created)
+}
+
 // NewModelsService returns new instance of ModelsService
 func NewModelsService(meta ModelsMetadata, servingConfig ModelsConfig, servingReload ModelsReload, storage ModelStorage) *ModelsService {
 	return &ModelsService{
@@ -21,6 +32,14 @@ func NewModelsService(meta ModelsMetadata, servingConfig ModelsConfig, servingRe
 	}
 }
 
+// WithSmokeTests enables smoke tests of models, when required is set versions of models
+// with attached smoke test have to pass it before they become stable
+func (s *ModelsService) WithSmokeTests(smokeTest ModelsSmokeTest, required bool) *ModelsService {
+	s.smokeTest = smokeTest
+	s.requireSmokeTest = required
+	return s
+}
+
 type ModulesService struct {
 	metadata ModulesMetadata
 	storage  ModuleStorage
@@ -18,3 +18,8 @@ type ModelsConfig interface {
 type ModelsReload interface {
 	ReloadConfig(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error)
 }
+
+type ModelsSmokeTest interface {
+	ValidateSmokeTest(test *app.SmokeTest) error
+	RunSmokeTest(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error)
+}
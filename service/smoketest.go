@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+func (s *ModelsService) SetSmokeTest(ctx context.Context, id app.ServableID, test app.SmokeTest) error {
+	if s.smokeTest == nil {
+		return errorSmokeTestsDisabled
+	}
+
+	if err := s.smokeTest.ValidateSmokeTest(&test); err != nil {
+		logging.ErrorWithStack(ctx, err)
+		return err
+	}
+
+	if err := s.metadata.SetSmokeTest(ctx, id, test); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *ModelsService) SmokeTest(ctx context.Context, id app.ServableID) (*app.ModelSmokeTest, error) {
+	test, err := s.metadata.GetSmokeTest(ctx, id)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if test == nil {
+		logging.ErrorWithStack(ctx, errorSmokeTestNotFound)
+		return nil, errorSmokeTestNotFound
+	}
+
+	return &app.ModelSmokeTest{ServableID: id, SmokeTest: *test}, nil
+}
+
+func (s *ModelsService) DeleteSmokeTest(ctx context.Context, id app.ServableID) error {
+	if err := s.metadata.DeleteSmokeTest(ctx, id); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *ModelsService) RunSmokeTest(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error) {
+	if s.smokeTest == nil {
+		return nil, errorSmokeTestsDisabled
+	}
+
+	model, err := s.modelVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.smokeTest.RunSmokeTest(ctx, model)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if results == nil {
+		logging.ErrorWithStack(ctx, errorSmokeTestNotFound)
+		return nil, errorSmokeTestNotFound
+	}
+
+	return &app.ModelSmokeTestResults{ModelID: model, Passed: app.SmokeTestPassed(results), Results: results}, nil
+}
+
+func (s *ModelsService) SmokeTestResults(ctx context.Context, id app.ServableID, version int64) (*app.ModelSmokeTestResults, error) {
+	model, err := s.modelVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.metadata.GetSmokeTestResults(ctx, model)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return nil, err
+	}
+	if results == nil {
+		results = []app.SmokeTestResult{}
+	}
+
+	return &app.ModelSmokeTestResults{ModelID: model, Passed: app.SmokeTestPassed(results), Results: results}, nil
+}
+
+// modelVersion returns ID of existing model version
+func (s *ModelsService) modelVersion(ctx context.Context, id app.ServableID, version int64) (app.ModelID, error) {
+	params := app.QueryParameters{"team": id.Team, "project": id.Project, "name": id.Name, "version": version}
+	modelMeta, err := s.metadata.Get(ctx, params)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return app.ModelID{}, err
+	}
+	if modelMeta == nil {
+		logging.ErrorWithStack(ctx, errorModelNotFound)
+		return app.ModelID{}, errorModelNotFound
+	}
+
+	return app.ModelID{ServableID: id, Version: version}, nil
+}
+
+// checkSmokeTestPassed returns error when smoke test is attached to model
+// and it hasn't passed on every tested instance of TFS with model version
+func (s *ModelsService) checkSmokeTestPassed(ctx context.Context, model app.ModelID) error {
+	test, err := s.metadata.GetSmokeTest(ctx, model.ServableID)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if test == nil {
+		return nil
+	}
+
+	results, err := s.metadata.GetSmokeTestResults(ctx, model)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if !app.SmokeTestPassed(results) {
+		return errorSmokeTestNotPassed
+	}
+
+	return nil
+}
+
+// checkSmokeTestNotAttached returns error when smoke test is attached to model,
+// version which is just being uploaded can't have passed it yet
+func (s *ModelsService) checkSmokeTestNotAttached(ctx context.Context, id app.ServableID) error {
+	test, err := s.metadata.GetSmokeTest(ctx, id)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if test != nil {
+		return errorSmokeTestNotPassed
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/service/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestModelsService_SetSmokeTest(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	test := app.SmokeTest{Method: app.SmokeTestRegress}
+	errInvalid := errors.New("invalid smoke test")
+
+	tests := []struct {
+		name        string
+		validateErr error
+		wantErr     error
+	}{
+		{name: "Valid smoke test should be attached"},
+		{name: "Invalid smoke test shouldn't be attached", validateErr: errInvalid, wantErr: errInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := new(mocks.ModelsMetadata)
+			ms := new(mocks.ModelsSmokeTest)
+			ms.On("ValidateSmokeTest", &test).Return(tt.validateErr)
+			if tt.validateErr == nil {
+				mm.On("SetSmokeTest", mock.Anything, id, test).Return(nil)
+			}
+
+			s := (&ModelsService{metadata: mm}).WithSmokeTests(ms, false)
+			if err := s.SetSmokeTest(context.Background(), id, test); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ModelsService.SetSmokeTest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			mm.AssertExpectations(t)
+		})
+	}
+}
+
+func TestModelsService_checkSmokeTestPassed(t *testing.T) {
+	model := modelID("testTeam", "testProject", "testName", "stable", 2)
+
+	tests := []struct {
+		name    string
+		test    *app.SmokeTest
+		results []app.SmokeTestResult
+		wantErr error
+	}{
+		{name: "Model without smoke test should pass"},
+		{name: "Untested version shouldn't pass", test: &app.SmokeTest{}, wantErr: errorSmokeTestNotPassed},
+		{name: "Version failed on any instance shouldn't pass", test: &app.SmokeTest{}, results: []app.SmokeTestResult{{Instance: "a", Passed: true}, {Instance: "b"}}, wantErr: errorSmokeTestNotPassed},
+		{name: "Version passed on every instance should pass", test: &app.SmokeTest{}, results: []app.SmokeTestResult{{Instance: "a", Passed: true}, {Instance: "b", Passed: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := new(mocks.ModelsMetadata)
+			mm.On("GetSmokeTest", mock.Anything, model.ServableID).Return(tt.test, nil)
+			mm.On("GetSmokeTestResults", mock.Anything, model).Return(tt.results, nil)
+
+			s := &ModelsService{metadata: mm}
+			if err := s.checkSmokeTestPassed(context.Background(), model); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ModelsService.checkSmokeTestPassed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModelsService_UploadModel_requiredSmokeTest(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	errInvalid := errors.New("invalid model")
+
+	tests := []struct {
+		name         string
+		defaultLabel string
+		label        []string
+		force        bool
+		test         *app.SmokeTest
+		wantErr      error
+	}{
+		{name: "Model with smoke test shouldn't be uploaded with label stable", label: []string{"stable"}, test: &app.SmokeTest{}, wantErr: errorSmokeTestNotPassed},
+		{name: "Model with smoke test shouldn't be uploaded with label stable when forced", label: []string{"stable"}, force: true, test: &app.SmokeTest{}, wantErr: errorSmokeTestNotPassed},
+		{name: "Model with smoke test shouldn't be uploaded with default label stable", defaultLabel: "stable", test: &app.SmokeTest{}, wantErr: errorSmokeTestNotPassed},
+		{name: "Model without smoke test should be uploaded with label stable", label: []string{"stable"}, wantErr: errInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm, mc, ms := new(mocks.ModelsMetadata), new(mocks.ModelsConfig), new(mocks.ModelStorage)
+			mc.On("DefaultLabel").Return(tt.defaultLabel)
+			mm.On("GetSmokeTest", mock.Anything, id).Return(tt.test, nil)
+			if tt.test == nil {
+				ms.On("StageModel", mock.Anything, id, mock.Anything).Return(nil, errInvalid)
+			}
+
+			s := (&ModelsService{metadata: mm, servingConfig: mc, storage: ms}).WithSmokeTests(new(mocks.ModelsSmokeTest), true)
+			if _, err := s.UploadModel(context.Background(), id, strings.NewReader("archive"), tt.force, tt.label...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ModelsService.UploadModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			mm.AssertExpectations(t)
+			ms.AssertExpectations(t)
+		})
+	}
+}
+
+func TestModelsService_SetLabel_requiredSmokeTest(t *testing.T) {
+	id := app.ServableID{Team: "testTeam", Project: "testProject", Name: "testName"}
+	newStable := modelID("testTeam", "testProject", "testName", "stable", 2)
+	prevStable := modelID("testTeam", "testProject", "testName", app.PrevStableLabel, 1)
+	versionParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "version": int64(2)}
+	stableParams := app.QueryParameters{"team": "testTeam", "project": "testProject", "name": "testName", "label": "stable"}
+	passed := []app.SmokeTestResult{{Instance: "a", Passed: true}}
+	failed := []app.SmokeTestResult{{Instance: "a"}}
+
+	tests := []struct {
+		name     string
+		force    bool
+		rollback bool
+		results  []app.SmokeTestResult
+		want     *app.LabelChanged
+		wantErr  error
+	}{
+		{name: "Version which hasn't passed smoke test shouldn't become stable", results: failed, wantErr: errorSmokeTestNotPassed},
+		{name: "Version which hasn't passed smoke test shouldn't become stable when forced", force: true, results: failed, wantErr: errorSmokeTestNotPassed},
+		{name: "Rollback to version which hasn't passed smoke test shouldn't be forced", force: true, rollback: true, results: failed, wantErr: errorSmokeTestNotPassed},
+		{
+			name:    "Version which has passed smoke test should become stable when forced",
+			force:   true,
+			results: passed,
+			want:    &app.LabelChanged{ServableID: id, Label: "stable", PreviousVersion: 1, NewVersion: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm, mc := new(mocks.ModelsMetadata), new(mocks.ModelsConfig)
+			mm.On("Get", mock.Anything, versionParams).Return(&app.ModelData{ModelID: newStable}, nil)
+			if !tt.force {
+				mm.On("Get", mock.Anything, stableParams).Return(nil, nil)
+			}
+			mm.On("GetSmokeTest", mock.Anything, id).Return(&app.SmokeTest{}, nil)
+			mm.On("GetSmokeTestResults", mock.Anything, newStable).Return(tt.results, nil)
+			if tt.wantErr == nil {
+				mc.On("UpdateLabel", mock.Anything, newStable).Return(int64(1), nil)
+				mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: newStable, Status: app.StatusReady}).Return(nil)
+				mm.On("ChangeLabel", mock.Anything, app.ModelData{ModelID: prevStable, Status: app.StatusReady}).Return(nil)
+			}
+
+			s := (&ModelsService{metadata: mm, servingConfig: mc}).WithSmokeTests(new(mocks.ModelsSmokeTest), true)
+			var got *app.LabelChanged
+			var err error
+			if tt.rollback {
+				mm.On("GetLabelHistoryEntry", mock.Anything, id, "stable", int64(7)).Return(&app.LabelHistoryEntry{ServableID: id, Label: "stable", FromVersion: 1, ToVersion: 2}, nil)
+				got, err = s.RollbackLabel(context.Background(), id, "stable", 7, tt.force)
+			} else {
+				got, err = s.SetLabel(context.Background(), newStable, tt.force)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ModelsService.SetLabel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ModelsService.SetLabel() = %v, want %v", got, tt.want)
+			}
+			mm.AssertExpectations(t)
+			mc.AssertExpectations(t)
+		})
+	}
+}
@@ -9,15 +9,17 @@ import (
 )
 
 type ModelStorage interface {
-	ReadModel(ctx context.Context, modelID app.ServableID, version int) ([]byte, error)
-	ReadAllModels(ctx context.Context, modelID app.ServableID) ([]byte, error)
+	ReadModel(ctx context.Context, modelID app.ServableID, version int) (app.ArchiveContent, error)
+	ReadAllModels(ctx context.Context, modelID app.ServableID) (app.ArchiveContent, error)
 
-	SaveModel(ctx context.Context, modelID app.ServableID, version int, archive io.Reader) (*storage.SaveModelResponse, error)
+	StageModel(ctx context.Context, modelID app.ServableID, archive io.Reader) (*storage.StagedModel, error)
+	SaveStagedModel(ctx context.Context, staged *storage.StagedModel, version int) (*storage.SaveModelResponse, error)
+	DiscardStagedModel(staged *storage.StagedModel) error
 	RemoveModel(ctx context.Context, id app.ServableID, version int64) error
 }
 
 type ModuleStorage interface {
-	ReadModule(ctx context.Context, moduleID app.ServableID, version int) ([]byte, error)
+	ReadModule(ctx context.Context, moduleID app.ServableID, version int) (app.ArchiveContent, error)
 	SaveModule(ctx context.Context, moduleID app.ServableID, version int, archive io.Reader) error
 	RemoveModule(ctx context.Context, id app.ServableID, version int64) error
 }
@@ -17,15 +17,20 @@ import (
 	tfsStoragePath "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/sources/storage_path"
 )
 
+// DefaultModelsBasePath is a location of models seen by TFS instances
+// which mount filesystem storage
+const DefaultModelsBasePath = "/models"
+
 type ServableConfig struct {
 	m            *sync.RWMutex
 	storage      ConfigStorage
 	defaultLabel string
+	basePath     string
 }
 
 // NewServableConfig returns new instance of ServableConfig
-func NewServableConfig(configStorage ConfigStorage, defaultLabel string) (*ServableConfig, error) {
-	return &ServableConfig{m: &sync.RWMutex{}, storage: configStorage, defaultLabel: defaultLabel}, nil
+func NewServableConfig(configStorage ConfigStorage, defaultLabel, basePath string) (*ServableConfig, error) {
+	return &ServableConfig{m: &sync.RWMutex{}, storage: configStorage, defaultLabel: defaultLabel, basePath: basePath}, nil
 }
 
 func (sc *ServableConfig) DefaultLabel() string {
@@ -121,19 +126,9 @@ func (sc *ServableConfig) AddModel(ctx context.Context, id app.ModelID) error {
 	}
 
 	// config not found, create new element
-	config := &tfsConfig.ModelConfig{
-		Name:          id.Name,
-		BasePath:      fmt.Sprintf("/models/%s/%s/%s", id.Team, id.Project, id.Name),
-		ModelPlatform: "tensorflow",
-		ModelVersionPolicy: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy{
-			PolicyChoice: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy_Specific_{
-				Specific: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy_Specific{
-					Versions: []int64{1},
-				},
-			},
-		},
-		VersionLabels: map[string]int64{id.Label: id.Version},
-	}
+	config := sc.NewModelConfig(id.ServableID)
+	config.GetModelVersionPolicy().GetSpecific().Versions = []int64{1}
+	config.VersionLabels = map[string]int64{id.Label: id.Version}
 
 	configs = append(configs, config)
 	msc.GetModelConfigList().Config = configs
@@ -146,6 +141,23 @@ func (sc *ServableConfig) AddModel(ctx context.Context, id app.ModelID) error {
 	return nil
 }
 
+// NewModelConfig returns config of model without any version
+func (sc *ServableConfig) NewModelConfig(id app.ServableID) *tfsConfig.ModelConfig {
+	return &tfsConfig.ModelConfig{
+		Name:          id.Name,
+		BasePath:      fmt.Sprintf("%s/%s/%s/%s", sc.basePath, id.Team, id.Project, id.Name),
+		ModelPlatform: "tensorflow",
+		ModelVersionPolicy: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy{
+			PolicyChoice: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy_Specific_{
+				Specific: &tfsStoragePath.FileSystemStoragePathSourceConfig_ServableVersionPolicy_Specific{
+					Versions: []int64{},
+				},
+			},
+		},
+		VersionLabels: map[string]int64{},
+	}
+}
+
 // UpdateLabel sets specific label for model version identified by ModelID
 func (sc *ServableConfig) UpdateLabel(ctx context.Context, id app.ModelID) (int64, error) {
 	msc, err := sc.Config(ctx, id.Team, id.Project)
@@ -261,6 +273,11 @@ func (sc *ServableConfig) RemoveModelLabel(ctx context.Context, id app.ModelID)
 	return nil
 }
 
+// SaveConfig saves given ModelServerConfig of team and project
+func (sc *ServableConfig) SaveConfig(ctx context.Context, team, project string, msc *tfsConfig.ModelServerConfig) error {
+	return sc.saveModel(ctx, msc, team, project)
+}
+
 // saveModel marshals ModelServerConfig struct into models.config file
 func (sc *ServableConfig) saveModel(ctx context.Context, msc *tfsConfig.ModelServerConfig, team, project string) error {
 	data, err := proto.Marshal(msc)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grupawp/tensorflow-deploy/exterr"
@@ -16,6 +17,7 @@ import (
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/lock"
 	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer/roundrobin"
 )
@@ -36,6 +38,8 @@ var (
 	infoNumberInvalidServableInstancesToReload = "number invalid servable instances"
 	infoReloadInstancesJob                     = "reload instances job start"
 	infoReloadInstancesJobEnd                  = "reload instances job end"
+	infoReloadInstancesJobStop                 = "reload instances job stop"
+	infoInstancesChanged                       = "instances changed"
 	infoAutoReloadEnd                          = "auto-reload end"
 	infoReloadConfigInstances                  = "reload config instances"
 	infoConfig                                 = "config doesn't exist for team project"
@@ -71,6 +75,12 @@ type Discoverer interface {
 	Discover(ctx context.Context, model app.ServableID) ([]string, error)
 }
 
+// Watcher is implemented by discoverers which are able to notify about changes of instances,
+// Watch blocks until ctx is done and sends to changed whenever instances may have changed
+type Watcher interface {
+	Watch(ctx context.Context, changed chan<- struct{})
+}
+
 type ServableConfigurer interface {
 	Config(ctx context.Context, team, project string) (*tfsConfig.ModelServerConfig, error)
 	ConfigWithoutLabels(ctx context.Context, team, project string) (*tfsConfig.ModelServerConfig, error)
@@ -86,6 +96,11 @@ type ModelsReloader struct {
 	modelsMetadata                  ModelsMetadata
 	lastStateInstancesOfModel       map[string]app.ServableInstances
 	allowLabelsForUnavailableModels bool
+	smokeTester                     *SmokeTester
+	watcher                         Watcher
+	// autoReloads tracks running auto-reloads, they may outlive
+	// ReloadInstancesIfIsNecessary when max duration is exceeded
+	autoReloads sync.WaitGroup
 }
 
 // NewModelsReloader returns new instance of ModelsReloader
@@ -93,6 +108,20 @@ func NewModelsReloader(serviceDiscovery Discoverer, modelMetadata ModelsMetadata
 	return &ModelsReloader{serviceDiscovery: serviceDiscovery, modelsMetadata: modelMetadata, servableConfigurer: modelsConfig, lock: lock, reloadInterval: reloadInterval, maxDurationAutoReload: maxDurationAutoReload, allowLabelsForUnavailableModels: allowLabelsForUnavailableModels}
 }
 
+// WithSmokeTests makes reloader send smoke tests to reloaded instances
+// which haven't been tested with loaded versions of models yet
+func (r *ModelsReloader) WithSmokeTests(smokeTester *SmokeTester) *ModelsReloader {
+	r.smokeTester = smokeTester
+	return r
+}
+
+// WithWatcher makes reload instances job run as soon as watcher notifies
+// about changes of instances instead of waiting for reload interval
+func (r *ModelsReloader) WithWatcher(watcher Watcher) *ModelsReloader {
+	r.watcher = watcher
+	return r
+}
+
 // ReloadConfig  reloads all instances
 func (r *ModelsReloader) ReloadConfig(ctx context.Context, team, project string, skipConfigWithoutLabels bool) ([]app.ReloadResponse, error) {
 	if _, err := r.reloadConfig(ctx, app.ServableID{Team: team, Project: project}, r.allowLabelsForUnavailableModels && skipConfigWithoutLabels); err != nil {
@@ -101,13 +130,46 @@ func (r *ModelsReloader) ReloadConfig(ctx context.Context, team, project string,
 	return nil, nil
 }
 
-// ReloadInstancesJob reloads TFS instances
+// ModelAvailability discovers instances of TFS serving model and returns
+// these of them on which model version isn't AVAILABLE
+func (r *ModelsReloader) ModelAvailability(ctx context.Context, model app.ModelID) (instances, unavailable []string, err error) {
+	instances, err = r.serviceDiscovery.Discover(ctx, model.ServableID)
+	if err != nil {
+		return nil, nil, exterr.WrapWithFrame(err)
+	}
+
+	return instances, r.invalidInstancesModel(ctx, model.Name, model.Version, instances), nil
+}
+
+// ReloadInstancesJob reloads TFS instances every reload interval or when watcher notifies
+// about changes until ctx is done, it returns after running auto-reloads are finished
 func (r *ModelsReloader) ReloadInstancesJob(ctx context.Context) {
+	defer r.autoReloads.Wait()
+
+	// changes noticed during reload are coalesced into single pending run
+	changed := make(chan struct{}, 1)
+	if r.watcher != nil {
+		watcherDone := make(chan struct{})
+		defer func() { <-watcherDone }()
+		go func() {
+			defer close(watcherDone)
+			r.watcher.Watch(ctx, changed)
+		}()
+	}
+
 	for {
 		logging.Info(ctx, infoReloadInstancesJob)
 		r.ReloadInstancesIfIsNecessary(ctx)
 		logging.Info(ctx, infoReloadInstancesJobEnd)
-		time.Sleep(time.Duration(r.reloadInterval) * time.Second)
+
+		select {
+		case <-ctx.Done():
+			logging.Info(ctx, infoReloadInstancesJobStop)
+			return
+		case <-changed:
+			logging.Info(ctx, infoInstancesChanged)
+		case <-time.After(time.Duration(r.reloadInterval) * time.Second):
+		}
 	}
 }
 
@@ -120,14 +182,23 @@ func (r *ModelsReloader) ReloadInstancesIfIsNecessary(ctx context.Context) {
 	}
 	defer r.lock.UnLockID(autoReloadLockID)
 
-	go r.reloadInstancesIfIsNecessary(ctx)
+	r.autoReloads.Add(1)
+	go func() {
+		defer r.autoReloads.Done()
+		r.reloadInstancesIfIsNecessary(ctx)
+	}()
 
 	for counter := 0; counter <= r.maxDurationAutoReload; counter++ {
 		if !r.lock.IsLockedID(autoReloadLockID) {
 			logging.Info(ctx, fmt.Sprintf("%s %d", infoSkipUnlockAutoReloadAction, counter))
 			return
 		}
-		time.Sleep(oneUnit * time.Second)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(oneUnit * time.Second):
+		}
 	}
 	logging.Info(ctx, fmt.Sprintf("%s", infoUnlockAutoReloadAction))
 	return
@@ -136,6 +207,13 @@ func (r *ModelsReloader) ReloadInstancesIfIsNecessary(ctx context.Context) {
 func (r *ModelsReloader) reloadInstancesIfIsNecessary(ctx context.Context) {
 	defer r.lock.UnLockID(autoReloadLockID)
 
+	start := time.Now()
+	reloaded := 0
+	defer func() {
+		metrics.AutoReloadDuration.Observe(time.Since(start).Seconds())
+		metrics.AutoReloadServables.Observe(float64(reloaded))
+	}()
+
 	models, err := r.modelsMetadata.ListUniqueTeamProject(ctx)
 	if err != nil {
 		logging.ErrorWithStackWithoutRequestID(ctx, exterr.WrapWithFrame(err))
@@ -166,7 +244,9 @@ func (r *ModelsReloader) reloadInstancesIfIsNecessary(ctx context.Context) {
 				r.removeServableInstance(v)
 				logging.ErrorWithStackWithoutRequestID(ctx, err)
 			}
+			continue
 		}
+		reloaded++
 	}
 	logging.Info(ctx, fmt.Sprintf("%s", infoAutoReloadEnd))
 }
@@ -272,6 +352,7 @@ func (r *ModelsReloader) reloadTFSInstances(ctx context.Context, config *tfsConf
 			wrappedDialError := exterr.WrapWithFrame(err)
 			reloaderErrors = append(reloaderErrors, wrappedDialError)
 			logging.Error(ctx, fmt.Sprintf("%s %v", instance, wrappedDialError), logDialErrorCode)
+			metrics.ReloadInstanceTotal.Inc(instance, metrics.ResultFailure)
 			continue
 		}
 
@@ -280,6 +361,7 @@ func (r *ModelsReloader) reloadTFSInstances(ctx context.Context, config *tfsConf
 			wrappedReloadConfigRequestError := exterr.WrapWithFrame(err)
 			reloaderErrors = append(reloaderErrors, wrappedReloadConfigRequestError)
 			logging.Error(ctx, fmt.Sprintf("%s %v", instance, wrappedReloadConfigRequestError), logReloadConfigRequestErrorCode)
+			metrics.ReloadInstanceTotal.Inc(instance, metrics.ResultFailure)
 			conn.Close()
 			continue
 		}
@@ -289,10 +371,12 @@ func (r *ModelsReloader) reloadTFSInstances(ctx context.Context, config *tfsConf
 				WithComponent(app.ComponentServing).WithCode(logResponseStatusErrorCode)
 			reloaderErrors = append(reloaderErrors, errResponseStatus)
 			logging.Error(ctx, fmt.Sprintf("%s %v", instance, errResponseStatus), strconv.Itoa(logResponseStatusErrorCode))
+			metrics.ReloadInstanceTotal.Inc(instance, metrics.ResultFailure)
 			conn.Close()
 			continue
 		}
 		conn.Close()
+		metrics.ReloadInstanceTotal.Inc(instance, metrics.ResultSuccess)
 		validInstances = append(validInstances, instance)
 	}
 
@@ -300,8 +384,11 @@ func (r *ModelsReloader) reloadTFSInstances(ctx context.Context, config *tfsConf
 }
 
 // ReloadConfig  reloads all instances
-func (r *ModelsReloader) reloadConfig(ctx context.Context, id app.ServableID, labelsOnly bool, instances ...string) (*[]string, error) {
-	var err error
+func (r *ModelsReloader) reloadConfig(ctx context.Context, id app.ServableID, labelsOnly bool, instances ...string) (_ *[]string, err error) {
+	defer func() {
+		metrics.ReloadServableTotal.Inc(id.Team, id.Project, metrics.Result(err))
+	}()
+
 	var retErrors []error
 	if len(instances) == 0 {
 		instances, err = r.serviceDiscovery.Discover(ctx, id)
@@ -355,6 +442,7 @@ func (r *ModelsReloader) reloadConfig(ctx context.Context, id app.ServableID, la
 
 	if len(retErrors) == 0 {
 		logging.Info(ctx, fmt.Sprintf("%s", infoReloadSuccess))
+		r.runSmokeTests(ctx, id, config, instances)
 		return nil, nil
 	}
 	var instanceErrorList []string
@@ -365,6 +453,24 @@ func (r *ModelsReloader) reloadConfig(ctx context.Context, id app.ServableID, la
 	return &instanceErrorList, exterr.WrapWithFrame(fmt.Errorf("%v", retErrors))
 }
 
+// runSmokeTests sends smoke tests of models from config to reloaded instances, failures
+// are only logged and recorded, they don't fail reload
+func (r *ModelsReloader) runSmokeTests(ctx context.Context, id app.ServableID, config *tfsConfig.ModelServerConfig, instances []string) {
+	if r.smokeTester == nil || len(instances) == 0 {
+		return
+	}
+
+	models, err := r.servableConfigurer.Models(ctx, id.Team, id.Project, config)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return
+	}
+
+	if err := r.smokeTester.runUntested(ctx, models, instances); err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+	}
+}
+
 func (r *ModelsReloader) invalidInstancesToReload(ctx context.Context, instances *[]app.ServableInstances, invalidInstances *[]app.ServableInstances) *[]app.ServableInstances {
 	result := &[]app.ServableInstances{}
 	if len(*invalidInstances) == 0 {
@@ -446,8 +552,8 @@ func (r *ModelsReloader) invalidInstancesModel(ctx context.Context, name string,
 		}
 
 		if resp != nil {
-			modelStatus := resp.GetModelVersionStatus()[0].GetState()
-			if modelStatus != tfsApis.ModelVersionStatus_AVAILABLE {
+			statuses := resp.GetModelVersionStatus()
+			if len(statuses) == 0 || statuses[0].GetState() != tfsApis.ModelVersionStatus_AVAILABLE {
 				invalidInstances = append(invalidInstances, instance)
 				conn.Close()
 				continue
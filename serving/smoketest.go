@@ -0,0 +1,263 @@
+package serving
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+	"github.com/grupawp/tensorflow-deploy/metrics"
+	"github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow/core/example"
+	tfsApis "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/apis"
+)
+
+// defaultSmokeTestRetryBackoff is time after which failed smoke test is sent again
+// to reloaded instance, so transient failures right after reload don't block promotion
+const defaultSmokeTestRetryBackoff = time.Minute
+
+var (
+	logInvalidSmokeTestErrorCode = 1007
+
+	infoSmokeTestPassed = "smoke test passed"
+	infoSmokeTestFailed = "smoke test failed"
+)
+
+// SmokeTestMetadata keeps smoke tests attached to models and their results
+type SmokeTestMetadata interface {
+	GetSmokeTest(ctx context.Context, id app.ServableID) (*app.SmokeTest, error)
+	GetSmokeTestResults(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error)
+	AddSmokeTestResults(ctx context.Context, model app.ModelID, results []app.SmokeTestResult) error
+}
+
+// SmokeTester sends smoke tests attached to models to instances of TFS
+// via PredictionService and records their results
+type SmokeTester struct {
+	serviceDiscovery Discoverer
+	metadata         SmokeTestMetadata
+	timeout          time.Duration
+	retryBackoff     time.Duration
+}
+
+// smokeTestCall sends request of smoke test with given client
+type smokeTestCall func(ctx context.Context, client tfsApis.PredictionServiceClient) error
+
+// NewSmokeTester returns new instance of SmokeTester, every request
+// is cancelled when it doesn't finish within timeout
+func NewSmokeTester(serviceDiscovery Discoverer, metadata SmokeTestMetadata, timeout time.Duration) *SmokeTester {
+	return &SmokeTester{serviceDiscovery: serviceDiscovery, metadata: metadata, timeout: timeout, retryBackoff: defaultSmokeTestRetryBackoff}
+}
+
+// ValidateSmokeTest checks whether smoke test can be sent to PredictionService
+func (t *SmokeTester) ValidateSmokeTest(test *app.SmokeTest) error {
+	_, err := smokeTestRequest(test, app.ModelID{})
+	return err
+}
+
+// RunSmokeTest sends smoke test attached to model to every discovered instance of TFS and
+// records results. Nil is returned when smoke test hasn't been attached to model
+func (t *SmokeTester) RunSmokeTest(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error) {
+	test, err := t.metadata.GetSmokeTest(ctx, model.ServableID)
+	if err != nil || test == nil {
+		return nil, err
+	}
+
+	instances, err := t.serviceDiscovery.Discover(ctx, model.ServableID)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return t.run(ctx, model, test, instances)
+}
+
+// runUntested sends smoke tests to instances which haven't passed them with versions
+// of models yet, it's called once reloaded instances have loaded these versions.
+// Failed smoke tests are sent again once retry backoff has elapsed
+func (t *SmokeTester) runUntested(ctx context.Context, models []app.ModelID, instances []string) error {
+	tests := make(map[string]*app.SmokeTest)
+	for _, model := range models {
+		test, ok := tests[model.Name]
+		if !ok {
+			var err error
+			if test, err = t.metadata.GetSmokeTest(ctx, model.ServableID); err != nil {
+				return err
+			}
+			tests[model.Name] = test
+		}
+		if test == nil {
+			continue
+		}
+
+		results, err := t.metadata.GetSmokeTestResults(ctx, model)
+		if err != nil {
+			return err
+		}
+		retryBefore := time.Now().Add(-t.retryBackoff).Unix()
+		tested := make(map[string]bool, len(results))
+		for _, result := range results {
+			if result.Passed {
+				tested[result.Instance] = true
+				continue
+			}
+			if created, err := strconv.ParseInt(result.Created, 10, 64); err == nil && created > retryBefore {
+				tested[result.Instance] = true
+			}
+		}
+
+		var untested []string
+		for _, instance := range instances {
+			if !tested[instance] {
+				untested = append(untested, instance)
+			}
+		}
+		if len(untested) == 0 {
+			continue
+		}
+
+		if _, err := t.run(ctx, model, test, untested); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *SmokeTester) run(ctx context.Context, model app.ModelID, test *app.SmokeTest, instances []string) ([]app.SmokeTestResult, error) {
+	call, err := smokeTestRequest(test, model)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]app.SmokeTestResult, 0, len(instances))
+	for _, instance := range instances {
+		result := t.runOnInstance(ctx, call, instance)
+		if result.Passed {
+			logging.Info(ctx, fmt.Sprintf("%s %s %d %s %dms", infoSmokeTestPassed, model.InstanceName(), model.Version, instance, result.LatencyMs))
+		} else {
+			logging.Info(ctx, fmt.Sprintf("%s %s %d %s %s", infoSmokeTestFailed, model.InstanceName(), model.Version, instance, result.Error))
+		}
+		resultLabel := metrics.ResultSuccess
+		if !result.Passed {
+			resultLabel = metrics.ResultFailure
+		}
+		metrics.SmokeTestTotal.Inc(instance, resultLabel)
+		metrics.SmokeTestDuration.Observe(float64(result.LatencyMs)/1000, resultLabel)
+		results = append(results, result)
+	}
+
+	if err := t.metadata.AddSmokeTestResults(ctx, model, results); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return results, nil
+}
+
+func (t *SmokeTester) runOnInstance(ctx context.Context, call smokeTestCall, instance string) app.SmokeTestResult {
+	result := app.SmokeTestResult{Instance: instance, Created: strconv.FormatInt(time.Now().Unix(), 10)}
+
+	conn, err := grpc.Dial(fmt.Sprintf("dns:///%s", instance), grpc.WithInsecure(), grpc.WithBalancerName(roundrobin.Name))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err = call(ctx, tfsApis.NewPredictionServiceClient(conn))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Passed = true
+
+	return result
+}
+
+// smokeTestRequest builds request of smoke test addressed to model version
+func smokeTestRequest(test *app.SmokeTest, model app.ModelID) (smokeTestCall, error) {
+	spec := &tfsApis.ModelSpec{
+		Name:          model.Name,
+		VersionChoice: &tfsApis.ModelSpec_Version{Version: &wrappers.Int64Value{Value: model.Version}},
+		SignatureName: test.SignatureName,
+	}
+
+	switch test.Method {
+	case app.SmokeTestPredict:
+		if len(test.Examples) != 0 {
+			return nil, invalidSmokeTestError("examples can't be sent by predict method")
+		}
+		request := &tfsApis.PredictRequest{}
+		if err := unmarshalSmokeTestJSON(test.Request, request); err != nil {
+			return nil, invalidSmokeTestError(fmt.Sprintf("request isn't valid PredictRequest: %v", err))
+		}
+		if len(request.GetInputs()) == 0 {
+			return nil, invalidSmokeTestError("request doesn't contain inputs")
+		}
+		if spec.SignatureName == "" {
+			spec.SignatureName = request.GetModelSpec().GetSignatureName()
+		}
+		request.ModelSpec = spec
+
+		return func(ctx context.Context, client tfsApis.PredictionServiceClient) error {
+			_, err := client.Predict(ctx, request)
+			return err
+		}, nil
+
+	case app.SmokeTestClassify, app.SmokeTestRegress:
+		if len(test.Request) != 0 {
+			return nil, invalidSmokeTestError("request can be sent only by predict method, " + test.Method + " sends examples")
+		}
+		if len(test.Examples) == 0 {
+			return nil, invalidSmokeTestError("examples haven't been provided")
+		}
+		examples := make([]*example.Example, 0, len(test.Examples))
+		for i, raw := range test.Examples {
+			row := &example.Example{}
+			if err := unmarshalSmokeTestJSON(raw, row); err != nil {
+				return nil, invalidSmokeTestError(fmt.Sprintf("example %d isn't valid tf.Example: %v", i, err))
+			}
+			examples = append(examples, row)
+		}
+		input := &tfsApis.Input{Kind: &tfsApis.Input_ExampleList{ExampleList: &tfsApis.ExampleList{Examples: examples}}}
+
+		if test.Method == app.SmokeTestClassify {
+			request := &tfsApis.ClassificationRequest{ModelSpec: spec, Input: input}
+			return func(ctx context.Context, client tfsApis.PredictionServiceClient) error {
+				_, err := client.Classify(ctx, request)
+				return err
+			}, nil
+		}
+		request := &tfsApis.RegressionRequest{ModelSpec: spec, Input: input}
+		return func(ctx context.Context, client tfsApis.PredictionServiceClient) error {
+			_, err := client.Regress(ctx, request)
+			return err
+		}, nil
+	}
+
+	return nil, invalidSmokeTestError(fmt.Sprintf("unsupported method %q, use %s, %s or %s", test.Method, app.SmokeTestPredict, app.SmokeTestClassify, app.SmokeTestRegress))
+}
+
+func unmarshalSmokeTestJSON(raw []byte, pb proto.Message) error {
+	if len(raw) == 0 {
+		return errors.New("it's empty")
+	}
+	return jsonpb.Unmarshal(bytes.NewReader(raw), pb)
+}
+
+func invalidSmokeTestError(msg string) error {
+	return exterr.NewErrorWithMessage("invalid smoke test: " + msg).WithComponent(app.ComponentServing).WithCode(logInvalidSmokeTestErrorCode)
+}
@@ -0,0 +1,168 @@
+package serving
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+	tfsApis "github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow_serving/apis"
+)
+
+func Test_smokeTestRequest(t *testing.T) {
+	predictRequest := json.RawMessage(`{"model_spec":{"signature_name":"predict_images"},"inputs":{"images":{"dtype":"DT_FLOAT","tensor_shape":{"dim":[{"size":"1"}]},"float_val":[0.5]}}}`)
+	example := json.RawMessage(`{"features":{"feature":{"age":{"int64_list":{"value":["42"]}}}}}`)
+
+	tests := []struct {
+		name    string
+		test    app.SmokeTest
+		wantErr bool
+	}{
+		{name: "Predict request should be accepted", test: app.SmokeTest{Method: app.SmokeTestPredict, Request: predictRequest}},
+		{name: "Classify examples should be accepted", test: app.SmokeTest{Method: app.SmokeTestClassify, Examples: []json.RawMessage{example, example}}},
+		{name: "Regress examples should be accepted", test: app.SmokeTest{Method: app.SmokeTestRegress, Examples: []json.RawMessage{example}}},
+		{name: "Unknown method should be rejected", test: app.SmokeTest{Method: "multi_inference", Request: predictRequest}, wantErr: true},
+		{name: "Predict request without inputs should be rejected", test: app.SmokeTest{Method: app.SmokeTestPredict, Request: json.RawMessage(`{}`)}, wantErr: true},
+		{name: "Predict request with unknown fields should be rejected", test: app.SmokeTest{Method: app.SmokeTestPredict, Request: json.RawMessage(`{"input":{}}`)}, wantErr: true},
+		{name: "Examples sent by predict should be rejected", test: app.SmokeTest{Method: app.SmokeTestPredict, Request: predictRequest, Examples: []json.RawMessage{example}}, wantErr: true},
+		{name: "Classify without examples should be rejected", test: app.SmokeTest{Method: app.SmokeTestClassify}, wantErr: true},
+		{name: "Invalid example should be rejected", test: app.SmokeTest{Method: app.SmokeTestRegress, Examples: []json.RawMessage{json.RawMessage(`{"features":[]}`)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := smokeTestRequest(&tt.test, app.ModelID{}); (err != nil) != tt.wantErr {
+				t.Errorf("smokeTestRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type testPredictionServer struct {
+	tfsApis.PredictionServiceServer
+	requests chan *tfsApis.PredictRequest
+	// failures is number of next requests which fail regardless of version
+	failures int
+}
+
+func (s *testPredictionServer) Predict(ctx context.Context, request *tfsApis.PredictRequest) (*tfsApis.PredictResponse, error) {
+	s.requests <- request
+	if s.failures > 0 {
+		s.failures--
+		return nil, errors.New("model is warming up")
+	}
+	if request.GetModelSpec().GetVersion().GetValue() != 3 {
+		return nil, errors.New("version not loaded")
+	}
+	return &tfsApis.PredictResponse{}, nil
+}
+
+type testSmokeTestMetadata struct {
+	test    *app.SmokeTest
+	results map[int64][]app.SmokeTestResult
+}
+
+func (m *testSmokeTestMetadata) GetSmokeTest(ctx context.Context, id app.ServableID) (*app.SmokeTest, error) {
+	return m.test, nil
+}
+
+func (m *testSmokeTestMetadata) GetSmokeTestResults(ctx context.Context, model app.ModelID) ([]app.SmokeTestResult, error) {
+	return m.results[model.Version], nil
+}
+
+func (m *testSmokeTestMetadata) AddSmokeTestResults(ctx context.Context, model app.ModelID, results []app.SmokeTestResult) error {
+	// results recorded before for the same instances are replaced
+	for _, result := range results {
+		replaced := false
+		for i := range m.results[model.Version] {
+			if m.results[model.Version][i].Instance == result.Instance {
+				m.results[model.Version][i] = result
+				replaced = true
+			}
+		}
+		if !replaced {
+			m.results[model.Version] = append(m.results[model.Version], result)
+		}
+	}
+	return nil
+}
+
+func newTestPredictionServer(t *testing.T) (*testPredictionServer, string) {
+	predictions := &testPredictionServer{requests: make(chan *tfsApis.PredictRequest, 10)}
+	address := testutil.ServeGRPC(t, func(server *grpc.Server) {
+		tfsApis.RegisterPredictionServiceServer(server, predictions)
+	})
+
+	return predictions, address
+}
+
+var testSmokeTest = &app.SmokeTest{Method: app.SmokeTestPredict, Request: json.RawMessage(`{"inputs":{"x":{"dtype":"DT_FLOAT","float_val":[1]}}}`)}
+
+func TestSmokeTester_runUntested(t *testing.T) {
+	predictions, instance := newTestPredictionServer(t)
+
+	metadata := &testSmokeTestMetadata{
+		test:    testSmokeTest,
+		results: map[int64][]app.SmokeTestResult{2: {{Instance: instance, Passed: true}}},
+	}
+	tester := NewSmokeTester(nil, metadata, 5*time.Second)
+
+	id := app.ServableID{Team: "team", Project: "project", Name: "name"}
+	models := []app.ModelID{{ServableID: id, Version: 2}, {ServableID: id, Version: 3}, {ServableID: id, Version: 4}}
+	if err := tester.runUntested(context.Background(), models, []string{instance}); err != nil {
+		t.Fatalf("SmokeTester.runUntested() error = %v", err)
+	}
+
+	// version 2 has been tested already
+	if len(predictions.requests) != 2 {
+		t.Fatalf("SmokeTester.runUntested() sent %d requests, want 2", len(predictions.requests))
+	}
+	if request := <-predictions.requests; request.GetModelSpec().GetName() != "name" {
+		t.Errorf("SmokeTester.runUntested() sent request to model %q, want name", request.GetModelSpec().GetName())
+	}
+	if got := metadata.results[3]; len(got) != 1 || !got[0].Passed {
+		t.Errorf("SmokeTester.runUntested() results of loaded version = %+v, want passed", got)
+	}
+	if got := metadata.results[4]; len(got) != 1 || got[0].Passed || got[0].Error == "" {
+		t.Errorf("SmokeTester.runUntested() results of missing version = %+v, want failed", got)
+	}
+}
+
+func TestSmokeTester_runUntested_retryFailed(t *testing.T) {
+	predictions, instance := newTestPredictionServer(t)
+	predictions.failures = 1
+
+	metadata := &testSmokeTestMetadata{test: testSmokeTest, results: map[int64][]app.SmokeTestResult{}}
+	tester := NewSmokeTester(nil, metadata, 5*time.Second)
+	models := []app.ModelID{{ServableID: app.ServableID{Team: "team", Project: "project", Name: "name"}, Version: 3}}
+
+	run := func() {
+		if err := tester.runUntested(context.Background(), models, []string{instance}); err != nil {
+			t.Fatalf("SmokeTester.runUntested() error = %v", err)
+		}
+	}
+
+	run()
+	if got := metadata.results[3]; len(got) != 1 || got[0].Passed {
+		t.Fatalf("SmokeTester.runUntested() results after transient failure = %+v, want failed", got)
+	}
+
+	// failed smoke test isn't sent again before retry backoff has elapsed
+	run()
+	if len(predictions.requests) != 1 {
+		t.Fatalf("SmokeTester.runUntested() sent %d requests within retry backoff, want 1", len(predictions.requests))
+	}
+
+	tester.retryBackoff = -time.Second
+	run()
+	if len(predictions.requests) != 2 {
+		t.Fatalf("SmokeTester.runUntested() sent %d requests after retry backoff, want 2", len(predictions.requests))
+	}
+	if got := metadata.results[3]; len(got) != 1 || !got[0].Passed {
+		t.Errorf("SmokeTester.runUntested() results after retry = %+v, want passed", got)
+	}
+}
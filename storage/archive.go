@@ -2,18 +2,30 @@ package storage
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
+	"sort"
+	"strings"
 
+	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/klauspost/compress/zstd"
 )
 
-// Archiver contains methods required while creating/saving an archive
+// Archiver contains methods required while creating/saving an archive.
+// Entries passed to SaveArchiveFile have relative paths without parent
+// directory segments, links and special files are rejected beforehand
 type Archiver interface {
-	GetFileContent(ctx context.Context, source string) ([]byte, error)
-	SaveArchiveFile(ctx context.Context, header *tar.Header, archive *tar.Reader, archivePath string) error
+	OpenFileContent(ctx context.Context, source string, offset int64) (io.ReadCloser, error)
+	SaveArchiveFile(ctx context.Context, header *tar.Header, content io.Reader, archivePath string) error
 }
 
 type ArchiveHeader struct {
@@ -21,42 +33,236 @@ type ArchiveHeader struct {
 	ContentPath string
 }
 
-func createArchive(ctx context.Context, headers []ArchiveHeader, storage Archiver) ([]byte, error) {
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
+const (
+	tarBlockSize = 512
+	// tarTrailerSize is a size of two zero blocks which end tar archive
+	tarTrailerSize = 2 * tarBlockSize
+)
+
+var (
+	zeroBlocks = make([]byte, tarTrailerSize)
+
+	logStorageArchiveContentChangedCode = 1011
+	logStorageArchivePathTraversalCode  = 1012
+	logStorageArchiveLinkCode           = 1013
+	logStorageArchiveEntryTypeCode      = 1014
+	logStorageArchiveTooLargeCode       = 1015
+	logStorageArchiveTooManyFilesCode   = 1016
+	logStorageArchiveFileTooLargeCode   = 1017
+
+	errArchiveContentChanged = exterr.NewErrorWithMessage("archive content size doesn't match its header").WithComponent(app.ComponentStorage).WithCode(logStorageArchiveContentChangedCode)
+)
+
+// archiveSegment is a part of tar archive, either encoded header or padding
+// kept in data, or content of file read from storage
+type archiveSegment struct {
+	offset int64
+	size   int64
+	data   []byte
+	source string
+}
+
+// ArchiveReader streams tar archive of files kept in storage without buffering it in memory.
+// Size of archive is known up front and it's seekable, so it can be served with HTTP ranges
+type ArchiveReader struct {
+	ctx      context.Context
+	archiver Archiver
+	segments []archiveSegment
+	size     int64
+	offset   int64
+
+	// content is an opened file of segment which is currently read at contentOffset
+	content       io.ReadCloser
+	contentOffset int64
+}
+
+func newArchiveReader(ctx context.Context, headers []ArchiveHeader, archiver Archiver) (*ArchiveReader, error) {
+	a := &ArchiveReader{ctx: ctx, archiver: archiver}
+
 	for _, header := range headers {
-		if err := tw.WriteHeader(header.Header); err != nil {
+		var buf bytes.Buffer
+		if err := tar.NewWriter(&buf).WriteHeader(header.Header); err != nil {
 			return nil, exterr.WrapWithFrame(err)
 		}
+		a.appendSegment(archiveSegment{size: int64(buf.Len()), data: buf.Bytes()})
 
-		if header.Header.Typeflag != tar.TypeReg {
+		if header.Header.Typeflag != tar.TypeReg || header.Header.Size == 0 {
 			continue
 		}
 
-		fileContent, err := storage.GetFileContent(ctx, header.ContentPath)
+		a.appendSegment(archiveSegment{size: header.Header.Size, source: header.ContentPath})
+		if padding := (tarBlockSize - header.Header.Size%tarBlockSize) % tarBlockSize; padding > 0 {
+			a.appendSegment(archiveSegment{size: padding, data: zeroBlocks[:padding]})
+		}
+	}
+	a.appendSegment(archiveSegment{size: tarTrailerSize, data: zeroBlocks})
+
+	return a, nil
+}
+
+func (a *ArchiveReader) appendSegment(segment archiveSegment) {
+	segment.offset = a.size
+	a.segments = append(a.segments, segment)
+	a.size += segment.size
+}
+
+// Size returns size of whole archive in bytes
+func (a *ArchiveReader) Size() int64 {
+	return a.size
+}
+
+// Read reads archive from current offset
+func (a *ArchiveReader) Read(p []byte) (int, error) {
+	if a.offset >= a.size {
+		return 0, io.EOF
+	}
+
+	i := sort.Search(len(a.segments), func(i int) bool {
+		return a.segments[i].offset+a.segments[i].size > a.offset
+	})
+	segment := a.segments[i]
+	segmentOffset := a.offset - segment.offset
+	if remaining := segment.size - segmentOffset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	if segment.source == "" {
+		n := copy(p, segment.data[segmentOffset:])
+		a.offset += int64(n)
+		return n, nil
+	}
+
+	if a.content == nil || a.contentOffset != a.offset {
+		a.closeContent()
+		content, err := a.archiver.OpenFileContent(a.ctx, segment.source, segmentOffset)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
+		a.content = content
+		a.contentOffset = a.offset
+	}
 
-		if _, err := tw.Write(fileContent); err != nil {
-			return nil, exterr.WrapWithFrame(err)
+	n, err := a.content.Read(p)
+	a.offset += int64(n)
+	a.contentOffset += int64(n)
+
+	if err == io.EOF {
+		a.closeContent()
+		if a.offset < segment.offset+segment.size {
+			return n, errArchiveContentChanged
 		}
+		err = nil
 	}
+	if err != nil {
+		return n, exterr.WrapWithFrame(err)
+	}
+
+	return n, nil
+}
 
-	if err := tw.Close(); err != nil {
-		return nil, exterr.WrapWithFrame(err)
+// Seek sets offset for next Read
+func (a *ArchiveReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += a.offset
+	case io.SeekEnd:
+		offset += a.size
 	}
+	if offset < 0 {
+		return 0, exterr.WrapWithFrame(errors.New("negative offset"))
+	}
+	a.offset = offset
+
+	return offset, nil
+}
 
-	return buf.Bytes(), nil
+// Close closes currently read file
+func (a *ArchiveReader) Close() error {
+	return a.closeContent()
 }
 
-func extractArchive(ctx context.Context, archivePath string, storage Archiver) error {
+func (a *ArchiveReader) closeContent() error {
+	if a.content == nil {
+		return nil
+	}
+	err := a.content.Close()
+	a.content = nil
+
+	return err
+}
+
+// archiveFormat is a format of uploaded archive recognized by its magic bytes
+type archiveFormat int
+
+const (
+	formatTar archiveFormat = iota
+	formatGzip
+	formatZstd
+	formatZip
+)
+
+var archiveMagicBytes = []struct {
+	format archiveFormat
+	magic  []byte
+}{
+	{format: formatGzip, magic: []byte{0x1f, 0x8b}},
+	{format: formatZstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{format: formatZip, magic: []byte{'P', 'K', 0x03, 0x04}},
+	// empty zip archive consists of end of central directory record only
+	{format: formatZip, magic: []byte{'P', 'K', 0x05, 0x06}},
+}
+
+// sniffArchiveFormat recognizes format of archive without consuming it,
+// archives without known magic bytes are treated as plain tar
+func sniffArchiveFormat(r *bufio.Reader) archiveFormat {
+	for _, m := range archiveMagicBytes {
+		if magic, err := r.Peek(len(m.magic)); err == nil && bytes.Equal(magic, m.magic) {
+			return m.format
+		}
+	}
+
+	return formatTar
+}
+
+// extractArchive extracts plain tar, gzip or zstd compressed tar and zip archive.
+// Entries are checked by extractionGuard before they are passed to storage
+func extractArchive(ctx context.Context, archivePath string, storage Archiver, limits ArchiveLimits) error {
 	a, err := os.Open(archivePath)
 	if err != nil {
 		return exterr.WrapWithFrame(err)
 	}
 	defer a.Close()
-	tr := tar.NewReader(a)
+
+	r := bufio.NewReader(a)
+	switch sniffArchiveFormat(r) {
+	case formatGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+		defer gz.Close()
+
+		return extractTar(ctx, gz, archivePath, storage, limits)
+
+	case formatZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+		defer zr.Close()
+
+		return extractTar(ctx, zr, archivePath, storage, limits)
+
+	case formatZip:
+		return extractZip(ctx, a, archivePath, storage, limits)
+	}
+
+	return extractTar(ctx, r, archivePath, storage, limits)
+}
+
+func extractTar(ctx context.Context, r io.Reader, archivePath string, storage Archiver, limits ArchiveLimits) error {
+	guard := &extractionGuard{limits: limits}
+	tr := tar.NewReader(r)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -66,6 +272,13 @@ func extractArchive(ctx context.Context, archivePath string, storage Archiver) e
 			return exterr.WrapWithFrame(err)
 		}
 
+		skip, err := guard.check(hdr)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
 		if err := storage.SaveArchiveFile(ctx, hdr, tr, archivePath); err != nil {
 			return err
 		}
@@ -73,3 +286,117 @@ func extractArchive(ctx context.Context, archivePath string, storage Archiver) e
 
 	return nil
 }
+
+// extractZip extracts zip archive, its entries are described with tar headers,
+// so storage handles them in the same way as entries of tar archive
+func extractZip(ctx context.Context, a *os.File, archivePath string, storage Archiver, limits ArchiveLimits) error {
+	info, err := a.Stat()
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	zr, err := zip.NewReader(a, info.Size())
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	guard := &extractionGuard{limits: limits}
+	for _, f := range zr.File {
+		if err := extractZipFile(ctx, f, archivePath, storage, guard); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZipFile(ctx context.Context, f *zip.File, archivePath string, storage Archiver, guard *extractionGuard) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     f.Name,
+		Size:     int64(f.UncompressedSize64),
+		Mode:     int64(f.Mode().Perm()),
+		ModTime:  f.Modified,
+	}
+	switch mode := f.Mode(); {
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Size = 0
+	case mode&os.ModeSymlink != 0:
+		hdr.Typeflag = tar.TypeSymlink
+	case !mode.IsRegular():
+		hdr.Typeflag = tar.TypeChar
+	}
+	if _, err := guard.check(hdr); err != nil {
+		return err
+	}
+
+	content, err := f.Open()
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	defer content.Close()
+
+	return storage.SaveArchiveFile(ctx, hdr, content, archivePath)
+}
+
+// extractionGuard rejects entries which could be written outside of extraction
+// directory and enforces limits of extracted content
+type extractionGuard struct {
+	limits ArchiveLimits
+	size   int64
+	files  int
+}
+
+// check checks if entry may be extracted, entries which don't
+// describe content of archive, e.g. PAX global headers, are skipped
+func (g *extractionGuard) check(hdr *tar.Header) (skip bool, err error) {
+	switch hdr.Typeflag {
+	case tar.TypeXGlobalHeader:
+		return true, nil
+	case tar.TypeReg, tar.TypeRegA, tar.TypeDir:
+	case tar.TypeSymlink, tar.TypeLink:
+		return false, archiveEntryError(logStorageArchiveLinkCode, "archive links are not allowed", hdr.Name)
+	default:
+		return false, archiveEntryError(logStorageArchiveEntryTypeCode, "archive entry type is not supported", hdr.Name)
+	}
+
+	if !isEntryPathSafe(hdr.Name) {
+		return false, archiveEntryError(logStorageArchivePathTraversalCode, "archive entry path is outside of archive", hdr.Name)
+	}
+	if hdr.Typeflag == tar.TypeDir {
+		return false, nil
+	}
+
+	g.files++
+	g.size += hdr.Size
+	if g.limits.MaxFileSize > 0 && hdr.Size > g.limits.MaxFileSize {
+		return false, archiveEntryError(logStorageArchiveFileTooLargeCode, fmt.Sprintf("archive file exceeds max size of %d bytes", g.limits.MaxFileSize), hdr.Name)
+	}
+	if g.limits.MaxFileCount > 0 && g.files > g.limits.MaxFileCount {
+		return false, archiveEntryError(logStorageArchiveTooManyFilesCode, fmt.Sprintf("archive exceeds max number of %d files", g.limits.MaxFileCount), hdr.Name)
+	}
+	if g.limits.MaxArchiveSize > 0 && g.size > g.limits.MaxArchiveSize {
+		return false, archiveEntryError(logStorageArchiveTooLargeCode, fmt.Sprintf("archive exceeds max total size of %d bytes", g.limits.MaxArchiveSize), hdr.Name)
+	}
+
+	return false, nil
+}
+
+// isEntryPathSafe checks if entry path is relative and doesn't contain parent directory segments
+func isEntryPathSafe(name string) bool {
+	if name == "" || path.IsAbs(name) {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+func archiveEntryError(code int, msg, name string) error {
+	return exterr.NewErrorWithMessage(fmt.Sprintf("%s: %s", msg, name)).WithComponent(app.ComponentStorage).WithCode(code)
+}
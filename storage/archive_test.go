@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/klauspost/compress/zstd"
+)
+
+type memoryArchiver map[string]string
+
+func (m memoryArchiver) OpenFileContent(ctx context.Context, source string, offset int64) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(m[source][offset:])), nil
+}
+
+func (m memoryArchiver) SaveArchiveFile(ctx context.Context, header *tar.Header, content io.Reader, archivePath string) error {
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	m[header.Name] = string(data)
+
+	return nil
+}
+
+func testArchiveHeaders(files memoryArchiver) []ArchiveHeader {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	headers := []ArchiveHeader{{Header: &tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0755, ModTime: modTime}}}
+	for _, name := range []string{"./saved_model.pb", "./variables/variables.index", "./" + strings.Repeat("long/", 30) + "README.md", "./empty"} {
+		headers = append(headers, ArchiveHeader{
+			Header:      &tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(files[name])), Mode: 0644, ModTime: modTime},
+			ContentPath: name,
+		})
+	}
+
+	return headers
+}
+
+func TestArchiveReader(t *testing.T) {
+	files := memoryArchiver{
+		"./saved_model.pb":                               strings.Repeat("model", 300),
+		"./variables/variables.index":                    "index",
+		"./" + strings.Repeat("long/", 30) + "README.md": strings.Repeat("x", 512),
+		"./empty": "",
+	}
+	headers := testArchiveHeaders(files)
+
+	var want bytes.Buffer
+	tw := tar.NewWriter(&want)
+	for _, header := range headers {
+		if err := tw.WriteHeader(header.Header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[header.ContentPath]))
+	}
+	tw.Close()
+
+	archive, err := newArchiveReader(context.Background(), headers, files)
+	if err != nil {
+		t.Fatalf("newArchiveReader() error = %v", err)
+	}
+	defer archive.Close()
+
+	if archive.Size() != int64(want.Len()) {
+		t.Errorf("Size() = %d, want %d", archive.Size(), want.Len())
+	}
+
+	got, err := ioutil.ReadAll(archive)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("ReadAll() doesn't match archive created by tar.Writer")
+	}
+
+	for _, offset := range []int64{0, 100, 512, 1000, 1536, int64(want.Len()) - 10} {
+		if _, err := archive.Seek(offset, io.SeekStart); err != nil {
+			t.Fatalf("Seek(%d) error = %v", offset, err)
+		}
+		got := make([]byte, 700)
+		n, err := io.ReadFull(archive, got)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatalf("Read() at offset %d error = %v", offset, err)
+		}
+		if !bytes.Equal(got[:n], want.Bytes()[offset:offset+int64(n)]) {
+			t.Errorf("Read() at offset %d doesn't match archive created by tar.Writer", offset)
+		}
+	}
+
+	if end, _ := archive.Seek(0, io.SeekEnd); end != int64(want.Len()) {
+		t.Errorf("Seek(0, io.SeekEnd) = %d, want %d", end, want.Len())
+	}
+}
+
+func TestArchiveReader_contentChanged(t *testing.T) {
+	files := memoryArchiver{"./saved_model.pb": "model"}
+	headers := testArchiveHeaders(files)
+	headers[1].Header.Size = 10
+
+	archive, err := newArchiveReader(context.Background(), headers, files)
+	if err != nil {
+		t.Fatalf("newArchiveReader() error = %v", err)
+	}
+	defer archive.Close()
+
+	if _, err := ioutil.ReadAll(archive); !errors.Is(err, errArchiveContentChanged) {
+		t.Errorf("ReadAll() error = %v, want %v", err, errArchiveContentChanged)
+	}
+}
+
+var extractedFiles = map[string]string{
+	"saved_model.pb":                          "pb",
+	"variables/variables.index":               "index",
+	"variables/variables.data-00000-of-00001": "data",
+	"README.md":                               "readme",
+}
+
+func testTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "variables/", Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"saved_model.pb", "variables/variables.index", "variables/variables.data-00000-of-00001", "README.md"} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(extractedFiles[name])), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(extractedFiles[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive func(t *testing.T, w io.Writer)
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Plain tar",
+			archive: testTar,
+			want:    extractedFiles,
+		},
+		{
+			name: "Gzip compressed tar",
+			archive: func(t *testing.T, w io.Writer) {
+				gw := gzip.NewWriter(w)
+				testTar(t, gw)
+				gw.Close()
+			},
+			want: extractedFiles,
+		},
+		{
+			name: "Zstd compressed tar",
+			archive: func(t *testing.T, w io.Writer) {
+				zw, err := zstd.NewWriter(w)
+				if err != nil {
+					t.Fatal(err)
+				}
+				testTar(t, zw)
+				zw.Close()
+			},
+			want: extractedFiles,
+		},
+		{
+			name: "Zip",
+			archive: func(t *testing.T, w io.Writer) {
+				zw := zip.NewWriter(w)
+				if _, err := zw.Create("variables/"); err != nil {
+					t.Fatal(err)
+				}
+				for name, content := range extractedFiles {
+					f, err := zw.Create(name)
+					if err != nil {
+						t.Fatal(err)
+					}
+					f.Write([]byte(content))
+				}
+				zw.Close()
+			},
+			want: extractedFiles,
+		},
+		{
+			name: "Empty zip",
+			archive: func(t *testing.T, w io.Writer) {
+				zip.NewWriter(w).Close()
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "Corrupted gzip",
+			archive: func(t *testing.T, w io.Writer) {
+				w.Write([]byte{0x1f, 0x8b, 0x00})
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown format",
+			archive: func(t *testing.T, w io.Writer) {
+				w.Write(bytes.Repeat([]byte("not an archive"), 100))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "tfd-archive")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			tt.archive(t, f)
+			f.Close()
+
+			got := memoryArchiver{}
+			if err := extractArchive(context.Background(), f.Name(), got, ArchiveLimits{}); (err != nil) != tt.wantErr {
+				t.Fatalf("extractArchive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(map[string]string(got), tt.want) {
+				t.Errorf("extractArchive() extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractArchive_rejected(t *testing.T) {
+	file := func(name string, size int) *tar.Header {
+		return &tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(size), Mode: 0644}
+	}
+	tarArchive := func(headers ...*tar.Header) func(t *testing.T, w io.Writer) {
+		return func(t *testing.T, w io.Writer) {
+			tw := tar.NewWriter(w)
+			for _, hdr := range headers {
+				if err := tw.WriteHeader(hdr); err != nil {
+					t.Fatal(err)
+				}
+				tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+			}
+			tw.Close()
+		}
+	}
+	zipSymlink := func(t *testing.T, w io.Writer) {
+		zw := zip.NewWriter(w)
+		fh := &zip.FileHeader{Name: "saved_model.pb"}
+		fh.SetMode(os.ModeSymlink | 0777)
+		f, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("/etc/passwd"))
+		zw.Close()
+	}
+	zipTraversal := func(t *testing.T, w io.Writer) {
+		zw := zip.NewWriter(w)
+		f, err := zw.Create("../saved_model.pb")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("pb"))
+		zw.Close()
+	}
+
+	limits := ArchiveLimits{MaxArchiveSize: 100, MaxFileCount: 3, MaxFileSize: 60}
+
+	tests := []struct {
+		name     string
+		archive  func(t *testing.T, w io.Writer)
+		wantCode int
+	}{
+		{name: "Parent directory", archive: tarArchive(file("../saved_model.pb", 2)), wantCode: logStorageArchivePathTraversalCode},
+		{name: "Parent directory inside path", archive: tarArchive(file("variables/../../saved_model.pb", 2)), wantCode: logStorageArchivePathTraversalCode},
+		{name: "Absolute path", archive: tarArchive(file("/etc/cron.d/job", 2)), wantCode: logStorageArchivePathTraversalCode},
+		{name: "Symlink", archive: tarArchive(&tar.Header{Typeflag: tar.TypeSymlink, Name: "saved_model.pb", Linkname: "/etc/passwd"}), wantCode: logStorageArchiveLinkCode},
+		{name: "Hardlink", archive: tarArchive(file("a", 2), &tar.Header{Typeflag: tar.TypeLink, Name: "saved_model.pb", Linkname: "a"}), wantCode: logStorageArchiveLinkCode},
+		{name: "Named pipe", archive: tarArchive(&tar.Header{Typeflag: tar.TypeFifo, Name: "saved_model.pb"}), wantCode: logStorageArchiveEntryTypeCode},
+		{name: "File too large", archive: tarArchive(file("saved_model.pb", 61)), wantCode: logStorageArchiveFileTooLargeCode},
+		{name: "Too many files", archive: tarArchive(file("a", 1), file("b", 1), file("c", 1), file("d", 1)), wantCode: logStorageArchiveTooManyFilesCode},
+		{name: "Archive too large", archive: tarArchive(file("a", 50), file("b", 51)), wantCode: logStorageArchiveTooLargeCode},
+		{name: "Symlink in zip", archive: zipSymlink, wantCode: logStorageArchiveLinkCode},
+		{name: "Parent directory in zip", archive: zipTraversal, wantCode: logStorageArchivePathTraversalCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "tfd-archive")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			tt.archive(t, f)
+			f.Close()
+
+			extracted := memoryArchiver{}
+			err = extractArchive(context.Background(), f.Name(), extracted, limits)
+			var extErr *exterr.Error
+			if !errors.As(err, &extErr) || extErr.Code() != tt.wantCode {
+				t.Fatalf("extractArchive() error = %v, want code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
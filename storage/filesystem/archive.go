@@ -12,7 +12,7 @@ import (
 )
 
 // SaveArchiveFile saves an archive under given archivePath location
-func (fs *FSStorage) SaveArchiveFile(ctx context.Context, header *tar.Header, archive *tar.Reader, archivePath string) error {
+func (fs *FSStorage) SaveArchiveFile(ctx context.Context, header *tar.Header, content io.Reader, archivePath string) error {
 	target := path.Join(path.Dir(archivePath), header.Name)
 
 	switch header.Typeflag {
@@ -26,7 +26,7 @@ func (fs *FSStorage) SaveArchiveFile(ctx context.Context, header *tar.Header, ar
 			return exterr.WrapWithFrame(err)
 		}
 
-		if _, err := io.Copy(f, archive); err != nil {
+		if _, err := io.Copy(f, content); err != nil {
 			return exterr.WrapWithFrame(err)
 		}
 
@@ -3,7 +3,7 @@ package filesystem
 import (
 	"archive/tar"
 	"context"
-	"io/ioutil"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -93,13 +93,19 @@ func NewStorager(storageFilesystemConfig *app.ConfigStorageFilesystem) (*FSStora
 
 }
 
-// GetFileContent returns bytes stream of file located under given source filepath
-func (fs *FSStorage) GetFileContent(ctx context.Context, source string) ([]byte, error) {
-	result, err := ioutil.ReadFile(source)
+// OpenFileContent returns reader of file located under given source filepath starting from given offset
+func (fs *FSStorage) OpenFileContent(ctx context.Context, source string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(source)
 	if err != nil {
 		return nil, exterr.WrapWithFrame(err)
 	}
-	return result, err
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return f, nil
 }
 
 func getArchiveHeaders(ctx context.Context, sourcePath string) ([]storage.ArchiveHeader, error) {
@@ -59,6 +59,62 @@ func (fs *FSStorage) ReadAllModels(ctx context.Context, modelID app.ServableID)
 	return getArchiveHeaders(ctx, sourcePath)
 }
 
+// ListModels lists versions of models kept in <basePath>/<team>/<project>/<name>/<version>
+// directories, entries which don't follow this layout are skipped
+func (fs *FSStorage) ListModels(ctx context.Context) ([]storage.StoredModel, error) {
+	var models []storage.StoredModel
+
+	teams, err := subdirectories(fs.modelConf.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		projects, err := subdirectories(path.Join(fs.modelConf.BasePath, team.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			names, err := subdirectories(path.Join(fs.modelConf.BasePath, team.Name(), project.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				id := app.ServableID{Team: team.Name(), Project: project.Name(), Name: name.Name()}
+				versions, err := subdirectories(path.Join(fs.modelConf.BasePath, id.Team, id.Project, id.Name))
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range versions {
+					version, err := strconv.ParseInt(v.Name(), 10, 64)
+					if err != nil {
+						continue
+					}
+					models = append(models, storage.StoredModel{ServableID: id, Version: version, Modified: v.ModTime()})
+				}
+			}
+		}
+	}
+
+	return models, nil
+}
+
+// subdirectories returns directories located directly in given directory
+func subdirectories(dirPath string) ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	dirs := entries[:0]
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry)
+		}
+	}
+
+	return dirs, nil
+}
+
 func (fs *FSStorage) SaveConfig(ctx context.Context, team, project string, config []byte) error {
 	sourcePath := path.Join(fs.modelConf.BasePath, team, project)
 	if _, err := os.Stat(sourcePath); err != nil {
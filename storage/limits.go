@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+const megabyte = 1 << 20
+
+// ArchiveLimits restricts content of extracted archives, zero disables a limit
+type ArchiveLimits struct {
+	// MaxArchiveSize is max total size of extracted files in bytes
+	MaxArchiveSize int64
+	// MaxFileCount is max number of extracted files
+	MaxFileCount int
+	// MaxFileSize is max size of single extracted file in bytes
+	MaxFileSize int64
+}
+
+// teamLimitsFile is a structure of YAML file with limits of teams, e.g.
+//
+//	teams:
+//	  team-a:
+//	    maxArchiveSizeInMB: 102400
+//	    maxFileCount: 20000
+//
+// limits which aren't set are inherited from defaults
+type teamLimitsFile struct {
+	Teams map[string]struct {
+		MaxArchiveSizeInMB *int64 `yaml:"maxArchiveSizeInMB"`
+		MaxFileCount       *int   `yaml:"maxFileCount"`
+		MaxFileSizeInMB    *int64 `yaml:"maxFileSizeInMB"`
+	} `yaml:"teams"`
+}
+
+// Limits holds default archive limits and limits of teams which override them
+type Limits struct {
+	defaults ArchiveLimits
+	teams    map[string]ArchiveLimits
+}
+
+// NewLimits returns limits applied to archives of every team
+func NewLimits(defaults ArchiveLimits) *Limits {
+	return &Limits{defaults: defaults, teams: make(map[string]ArchiveLimits)}
+}
+
+// WithTeamLimits loads limits of teams from given YAML file
+func (l *Limits) WithTeamLimits(teamLimitsPath string) (*Limits, error) {
+	f, err := os.Open(teamLimitsPath)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer f.Close()
+
+	var file teamLimitsFile
+	if err := yaml.NewDecoder(f).Decode(&file); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	for team, teamLimits := range file.Teams {
+		limits := l.defaults
+		if teamLimits.MaxArchiveSizeInMB != nil {
+			limits.MaxArchiveSize = *teamLimits.MaxArchiveSizeInMB * megabyte
+		}
+		if teamLimits.MaxFileCount != nil {
+			limits.MaxFileCount = *teamLimits.MaxFileCount
+		}
+		if teamLimits.MaxFileSizeInMB != nil {
+			limits.MaxFileSize = *teamLimits.MaxFileSizeInMB * megabyte
+		}
+		l.teams[team] = limits
+	}
+
+	return l, nil
+}
+
+// ForTeam returns limits of archives uploaded by team, archives
+// aren't limited when limits haven't been configured
+func (l *Limits) ForTeam(team string) ArchiveLimits {
+	if l == nil {
+		return ArchiveLimits{}
+	}
+	if limits, ok := l.teams[team]; ok {
+		return limits
+	}
+
+	return l.defaults
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLimits_ForTeam(t *testing.T) {
+	f, err := ioutil.TempFile("", "tfd-limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("teams:\n  team-a:\n    maxArchiveSizeInMB: 2\n    maxFileCount: 0\n")
+	f.Close()
+
+	defaults := ArchiveLimits{MaxArchiveSize: megabyte, MaxFileCount: 10, MaxFileSize: megabyte}
+	limits, err := NewLimits(defaults).WithTeamLimits(f.Name())
+	if err != nil {
+		t.Fatalf("WithTeamLimits() error = %v", err)
+	}
+
+	var unlimited *Limits
+
+	tests := []struct {
+		name   string
+		limits *Limits
+		team   string
+		want   ArchiveLimits
+	}{
+		{name: "Team with own limits inherits limits which aren't set", limits: limits, team: "team-a", want: ArchiveLimits{MaxArchiveSize: 2 * megabyte, MaxFileCount: 0, MaxFileSize: megabyte}},
+		{name: "Team without own limits", limits: limits, team: "team-b", want: defaults},
+		{name: "Limits which haven't been configured", limits: unlimited, team: "team-a", want: ArchiveLimits{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.ForTeam(tt.team); got != tt.want {
+				t.Errorf("ForTeam() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
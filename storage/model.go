@@ -6,9 +6,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
 var (
@@ -34,6 +36,9 @@ type ModelsStorage struct {
 	writer   ModelWriter
 	remover  ModelRemover
 	archiver Archiver
+	limits   *Limits
+
+	validateSavedModel bool
 }
 
 // NewModelsStorage returns new instance of ModelsStorage
@@ -41,11 +46,27 @@ func NewModelsStorage(storageImplementation ModelStorage) *ModelsStorage {
 	return &ModelsStorage{reader: storageImplementation, writer: storageImplementation, remover: storageImplementation, archiver: storageImplementation}
 }
 
+// WithLimits sets limits of archives extracted while saving models
+func (m *ModelsStorage) WithLimits(limits *Limits) *ModelsStorage {
+	m.limits = limits
+
+	return m
+}
+
+// WithSavedModelValidation enables validation of saved_model.pb and variables
+// of models before they are saved
+func (m *ModelsStorage) WithSavedModelValidation() *ModelsStorage {
+	m.validateSavedModel = true
+
+	return m
+}
+
 // ModelReader contains all read operations required by storage
 type ModelReader interface {
 	ReadConfig(ctx context.Context, team, project string) ([]byte, error)
 	ReadModel(ctx context.Context, modelID app.ServableID, version int) ([]ArchiveHeader, error)
 	ReadAllModels(ctx context.Context, modelID app.ServableID) ([]ArchiveHeader, error)
+	ListModels(ctx context.Context) ([]StoredModel, error)
 	DirectoryLayout(path string) ([]string, error)
 }
 
@@ -61,80 +82,147 @@ type ModelRemover interface {
 	RemoveModel(ctx context.Context, id app.ServableID, version int) error
 }
 
-// ReadModel gets archived bytes stream of model for given ServableID and model version
-func (m *ModelsStorage) ReadModel(ctx context.Context, modelID app.ServableID, version int) ([]byte, error) {
+// ReadModel gets archive stream of model for given ServableID and model version
+func (m *ModelsStorage) ReadModel(ctx context.Context, modelID app.ServableID, version int) (_ app.ArchiveContent, err error) {
+	defer metrics.ObserveStorageOperation("read_model", time.Now(), &err)
+
 	headers, err := m.reader.ReadModel(ctx, modelID, version)
 	if err != nil {
 		return nil, err
 	}
 
-	return createArchive(ctx, headers, m.archiver)
+	return newArchiveReader(ctx, headers, m.archiver)
 }
 
-// ReadAllModels  gets archived bytes stream of model for given ServableID
+// ReadAllModels gets archive stream of model for given ServableID
 // inside archive we got all versions of model
-func (m *ModelsStorage) ReadAllModels(ctx context.Context, modelID app.ServableID) ([]byte, error) {
+func (m *ModelsStorage) ReadAllModels(ctx context.Context, modelID app.ServableID) (_ app.ArchiveContent, err error) {
+	defer metrics.ObserveStorageOperation("read_all_models", time.Now(), &err)
+
 	headers, err := m.reader.ReadAllModels(ctx, modelID)
 	if err != nil {
 		return nil, err
 	}
 
-	return createArchive(ctx, headers, m.archiver)
+	return newArchiveReader(ctx, headers, m.archiver)
 }
 
 // ReadConfig gets bytes stream of config file depends on given teamm and project
-func (m *ModelsStorage) ReadConfig(ctx context.Context, team, project string) ([]byte, error) {
+func (m *ModelsStorage) ReadConfig(ctx context.Context, team, project string) (_ []byte, err error) {
+	defer metrics.ObserveStorageOperation("read_config", time.Now(), &err)
+
 	return m.reader.ReadConfig(ctx, team, project)
 }
 
+// StoredModel describes version of model kept in storage
+type StoredModel struct {
+	app.ServableID
+	Version int64
+	// Modified is the latest time when files of version were modified
+	Modified time.Time
+}
+
+// ListModels lists every version of every model kept in storage
+func (m *ModelsStorage) ListModels(ctx context.Context) (_ []StoredModel, err error) {
+	defer metrics.ObserveStorageOperation("list_models", time.Now(), &err)
+
+	return m.reader.ListModels(ctx)
+}
+
 type SaveModelResponse struct {
 	Config []byte
 }
 
+// StagedModel is a model extracted and validated in incoming directory,
+// which hasn't been saved under any version yet
+type StagedModel struct {
+	// Signatures of MetaGraph tagged serve, they are empty when saved model
+	// hasn't been validated and it can't be parsed
+	Signatures app.Signatures
+
+	modelID     app.ServableID
+	archivePath string
+}
+
+// SaveModel stages model from given archive and saves it under given version
 func (m *ModelsStorage) SaveModel(ctx context.Context, modelID app.ServableID, version int, archive io.Reader) (*SaveModelResponse, error) {
-	archiveID, err := m.writer.SaveIncomingModelArchive(modelID, archive)
+	staged, err := m.StageModel(ctx, modelID, archive)
 	if err != nil {
 		return nil, err
 	}
 
-	baseArchiveIDPath, _ := filepath.Split(archiveID)
+	return m.SaveStagedModel(ctx, staged, version)
+}
+
+// StageModel extracts archive into incoming directory and validates extracted model,
+// staging directory is removed when model is invalid
+func (m *ModelsStorage) StageModel(ctx context.Context, modelID app.ServableID, archive io.Reader) (_ *StagedModel, err error) {
+	defer metrics.ObserveStorageOperation("stage_model", time.Now(), &err)
 
-	if err := extractArchive(ctx, archiveID, m.archiver); err != nil {
-		removeAllErr := os.RemoveAll(baseArchiveIDPath)
-		if removeAllErr != nil {
+	archiveID, err := m.writer.SaveIncomingModelArchive(modelID, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := &StagedModel{modelID: modelID, archivePath: archiveID}
+	if err := m.validateStagedModel(ctx, staged); err != nil {
+		if removeAllErr := m.DiscardStagedModel(staged); removeAllErr != nil {
 			return nil, exterr.WrapWithErr(err, removeAllErr)
 		}
 		return nil, err
 	}
 
+	return staged, nil
+}
+
+func (m *ModelsStorage) validateStagedModel(ctx context.Context, staged *StagedModel) error {
+	baseArchiveIDPath, _ := filepath.Split(staged.archivePath)
+
+	if err := extractArchive(ctx, staged.archivePath, m.archiver, m.limits.ForTeam(staged.modelID.Team)); err != nil {
+		return err
+	}
+
 	directoryLayout, err := m.reader.DirectoryLayout(baseArchiveIDPath)
 	if err != nil {
-		removeAllErr := os.RemoveAll(baseArchiveIDPath)
-		if removeAllErr != nil {
-			return nil, exterr.WrapWithErr(err, removeAllErr)
-		}
-		return nil, err
+		return err
 	}
 
 	if !isDirectoryLayoutValid(directoryLayout, directoryLayoutRegexp) {
-		err := exterr.NewErrorWithErr(errInvalidDirectoryLayout).WithComponent(app.ComponentStorage).WithCode(logStorageInvalidDirectoryLayoutModelCode)
-		removeAllErr := os.RemoveAll(baseArchiveIDPath)
-		if removeAllErr != nil {
-			return nil, exterr.WrapWithErr(err, removeAllErr)
+		return exterr.NewErrorWithErr(errInvalidDirectoryLayout).WithComponent(app.ComponentStorage).WithCode(logStorageInvalidDirectoryLayoutModelCode)
+	}
+
+	if !m.validateSavedModel {
+		// signatures are still stored when model can be parsed
+		if model, err := readSavedModel(baseArchiveIDPath); err == nil {
+			if metaGraph := serveMetaGraph(model); metaGraph != nil {
+				staged.Signatures = signaturesOf(metaGraph)
+			}
 		}
-		return nil, err
+		return nil
+	}
+
+	metaGraph, err := validateSavedModel(baseArchiveIDPath)
+	if err != nil {
+		return err
 	}
+	staged.Signatures = signaturesOf(metaGraph)
+
+	return nil
+}
 
-	if err := m.writer.SaveModel(ctx, archiveID, modelID, version); err != nil {
-		removeAllErr := os.RemoveAll(baseArchiveIDPath)
-		if removeAllErr != nil {
+// SaveStagedModel saves staged model under given version
+func (m *ModelsStorage) SaveStagedModel(ctx context.Context, staged *StagedModel, version int) (_ *SaveModelResponse, err error) {
+	defer metrics.ObserveStorageOperation("save_model", time.Now(), &err)
+
+	if err := m.writer.SaveModel(ctx, staged.archivePath, staged.modelID, version); err != nil {
+		if removeAllErr := m.DiscardStagedModel(staged); removeAllErr != nil {
 			return nil, exterr.WrapWithErr(err, removeAllErr)
 		}
 		return nil, err
 	}
 
 	var response SaveModelResponse
-	config, err := m.ReadConfig(ctx, modelID.Team, modelID.Project)
+	config, err := m.ReadConfig(ctx, staged.modelID.Team, staged.modelID.Project)
 	if err != nil && !errors.Is(err, ErrConfigDoesNotExist) {
 		return nil, err
 	}
@@ -144,12 +232,23 @@ func (m *ModelsStorage) SaveModel(ctx context.Context, modelID app.ServableID, v
 	return &response, nil
 }
 
+// DiscardStagedModel removes staging directory of model which won't be saved
+func (m *ModelsStorage) DiscardStagedModel(staged *StagedModel) error {
+	baseArchiveIDPath, _ := filepath.Split(staged.archivePath)
+
+	return os.RemoveAll(baseArchiveIDPath)
+}
+
 // SaveConfig saves given config under valid location based on team and project parammeters
-func (m *ModelsStorage) SaveConfig(ctx context.Context, team, project string, config []byte) error {
+func (m *ModelsStorage) SaveConfig(ctx context.Context, team, project string, config []byte) (err error) {
+	defer metrics.ObserveStorageOperation("save_config", time.Now(), &err)
+
 	return m.writer.SaveConfig(ctx, team, project, config)
 }
 
 // RemoveModel removes a model based on given ServableID and model version
-func (m *ModelsStorage) RemoveModel(ctx context.Context, id app.ServableID, version int64) error {
+func (m *ModelsStorage) RemoveModel(ctx context.Context, id app.ServableID, version int64) (err error) {
+	defer metrics.ObserveStorageOperation("remove_model", time.Now(), &err)
+
 	return m.remover.RemoveModel(ctx, id, int(version))
 }
@@ -5,9 +5,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/grupawp/tensorflow-deploy/app"
 	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/metrics"
 )
 
 var (
@@ -28,6 +30,7 @@ type ModulesStorage struct {
 	reader   ModuleReader
 	writer   ModuleWriter
 	remover  ModuleRemover
+	limits   *Limits
 }
 
 // NewModuleStorage returns new instance of ModulesStorage
@@ -35,20 +38,29 @@ func NewModuleStorage(storageImplementation ModuleStorage) *ModulesStorage {
 	return &ModulesStorage{reader: storageImplementation, writer: storageImplementation, remover: storageImplementation, archiver: storageImplementation}
 }
 
+// WithLimits sets limits of archives extracted while saving modules
+func (m *ModulesStorage) WithLimits(limits *Limits) *ModulesStorage {
+	m.limits = limits
+
+	return m
+}
+
 // ModuleReader contains all read operations required by storage
 type ModuleReader interface {
 	ReadModule(ctx context.Context, moduleID app.ServableID, version int) ([]ArchiveHeader, error)
 	DirectoryLayout(path string) ([]string, error)
 }
 
-// ReadModule gets archived bytes stream of module for given ServableID and module version
-func (m *ModulesStorage) ReadModule(ctx context.Context, moduleID app.ServableID, version int) ([]byte, error) {
+// ReadModule gets archive stream of module for given ServableID and module version
+func (m *ModulesStorage) ReadModule(ctx context.Context, moduleID app.ServableID, version int) (_ app.ArchiveContent, err error) {
+	defer metrics.ObserveStorageOperation("read_module", time.Now(), &err)
+
 	headers, err := m.reader.ReadModule(ctx, moduleID, version)
 	if err != nil {
 		return nil, err
 	}
 
-	return createArchive(ctx, headers, m.archiver)
+	return newArchiveReader(ctx, headers, m.archiver)
 }
 
 // ModuleWriter contains all write operations required by storage
@@ -62,14 +74,16 @@ type ModuleRemover interface {
 	RemoveModule(ctx context.Context, id app.ServableID, version int64) error
 }
 
-func (m *ModulesStorage) SaveModule(ctx context.Context, moduleID app.ServableID, version int, archive io.Reader) error {
+func (m *ModulesStorage) SaveModule(ctx context.Context, moduleID app.ServableID, version int, archive io.Reader) (err error) {
+	defer metrics.ObserveStorageOperation("save_module", time.Now(), &err)
+
 	archiveID, err := m.writer.SaveIncomingModuleArchive(moduleID, archive)
 	if err != nil {
 		return err
 	}
 
 	baseArchiveIDPath, _ := filepath.Split(archiveID)
-	if err := extractArchive(ctx, archiveID, m.archiver); err != nil {
+	if err := extractArchive(ctx, archiveID, m.archiver, m.limits.ForTeam(moduleID.Team)); err != nil {
 		removeAllErr := os.RemoveAll(baseArchiveIDPath)
 		if removeAllErr != nil {
 			return exterr.WrapWithErr(err, removeAllErr)
@@ -107,6 +121,8 @@ func (m *ModulesStorage) SaveModule(ctx context.Context, moduleID app.ServableID
 }
 
 // RemoveModule removes a module based on given ServableID and module version
-func (m *ModulesStorage) RemoveModule(ctx context.Context, id app.ServableID, version int64) error {
+func (m *ModulesStorage) RemoveModule(ctx context.Context, id app.ServableID, version int64) (err error) {
+	defer metrics.ObserveStorageOperation("remove_module", time.Now(), &err)
+
 	return m.remover.RemoveModule(ctx, id, version)
 }
@@ -0,0 +1,326 @@
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+)
+
+const (
+	signAlgorithm    = "AWS4-HMAC-SHA256"
+	signService      = "s3"
+	signTerminator   = "aws4_request"
+	amzDateFormat    = "20060102T150405Z"
+	amzShortFormat   = "20060102"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	headerAmzDate          = "X-Amz-Date"
+	headerAmzContentSHA256 = "X-Amz-Content-Sha256"
+)
+
+var (
+	logS3RequestErrorCode        = 1006
+	logS3ObjectNotFoundErrorCode = 1007
+
+	errObjectNotFound = exterr.NewErrorWithMessage("object not found").WithComponent(app.ComponentStorage).WithCode(logS3ObjectNotFoundErrorCode)
+)
+
+// object describes a single key returned by a list request
+type object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listBucketResult struct {
+	Contents              []object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// client is a minimal S3 REST client signing requests with AWS Signature Version 4
+type client struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyID     string
+	secretAccessKey string
+	pathStyle       bool
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newClient(endpoint, region, bucket, accessKeyID, secretAccessKey string, pathStyle bool) (*client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return &client{
+		endpoint:        u,
+		region:          region,
+		bucket:          bucket,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		pathStyle:       pathStyle,
+		httpClient:      http.DefaultClient,
+		now:             time.Now,
+	}, nil
+}
+
+// objectURL returns URL of given key, respecting path or virtual-hosted style
+func (c *client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	if c.pathStyle {
+		u.Path = "/" + c.bucket + "/" + key
+	} else {
+		u.Host = c.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = ""
+
+	return &u
+}
+
+// putObject uploads body under given key; body must be seekable so payload hash can be calculated
+func (c *client) putObject(ctx context.Context, key string, body io.ReadSeeker, size int64) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, c.objectURL(key).String(), ioutil.NopCloser(body))
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	req.ContentLength = size
+
+	resp, err := c.do(ctx, req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// getObject returns body of object stored under given key starting from given offset; caller has to close it
+func (c *client) getObject(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.do(ctx, req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip leading bytes when server ignored range
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, exterr.WrapWithFrame(err)
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// deleteObject removes object stored under given key
+func (c *client) deleteObject(ctx context.Context, key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.objectURL(key).String(), nil)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	resp, err := c.do(ctx, req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// listObjects returns all objects which keys start with given prefix
+func (c *client) listObjects(ctx context.Context, prefix string) ([]object, error) {
+	var objects []object
+	var continuationToken string
+
+	for {
+		u := c.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+
+		resp, err := c.do(ctx, req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
+// do signs and sends request; non 2xx responses are converted into errors
+func (c *client) do(ctx context.Context, req *http.Request, payloadHash string) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	c.sign(req, payloadHash)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errObjectNotFound
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, exterr.NewErrorWithMessage(fmt.Sprintf("%s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))).
+		WithComponent(app.ComponentStorage).WithCode(logS3RequestErrorCode)
+}
+
+// sign adds AWS Signature Version 4 headers to given request
+func (c *client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+
+	req.Header.Set(headerAmzDate, amzDate)
+	req.Header.Set(headerAmzContentSHA256, payloadHash)
+
+	// anonymous access, e.g. public buckets
+	if c.accessKeyID == "" {
+		return
+	}
+
+	signedHeaders := []string{"host", strings.ToLower(headerAmzContentSHA256), strings.ToLower(headerAmzDate)}
+	canonicalHeaders := fmt.Sprintf("host:%s\n%s:%s\n%s:%s\n", req.URL.Host,
+		strings.ToLower(headerAmzContentSHA256), payloadHash,
+		strings.ToLower(headerAmzDate), amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(amzShortFormat), c.region, signService, signTerminator}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), now.Format(amzShortFormat))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, signTerminator)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, c.accessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalURI encodes every path segment as required by Signature Version 4
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns query sorted by keys with encoded keys and values
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		vals := values[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes everything except unreserved characters (RFC 3986)
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/storage"
+)
+
+var (
+	logS3ModelAlreadyExistsCode = 1008
+	errModelAlreadyExists       = exterr.NewErrorWithMessage("model already exists").WithComponent(app.ComponentStorage).WithCode(logS3ModelAlreadyExistsCode)
+)
+
+func (s *S3Storage) modelKey(id app.ServableID, version int) string {
+	return s.key(modelsKeyPrefix, id.Team, id.Project, id.Name, strconv.Itoa(version))
+}
+
+func (s *S3Storage) ReadModel(ctx context.Context, modelID app.ServableID, version int) ([]storage.ArchiveHeader, error) {
+	return s.archiveHeaders(ctx, s.modelKey(modelID, version))
+}
+
+func (s *S3Storage) ReadAllModels(ctx context.Context, modelID app.ServableID) ([]storage.ArchiveHeader, error) {
+	return s.archiveHeaders(ctx, s.key(modelsKeyPrefix, modelID.Team, modelID.Project, modelID.Name))
+}
+
+// ListModels lists versions of models which have any object stored under
+// <prefix>/models/<team>/<project>/<name>/<version> key prefix
+func (s *S3Storage) ListModels(ctx context.Context) ([]storage.StoredModel, error) {
+	keyPrefix := s.key(modelsKeyPrefix) + "/"
+	objects, err := s.client.listObjects(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []storage.StoredModel
+	indexes := make(map[string]int)
+	for _, o := range objects {
+		// config of project is stored under <team>/<project>/<config>
+		parts := strings.SplitN(strings.TrimPrefix(o.Key, keyPrefix), "/", 5)
+		if len(parts) < 5 {
+			continue
+		}
+		version, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		versionKey := strings.Join(parts[:4], "/")
+		if i, ok := indexes[versionKey]; ok {
+			if o.LastModified.After(models[i].Modified) {
+				models[i].Modified = o.LastModified
+			}
+			continue
+		}
+		indexes[versionKey] = len(models)
+		models = append(models, storage.StoredModel{
+			ServableID: app.ServableID{Team: parts[0], Project: parts[1], Name: parts[2]},
+			Version:    version,
+			Modified:   o.LastModified,
+		})
+	}
+
+	return models, nil
+}
+
+func (s *S3Storage) ReadConfig(ctx context.Context, team, project string) ([]byte, error) {
+	config, err := s.GetFileContent(ctx, s.key(modelsKeyPrefix, team, project, s.configName))
+	if errors.Is(err, errObjectNotFound) {
+		return nil, exterr.WrapWithErr(err, storage.ErrConfigDoesNotExist)
+	}
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return config, nil
+}
+
+func (s *S3Storage) SaveConfig(ctx context.Context, team, project string, config []byte) error {
+	return s.client.putObject(ctx, s.key(modelsKeyPrefix, team, project, s.configName), bytes.NewReader(config), int64(len(config)))
+}
+
+func (s *S3Storage) SaveModel(ctx context.Context, archivePath string, modelID app.ServableID, version int) error {
+	destinationKey := s.modelKey(modelID, version)
+
+	exists, err := s.exists(ctx, destinationKey)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errModelAlreadyExists
+	}
+
+	return s.uploadDirectory(ctx, archivePath, destinationKey)
+}
+
+func (s *S3Storage) SaveIncomingModelArchive(modelID app.ServableID, archive io.Reader) (string, error) {
+	return s.saveIncomingArchive(modelsKeyPrefix, modelID, s.modelArchiveName, archive)
+}
+
+func (s *S3Storage) RemoveModel(ctx context.Context, id app.ServableID, version int) error {
+	return s.removePrefix(ctx, s.modelKey(id, version))
+}
@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/storage"
+)
+
+var (
+	logS3ModuleAlreadyExistsCode = 1009
+	errModuleAlreadyExists       = exterr.NewErrorWithMessage("module already exists").WithComponent(app.ComponentStorage).WithCode(logS3ModuleAlreadyExistsCode)
+)
+
+func (s *S3Storage) moduleKey(id app.ServableID, version int64) string {
+	return s.key(modulesKeyPrefix, id.Team, id.Project, id.Name, strconv.FormatInt(version, 10))
+}
+
+func (s *S3Storage) ReadModule(ctx context.Context, moduleID app.ServableID, version int) ([]storage.ArchiveHeader, error) {
+	return s.archiveHeaders(ctx, s.moduleKey(moduleID, int64(version)))
+}
+
+func (s *S3Storage) SaveModule(ctx context.Context, archivePath string, moduleID app.ServableID, version int) error {
+	destinationKey := s.moduleKey(moduleID, int64(version))
+
+	exists, err := s.exists(ctx, destinationKey)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errModuleAlreadyExists
+	}
+
+	return s.uploadDirectory(ctx, archivePath, destinationKey)
+}
+
+func (s *S3Storage) SaveIncomingModuleArchive(moduleID app.ServableID, archive io.Reader) (string, error) {
+	return s.saveIncomingArchive(modulesKeyPrefix, moduleID, s.moduleArchiveName, archive)
+}
+
+func (s *S3Storage) RemoveModule(ctx context.Context, id app.ServableID, version int64) error {
+	return s.removePrefix(ctx, s.moduleKey(id, version))
+}
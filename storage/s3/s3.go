@@ -0,0 +1,275 @@
+package s3
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/storage"
+)
+
+const (
+	modelsKeyPrefix  = "models"
+	modulesKeyPrefix = "modules"
+
+	stagingDirPerm  os.FileMode = 0755
+	stagingFilePerm os.FileMode = 0644
+	objectFilePerm  int64       = 0644
+	objectDirPerm   int64       = 0755
+)
+
+// S3Storage holds S3-compatible object storage configuration.
+// Incoming archives are extracted and validated in a local staging
+// directory and then uploaded under keys following the layout
+// <prefix>/models/<team>/<project>/<name>/<version>
+type S3Storage struct {
+	client *client
+
+	bucket              string
+	prefix              string
+	incomingArchivePath string
+	modelArchiveName    string
+	moduleArchiveName   string
+	configName          string
+}
+
+// NewStorager returns new instance of S3Storage
+// or error when something goes wrong
+func NewStorager(conf *app.ConfigStorageS3) (*S3Storage, error) {
+	c, err := newClient(*conf.Endpoint, *conf.Region, *conf.Bucket, *conf.AccessKeyID, *conf.SecretAccessKey, *conf.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{modelsKeyPrefix, modulesKeyPrefix} {
+		if err := os.MkdirAll(path.Join(*conf.IncomingArchivePath, dir), stagingDirPerm); err != nil {
+			return nil, exterr.WrapWithFrame(err)
+		}
+	}
+
+	return &S3Storage{
+		client:              c,
+		bucket:              *conf.Bucket,
+		prefix:              strings.Trim(*conf.Prefix, "/"),
+		incomingArchivePath: *conf.IncomingArchivePath,
+		modelArchiveName:    *conf.ModelArchiveName,
+		moduleArchiveName:   *conf.ModuleArchiveName,
+		configName:          *conf.ConfigName,
+	}, nil
+}
+
+// ServingBasePath returns location of models which should be used by TFS instances
+func (s *S3Storage) ServingBasePath() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.key(modelsKeyPrefix))
+}
+
+// GetFileContent returns bytes stream of object stored under given key
+func (s *S3Storage) GetFileContent(ctx context.Context, source string) ([]byte, error) {
+	body, err := s.client.getObject(ctx, source, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return content, nil
+}
+
+// OpenFileContent returns reader of object stored under given key starting from given offset
+func (s *S3Storage) OpenFileContent(ctx context.Context, source string, offset int64) (io.ReadCloser, error) {
+	return s.client.getObject(ctx, source, offset)
+}
+
+// SaveArchiveFile extracts archive entry into the local staging directory of given archivePath
+func (s *S3Storage) SaveArchiveFile(ctx context.Context, header *tar.Header, content io.Reader, archivePath string) error {
+	target := path.Join(path.Dir(archivePath), header.Name)
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), stagingDirPerm); err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE, stagingFilePerm)
+		if err != nil {
+			return exterr.WrapWithFrame(err)
+		}
+
+		if _, err := io.Copy(f, content); err != nil {
+			f.Close()
+			return exterr.WrapWithFrame(err)
+		}
+
+		f.Close()
+	}
+
+	return nil
+}
+
+// DirectoryLayout returns directory structure of the local staging directory
+func (s *S3Storage) DirectoryLayout(path string) ([]string, error) {
+	var directoryLayout []string
+	parentPath := path
+	err := filepath.Walk(path,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			directoryLayout = append(directoryLayout, strings.Replace(path, parentPath, "", 1))
+			return nil
+		})
+
+	if err != nil {
+		return directoryLayout, exterr.WrapWithFrame(err)
+	}
+
+	return directoryLayout, nil
+}
+
+// key joins given elements with configured prefix
+func (s *S3Storage) key(elem ...string) string {
+	return path.Join(append([]string{s.prefix}, elem...)...)
+}
+
+// saveIncomingArchive stores archive in a new staging directory
+func (s *S3Storage) saveIncomingArchive(kind string, id app.ServableID, archiveName string, archive io.Reader) (string, error) {
+	incomingDir := fmt.Sprintf("%s-%s-%s-%d", id.Team, id.Project, id.Name, time.Now().Unix())
+	incomingDir = path.Join(s.incomingArchivePath, kind, incomingDir)
+	if err := os.Mkdir(incomingDir, stagingDirPerm); err != nil {
+		return "", exterr.WrapWithFrame(err)
+	}
+
+	filePath := path.Join(incomingDir, archiveName)
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, stagingFilePerm)
+	if err != nil {
+		return "", exterr.WrapWithFrame(err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, archive); err != nil {
+		return "", exterr.WrapWithFrame(err)
+	}
+
+	return filePath, nil
+}
+
+// uploadDirectory uploads every file of the staging directory containing archivePath
+// except the archive itself; staging directory is removed afterwards
+func (s *S3Storage) uploadDirectory(ctx context.Context, archivePath, destinationKey string) error {
+	stagingDir := filepath.Dir(archivePath)
+	defer os.RemoveAll(stagingDir)
+
+	err := filepath.Walk(stagingDir, func(currentPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || currentPath == archivePath {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(stagingDir, currentPath)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(currentPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return s.client.putObject(ctx, path.Join(destinationKey, filepath.ToSlash(relativePath)), f, info.Size())
+	})
+	if err != nil {
+		// don't leave partially uploaded servable behind
+		if removeErr := s.removePrefix(ctx, destinationKey); removeErr != nil {
+			return exterr.WrapWithErr(err, removeErr)
+		}
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// exists checks if any object is stored under given key prefix
+func (s *S3Storage) exists(ctx context.Context, keyPrefix string) (bool, error) {
+	objects, err := s.client.listObjects(ctx, keyPrefix+"/")
+	if err != nil {
+		return false, err
+	}
+
+	return len(objects) > 0, nil
+}
+
+// removePrefix removes every object stored under given key prefix
+func (s *S3Storage) removePrefix(ctx context.Context, keyPrefix string) error {
+	objects, err := s.client.listObjects(ctx, keyPrefix+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		if err := s.client.deleteObject(ctx, o.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveHeaders returns tar headers of every object stored under given key prefix,
+// directories are synthesized from object keys
+func (s *S3Storage) archiveHeaders(ctx context.Context, keyPrefix string) ([]storage.ArchiveHeader, error) {
+	objects, err := s.client.listObjects(ctx, keyPrefix+"/")
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, errObjectNotFound
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	headers := []storage.ArchiveHeader{{
+		Header: &tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: objectDirPerm, ModTime: objects[0].LastModified},
+	}}
+	dirs := map[string]bool{}
+
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, keyPrefix+"/")
+
+		var missingDirs []string
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			missingDirs = append([]string{dir}, missingDirs...)
+		}
+
+		for _, dir := range missingDirs {
+			headers = append(headers, storage.ArchiveHeader{
+				Header: &tar.Header{Typeflag: tar.TypeDir, Name: "./" + dir + "/", Mode: objectDirPerm, ModTime: o.LastModified},
+			})
+		}
+
+		headers = append(headers, storage.ArchiveHeader{
+			Header:      &tar.Header{Typeflag: tar.TypeReg, Name: "./" + name, Size: o.Size, Mode: objectFilePerm, ModTime: o.LastModified},
+			ContentPath: o.Key,
+		})
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow/core/protobuf"
+)
+
+const (
+	savedModelFileName     = "saved_model.pb"
+	savedModelTextFileName = "saved_model.pbtxt"
+	variablesDirName       = "variables"
+	variablesIndexFileName = "variables.index"
+
+	// serveTag is the tag of MetaGraph loaded by TensorFlow Serving
+	serveTag = "serve"
+	// initOpSignatureKey is the key of SignatureDef added by TensorFlow 2 to run
+	// initialization of model, it can't be used for inference
+	initOpSignatureKey = "__saved_model_init_op"
+)
+
+var (
+	logStorageInvalidSavedModelCode        = 1018
+	logStorageServeMetaGraphMissingCode    = 1019
+	logStorageSignatureDefMissingCode      = 1020
+	logStorageInvalidVariablesIndexCode    = 1021
+	logStorageVariablesShardMismatchCode   = 1022
+	logStorageVariablesEntryOutOfShardCode = 1023
+
+	errServeMetaGraphMissing = exterr.NewErrorWithMessage("saved model doesn't contain MetaGraph tagged " + serveTag).WithComponent(app.ComponentStorage).WithCode(logStorageServeMetaGraphMissingCode)
+	errSignatureDefMissing   = exterr.NewErrorWithMessage("MetaGraph tagged " + serveTag + " doesn't contain any SignatureDef").WithComponent(app.ComponentStorage).WithCode(logStorageSignatureDefMissingCode)
+
+	variablesDataRegexp = regexp.MustCompile(`^variables\.data-([0-9]{5})-of-([0-9]{5})$`)
+)
+
+// savedModel is the SavedModel message of tensorflow/core/protobuf/saved_model.proto
+type savedModel struct {
+	SavedModelSchemaVersion int64                    `protobuf:"varint,1,opt,name=saved_model_schema_version,json=savedModelSchemaVersion,proto3" json:"saved_model_schema_version,omitempty"`
+	MetaGraphs              []*protobuf.MetaGraphDef `protobuf:"bytes,2,rep,name=meta_graphs,json=metaGraphs,proto3" json:"meta_graphs,omitempty"`
+}
+
+func (m *savedModel) Reset()         { *m = savedModel{} }
+func (m *savedModel) String() string { return proto.CompactTextString(m) }
+func (*savedModel) ProtoMessage()    {}
+
+// bundleHeader is the BundleHeaderProto message of tensorflow/core/protobuf/tensor_bundle.proto,
+// it is kept in variables index under empty key
+type bundleHeader struct {
+	NumShards int32 `protobuf:"varint,1,opt,name=num_shards,json=numShards,proto3" json:"num_shards,omitempty"`
+}
+
+func (m *bundleHeader) Reset()         { *m = bundleHeader{} }
+func (m *bundleHeader) String() string { return proto.CompactTextString(m) }
+func (*bundleHeader) ProtoMessage()    {}
+
+// bundleEntry is the BundleEntryProto message of tensorflow/core/protobuf/tensor_bundle.proto,
+// only fields locating tensor in data shards are decoded
+type bundleEntry struct {
+	ShardID int32 `protobuf:"varint,3,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	Offset  int64 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size    int64 `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (m *bundleEntry) Reset()         { *m = bundleEntry{} }
+func (m *bundleEntry) String() string { return proto.CompactTextString(m) }
+func (*bundleEntry) ProtoMessage()    {}
+
+// validateSavedModel checks whether model extracted into dir can be served by TensorFlow
+// Serving and returns MetaGraph tagged serve
+func validateSavedModel(dir string) (*protobuf.MetaGraphDef, error) {
+	model, err := readSavedModel(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	metaGraph := serveMetaGraph(model)
+	if metaGraph == nil {
+		return nil, errServeMetaGraphMissing
+	}
+
+	if len(servingSignatures(metaGraph)) == 0 {
+		return nil, errSignatureDefMissing
+	}
+
+	if err := validateVariables(filepath.Join(dir, variablesDirName)); err != nil {
+		return nil, err
+	}
+
+	return metaGraph, nil
+}
+
+// readSavedModel reads binary saved_model.pb or, if it doesn't exist, saved_model.pbtxt
+func readSavedModel(dir string) (*savedModel, error) {
+	var model savedModel
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, savedModelFileName))
+	if err == nil {
+		if err := proto.Unmarshal(content, &model); err != nil {
+			return nil, savedModelError(logStorageInvalidSavedModelCode, fmt.Sprintf("%s can't be parsed: %v", savedModelFileName, err))
+		}
+		return &model, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, savedModelTextFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, savedModelError(logStorageInvalidSavedModelCode, savedModelFileName+" doesn't exist")
+		}
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if err := proto.UnmarshalText(string(content), &model); err != nil {
+		return nil, savedModelError(logStorageInvalidSavedModelCode, fmt.Sprintf("%s can't be parsed: %v", savedModelTextFileName, err))
+	}
+
+	return &model, nil
+}
+
+// serveMetaGraph returns MetaGraph tagged serve, MetaGraph tagged only serve is preferred
+func serveMetaGraph(model *savedModel) *protobuf.MetaGraphDef {
+	var found *protobuf.MetaGraphDef
+	for _, metaGraph := range model.MetaGraphs {
+		tags := metaGraph.GetMetaInfoDef().GetTags()
+		for _, tag := range tags {
+			if tag != serveTag {
+				continue
+			}
+			if len(tags) == 1 {
+				return metaGraph
+			}
+			if found == nil {
+				found = metaGraph
+			}
+		}
+	}
+
+	return found
+}
+
+// servingSignatures returns signatures of MetaGraph which can be used for inference
+func servingSignatures(metaGraph *protobuf.MetaGraphDef) map[string]*protobuf.SignatureDef {
+	signatures := make(map[string]*protobuf.SignatureDef)
+	for key, signature := range metaGraph.GetSignatureDef() {
+		if key == initOpSignatureKey {
+			continue
+		}
+		signatures[key] = signature
+	}
+
+	return signatures
+}
+
+// signaturesOf returns signatures of MetaGraph which can be used for inference
+func signaturesOf(metaGraph *protobuf.MetaGraphDef) app.Signatures {
+	signatures := make(app.Signatures)
+	for key, signatureDef := range servingSignatures(metaGraph) {
+		signature := app.Signature{
+			MethodName: signatureDef.GetMethodName(),
+			Inputs:     make(map[string]app.TensorInfo),
+			Outputs:    make(map[string]app.TensorInfo),
+		}
+		for name, tensor := range signatureDef.GetInputs() {
+			signature.Inputs[name] = tensorInfoOf(tensor)
+		}
+		for name, tensor := range signatureDef.GetOutputs() {
+			signature.Outputs[name] = tensorInfoOf(tensor)
+		}
+		signatures[key] = signature
+	}
+
+	return signatures
+}
+
+func tensorInfoOf(tensor *protobuf.TensorInfo) app.TensorInfo {
+	info := app.TensorInfo{Name: tensor.GetName(), DType: tensor.GetDtype().String()}
+
+	shape := tensor.GetTensorShape()
+	if shape == nil || shape.GetUnknownRank() {
+		return info
+	}
+
+	info.Shape = make([]int64, 0, len(shape.GetDim()))
+	for _, dim := range shape.GetDim() {
+		info.Shape = append(info.Shape, dim.GetSize())
+	}
+
+	return info
+}
+
+// validateVariables checks that variables index refers only to existing data shards
+// and that every data shard has been provided
+func validateVariables(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	shardSizes := make(map[int]int64)
+	shardsTotal := -1
+	for _, file := range files {
+		match := variablesDataRegexp.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+
+		shard, _ := strconv.Atoi(match[1])
+		total, _ := strconv.Atoi(match[2])
+		if shard >= total {
+			return savedModelError(logStorageVariablesShardMismatchCode, fmt.Sprintf("variables data shard %s is out of range", file.Name()))
+		}
+		if shardsTotal != -1 && total != shardsTotal {
+			return savedModelError(logStorageVariablesShardMismatchCode, fmt.Sprintf("variables data shards are split into %d and %d shards", shardsTotal, total))
+		}
+		shardsTotal = total
+		shardSizes[shard] = file.Size()
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(dir, variablesIndexFileName))
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	var header *bundleHeader
+	err = tableEntries(index, func(key, value []byte) error {
+		// header is kept under empty key, which precedes keys of tensors
+		if len(key) == 0 {
+			header = &bundleHeader{}
+			return proto.Unmarshal(value, header)
+		}
+		if header == nil {
+			return errInvalidTable
+		}
+
+		var entry bundleEntry
+		if err := proto.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+
+		size, ok := shardSizes[int(entry.ShardID)]
+		if !ok || entry.ShardID >= header.NumShards {
+			return savedModelError(logStorageVariablesShardMismatchCode, fmt.Sprintf("variable %q refers to missing data shard %d", key, entry.ShardID))
+		}
+		if entry.Offset < 0 || entry.Size < 0 || entry.Offset > size-entry.Size {
+			return savedModelError(logStorageVariablesEntryOutOfShardCode, fmt.Sprintf("variable %q exceeds size of data shard %d", key, entry.ShardID))
+		}
+
+		return nil
+	})
+	if err != nil {
+		var extErr *exterr.Error
+		if errors.As(err, &extErr) {
+			return err
+		}
+		return savedModelError(logStorageInvalidVariablesIndexCode, fmt.Sprintf("%s can't be parsed: %v", variablesIndexFileName, err))
+	}
+	if header == nil {
+		return savedModelError(logStorageInvalidVariablesIndexCode, variablesIndexFileName+" doesn't contain header")
+	}
+
+	if len(shardSizes) == 0 {
+		return savedModelError(logStorageVariablesShardMismatchCode, "variables data shards haven't been provided")
+	}
+	if int(header.NumShards) != shardsTotal || len(shardSizes) != shardsTotal {
+		return savedModelError(logStorageVariablesShardMismatchCode, fmt.Sprintf("%s refers to %d data shards, %d of %d data shards have been provided", variablesIndexFileName, header.NumShards, len(shardSizes), shardsTotal))
+	}
+
+	return nil
+}
+
+func savedModelError(code int, msg string) error {
+	return exterr.NewErrorWithMessage(msg).WithComponent(app.ComponentStorage).WithCode(code)
+}
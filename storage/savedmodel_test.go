@@ -0,0 +1,464 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/klauspost/compress/snappy"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow/core/framework"
+	"github.com/grupawp/tensorflow-deploy/serving/protobuf/tensorflow/core/protobuf"
+)
+
+type tableEntry struct {
+	key   string
+	value []byte
+}
+
+// testTable returns table in LevelDB table format, keys in data blocks are prefix compressed
+func testTable(t *testing.T, entries []tableEntry, entriesPerBlock int, compression byte) []byte {
+	var table bytes.Buffer
+	putUvarint := func(b []byte, v uint64) []byte {
+		var buf [binary.MaxVarintLen64]byte
+		return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+	}
+	putUint32 := func(b []byte, v uint32) []byte {
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], v)
+		return append(b, buf[:]...)
+	}
+	writeBlock := func(entries []tableEntry) blockHandle {
+		var block []byte
+		var restarts []uint32
+		var previous string
+		for i, entry := range entries {
+			shared := 0
+			if i%2 == 0 {
+				restarts = append(restarts, uint32(len(block)))
+			} else {
+				for shared < len(previous) && shared < len(entry.key) && previous[shared] == entry.key[shared] {
+					shared++
+				}
+			}
+			block = putUvarint(block, uint64(shared))
+			block = putUvarint(block, uint64(len(entry.key)-shared))
+			block = putUvarint(block, uint64(len(entry.value)))
+			block = append(block, entry.key[shared:]...)
+			block = append(block, entry.value...)
+			previous = entry.key
+		}
+		if len(restarts) == 0 {
+			restarts = append(restarts, 0)
+		}
+		for _, restart := range restarts {
+			block = putUint32(block, restart)
+		}
+		block = putUint32(block, uint32(len(restarts)))
+
+		if compression == blockSnappyCompression {
+			block = snappy.Encode(nil, block)
+		}
+		trailer := []byte{compression, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(trailer[1:], maskChecksum(crc32.Checksum(append(block[:len(block):len(block)], compression), crcTable)))
+
+		handle := blockHandle{offset: uint64(table.Len()), size: uint64(len(block))}
+		table.Write(block)
+		table.Write(trailer)
+
+		return handle
+	}
+	encodeHandle := func(handle blockHandle) []byte {
+		return putUvarint(putUvarint(nil, handle.offset), handle.size)
+	}
+
+	var index []tableEntry
+	for i := 0; i < len(entries); i += entriesPerBlock {
+		end := i + entriesPerBlock
+		if end > len(entries) {
+			end = len(entries)
+		}
+		handle := writeBlock(entries[i:end])
+		index = append(index, tableEntry{key: entries[end-1].key, value: encodeHandle(handle)})
+	}
+
+	metaIndexHandle := writeBlock(nil)
+	indexHandle := writeBlock(index)
+
+	footer := make([]byte, tableFooterLength)
+	copy(footer, append(encodeHandle(metaIndexHandle), encodeHandle(indexHandle)...))
+	binary.LittleEndian.PutUint64(footer[tableFooterLength-8:], tableMagic)
+	table.Write(footer)
+
+	return table.Bytes()
+}
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestTableEntries(t *testing.T) {
+	var entries []tableEntry
+	for _, key := range []string{"", "dense/bias", "dense/kernel", "dense_1/bias", "dense_1/kernel", "global_step"} {
+		entries = append(entries, tableEntry{key: key, value: []byte("value of " + key)})
+	}
+
+	tests := []struct {
+		name    string
+		table   func(t *testing.T) []byte
+		want    []tableEntry
+		wantErr bool
+	}{
+		{
+			name:  "Single block",
+			table: func(t *testing.T) []byte { return testTable(t, entries, 10, blockNoCompression) },
+			want:  entries,
+		},
+		{
+			name:  "Many blocks",
+			table: func(t *testing.T) []byte { return testTable(t, entries, 4, blockNoCompression) },
+			want:  entries,
+		},
+		{
+			name:  "Snappy compressed blocks",
+			table: func(t *testing.T) []byte { return testTable(t, entries, 3, blockSnappyCompression) },
+			want:  entries,
+		},
+		{
+			name: "Corrupted block",
+			table: func(t *testing.T) []byte {
+				table := testTable(t, entries, 10, blockNoCompression)
+				table[5] ^= 0xff
+				return table
+			},
+			wantErr: true,
+		},
+		{
+			name: "Truncated table",
+			table: func(t *testing.T) []byte {
+				return testTable(t, entries, 10, blockNoCompression)[20:]
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Not a table",
+			table:   func(t *testing.T) []byte { return bytes.Repeat([]byte("index"), 20) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []tableEntry
+			err := tableEntries(tt.table(t), func(key, value []byte) error {
+				got = append(got, tableEntry{key: string(key), value: append([]byte(nil), value...)})
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tableEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tableEntries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testMetaGraph(tags []string, signatures ...string) *protobuf.MetaGraphDef {
+	metaGraph := &protobuf.MetaGraphDef{
+		MetaInfoDef:  &protobuf.MetaGraphDef_MetaInfoDef{Tags: tags},
+		SignatureDef: make(map[string]*protobuf.SignatureDef),
+	}
+	for _, signature := range signatures {
+		metaGraph.SignatureDef[signature] = &protobuf.SignatureDef{MethodName: "tensorflow/serving/predict"}
+	}
+
+	return metaGraph
+}
+
+// testVariablesIndex returns index of variables kept in shards, each of variables takes 4 bytes
+func testVariablesIndex(t *testing.T, numShards int32, variables map[string]bundleEntry) []byte {
+	entries := []tableEntry{{key: "", value: marshal(t, &bundleHeader{NumShards: numShards})}}
+	for _, name := range []string{"dense/bias", "dense/kernel", "global_step"} {
+		if entry, ok := variables[name]; ok {
+			entries = append(entries, tableEntry{key: name, value: marshal(t, &entry)})
+		}
+	}
+
+	return testTable(t, entries, 2, blockNoCompression)
+}
+
+func TestValidateSavedModel(t *testing.T) {
+	serving := []*protobuf.MetaGraphDef{testMetaGraph([]string{"serve"}, "serving_default")}
+	variables := map[string]bundleEntry{
+		"dense/bias":   {ShardID: 0, Offset: 0, Size: 4},
+		"dense/kernel": {ShardID: 1, Offset: 0, Size: 4},
+		"global_step":  {ShardID: 1, Offset: 4, Size: 4},
+	}
+	shards := map[string]int{"variables.data-00000-of-00002": 4, "variables.data-00001-of-00002": 8}
+
+	tests := []struct {
+		name      string
+		files     func(t *testing.T) map[string][]byte
+		shards    map[string]int
+		wantCode  int
+		wantGraph []string
+	}{
+		{
+			name: "Valid model",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:    shards,
+			wantGraph: []string{"serve"},
+		},
+		{
+			name: "Valid model in text format",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelTextFileName: []byte(proto.MarshalTextString(&savedModel{MetaGraphs: serving})),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:    shards,
+			wantGraph: []string{"serve"},
+		},
+		{
+			name: "MetaGraph tagged only serve is preferred",
+			files: func(t *testing.T) map[string][]byte {
+				model := &savedModel{MetaGraphs: []*protobuf.MetaGraphDef{
+					testMetaGraph([]string{"serve", "gpu"}, "serving_default"),
+					testMetaGraph([]string{"train"}),
+					testMetaGraph([]string{"serve"}, "serving_default"),
+				}}
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, model),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:    shards,
+			wantGraph: []string{"serve"},
+		},
+		{
+			name: "Corrupted saved model",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{savedModelFileName: []byte("\xff\xff\xff")}
+			},
+			wantCode: logStorageInvalidSavedModelCode,
+		},
+		{
+			name: "MetaGraph tagged serve is missing",
+			files: func(t *testing.T) map[string][]byte {
+				model := &savedModel{MetaGraphs: []*protobuf.MetaGraphDef{testMetaGraph([]string{"train"}, "serving_default")}}
+				return map[string][]byte{savedModelFileName: marshal(t, model)}
+			},
+			wantCode: logStorageServeMetaGraphMissingCode,
+		},
+		{
+			name: "Only init op signature",
+			files: func(t *testing.T) map[string][]byte {
+				model := &savedModel{MetaGraphs: []*protobuf.MetaGraphDef{testMetaGraph([]string{"serve"}, initOpSignatureKey)}}
+				return map[string][]byte{savedModelFileName: marshal(t, model)}
+			},
+			wantCode: logStorageSignatureDefMissingCode,
+		},
+		{
+			name: "Corrupted variables index",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: []byte("index"),
+				}
+			},
+			shards:   shards,
+			wantCode: logStorageInvalidVariablesIndexCode,
+		},
+		{
+			name: "Data shard is missing",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:   map[string]int{"variables.data-00000-of-00002": 4},
+			wantCode: logStorageVariablesShardMismatchCode,
+		},
+		{
+			name: "Index refers to less shards than provided",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: testVariablesIndex(t, 1, map[string]bundleEntry{"dense/bias": {Size: 4}}),
+				}
+			},
+			shards:   shards,
+			wantCode: logStorageVariablesShardMismatchCode,
+		},
+		{
+			name: "Data shards of different splits",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:   map[string]int{"variables.data-00000-of-00002": 4, "variables.data-00001-of-00003": 8},
+			wantCode: logStorageVariablesShardMismatchCode,
+		},
+		{
+			name: "Variable exceeds data shard",
+			files: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					savedModelFileName:     marshal(t, &savedModel{MetaGraphs: serving}),
+					variablesIndexFileName: testVariablesIndex(t, 2, variables),
+				}
+			},
+			shards:   map[string]int{"variables.data-00000-of-00002": 4, "variables.data-00001-of-00002": 6},
+			wantCode: logStorageVariablesEntryOutOfShardCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tfd-saved-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := os.Mkdir(filepath.Join(dir, variablesDirName), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files(t) {
+				if name == variablesIndexFileName {
+					name = filepath.Join(variablesDirName, name)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, size := range tt.shards {
+				if err := ioutil.WriteFile(filepath.Join(dir, variablesDirName, name), make([]byte, size), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			metaGraph, err := validateSavedModel(dir)
+			if tt.wantCode != 0 {
+				var extErr *exterr.Error
+				if !errors.As(err, &extErr) || extErr.Code() != tt.wantCode {
+					t.Fatalf("validateSavedModel() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateSavedModel() error = %v", err)
+			}
+			if got := metaGraph.GetMetaInfoDef().GetTags(); !reflect.DeepEqual(got, tt.wantGraph) {
+				t.Errorf("validateSavedModel() returned MetaGraph tagged %v, want %v", got, tt.wantGraph)
+			}
+		})
+	}
+}
+
+func TestSignaturesOf(t *testing.T) {
+	shape := func(dims ...int64) *framework.TensorShapeProto {
+		shape := &framework.TensorShapeProto{}
+		for _, size := range dims {
+			shape.Dim = append(shape.Dim, &framework.TensorShapeProto_Dim{Size: size})
+		}
+		return shape
+	}
+	tensor := func(name string, dtype framework.DataType, shape *framework.TensorShapeProto) *protobuf.TensorInfo {
+		return &protobuf.TensorInfo{Encoding: &protobuf.TensorInfo_Name{Name: name}, Dtype: dtype, TensorShape: shape}
+	}
+
+	metaGraph := &protobuf.MetaGraphDef{
+		SignatureDef: map[string]*protobuf.SignatureDef{
+			initOpSignatureKey: {Outputs: map[string]*protobuf.TensorInfo{initOpSignatureKey: tensor("init_1", framework.DataType_DT_INVALID, nil)}},
+			"serving_default": {
+				MethodName: "tensorflow/serving/predict",
+				Inputs: map[string]*protobuf.TensorInfo{
+					"images": tensor("images:0", framework.DataType_DT_FLOAT, shape(-1, 28, 28)),
+					"scale":  tensor("scale:0", framework.DataType_DT_FLOAT, shape()),
+				},
+				Outputs: map[string]*protobuf.TensorInfo{
+					"classes": tensor("classes:0", framework.DataType_DT_STRING, &framework.TensorShapeProto{UnknownRank: true}),
+				},
+			},
+		},
+	}
+
+	want := app.Signatures{
+		"serving_default": {
+			MethodName: "tensorflow/serving/predict",
+			Inputs: map[string]app.TensorInfo{
+				"images": {Name: "images:0", DType: "DT_FLOAT", Shape: []int64{-1, 28, 28}},
+				"scale":  {Name: "scale:0", DType: "DT_FLOAT", Shape: []int64{}},
+			},
+			Outputs: map[string]app.TensorInfo{
+				"classes": {Name: "classes:0", DType: "DT_STRING"},
+			},
+		},
+	}
+
+	if got := signaturesOf(metaGraph); !reflect.DeepEqual(got, want) {
+		t.Errorf("signaturesOf() = %+v, want %+v", got, want)
+	}
+}
+
+// testdata/saved_model is exported by TensorFlow, it's the example model of demo
+func TestValidateSavedModel_exported(t *testing.T) {
+	var keys []string
+	var header bundleHeader
+	index, err := ioutil.ReadFile(filepath.Join("testdata", "saved_model", "variables", "variables.index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tableEntries(index, func(key, value []byte) error {
+		keys = append(keys, string(key))
+		if len(key) == 0 {
+			return proto.Unmarshal(value, &header)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("tableEntries() error = %v", err)
+	}
+	if want := []string{"", "_CHECKPOINTABLE_OBJECT_GRAPH"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("tableEntries() keys = %q, want %q", keys, want)
+	}
+	if header.NumShards != 1 {
+		t.Errorf("header NumShards = %d, want 1", header.NumShards)
+	}
+
+	metaGraph, err := validateSavedModel(filepath.Join("testdata", "saved_model"))
+	if err != nil {
+		t.Fatalf("validateSavedModel() error = %v", err)
+	}
+	var signatures []string
+	for name := range signaturesOf(metaGraph) {
+		signatures = append(signatures, name)
+	}
+	sort.Strings(signatures)
+	if want := []string{"e", "pi", "serving_default"}; !reflect.DeepEqual(signatures, want) {
+		t.Errorf("signaturesOf() = %q, want %q", signatures, want)
+	}
+}
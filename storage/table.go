@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Tables are immutable, sorted key/value files in LevelDB table format. TensorFlow
+// keeps index of variables (variables/variables.index) in such table, see
+// tensorflow/core/lib/io/format.h for details of the format.
+const (
+	tableMagic         = 0xdb4775248b80fb57
+	tableFooterLength  = 48
+	blockTrailerLength = 5
+
+	blockNoCompression     = 0x0
+	blockSnappyCompression = 0x1
+
+	crcMaskDelta = 0xa282ead8
+)
+
+var (
+	errInvalidTable = errors.New("invalid table")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// blockHandle points to block of table
+type blockHandle struct {
+	offset uint64
+	size   uint64
+}
+
+func decodeBlockHandle(b []byte) (blockHandle, int, error) {
+	offset, n := binary.Uvarint(b)
+	if n <= 0 {
+		return blockHandle{}, 0, errInvalidTable
+	}
+
+	size, m := binary.Uvarint(b[n:])
+	if m <= 0 {
+		return blockHandle{}, 0, errInvalidTable
+	}
+
+	return blockHandle{offset: offset, size: size}, n + m, nil
+}
+
+// tableEntries calls fn for every entry of table in order of keys. Key and value
+// are valid only until fn returns
+func tableEntries(table []byte, fn func(key, value []byte) error) error {
+	if len(table) < tableFooterLength {
+		return errInvalidTable
+	}
+
+	footer := table[len(table)-tableFooterLength:]
+	if binary.LittleEndian.Uint64(footer[tableFooterLength-8:]) != tableMagic {
+		return errInvalidTable
+	}
+
+	// footer starts with handle of metaindex block, which isn't used by TensorFlow
+	_, n, err := decodeBlockHandle(footer)
+	if err != nil {
+		return err
+	}
+	indexHandle, _, err := decodeBlockHandle(footer[n:])
+	if err != nil {
+		return err
+	}
+
+	index, err := readBlock(table, indexHandle)
+	if err != nil {
+		return err
+	}
+
+	return blockEntries(index, func(_, value []byte) error {
+		handle, _, err := decodeBlockHandle(value)
+		if err != nil {
+			return err
+		}
+
+		block, err := readBlock(table, handle)
+		if err != nil {
+			return err
+		}
+
+		return blockEntries(block, fn)
+	})
+}
+
+// readBlock returns verified and decompressed content of block
+func readBlock(table []byte, handle blockHandle) ([]byte, error) {
+	length := uint64(len(table))
+	if handle.offset > length || handle.size > length-handle.offset || length-handle.offset-handle.size < blockTrailerLength {
+		return nil, errInvalidTable
+	}
+
+	block := table[handle.offset : handle.offset+handle.size+blockTrailerLength]
+	content, trailer := block[:handle.size], block[handle.size:]
+
+	// checksum covers content and compression type
+	if maskChecksum(crc32.Checksum(block[:handle.size+1], crcTable)) != binary.LittleEndian.Uint32(trailer[1:]) {
+		return nil, errInvalidTable
+	}
+
+	switch trailer[0] {
+	case blockNoCompression:
+		return content, nil
+	case blockSnappyCompression:
+		decoded, err := snappy.Decode(nil, content)
+		if err != nil {
+			return nil, errInvalidTable
+		}
+		return decoded, nil
+	}
+
+	return nil, errInvalidTable
+}
+
+// blockEntries calls fn for every entry of block. Entries keep only suffix of key
+// which differs from key of previous entry, block ends with offsets of entries
+// which keep full key (restarts) and their number
+func blockEntries(block []byte, fn func(key, value []byte) error) error {
+	if len(block) < 4 {
+		return errInvalidTable
+	}
+
+	restarts := uint64(binary.LittleEndian.Uint32(block[len(block)-4:]))
+	if restarts > uint64(len(block)-4)/4 {
+		return errInvalidTable
+	}
+
+	entries := block[:uint64(len(block)-4)-restarts*4]
+	var key []byte
+	for len(entries) > 0 {
+		var header [3]uint64
+		for i := range header {
+			v, n := binary.Uvarint(entries)
+			if n <= 0 {
+				return errInvalidTable
+			}
+			header[i] = v
+			entries = entries[n:]
+		}
+
+		shared, unshared, valueLength := header[0], header[1], header[2]
+		if shared > uint64(len(key)) || unshared > uint64(len(entries)) || valueLength > uint64(len(entries))-unshared {
+			return errInvalidTable
+		}
+
+		key = append(key[:shared], entries[:unshared]...)
+		value := entries[unshared : unshared+valueLength]
+		if err := fn(key, value); err != nil {
+			return err
+		}
+
+		entries = entries[unshared+valueLength:]
+	}
+
+	return nil
+}
+
+// maskChecksum masks CRC-32C checksum the way table format does it
+func maskChecksum(crc uint32) uint32 {
+	return (crc>>15 | crc<<17) + crcMaskDelta
+}
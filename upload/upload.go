@@ -0,0 +1,457 @@
+// Package upload keeps state of resumable uploads. Content of each upload is
+// appended chunk by chunk to a local file and its SHA-256 is computed
+// incrementally, so completed archive doesn't have to be read again before it's
+// handed over to the service. State is persisted next to the content, so uploads
+// may be resumed after TensorFlow Deploy restarts
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/exterr"
+	"github.com/grupawp/tensorflow-deploy/logging"
+)
+
+const (
+	dirPerm  = 0755
+	filePerm = 0644
+
+	infoExtension = ".info"
+	dataExtension = ".data"
+	idLength      = 16
+)
+
+var (
+	sessionNotFoundErrorCode  = 1001
+	offsetMismatchErrorCode   = 1002
+	sessionBusyErrorCode      = 1003
+	chunkTooLargeErrorCode    = 1004
+	uploadIncompleteErrorCode = 1005
+	invalidLengthErrorCode    = 1006
+	invalidChecksumErrorCode  = 1007
+	checksumMismatchErrorCode = 1008
+	lengthTooLargeErrorCode   = 1009
+
+	// ErrSessionNotFound is returned when upload session doesn't exist, has expired or belongs to other servable
+	ErrSessionNotFound = exterr.NewErrorWithMessage("upload session not found").WithComponent(app.ComponentUpload).WithCode(sessionNotFoundErrorCode)
+	// ErrOffsetMismatch is returned when chunk offset differs from number of bytes already received
+	ErrOffsetMismatch = exterr.NewErrorWithMessage("chunk offset doesn't match upload offset").WithComponent(app.ComponentUpload).WithCode(offsetMismatchErrorCode)
+	// ErrSessionBusy is returned when other request is already writing to upload session
+	ErrSessionBusy = exterr.NewErrorWithMessage("upload session is used by other request").WithComponent(app.ComponentUpload).WithCode(sessionBusyErrorCode)
+	// ErrChunkTooLarge is returned when chunk exceeds declared upload length
+	ErrChunkTooLarge = exterr.NewErrorWithMessage("chunk exceeds upload length").WithComponent(app.ComponentUpload).WithCode(chunkTooLargeErrorCode)
+	// ErrUploadIncomplete is returned when upload is finalized before all bytes have been received
+	ErrUploadIncomplete = exterr.NewErrorWithMessage("upload is incomplete").WithComponent(app.ComponentUpload).WithCode(uploadIncompleteErrorCode)
+	// ErrInvalidLength is returned when declared upload length isn't positive
+	ErrInvalidLength = exterr.NewErrorWithMessage("invalid upload length").WithComponent(app.ComponentUpload).WithCode(invalidLengthErrorCode)
+	// ErrInvalidChecksum is returned when declared checksum isn't hex encoded SHA-256
+	ErrInvalidChecksum = exterr.NewErrorWithMessage("invalid upload checksum, hex encoded sha256 expected").WithComponent(app.ComponentUpload).WithCode(invalidChecksumErrorCode)
+	// ErrChecksumMismatch is returned when SHA-256 of received content differs from declared checksum
+	ErrChecksumMismatch = exterr.NewErrorWithMessage("upload checksum mismatch").WithComponent(app.ComponentUpload).WithCode(checksumMismatchErrorCode)
+	// ErrLengthTooLarge is returned when declared upload length exceeds max length of sessions
+	ErrLengthTooLarge = exterr.NewErrorWithMessage("upload length exceeds max length").WithComponent(app.ComponentUpload).WithCode(lengthTooLargeErrorCode)
+)
+
+// Session describes state of resumable upload
+type Session struct {
+	app.ServableID
+	ID     string `json:"id"`
+	Label  string `json:"label,omitempty"`
+	Length int64  `json:"length"`
+	Offset int64  `json:"offset"`
+	// Checksum is SHA-256 declared by client, it's verified when upload is finalized
+	Checksum string `json:"checksum,omitempty"`
+	// SHA256 is checksum of received content, it's set once upload is complete
+	SHA256  string    `json:"sha256,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// Complete checks if all bytes of upload have been received
+func (s Session) Complete() bool {
+	return s.Offset == s.Length
+}
+
+// state is persisted in info file of session
+type state struct {
+	Session
+	HashState []byte `json:"hash_state"`
+}
+
+// Sessions keeps resumable uploads in local directory
+type Sessions struct {
+	path       string
+	expiration time.Duration
+	// maxLength limits length declared by new uploads, 0 disables the limit
+	maxLength int64
+
+	m    sync.Mutex
+	busy map[string]struct{}
+}
+
+// NewSessions returns sessions kept in path, sessions which haven't
+// received any chunk for expiration are removed
+func NewSessions(ctx context.Context, path string, expiration time.Duration) (*Sessions, error) {
+	if err := os.MkdirAll(path, dirPerm); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	s := &Sessions{path: path, expiration: expiration, busy: make(map[string]struct{})}
+	s.removeExpired(ctx)
+
+	return s, nil
+}
+
+// WithMaxLength limits length in bytes declared by new uploads, so clients
+// can't reserve unbounded disk space
+func (s *Sessions) WithMaxLength(length int64) *Sessions {
+	s.maxLength = length
+
+	return s
+}
+
+// Create starts new upload of length bytes, checksum is optional
+func (s *Sessions) Create(ctx context.Context, id app.ServableID, label string, length int64, checksum string) (*Session, error) {
+	if length <= 0 {
+		return nil, ErrInvalidLength
+	}
+	if s.maxLength > 0 && length > s.maxLength {
+		return nil, ErrLengthTooLarge
+	}
+	checksum = strings.ToLower(checksum)
+	if checksum != "" {
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			return nil, ErrInvalidChecksum
+		}
+	}
+
+	s.removeExpired(ctx)
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	hashState, err := marshalHash(sha256.New())
+	if err != nil {
+		return nil, err
+	}
+
+	st := &state{
+		Session: Session{
+			ServableID: id,
+			ID:         sessionID,
+			Label:      label,
+			Length:     length,
+			Checksum:   checksum,
+			Expires:    time.Now().Add(s.expiration),
+		},
+		HashState: hashState,
+	}
+
+	data, err := os.OpenFile(s.dataPath(sessionID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePerm)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if err := data.Close(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	if err := s.save(st); err != nil {
+		os.Remove(s.dataPath(sessionID))
+		return nil, err
+	}
+
+	return &st.Session, nil
+}
+
+// Get returns upload session of servable
+func (s *Sessions) Get(ctx context.Context, id app.ServableID, sessionID string) (*Session, error) {
+	st, err := s.load(id, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &st.Session, nil
+}
+
+// Write appends chunk starting at offset to upload. Bytes received before chunk is
+// interrupted are kept, returned session holds offset from which upload has to be resumed
+func (s *Sessions) Write(ctx context.Context, id app.ServableID, sessionID string, offset int64, chunk io.Reader) (*Session, error) {
+	if err := s.acquire(sessionID); err != nil {
+		return nil, err
+	}
+	defer s.release(sessionID)
+
+	st, err := s.load(id, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != st.Offset {
+		return &st.Session, ErrOffsetMismatch
+	}
+
+	h, err := unmarshalHash(st.HashState)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.OpenFile(s.dataPath(sessionID), os.O_WRONLY, filePerm)
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	defer data.Close()
+
+	// drop bytes written after state has been saved for the last time,
+	// e.g. when process has been killed in the middle of chunk
+	if err := data.Truncate(st.Offset); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if _, err := data.Seek(st.Offset, io.SeekStart); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	remaining := st.Length - st.Offset
+	written, copyErr := io.Copy(io.MultiWriter(data, h), io.LimitReader(chunk, remaining))
+	if copyErr == nil && written == remaining {
+		var probe [1]byte
+		if n, _ := io.ReadFull(chunk, probe[:]); n > 0 {
+			// state isn't saved, so whole chunk is discarded by next write
+			return &st.Session, ErrChunkTooLarge
+		}
+	}
+
+	if err := data.Sync(); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	st.Offset += written
+	st.Expires = time.Now().Add(s.expiration)
+	if st.HashState, err = marshalHash(h); err != nil {
+		return nil, err
+	}
+	if st.Complete() {
+		st.SHA256 = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	if err := s.save(st); err != nil {
+		return nil, err
+	}
+
+	if copyErr != nil {
+		return &st.Session, exterr.WrapWithFrame(copyErr)
+	}
+
+	return &st.Session, nil
+}
+
+// Open returns content of complete upload after its checksum has been verified
+func (s *Sessions) Open(ctx context.Context, id app.ServableID, sessionID string) (*Session, io.ReadCloser, error) {
+	st, err := s.load(id, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !st.Complete() {
+		return &st.Session, nil, ErrUploadIncomplete
+	}
+	if st.Checksum != "" && st.Checksum != st.SHA256 {
+		return &st.Session, nil, ErrChecksumMismatch
+	}
+
+	data, err := os.Open(s.dataPath(sessionID))
+	if err != nil {
+		return nil, nil, exterr.WrapWithFrame(err)
+	}
+
+	return &st.Session, data, nil
+}
+
+// Remove removes upload session of servable with its content
+func (s *Sessions) Remove(ctx context.Context, id app.ServableID, sessionID string) error {
+	if err := s.acquire(sessionID); err != nil {
+		return err
+	}
+	defer s.release(sessionID)
+
+	if _, err := s.load(id, sessionID); err != nil {
+		return err
+	}
+
+	return s.remove(sessionID)
+}
+
+func (s *Sessions) remove(sessionID string) error {
+	if err := os.Remove(s.infoPath(sessionID)); err != nil && !os.IsNotExist(err) {
+		return exterr.WrapWithFrame(err)
+	}
+	if err := os.Remove(s.dataPath(sessionID)); err != nil && !os.IsNotExist(err) {
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+// removeExpired removes expired sessions and content files left without state
+func (s *Sessions) removeExpired(ctx context.Context) {
+	files, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		logging.ErrorWithStack(ctx, exterr.WrapWithFrame(err))
+		return
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		sessionID := strings.TrimSuffix(file.Name(), ext)
+		if !validSessionID(sessionID) {
+			continue
+		}
+
+		expired := false
+		switch ext {
+		case infoExtension:
+			st, err := s.read(sessionID)
+			expired = err != nil || now.After(st.Expires)
+		case dataExtension:
+			_, err := os.Stat(s.infoPath(sessionID))
+			expired = os.IsNotExist(err) && now.Sub(file.ModTime()) > s.expiration
+		default:
+			continue
+		}
+		if !expired || s.acquire(sessionID) != nil {
+			continue
+		}
+
+		if err := s.remove(sessionID); err != nil {
+			logging.ErrorWithStack(ctx, err)
+		}
+		s.release(sessionID)
+	}
+}
+
+// load reads state of session and checks if it belongs to servable
+func (s *Sessions) load(id app.ServableID, sessionID string) (*state, error) {
+	if !validSessionID(sessionID) {
+		return nil, ErrSessionNotFound
+	}
+
+	st, err := s.read(sessionID)
+	if os.IsNotExist(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+	if st.ServableID != id || time.Now().After(st.Expires) {
+		return nil, ErrSessionNotFound
+	}
+
+	return st, nil
+}
+
+func (s *Sessions) read(sessionID string) (*state, error) {
+	content, err := ioutil.ReadFile(s.infoPath(sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(content, &st); err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+// save replaces state of session atomically
+func (s *Sessions) save(st *state) error {
+	content, err := json.Marshal(st)
+	if err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+
+	tmp := s.infoPath(st.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, filePerm); err != nil {
+		return exterr.WrapWithFrame(err)
+	}
+	if err := os.Rename(tmp, s.infoPath(st.ID)); err != nil {
+		os.Remove(tmp)
+		return exterr.WrapWithFrame(err)
+	}
+
+	return nil
+}
+
+func (s *Sessions) acquire(sessionID string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.busy[sessionID]; ok {
+		return ErrSessionBusy
+	}
+	s.busy[sessionID] = struct{}{}
+
+	return nil
+}
+
+func (s *Sessions) release(sessionID string) {
+	s.m.Lock()
+	delete(s.busy, sessionID)
+	s.m.Unlock()
+}
+
+func (s *Sessions) infoPath(sessionID string) string {
+	return filepath.Join(s.path, sessionID+infoExtension)
+}
+
+func (s *Sessions) dataPath(sessionID string) string {
+	return filepath.Join(s.path, sessionID+dataExtension)
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, idLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// validSessionID checks if sessionID has been generated by newSessionID,
+// so it can be safely used in file paths
+func validSessionID(sessionID string) bool {
+	decoded, err := hex.DecodeString(sessionID)
+
+	return err == nil && len(decoded) == idLength && sessionID == strings.ToLower(sessionID)
+}
+
+func marshalHash(h hash.Hash) ([]byte, error) {
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return state, nil
+}
+
+func unmarshalHash(state []byte) (hash.Hash, error) {
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, exterr.WrapWithFrame(err)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,259 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grupawp/tensorflow-deploy/app"
+	"github.com/grupawp/tensorflow-deploy/internal/testutil"
+)
+
+var (
+	testServable = app.ServableID{Team: "team", Project: "project", Name: "name"}
+	testContent  = []byte("The sums are computed as described in FIPS-180-4")
+)
+
+type chunk struct {
+	offset  int64
+	content io.Reader
+	wantErr error
+}
+
+// interruptedReader returns error after content has been read, like body of dropped connection
+type interruptedReader struct {
+	content io.Reader
+}
+
+var errInterrupted = errors.New("connection reset")
+
+func (r interruptedReader) Read(p []byte) (int, error) {
+	n, err := r.content.Read(p)
+	if err == io.EOF {
+		return n, errInterrupted
+	}
+
+	return n, err
+}
+
+func newTestSessions(t *testing.T) *Sessions {
+	dir := testutil.TempDir(t)
+
+	s, err := NewSessions(context.Background(), dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSessions() error = %v", err)
+	}
+
+	return s
+}
+
+func TestSessions_Write(t *testing.T) {
+	checksum := fmt.Sprintf("%x", sha256.Sum256(testContent))
+
+	tests := []struct {
+		name       string
+		checksum   string
+		chunks     []chunk
+		wantOffset int64
+		wantOpen   error
+	}{
+		{
+			name:       "Upload in single chunk",
+			checksum:   checksum,
+			chunks:     []chunk{{offset: 0, content: bytes.NewReader(testContent)}},
+			wantOffset: int64(len(testContent)),
+		},
+		{
+			name: "Upload in chunks",
+			chunks: []chunk{
+				{offset: 0, content: bytes.NewReader(testContent[:10])},
+				{offset: 10, content: bytes.NewReader(testContent[10:20])},
+				{offset: 20, content: bytes.NewReader(testContent[20:])},
+			},
+			wantOffset: int64(len(testContent)),
+		},
+		{
+			name:     "Interrupted chunk should keep received bytes",
+			checksum: checksum,
+			chunks: []chunk{
+				{offset: 0, content: interruptedReader{bytes.NewReader(testContent[:10])}, wantErr: errInterrupted},
+				{offset: 10, content: bytes.NewReader(testContent[10:])},
+			},
+			wantOffset: int64(len(testContent)),
+		},
+		{
+			name: "Chunk with invalid offset should be rejected",
+			chunks: []chunk{
+				{offset: 0, content: bytes.NewReader(testContent[:10])},
+				{offset: 5, content: bytes.NewReader(testContent[5:]), wantErr: ErrOffsetMismatch},
+			},
+			wantOffset: 10,
+			wantOpen:   ErrUploadIncomplete,
+		},
+		{
+			name: "Chunk exceeding upload length should be discarded",
+			chunks: []chunk{
+				{offset: 0, content: bytes.NewReader(testContent[:10])},
+				{offset: 10, content: bytes.NewReader(append(testContent[10:], '!')), wantErr: ErrChunkTooLarge},
+			},
+			wantOffset: 10,
+			wantOpen:   ErrUploadIncomplete,
+		},
+		{
+			name:       "Upload with different checksum should be rejected",
+			checksum:   fmt.Sprintf("%x", sha256.Sum256([]byte("other"))),
+			chunks:     []chunk{{offset: 0, content: bytes.NewReader(testContent)}},
+			wantOffset: int64(len(testContent)),
+			wantOpen:   ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestSessions(t)
+
+			session, err := s.Create(ctx, testServable, "", int64(len(testContent)), tt.checksum)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			for _, c := range tt.chunks {
+				if _, err := s.Write(ctx, testServable, session.ID, c.offset, c.content); !errors.Is(err, c.wantErr) {
+					t.Fatalf("Write() at offset %d error = %v, wantErr %v", c.offset, err, c.wantErr)
+				}
+			}
+
+			// state is read again, as after restart
+			session, err = restartSessions(t, s).Get(ctx, testServable, session.ID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if session.Offset != tt.wantOffset {
+				t.Errorf("Offset = %d, want %d", session.Offset, tt.wantOffset)
+			}
+
+			_, content, err := s.Open(ctx, testServable, session.ID)
+			if !errors.Is(err, tt.wantOpen) {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantOpen)
+			}
+			if err != nil {
+				return
+			}
+			defer content.Close()
+
+			got, err := ioutil.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, testContent) {
+				t.Errorf("content = %q, want %q", got, testContent)
+			}
+			if session.SHA256 != checksum {
+				t.Errorf("SHA256 = %s, want %s", session.SHA256, checksum)
+			}
+		})
+	}
+}
+
+// restartSessions returns new sessions kept in the same directory as s
+func restartSessions(t *testing.T, s *Sessions) *Sessions {
+	restarted, err := NewSessions(context.Background(), s.path, s.expiration)
+	if err != nil {
+		t.Fatalf("NewSessions() error = %v", err)
+	}
+
+	return restarted
+}
+
+func TestSessions_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int64
+		checksum string
+		wantErr  error
+	}{
+		{name: "Valid upload", length: 1, checksum: fmt.Sprintf("%X", sha256.Sum256(testContent))},
+		{name: "Upload without length", length: 0, wantErr: ErrInvalidLength},
+		{name: "Upload with invalid checksum", length: 1, checksum: "5d403c76", wantErr: ErrInvalidChecksum},
+		{name: "Upload exceeding max length", length: 1025, wantErr: ErrLengthTooLarge},
+		{name: "Upload of max length", length: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestSessions(t).WithMaxLength(1024).Create(context.Background(), testServable, "", tt.length, tt.checksum); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessions_Get(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSessions(t)
+
+	session, err := s.Create(ctx, testServable, "canary", 10, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		servable  app.ServableID
+		sessionID string
+		wantErr   error
+	}{
+		{name: "Session of servable", servable: testServable, sessionID: session.ID},
+		{name: "Session of other servable", servable: app.ServableID{Team: "other", Project: "project", Name: "name"}, sessionID: session.ID, wantErr: ErrSessionNotFound},
+		{name: "Unknown session", servable: testServable, sessionID: "00000000000000000000000000000000", wantErr: ErrSessionNotFound},
+		{name: "Session ID outside of sessions directory", servable: testServable, sessionID: "../" + session.ID, wantErr: ErrSessionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Get(ctx, tt.servable, tt.sessionID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessions_removeExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSessions(t)
+
+	expired, err := s.Create(ctx, testServable, "", 10, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	st, err := s.read(expired.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Expires = time.Now().Add(-time.Second)
+	if err := s.save(st); err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := s.Create(ctx, testServable, "", 10, "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	s.removeExpired(ctx)
+
+	for _, path := range []string{s.infoPath(expired.ID), s.dataPath(expired.ID)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s of expired session should be removed", filepath.Base(path))
+		}
+	}
+	if _, err := s.Get(ctx, testServable, active.ID); err != nil {
+		t.Errorf("Get() of active session error = %v", err)
+	}
+}